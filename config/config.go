@@ -29,6 +29,12 @@ type HTTPConfig struct {
 	WriteTimeout      time.Duration `mapstructure:"write_timeout"`
 	IdleTimeout       time.Duration `mapstructure:"idle_timeout"`
 	ShutdownTimeout   time.Duration `mapstructure:"shutdown_timeout"`
+
+	// PreStopDelay is how long the server keeps serving after a shutdown
+	// signal while reporting itself as not ready. This gives load balancers
+	// (e.g., Kubernetes endpoints) time to stop routing new traffic before
+	// the listener is closed. Zero disables the delay.
+	PreStopDelay time.Duration `mapstructure:"pre_stop_delay"`
 }
 
 // TLSConfig groups all TLS / ACME-related settings.
@@ -253,6 +259,7 @@ func LoadWithAppConfig(logger *zap.Logger, appEnvPrefix string, appKeys []AppKey
 	pflag.String("write_timeout", "60s", "HTTP server write timeout (e.g., \"60s\", \"2m\")")
 	pflag.String("idle_timeout", "120s", "HTTP server idle timeout (e.g., \"120s\", \"2m\")")
 	pflag.String("shutdown_timeout", "15s", "Graceful shutdown timeout (e.g., \"15s\", \"30s\")")
+	pflag.String("pre_stop_delay", "0s", "Delay between failing readiness and closing the listener on shutdown (e.g., \"5s\"; 0 disables)")
 
 	// misc / CORS
 	pflag.Bool("enable_compression", true, "Enable HTTP compression")
@@ -366,6 +373,7 @@ func LoadWithAppConfig(logger *zap.Logger, appEnvPrefix string, appKeys []AppKey
 	cfg.HTTP.WriteTimeout = parseDurationWithDefault(logger, v, "write_timeout", 60*time.Second)
 	cfg.HTTP.IdleTimeout = parseDurationWithDefault(logger, v, "idle_timeout", 120*time.Second)
 	cfg.HTTP.ShutdownTimeout = parseDurationWithDefault(logger, v, "shutdown_timeout", 15*time.Second)
+	cfg.HTTP.PreStopDelay = parseOptionalDuration(logger, v, "pre_stop_delay")
 
 	// Set ACME directory URL default based on environment if not explicitly configured
 	if cfg.TLS.UseLetsEncrypt && cfg.TLS.ACMEDirectoryURL == "" {
@@ -400,6 +408,7 @@ func allKeys() []string {
 		"env", "log_level",
 		"http_port", "https_port", "use_https",
 		"read_timeout", "read_header_timeout", "write_timeout", "idle_timeout", "shutdown_timeout",
+		"pre_stop_delay",
 		"use_lets_encrypt", "lets_encrypt_email", "lets_encrypt_cache_dir",
 		"cert_file", "key_file", "domain", "domains",
 		"lets_encrypt_challenge", "route53_hosted_zone_id", "acme_directory_url",
@@ -430,6 +439,7 @@ func setDefaults(v *viper.Viper) {
 	v.SetDefault("write_timeout", "60s")
 	v.SetDefault("idle_timeout", "120s")
 	v.SetDefault("shutdown_timeout", "15s")
+	v.SetDefault("pre_stop_delay", "0s")
 
 	v.SetDefault("use_lets_encrypt", false)
 	v.SetDefault("lets_encrypt_email", "")
@@ -107,3 +107,43 @@ func parseDurationWithDefault(logger *zap.Logger, v *viper.Viper, key string, de
 	}
 	return dur
 }
+
+// parseOptionalDuration parses a duration from viper where zero is a valid
+// value meaning "disabled" (e.g., "0", "0s"). Invalid or negative values log
+// a warning and fall back to zero.
+func parseOptionalDuration(logger *zap.Logger, v *viper.Viper, key string) time.Duration {
+	if isZeroDuration(v.Get(key)) {
+		return 0
+	}
+	return parseDurationWithDefault(logger, v, key, 0)
+}
+
+// isZeroDuration reports whether raw represents an explicit zero or empty duration.
+func isZeroDuration(raw interface{}) bool {
+	switch t := raw.(type) {
+	case nil:
+		return true
+	case time.Duration:
+		return t == 0
+	case string:
+		s := strings.TrimSpace(t)
+		if s == "" {
+			return true
+		}
+		if d, err := time.ParseDuration(s); err == nil {
+			return d == 0
+		}
+		n, err := strconv.ParseFloat(s, 64)
+		return err == nil && n == 0
+	case int:
+		return t == 0
+	case int32:
+		return t == 0
+	case int64:
+		return t == 0
+	case float64:
+		return t == 0
+	default:
+		return false
+	}
+}
@@ -33,6 +33,11 @@ func Recoverer(logger *zap.Logger) func(next http.Handler) http.Handler {
 
 			defer func() {
 				if rec := recover(); rec != nil {
+					// http.ErrAbortHandler deliberately aborts the response;
+					// let net/http handle it so the connection is dropped.
+					if rec == http.ErrAbortHandler {
+						panic(rec)
+					}
 					logger.Error("panic recovered",
 						zap.Any("panic_value", rec),
 						zap.ByteString("stacktrace", debug.Stack()),
@@ -45,6 +45,15 @@ func RegisterDefault(logger *zap.Logger) {
 	mustRegister(logger, "HTTP request histogram", reqDuration)
 }
 
+// MustRegister registers an additional collector with the default Prometheus
+// registry using the same rules as RegisterDefault: re-registering the same
+// collector is a no-op, any other failure is fatal. Waffle packages that
+// expose their own metrics (e.g., server drain state) use this so all
+// collectors share one registration policy.
+func MustRegister(logger *zap.Logger, name string, c prometheus.Collector) {
+	mustRegister(logger, name, c)
+}
+
 // mustRegister attempts to register a Prometheus collector. If registration
 // fails for a reason other than AlreadyRegisteredError, it logs a fatal error
 // (which calls os.Exit) or panics if no logger is provided.
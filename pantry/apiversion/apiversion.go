@@ -0,0 +1,125 @@
+// apiversion/apiversion.go
+package apiversion
+
+import (
+	"context"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// contextKey is the type for context keys in this package.
+type contextKey struct{}
+
+// FromContext returns the API version resolved for the request, or "" if
+// the request did not pass through a versioned Router.
+func FromContext(ctx context.Context) string {
+	v, _ := ctx.Value(contextKey{}).(string)
+	return v
+}
+
+// WithVersion returns a copy of ctx carrying version.
+func WithVersion(ctx context.Context, version string) context.Context {
+	return context.WithValue(ctx, contextKey{}, version)
+}
+
+// Source identifies where a version was resolved from.
+type Source string
+
+const (
+	SourcePath    Source = "path"
+	SourceAccept  Source = "accept"
+	SourceHeader  Source = "header"
+	SourceDefault Source = "default"
+)
+
+// Config configures how versions are resolved.
+type Config struct {
+	// Default is the version used when the request doesn't specify one.
+	// Required.
+	Default string
+
+	// DisablePathPrefix turns off resolving the version from a leading
+	// path segment such as "/v2/users". When enabled (the default), the
+	// segment is stripped before routing.
+	DisablePathPrefix bool
+
+	// MediaTypeParam is the Accept media-type parameter carrying the
+	// version, e.g. "version" for "application/json; version=2".
+	// Set to "-" to disable. Default: "version".
+	MediaTypeParam string
+
+	// Header is a custom request header carrying the version, e.g.
+	// "X-API-Version". Empty disables header resolution.
+	Header string
+
+	// ResponseHeader, if set, echoes the resolved version on every response,
+	// e.g. "X-API-Version".
+	ResponseHeader string
+}
+
+// resolve determines the requested version and, for path-based versions,
+// the remaining path after the version segment. Resolution order is path
+// prefix, Accept media-type parameter, custom header, then Default.
+func (c Config) resolve(r *http.Request, path string) (version string, rest string, src Source) {
+	if !c.DisablePathPrefix {
+		if v, tail, ok := splitVersionSegment(path); ok {
+			return v, tail, SourcePath
+		}
+	}
+
+	param := c.MediaTypeParam
+	if param == "" {
+		param = "version"
+	}
+	if param != "-" {
+		if v := versionFromAccept(r.Header.Values("Accept"), param); v != "" {
+			return normalize(v), path, SourceAccept
+		}
+	}
+
+	if c.Header != "" {
+		if v := strings.TrimSpace(r.Header.Get(c.Header)); v != "" {
+			return normalize(v), path, SourceHeader
+		}
+	}
+
+	return c.Default, path, SourceDefault
+}
+
+// splitVersionSegment splits "/v2/users" into ("2", "/users"). The segment
+// must be "v" followed by a digit.
+func splitVersionSegment(path string) (string, string, bool) {
+	p := strings.TrimPrefix(path, "/")
+	seg, tail, _ := strings.Cut(p, "/")
+	if len(seg) < 2 || (seg[0] != 'v' && seg[0] != 'V') || seg[1] < '0' || seg[1] > '9' {
+		return "", path, false
+	}
+	return seg[1:], "/" + tail, true
+}
+
+// versionFromAccept returns the value of param from the first Accept media
+// range that carries it.
+func versionFromAccept(values []string, param string) string {
+	for _, value := range values {
+		for _, part := range strings.Split(value, ",") {
+			_, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+			if err != nil {
+				continue
+			}
+			if v := params[param]; v != "" {
+				return v
+			}
+		}
+	}
+	return ""
+}
+
+// normalize strips a leading "v" so "v2" and "2" resolve to the same version.
+func normalize(v string) string {
+	v = strings.TrimSpace(v)
+	if len(v) > 1 && (v[0] == 'v' || v[0] == 'V') {
+		return v[1:]
+	}
+	return v
+}
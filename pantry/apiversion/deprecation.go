@@ -0,0 +1,100 @@
+// apiversion/deprecation.go
+package apiversion
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/dalemusser/waffle/metrics"
+	"github.com/go-chi/chi/v5"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Deprecation describes a deprecated version or route.
+type Deprecation struct {
+	// Date is when the version or route was deprecated. Sent in the
+	// Deprecation header (RFC 9745) as "@<unix seconds>". If zero, the
+	// header is sent as "true".
+	Date time.Time
+
+	// Sunset is when the version or route will stop working. Sent in the
+	// Sunset header (RFC 8594). Optional.
+	Sunset time.Time
+
+	// Link points to migration documentation. Sent as
+	// Link: <url>; rel="deprecation". Optional.
+	Link string
+}
+
+// apply writes the deprecation headers to h.
+func (d Deprecation) apply(h http.Header) {
+	if d.Date.IsZero() {
+		h.Set("Deprecation", "true")
+	} else {
+		h.Set("Deprecation", "@"+strconv.FormatInt(d.Date.Unix(), 10))
+	}
+	if !d.Sunset.IsZero() {
+		h.Set("Sunset", d.Sunset.UTC().Format(http.TimeFormat))
+	}
+	if d.Link != "" {
+		h.Add("Link", "<"+d.Link+`>; rel="deprecation"; type="text/html"`)
+	}
+}
+
+var (
+	versionRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "api_version_requests_total",
+		Help: "API requests by resolved version and source (path, accept, header, default).",
+	}, []string{"version", "source"})
+	deprecatedRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "api_deprecated_requests_total",
+		Help: "Requests served by deprecated API versions or routes.",
+	}, []string{"version", "route"})
+)
+
+var registerOnce sync.Once
+
+func registerMetrics() {
+	registerOnce.Do(func() {
+		metrics.MustRegister(nil, "API version requests counter", versionRequests)
+		metrics.MustRegister(nil, "API deprecated requests counter", deprecatedRequests)
+	})
+}
+
+// Deprecate returns middleware that marks a route as deprecated. Use it with
+// chi's With for individual routes:
+//
+//	r.With(apiversion.Deprecate(apiversion.Deprecation{
+//	    Sunset: time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC),
+//	    Link:   "https://docs.example.com/migrate/orders",
+//	})).Get("/orders/legacy", legacyOrders)
+func Deprecate(d Deprecation) func(http.Handler) http.Handler {
+	registerMetrics()
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			d.apply(w.Header())
+			next.ServeHTTP(w, r)
+			countDeprecated(r)
+		})
+	}
+}
+
+// countDeprecated records a deprecated request. It runs after the handler so
+// the chi route pattern is complete. Requests without a pattern are counted
+// as "unmatched" rather than by path, which would give every ID its own
+// label.
+func countDeprecated(r *http.Request) {
+	route := "unmatched"
+	if rctx := chi.RouteContext(r.Context()); rctx != nil {
+		if pattern := rctx.RoutePattern(); pattern != "" {
+			route = pattern
+		}
+	}
+	version := FromContext(r.Context())
+	if version == "" {
+		version = "none"
+	}
+	deprecatedRequests.WithLabelValues(version, route).Inc()
+}
@@ -0,0 +1,147 @@
+// apiversion/router.go
+package apiversion
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/dalemusser/waffle/httputil"
+	"github.com/go-chi/chi/v5"
+)
+
+// Router dispatches requests to per-version chi routers. Mount it like any
+// other handler:
+//
+//	vr := apiversion.NewRouter(apiversion.Config{Default: "2", Header: "X-API-Version"})
+//	vr.Version("1", func(r chi.Router) {
+//	    r.Get("/users", listUsersV1)
+//	}, apiversion.Deprecated(apiversion.Deprecation{
+//	    Sunset: time.Date(2026, 12, 31, 0, 0, 0, 0, time.UTC),
+//	    Link:   "https://docs.example.com/api/v2-migration",
+//	}))
+//	vr.Version("2", func(r chi.Router) {
+//	    r.Get("/users", listUsersV2)
+//	})
+//	r.Mount("/api", vr)
+//
+// Requests to /api/v1/users, or to /api/users with "Accept:
+// application/json; version=1" or "X-API-Version: 1", reach listUsersV1.
+// Requests that specify no version get the default.
+type Router struct {
+	cfg Config
+
+	mu       sync.RWMutex
+	versions map[string]*versionEntry
+}
+
+type versionEntry struct {
+	mux         chi.Router
+	deprecation *Deprecation
+}
+
+// Option configures a version registered with Router.Version.
+type Option func(*versionEntry)
+
+// Deprecated marks every route in a version as deprecated.
+func Deprecated(d Deprecation) Option {
+	return func(e *versionEntry) {
+		e.deprecation = &d
+	}
+}
+
+// NewRouter creates a versioned router.
+func NewRouter(cfg Config) *Router {
+	registerMetrics()
+	return &Router{
+		cfg:      cfg,
+		versions: make(map[string]*versionEntry),
+	}
+}
+
+// Version registers the routes for a version and returns its router.
+// Calling Version again for the same version adds to the existing router.
+func (vr *Router) Version(version string, fn func(r chi.Router), opts ...Option) chi.Router {
+	version = normalize(version)
+
+	vr.mu.Lock()
+	e, ok := vr.versions[version]
+	if !ok {
+		e = &versionEntry{mux: chi.NewRouter()}
+		vr.versions[version] = e
+	}
+	for _, opt := range opts {
+		opt(e)
+	}
+	vr.mu.Unlock()
+
+	if fn != nil {
+		fn(e.mux)
+	}
+	return e.mux
+}
+
+// Versions returns the registered versions, sorted.
+func (vr *Router) Versions() []string {
+	vr.mu.RLock()
+	defer vr.mu.RUnlock()
+	out := make([]string, 0, len(vr.versions))
+	for v := range vr.versions {
+		out = append(out, v)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// ServeHTTP resolves the version and dispatches to its router.
+func (vr *Router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rctx := chi.RouteContext(r.Context())
+	path := r.URL.Path
+	if rctx != nil && rctx.RoutePath != "" {
+		path = rctx.RoutePath
+	}
+
+	version, rest, src := vr.cfg.resolve(r, path)
+
+	vr.mu.RLock()
+	e, ok := vr.versions[version]
+	vr.mu.RUnlock()
+	if !ok {
+		if src == SourcePath {
+			httputil.JSONError(w, http.StatusNotFound, "not_found",
+				"The requested resource was not found")
+			return
+		}
+		httputil.JSONError(w, http.StatusBadRequest, "unsupported_version",
+			"Unsupported API version "+version+"; supported: "+strings.Join(vr.Versions(), ", "))
+		return
+	}
+
+	versionRequests.WithLabelValues(version, string(src)).Inc()
+
+	ctx := WithVersion(r.Context(), version)
+	if rctx == nil {
+		rctx = chi.NewRouteContext()
+		ctx = context.WithValue(ctx, chi.RouteCtxKey, rctx)
+	}
+	if src == SourcePath {
+		// Keep the version segment in the route pattern for metrics and logs.
+		rctx.RoutePatterns = append(rctx.RoutePatterns, "/v"+version+"/*")
+	}
+	rctx.RoutePath = rest
+	r = r.WithContext(ctx)
+
+	if vr.cfg.ResponseHeader != "" {
+		w.Header().Set(vr.cfg.ResponseHeader, version)
+	}
+
+	if e.deprecation != nil {
+		e.deprecation.apply(w.Header())
+		e.mux.ServeHTTP(w, r)
+		countDeprecated(r)
+		return
+	}
+	e.mux.ServeHTTP(w, r)
+}
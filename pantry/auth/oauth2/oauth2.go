@@ -60,6 +60,17 @@ type SessionStore interface {
 	Delete(ctx context.Context, sessionID string) error
 }
 
+// UserSessionStore is a SessionStore that can remove all of a user's
+// sessions, for example when the user changes their password. Stores that
+// implement it can be passed to session.RevokerFunc to sign the user out of
+// OAuth2 sessions along with server-side sessions.
+type UserSessionStore interface {
+	SessionStore
+
+	// DeleteByUser removes all sessions whose User.ID is userID.
+	DeleteByUser(ctx context.Context, userID string) error
+}
+
 // StateStore defines the interface for storing OAuth2 state parameters.
 // State is used to prevent CSRF attacks during the OAuth flow.
 type StateStore interface {
@@ -53,6 +53,18 @@ func (s *MemorySessionStore) Delete(_ context.Context, sessionID string) error {
 	return nil
 }
 
+// DeleteByUser removes all sessions of a user.
+func (s *MemorySessionStore) DeleteByUser(_ context.Context, userID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id, session := range s.sessions {
+		if session.User.ID == userID {
+			delete(s.sessions, id)
+		}
+	}
+	return nil
+}
+
 // Cleanup removes all expired sessions. Call this periodically.
 func (s *MemorySessionStore) Cleanup() int {
 	s.mu.Lock()
@@ -0,0 +1,208 @@
+// bodylog/bodylog.go
+package bodylog
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Capture is a recorded request/response exchange with sensitive values
+// redacted.
+type Capture struct {
+	ID        string        `json:"id"`
+	Time      time.Time     `json:"time"`
+	RequestID string        `json:"request_id,omitempty"`
+	Method    string        `json:"method"`
+	Path      string        `json:"path"`
+	Route     string        `json:"route,omitempty"`
+	Query     string        `json:"query,omitempty"`
+	Status    int           `json:"status"`
+	Latency   time.Duration `json:"latency"`
+
+	RequestHeaders    map[string][]string `json:"request_headers,omitempty"`
+	RequestBody       string              `json:"request_body,omitempty"`
+	RequestTruncated  bool                `json:"request_truncated,omitempty"`
+	ResponseHeaders   map[string][]string `json:"response_headers,omitempty"`
+	ResponseBody      string              `json:"response_body,omitempty"`
+	ResponseTruncated bool                `json:"response_truncated,omitempty"`
+}
+
+// Sink receives captures.
+type Sink interface {
+	Write(ctx context.Context, c *Capture) error
+}
+
+// SinkFunc adapts a function to Sink.
+type SinkFunc func(ctx context.Context, c *Capture) error
+
+// Write calls f(ctx, c).
+func (f SinkFunc) Write(ctx context.Context, c *Capture) error {
+	return f(ctx, c)
+}
+
+// ZapSink writes captures to a zap logger at Info level as "http_capture".
+type ZapSink struct {
+	logger *zap.Logger
+}
+
+// NewZapSink creates a sink that logs captures.
+func NewZapSink(logger *zap.Logger) *ZapSink {
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+	return &ZapSink{logger: logger}
+}
+
+// Write logs the capture.
+func (s *ZapSink) Write(ctx context.Context, c *Capture) error {
+	s.logger.Info("http_capture",
+		zap.String("capture_id", c.ID),
+		zap.String("request_id", c.RequestID),
+		zap.String("method", c.Method),
+		zap.String("path", c.Path),
+		zap.String("route", c.Route),
+		zap.String("query", c.Query),
+		zap.Int("status", c.Status),
+		zap.Duration("latency", c.Latency),
+		zap.Any("request_headers", c.RequestHeaders),
+		zap.String("request_body", c.RequestBody),
+		zap.Bool("request_truncated", c.RequestTruncated),
+		zap.Any("response_headers", c.ResponseHeaders),
+		zap.String("response_body", c.ResponseBody),
+		zap.Bool("response_truncated", c.ResponseTruncated),
+	)
+	return nil
+}
+
+// Ring keeps the most recent captures in memory for AdminHandler.
+type Ring struct {
+	mu       sync.RWMutex
+	captures []*Capture
+	next     int
+	full     bool
+}
+
+// NewRing creates a ring buffer holding up to size captures.
+func NewRing(size int) *Ring {
+	if size <= 0 {
+		size = 500
+	}
+	return &Ring{captures: make([]*Capture, size)}
+}
+
+// Write stores the capture, evicting the oldest when full.
+func (r *Ring) Write(ctx context.Context, c *Capture) error {
+	r.mu.Lock()
+	r.captures[r.next] = c
+	r.next = (r.next + 1) % len(r.captures)
+	if r.next == 0 {
+		r.full = true
+	}
+	r.mu.Unlock()
+	return nil
+}
+
+// Filter selects captures from a Ring.
+type Filter struct {
+	Method    string // exact match, case-insensitive
+	Path      string // prefix match
+	Status    int    // exact match; 0 = any
+	MinStatus int    // e.g. 400 for errors only
+	RequestID string
+	Limit     int // default 100
+}
+
+// List returns captures matching f, newest first.
+func (r *Ring) List(f Filter) []*Capture {
+	if f.Limit <= 0 {
+		f.Limit = 100
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var out []*Capture
+	n := len(r.captures)
+	count := r.next
+	if r.full {
+		count = n
+	}
+	for i := 0; i < count && len(out) < f.Limit; i++ {
+		c := r.captures[(r.next-1-i+n)%n]
+		if c == nil || !f.matches(c) {
+			continue
+		}
+		out = append(out, c)
+	}
+	return out
+}
+
+func (f Filter) matches(c *Capture) bool {
+	if f.Method != "" && !strings.EqualFold(f.Method, c.Method) {
+		return false
+	}
+	if f.Path != "" && !strings.HasPrefix(c.Path, f.Path) {
+		return false
+	}
+	if f.Status != 0 && c.Status != f.Status {
+		return false
+	}
+	if f.MinStatus != 0 && c.Status < f.MinStatus {
+		return false
+	}
+	if f.RequestID != "" && c.RequestID != f.RequestID {
+		return false
+	}
+	return true
+}
+
+// Get returns the capture with the given ID.
+func (r *Ring) Get(id string) (*Capture, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, c := range r.captures {
+		if c != nil && c.ID == id {
+			return c, true
+		}
+	}
+	return nil, false
+}
+
+// Len returns the number of stored captures.
+func (r *Ring) Len() int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if r.full {
+		return len(r.captures)
+	}
+	return r.next
+}
+
+// Clear removes all captures.
+func (r *Ring) Clear() {
+	r.mu.Lock()
+	for i := range r.captures {
+		r.captures[i] = nil
+	}
+	r.next = 0
+	r.full = false
+	r.mu.Unlock()
+}
+
+// MultiSink writes to several sinks, returning the first error.
+type MultiSink []Sink
+
+// Write writes c to every sink.
+func (m MultiSink) Write(ctx context.Context, c *Capture) error {
+	var first error
+	for _, s := range m {
+		if err := s.Write(ctx, c); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
@@ -0,0 +1,284 @@
+// bodylog/middleware.go
+package bodylog
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/dalemusser/waffle/httputil"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"go.uber.org/zap"
+)
+
+// Config configures the capture middleware.
+type Config struct {
+	// Sink receives captures. Required; use NewZapSink, NewRing, or both
+	// via MultiSink.
+	Sink Sink
+
+	// MaxBodySize is the maximum number of bytes captured from each of the
+	// request and response bodies. Handlers still see the full body.
+	// Default: 16KB.
+	MaxBodySize int
+
+	// SampleRate is the fraction of requests captured, 0 < rate <= 1.
+	// Default: 1 (capture every request the middleware sees).
+	SampleRate float64
+
+	// ShouldCapture selects requests to capture. Applied before sampling.
+	// If nil, every request is eligible.
+	ShouldCapture func(r *http.Request) bool
+
+	// Redactor removes sensitive values. Default: DefaultRedactor().
+	Redactor *Redactor
+
+	// ContentTypes limits body capture to matching content types (substring
+	// match). Other bodies are recorded as "[<n> bytes, <type>]".
+	// Default: json, xml, text/, x-www-form-urlencoded.
+	ContentTypes []string
+
+	// Logger reports sink errors. Optional.
+	Logger *zap.Logger
+}
+
+// DefaultContentTypes are the body content types captured by default.
+var DefaultContentTypes = []string{
+	"json",
+	"xml",
+	"text/",
+	"application/x-www-form-urlencoded",
+}
+
+// DefaultConfig returns a Config that logs captures to logger.
+func DefaultConfig(logger *zap.Logger) Config {
+	return Config{
+		Sink:   NewZapSink(logger),
+		Logger: logger,
+	}
+}
+
+var captureSeq atomic.Uint64
+
+// Middleware returns middleware that captures request and response bodies.
+// It is opt-in: mount it on the routes you want to debug rather than the
+// whole router.
+//
+//	ring := bodylog.NewRing(200)
+//	r.With(bodylog.Middleware(bodylog.Config{Sink: ring})).
+//	    Post("/integrations/{district}/rosters", importRosters)
+func Middleware(cfg Config) func(http.Handler) http.Handler {
+	if cfg.Sink == nil {
+		cfg.Sink = NewZapSink(cfg.Logger)
+	}
+	if cfg.MaxBodySize <= 0 {
+		cfg.MaxBodySize = 16 << 10
+	}
+	if cfg.SampleRate <= 0 || cfg.SampleRate > 1 {
+		cfg.SampleRate = 1
+	}
+	if cfg.Redactor == nil {
+		cfg.Redactor = DefaultRedactor()
+	}
+	if cfg.ContentTypes == nil {
+		cfg.ContentTypes = DefaultContentTypes
+	}
+	if cfg.Logger == nil {
+		cfg.Logger = zap.NewNop()
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if cfg.ShouldCapture != nil && !cfg.ShouldCapture(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if cfg.SampleRate < 1 && rand.Float64() >= cfg.SampleRate {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			start := time.Now()
+
+			// Capture the request body, then hand the handler a reader
+			// that replays the captured bytes followed by the remainder.
+			var reqBody []byte
+			reqTruncated := false
+			if r.Body != nil && r.Body != http.NoBody {
+				buf, err := io.ReadAll(io.LimitReader(r.Body, int64(cfg.MaxBodySize)+1))
+				if len(buf) > cfg.MaxBodySize {
+					reqBody = buf[:cfg.MaxBodySize]
+					reqTruncated = true
+				} else {
+					reqBody = buf
+				}
+				rest := io.Reader(r.Body)
+				if err != nil {
+					rest = errReader{err}
+				}
+				r.Body = &replayBody{
+					Reader: io.MultiReader(bytes.NewReader(buf), rest),
+					Closer: r.Body,
+				}
+			}
+
+			respBuf := &limitedBuffer{max: cfg.MaxBodySize}
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			ww.Tee(respBuf)
+
+			next.ServeHTTP(ww, r)
+
+			status := ww.Status()
+			if status == 0 {
+				status = http.StatusOK
+			}
+
+			c := &Capture{
+				ID:                strconv.FormatUint(captureSeq.Add(1), 10),
+				Time:              start,
+				RequestID:         middleware.GetReqID(r.Context()),
+				Method:            r.Method,
+				Path:              r.URL.Path,
+				Route:             routePattern(r),
+				Query:             cfg.Redactor.Query(r.URL.RawQuery),
+				Status:            status,
+				Latency:           time.Since(start),
+				RequestHeaders:    cfg.Redactor.Headers(r.Header),
+				RequestBody:       cfg.body(r.Header.Get("Content-Type"), reqBody, reqTruncated),
+				RequestTruncated:  reqTruncated,
+				ResponseHeaders:   cfg.Redactor.Headers(ww.Header()),
+				ResponseBody:      cfg.body(ww.Header().Get("Content-Type"), respBuf.Bytes(), respBuf.truncated),
+				ResponseTruncated: respBuf.truncated,
+			}
+
+			// Don't let a cancelled request context stop the sink.
+			if err := cfg.Sink.Write(context.WithoutCancel(r.Context()), c); err != nil {
+				cfg.Logger.Warn("bodylog sink write failed", zap.Error(err))
+			}
+		})
+	}
+}
+
+// body redacts a captured body, or summarizes it if its content type isn't
+// captured.
+func (cfg Config) body(contentType string, b []byte, truncated bool) string {
+	if len(b) == 0 {
+		return ""
+	}
+	ct := strings.ToLower(contentType)
+	for _, want := range cfg.ContentTypes {
+		if strings.Contains(ct, want) {
+			return cfg.Redactor.Body(ct, b, truncated)
+		}
+	}
+	if ct == "" {
+		ct = "unknown"
+	}
+	return "[" + strconv.Itoa(len(b)) + " bytes, " + ct + "]"
+}
+
+func routePattern(r *http.Request) string {
+	if rctx := chi.RouteContext(r.Context()); rctx != nil {
+		return rctx.RoutePattern()
+	}
+	return ""
+}
+
+// replayBody serves captured bytes followed by the rest of the original
+// body, and closes the original body.
+type replayBody struct {
+	io.Reader
+	io.Closer
+}
+
+// errReader replays an error hit while capturing the request body.
+type errReader struct{ err error }
+
+func (e errReader) Read(p []byte) (int, error) { return 0, e.err }
+
+// limitedBuffer keeps the first max bytes written to it.
+type limitedBuffer struct {
+	buf       bytes.Buffer
+	max       int
+	truncated bool
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if room := b.max - b.buf.Len(); room > 0 {
+		if len(p) > room {
+			b.buf.Write(p[:room])
+			b.truncated = true
+		} else {
+			b.buf.Write(p)
+		}
+	} else if len(p) > 0 {
+		b.truncated = true
+	}
+	return len(p), nil
+}
+
+func (b *limitedBuffer) Bytes() []byte { return b.buf.Bytes() }
+
+// AdminHandler returns an HTTP handler for browsing captures in a Ring.
+//
+//	GET    /          list captures (newest first)
+//	GET    /{id}      get a single capture
+//	DELETE /          clear all captures
+//
+// List filters (query parameters): method, path (prefix), status,
+// min_status, request_id, limit.
+//
+// Mount it behind admin authentication:
+//
+//	r.With(requireAdmin).Mount("/admin/captures", bodylog.AdminHandler(ring))
+func AdminHandler(ring *Ring) http.Handler {
+	r := chi.NewRouter()
+
+	r.Get("/", func(w http.ResponseWriter, req *http.Request) {
+		q := req.URL.Query()
+		f := Filter{
+			Method:    q.Get("method"),
+			Path:      q.Get("path"),
+			RequestID: q.Get("request_id"),
+		}
+		f.Status, _ = strconv.Atoi(q.Get("status"))
+		f.MinStatus, _ = strconv.Atoi(q.Get("min_status"))
+		f.Limit, _ = strconv.Atoi(q.Get("limit"))
+
+		captures := ring.List(f)
+		if captures == nil {
+			captures = []*Capture{}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{
+			"captures": captures,
+			"count":    len(captures),
+			"stored":   ring.Len(),
+		})
+	})
+
+	r.Get("/{id}", func(w http.ResponseWriter, req *http.Request) {
+		c, ok := ring.Get(chi.URLParam(req, "id"))
+		if !ok {
+			httputil.JSONError(w, http.StatusNotFound, "not_found", "capture not found")
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(c)
+	})
+
+	r.Delete("/", func(w http.ResponseWriter, req *http.Request) {
+		ring.Clear()
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	return r
+}
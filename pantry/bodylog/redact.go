@@ -0,0 +1,215 @@
+// bodylog/redact.go
+package bodylog
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// Redacted replaces sensitive values.
+const Redacted = "[REDACTED]"
+
+// DefaultPatterns are the key patterns redacted by DefaultRedactor. They are
+// matched case-insensitively against JSON field names, form and query keys,
+// and header names.
+var DefaultPatterns = []string{
+	"passw(or)?d",
+	"secret",
+	"token",
+	"api[-_]?key",
+	"authorization",
+	"cookie",
+	"session",
+	"ssn",
+	"social[-_]?security",
+	"card[-_]?number",
+	"cvv",
+}
+
+// Redactor removes sensitive values from captured bodies, headers and
+// query strings by matching key names against patterns.
+type Redactor struct {
+	patterns []*regexp.Regexp
+}
+
+// NewRedactor creates a Redactor from regular expression patterns. Patterns
+// are matched case-insensitively anywhere in the key, so "token" matches
+// "access_token" and "X-Refresh-Token".
+func NewRedactor(patterns ...string) (*Redactor, error) {
+	r := &Redactor{}
+	for _, p := range patterns {
+		re, err := regexp.Compile("(?i)" + p)
+		if err != nil {
+			return nil, err
+		}
+		r.patterns = append(r.patterns, re)
+	}
+	return r, nil
+}
+
+// MustRedactor is like NewRedactor but panics on an invalid pattern.
+func MustRedactor(patterns ...string) *Redactor {
+	r, err := NewRedactor(patterns...)
+	if err != nil {
+		panic("bodylog: " + err.Error())
+	}
+	return r
+}
+
+// DefaultRedactor returns a Redactor using DefaultPatterns.
+func DefaultRedactor() *Redactor {
+	return MustRedactor(DefaultPatterns...)
+}
+
+// Sensitive reports whether key matches any pattern.
+func (r *Redactor) Sensitive(key string) bool {
+	for _, re := range r.patterns {
+		if re.MatchString(key) {
+			return true
+		}
+	}
+	return false
+}
+
+// Headers returns a copy of h with sensitive header values redacted.
+func (r *Redactor) Headers(h http.Header) map[string][]string {
+	out := make(map[string][]string, len(h))
+	for k, v := range h {
+		if r.Sensitive(k) {
+			out[k] = []string{Redacted}
+			continue
+		}
+		out[k] = append([]string(nil), v...)
+	}
+	return out
+}
+
+// Query redacts sensitive values in a raw query string.
+func (r *Redactor) Query(raw string) string {
+	if raw == "" {
+		return ""
+	}
+	values, err := url.ParseQuery(raw)
+	if err != nil {
+		// If we can't parse it, redact the entire query to be safe
+		return Redacted
+	}
+	for k := range values {
+		if r.Sensitive(k) {
+			values.Set(k, Redacted)
+		}
+	}
+	return values.Encode()
+}
+
+// Body redacts a captured body according to its content type. JSON and
+// form bodies have sensitive fields redacted; other text is returned as-is.
+// truncated indicates the body was cut at the capture limit, in which case
+// JSON is redacted with a best-effort scan since it can't be parsed.
+func (r *Redactor) Body(contentType string, body []byte, truncated bool) string {
+	if len(body) == 0 {
+		return ""
+	}
+	ct := strings.ToLower(contentType)
+	switch {
+	case strings.Contains(ct, "json"):
+		return r.JSON(body, truncated)
+	case strings.Contains(ct, "application/x-www-form-urlencoded"):
+		if truncated {
+			return r.formScan(string(body))
+		}
+		return r.Query(string(body))
+	default:
+		return string(body)
+	}
+}
+
+// JSON redacts sensitive fields in a JSON document. Nested objects and
+// arrays are walked; a sensitive key's entire value is replaced. If the
+// document can't be parsed (for example, because it was truncated),
+// sensitive "key": value pairs are redacted with a textual scan. Numbers
+// are kept as written, so large IDs don't lose precision.
+func (r *Redactor) JSON(body []byte, truncated bool) string {
+	if !truncated {
+		if v, ok := decodeJSON(body); ok {
+			out, err := json.Marshal(r.walk(v))
+			if err == nil {
+				return string(out)
+			}
+		}
+	}
+	return r.jsonScan(string(body))
+}
+
+// decodeJSON parses a single JSON document, keeping numbers as
+// json.Number.
+func decodeJSON(body []byte) (any, bool) {
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil, false
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, false // trailing data
+	}
+	return v, true
+}
+
+func (r *Redactor) walk(v any) any {
+	switch t := v.(type) {
+	case map[string]any:
+		for k, val := range t {
+			if r.Sensitive(k) {
+				t[k] = Redacted
+			} else {
+				t[k] = r.walk(val)
+			}
+		}
+		return t
+	case []any:
+		for i, val := range t {
+			t[i] = r.walk(val)
+		}
+		return t
+	default:
+		return v
+	}
+}
+
+// jsonPair matches "key": value where value is a string (possibly cut
+// off by truncation) or a bare scalar.
+var jsonPair = regexp.MustCompile(`"((?:[^"\\]|\\.)*)"(\s*:\s*)("(?:[^"\\]|\\.)*"?|[^,}\]\s]+)`)
+
+func (r *Redactor) jsonScan(s string) string {
+	return jsonPair.ReplaceAllStringFunc(s, func(m string) string {
+		sub := jsonPair.FindStringSubmatch(m)
+		if r.Sensitive(sub[1]) {
+			return `"` + sub[1] + `"` + sub[2] + `"` + Redacted + `"`
+		}
+		return m
+	})
+}
+
+// formScan redacts key=value pairs in a form body that can't be parsed.
+func (r *Redactor) formScan(s string) string {
+	pairs := strings.Split(s, "&")
+	for i, pair := range pairs {
+		k, _, ok := strings.Cut(pair, "=")
+		if !ok {
+			continue
+		}
+		if key, err := url.QueryUnescape(k); err == nil {
+			k = key
+		}
+		if r.Sensitive(k) {
+			pairs[i] = url.QueryEscape(k) + "=" + Redacted
+		}
+	}
+	return strings.Join(pairs, "&")
+}
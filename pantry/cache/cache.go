@@ -35,6 +35,8 @@ type Cache interface {
 var (
 	ErrNotFound = errors.New("cache: key not found")
 	ErrClosed   = errors.New("cache: cache is closed")
+
+	ErrTagsNotSupported = errors.New("cache: tags not supported")
 )
 
 // GetJSON retrieves and unmarshals a JSON value.
@@ -60,61 +62,36 @@ func SetJSON(ctx context.Context, c Cache, key string, value any, ttl time.Durat
 }
 
 // GetOrSet retrieves a value, or computes and stores it if not found.
-func GetOrSet(ctx context.Context, c Cache, key string, ttl time.Duration, compute func() ([]byte, error)) ([]byte, error) {
-	// Try to get existing value
-	data, err := c.Get(ctx, key)
-	if err == nil {
-		return data, nil
-	}
-	if !errors.Is(err, ErrNotFound) {
-		return nil, err
-	}
-
-	// Compute new value
-	data, err = compute()
-	if err != nil {
-		return nil, err
-	}
-
-	// Store it
-	if err := c.Set(ctx, key, data, ttl); err != nil {
-		return nil, err
+// Concurrent misses for the same key in this process share one call to
+// compute. Options add coalescing across instances, stale-while-revalidate,
+// early expiration and error caching; see stampede.go.
+func GetOrSet(ctx context.Context, c Cache, key string, ttl time.Duration, compute func() ([]byte, error), opts ...GetOrSetOption) ([]byte, error) {
+	o := &getOrSetOptions{}
+	for _, opt := range opts {
+		opt(o)
 	}
-
-	return data, nil
+	return getOrSet(ctx, c, key, ttl, compute, o)
 }
 
 // GetOrSetJSON retrieves a JSON value, or computes and stores it if not found.
-func GetOrSetJSON[T any](ctx context.Context, c Cache, key string, ttl time.Duration, compute func() (T, error)) (T, error) {
+// It takes the same options as GetOrSet.
+func GetOrSetJSON[T any](ctx context.Context, c Cache, key string, ttl time.Duration, compute func() (T, error), opts ...GetOrSetOption) (T, error) {
 	var result T
 
-	// Try to get existing value
-	data, err := c.Get(ctx, key)
-	if err == nil {
-		if err := json.Unmarshal(data, &result); err != nil {
-			return result, err
+	data, err := GetOrSet(ctx, c, key, ttl, func() ([]byte, error) {
+		value, err := compute()
+		if err != nil {
+			return nil, err
 		}
-		return result, nil
-	}
-	if !errors.Is(err, ErrNotFound) {
-		return result, err
-	}
-
-	// Compute new value
-	result, err = compute()
+		return json.Marshal(value)
+	}, opts...)
 	if err != nil {
 		return result, err
 	}
 
-	// Store it
-	data, err = json.Marshal(result)
-	if err != nil {
-		return result, err
-	}
-	if err := c.Set(ctx, key, data, ttl); err != nil {
+	if err := json.Unmarshal(data, &result); err != nil {
 		return result, err
 	}
-
 	return result, nil
 }
 
@@ -134,3 +111,33 @@ type MultiSetter interface {
 type MultiDeleter interface {
 	DeleteMulti(ctx context.Context, keys []string) error
 }
+
+// Tag-based invalidation (optional interface)
+
+// Tagger supports tag-based invalidation.
+type Tagger interface {
+	// SetWithTags stores a value with the given TTL and tags.
+	SetWithTags(ctx context.Context, key string, value []byte, ttl time.Duration, tags ...string) error
+
+	// InvalidateTags removes every entry stored with any of the tags.
+	InvalidateTags(ctx context.Context, tags ...string) error
+}
+
+// SetWithTags stores a value with tags if c implements Tagger, and
+// without them otherwise.
+func SetWithTags(ctx context.Context, c Cache, key string, value []byte, ttl time.Duration, tags ...string) error {
+	if t, ok := c.(Tagger); ok && len(tags) > 0 {
+		return t.SetWithTags(ctx, key, value, ttl, tags...)
+	}
+	return c.Set(ctx, key, value, ttl)
+}
+
+// InvalidateTags removes every entry stored with any of the tags.
+// Returns ErrTagsNotSupported if c doesn't implement Tagger.
+func InvalidateTags(ctx context.Context, c Cache, tags ...string) error {
+	t, ok := c.(Tagger)
+	if !ok {
+		return ErrTagsNotSupported
+	}
+	return t.InvalidateTags(ctx, tags...)
+}
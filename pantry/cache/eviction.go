@@ -0,0 +1,367 @@
+// cache/eviction.go
+package cache
+
+import (
+	"container/heap"
+	"container/list"
+)
+
+// EvictionPolicy selects which entries a bounded Memory cache evicts.
+type EvictionPolicy int
+
+const (
+	// EvictLRU evicts the least recently used entry.
+	EvictLRU EvictionPolicy = iota
+
+	// EvictLFU evicts the least frequently used entry, oldest first among
+	// equals.
+	EvictLFU
+
+	// EvictTinyLFU uses W-TinyLFU: new entries go to a small LRU window,
+	// and leave it for the main cache only if a frequency sketch says
+	// they are used more often than the entry they would displace. This
+	// keeps one-off keys from flushing popular ones.
+	EvictTinyLFU
+)
+
+// String returns the policy name.
+func (p EvictionPolicy) String() string {
+	switch p {
+	case EvictLRU:
+		return "lru"
+	case EvictLFU:
+		return "lfu"
+	case EvictTinyLFU:
+		return "tinylfu"
+	default:
+		return "unknown"
+	}
+}
+
+// EvictionReason says why an entry left a Memory cache without being
+// deleted.
+type EvictionReason int
+
+const (
+	// EvictionCapacity means the entry was evicted to stay within budget.
+	EvictionCapacity EvictionReason = iota
+
+	// EvictionRejected means a new entry was not admitted: it was larger
+	// than a shard's byte budget, or W-TinyLFU preferred the entries
+	// already cached.
+	EvictionRejected
+
+	// EvictionExpired means the entry's TTL passed.
+	EvictionExpired
+)
+
+// String returns the reason name.
+func (r EvictionReason) String() string {
+	switch r {
+	case EvictionCapacity:
+		return "capacity"
+	case EvictionRejected:
+		return "rejected"
+	case EvictionExpired:
+		return "expired"
+	default:
+		return "unknown"
+	}
+}
+
+// evictionPolicy tracks the entries of one shard. Callers hold the shard
+// lock.
+type evictionPolicy interface {
+	// add records a new entry.
+	add(it *item)
+
+	// access records a hit.
+	access(it *item)
+
+	// miss records a lookup of an absent key.
+	miss(hash uint64)
+
+	// remove forgets an entry that was deleted.
+	remove(it *item)
+
+	// evict picks the next entry to evict and forgets it. It returns nil
+	// if there are no entries.
+	evict() *item
+}
+
+func newEvictionPolicy(p EvictionPolicy, capacity int) evictionPolicy {
+	switch p {
+	case EvictLFU:
+		return &lfuPolicy{}
+	case EvictTinyLFU:
+		return newTinyLFUPolicy(capacity)
+	default:
+		return &lruPolicy{}
+	}
+}
+
+// lruPolicy keeps entries in recency order, most recent at the front.
+type lruPolicy struct {
+	ll list.List
+}
+
+func (p *lruPolicy) add(it *item) {
+	it.elem = p.ll.PushFront(it)
+}
+
+func (p *lruPolicy) access(it *item) {
+	p.ll.MoveToFront(it.elem)
+}
+
+func (p *lruPolicy) miss(hash uint64) {}
+
+func (p *lruPolicy) remove(it *item) {
+	p.ll.Remove(it.elem)
+}
+
+func (p *lruPolicy) evict() *item {
+	e := p.ll.Back()
+	if e == nil {
+		return nil
+	}
+	p.ll.Remove(e)
+	return e.Value.(*item)
+}
+
+// lfuPolicy keeps entries in a min-heap ordered by use count, then by
+// last use.
+type lfuPolicy struct {
+	h    lfuHeap
+	tick uint64
+}
+
+func (p *lfuPolicy) add(it *item) {
+	p.tick++
+	it.freq = 1
+	it.tick = p.tick
+	heap.Push(&p.h, it)
+}
+
+func (p *lfuPolicy) access(it *item) {
+	p.tick++
+	it.freq++
+	it.tick = p.tick
+	heap.Fix(&p.h, it.index)
+}
+
+func (p *lfuPolicy) miss(hash uint64) {}
+
+func (p *lfuPolicy) remove(it *item) {
+	heap.Remove(&p.h, it.index)
+}
+
+func (p *lfuPolicy) evict() *item {
+	if len(p.h) == 0 {
+		return nil
+	}
+	return heap.Pop(&p.h).(*item)
+}
+
+type lfuHeap []*item
+
+func (h lfuHeap) Len() int { return len(h) }
+
+func (h lfuHeap) Less(i, j int) bool {
+	if h[i].freq != h[j].freq {
+		return h[i].freq < h[j].freq
+	}
+	return h[i].tick < h[j].tick
+}
+
+func (h lfuHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *lfuHeap) Push(x any) {
+	it := x.(*item)
+	it.index = len(*h)
+	*h = append(*h, it)
+}
+
+func (h *lfuHeap) Pop() any {
+	old := *h
+	n := len(old)
+	it := old[n-1]
+	old[n-1] = nil
+	*h = old[:n-1]
+	return it
+}
+
+// W-TinyLFU segments.
+const (
+	segWindow = iota
+	segProbation
+	segProtected
+)
+
+// tinyLFUPolicy implements W-TinyLFU. The window takes about 1% of the
+// entries; the rest are split between probation (entries seen once in the
+// main cache) and protected (entries hit again there), 20/80.
+type tinyLFUPolicy struct {
+	window    list.List
+	probation list.List
+	protected list.List
+	sketch    *cmSketch
+}
+
+func newTinyLFUPolicy(capacity int) *tinyLFUPolicy {
+	return &tinyLFUPolicy{sketch: newCMSketch(capacity)}
+}
+
+func (p *tinyLFUPolicy) list(seg uint8) *list.List {
+	switch seg {
+	case segWindow:
+		return &p.window
+	case segProbation:
+		return &p.probation
+	default:
+		return &p.protected
+	}
+}
+
+func (p *tinyLFUPolicy) move(it *item, seg uint8) {
+	p.list(it.seg).Remove(it.elem)
+	it.seg = seg
+	it.elem = p.list(seg).PushFront(it)
+}
+
+// balance keeps the window and protected segments within their shares by
+// demoting their oldest entries to probation.
+func (p *tinyLFUPolicy) balance() {
+	total := p.window.Len() + p.probation.Len() + p.protected.Len()
+	windowMax := max(1, total/100)
+	for p.window.Len() > windowMax {
+		p.move(p.window.Back().Value.(*item), segProbation)
+	}
+	protectedMax := (total - windowMax) * 8 / 10
+	for p.protected.Len() > protectedMax {
+		p.move(p.protected.Back().Value.(*item), segProbation)
+	}
+}
+
+func (p *tinyLFUPolicy) add(it *item) {
+	p.sketch.increment(it.hash)
+	it.seg = segWindow
+	it.elem = p.window.PushFront(it)
+	p.balance()
+}
+
+func (p *tinyLFUPolicy) access(it *item) {
+	p.sketch.increment(it.hash)
+	switch it.seg {
+	case segProbation:
+		p.move(it, segProtected)
+		p.balance()
+	default:
+		p.list(it.seg).MoveToFront(it.elem)
+	}
+}
+
+func (p *tinyLFUPolicy) miss(hash uint64) {
+	p.sketch.increment(hash)
+}
+
+func (p *tinyLFUPolicy) remove(it *item) {
+	p.list(it.seg).Remove(it.elem)
+}
+
+// evict pits the oldest window entry (the candidate) against the oldest
+// main entry (the victim). The candidate is admitted to probation only if
+// it is used more often than the victim; the loser is evicted.
+func (p *tinyLFUPolicy) evict() *item {
+	var candidate, victim *item
+	if e := p.window.Back(); e != nil {
+		candidate = e.Value.(*item)
+	}
+	if e := p.probation.Back(); e != nil {
+		victim = e.Value.(*item)
+	} else if e := p.protected.Back(); e != nil {
+		victim = e.Value.(*item)
+	}
+
+	switch {
+	case candidate == nil && victim == nil:
+		return nil
+	case victim == nil:
+		p.remove(candidate)
+		return candidate
+	case candidate == nil:
+		p.remove(victim)
+		return victim
+	}
+
+	if p.sketch.estimate(candidate.hash) > p.sketch.estimate(victim.hash) {
+		p.move(candidate, segProbation)
+		p.remove(victim)
+		return victim
+	}
+	p.remove(candidate)
+	return candidate
+}
+
+// cmSketch is a count-min sketch with counters saturating at 15,
+// halved periodically so old popularity fades.
+type cmSketch struct {
+	rows      [4][]uint8
+	mask      uint64
+	additions int
+	resetAt   int
+}
+
+// cmSeeds decorrelate the sketch rows.
+var cmSeeds = [4]uint64{0xc3a5c85c97cb3127, 0xb492b66fbe98f273, 0x9ae16a3b2f90404f, 0xcbf29ce484222325}
+
+func newCMSketch(capacity int) *cmSketch {
+	width := 16
+	for width < capacity {
+		width <<= 1
+	}
+	s := &cmSketch{
+		mask:    uint64(width - 1),
+		resetAt: width * 10,
+	}
+	for i := range s.rows {
+		s.rows[i] = make([]uint8, width)
+	}
+	return s
+}
+
+func (s *cmSketch) index(hash uint64, row int) uint64 {
+	h := (hash ^ cmSeeds[row]) * 0x9e3779b97f4a7c15
+	return (h >> 32) & s.mask
+}
+
+func (s *cmSketch) increment(hash uint64) {
+	for i := range s.rows {
+		idx := s.index(hash, i)
+		if s.rows[i][idx] < 15 {
+			s.rows[i][idx]++
+		}
+	}
+	s.additions++
+	if s.additions >= s.resetAt {
+		for i := range s.rows {
+			for j := range s.rows[i] {
+				s.rows[i][j] >>= 1
+			}
+		}
+		s.additions /= 2
+	}
+}
+
+func (s *cmSketch) estimate(hash uint64) uint8 {
+	est := uint8(15)
+	for i := range s.rows {
+		if v := s.rows[i][s.index(hash, i)]; v < est {
+			est = v
+		}
+	}
+	return est
+}
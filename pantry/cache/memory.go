@@ -2,24 +2,76 @@
 package cache
 
 import (
+	"container/list"
 	"context"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
 // Memory implements an in-memory cache with TTL support.
+//
+// By default it is unbounded and only expired items are removed. Set
+// MaxEntries or MaxBytes to bound it: entries are then evicted using the
+// configured EvictionPolicy. Keys are spread over shards, each with its own
+// lock and its own share of the budget.
 type Memory struct {
-	mu      sync.RWMutex
-	items   map[string]*item
-	closed  bool
+	shards  []*memoryShard
+	mask    uint64
+	onEvict func(key string, value []byte, reason EvictionReason)
+
+	tagMu sync.Mutex
+	tags  map[string]map[string]struct{} // tag -> keys
+
+	closeMu sync.Mutex
+	closed  atomic.Bool
 	stopCh  chan struct{}
 	cleanCh chan struct{}
+
+	hits        atomic.Uint64
+	misses      atomic.Uint64
+	evictions   atomic.Uint64
+	rejections  atomic.Uint64
+	expirations atomic.Uint64
 }
 
 type item struct {
+	key       string
 	value     []byte
 	expiresAt time.Time
 	noExpiry  bool
+	tags      []string
+	size      int64
+	hash      uint64
+
+	// Eviction policy state
+	elem  *list.Element // LRU and W-TinyLFU
+	seg   uint8         // W-TinyLFU segment
+	freq  uint64        // LFU use count
+	tick  uint64        // LFU last use
+	index int           // LFU heap index
+}
+
+func (it *item) expired(now time.Time) bool {
+	return !it.noExpiry && now.After(it.expiresAt)
+}
+
+// memoryShard holds a slice of the keyspace.
+type memoryShard struct {
+	mu       sync.RWMutex
+	items    map[string]*item
+	policy   evictionPolicy // nil if unbounded
+	bytes    int64
+	maxItems int
+	maxBytes int64
+}
+
+// evicted records an entry removed by eviction or expiry, reported once
+// the shard lock is released.
+type evicted struct {
+	key    string
+	value  []byte
+	reason EvictionReason
 }
 
 // MemoryConfig configures the in-memory cache.
@@ -31,13 +83,45 @@ type MemoryConfig struct {
 	// InitialCapacity is the initial map capacity.
 	// Default: 100.
 	InitialCapacity int
+
+	// MaxEntries bounds the number of entries.
+	// Default: 0 (unbounded).
+	MaxEntries int
+
+	// MaxBytes bounds the total size of keys and values. Per-entry
+	// bookkeeping isn't counted, so leave headroom.
+	// Default: 0 (unbounded).
+	MaxBytes int64
+
+	// Policy selects which entries to evict when over budget.
+	// Default: EvictLRU.
+	Policy EvictionPolicy
+
+	// Shards is the number of independently locked shards, rounded up to
+	// a power of two. Each shard gets an equal share of MaxEntries and
+	// MaxBytes, so small budgets use fewer shards.
+	// Default: 16.
+	Shards int
+
+	// OnEvict is called when an entry is evicted, rejected or expires. It
+	// is not called for Delete, Clear or overwrites. It runs without locks
+	// held, on the goroutine that caused the eviction.
+	OnEvict func(key string, value []byte, reason EvictionReason)
 }
 
+// Minimum per-shard budgets; below these, fewer shards are used so that
+// eviction stays close to the configured policy.
+const (
+	minShardEntries = 16
+	minShardBytes   = 64 << 10
+)
+
 // DefaultMemoryConfig returns sensible defaults.
 func DefaultMemoryConfig() MemoryConfig {
 	return MemoryConfig{
 		CleanupInterval: time.Minute,
 		InitialCapacity: 100,
+		Shards:          16,
 	}
 }
 
@@ -51,61 +135,152 @@ func NewMemoryWithConfig(cfg MemoryConfig) *Memory {
 	if cfg.InitialCapacity <= 0 {
 		cfg.InitialCapacity = 100
 	}
+	if cfg.Shards <= 0 {
+		cfg.Shards = 16
+	}
+
+	shards := 1
+	for shards < cfg.Shards {
+		shards <<= 1
+	}
+	for shards > 1 && cfg.MaxEntries > 0 && cfg.MaxEntries/shards < minShardEntries {
+		shards >>= 1
+	}
+	for shards > 1 && cfg.MaxBytes > 0 && cfg.MaxBytes/int64(shards) < minShardBytes {
+		shards >>= 1
+	}
 
 	m := &Memory{
-		items:   make(map[string]*item, cfg.InitialCapacity),
+		shards:  make([]*memoryShard, shards),
+		mask:    uint64(shards - 1),
+		onEvict: cfg.OnEvict,
+		tags:    make(map[string]map[string]struct{}),
 		stopCh:  make(chan struct{}),
 		cleanCh: make(chan struct{}),
 	}
 
+	bounded := cfg.MaxEntries > 0 || cfg.MaxBytes > 0
+	for i := range m.shards {
+		s := &memoryShard{
+			items:    make(map[string]*item, cfg.InitialCapacity/shards+1),
+			maxItems: cfg.MaxEntries / shards,
+			maxBytes: cfg.MaxBytes / int64(shards),
+		}
+		if bounded {
+			s.policy = newEvictionPolicy(cfg.Policy, s.maxItems)
+		}
+		m.shards[i] = s
+	}
+
 	// Start cleanup goroutine if interval is set
 	if cfg.CleanupInterval > 0 {
 		go m.cleanup(cfg.CleanupInterval)
+	} else {
+		close(m.cleanCh)
 	}
 
 	return m
 }
 
+// hashKey is FNV-1a.
+func hashKey(key string) uint64 {
+	h := uint64(14695981039346656037)
+	for i := 0; i < len(key); i++ {
+		h ^= uint64(key[i])
+		h *= 1099511628211
+	}
+	return h
+}
+
+func (m *Memory) shard(hash uint64) *memoryShard {
+	return m.shards[hash&m.mask]
+}
+
 // Get retrieves a value by key.
 func (m *Memory) Get(ctx context.Context, key string) ([]byte, error) {
-	m.mu.RLock()
-	defer m.mu.RUnlock()
-
-	if m.closed {
+	if m.closed.Load() {
 		return nil, ErrClosed
 	}
 
-	it, exists := m.items[key]
-	if !exists {
+	value, ok := m.get(key)
+	if !ok {
 		return nil, ErrNotFound
 	}
+	return value, nil
+}
 
-	// Check expiration
-	if !it.noExpiry && time.Now().After(it.expiresAt) {
-		return nil, ErrNotFound
+// get returns a copy of a live value and records the hit or miss.
+func (m *Memory) get(key string) ([]byte, bool) {
+	hash := hashKey(key)
+	s := m.shard(hash)
+
+	// Unbounded shards have no policy state to update
+	if s.policy == nil {
+		s.mu.RLock()
+		it, ok := s.items[key]
+		if !ok || it.expired(time.Now()) {
+			s.mu.RUnlock()
+			m.misses.Add(1)
+			return nil, false
+		}
+		// Return a copy to prevent mutation
+		value := make([]byte, len(it.value))
+		copy(value, it.value)
+		s.mu.RUnlock()
+		m.hits.Add(1)
+		return value, true
 	}
 
-	// Return a copy to prevent mutation
-	result := make([]byte, len(it.value))
-	copy(result, it.value)
-	return result, nil
+	s.mu.Lock()
+	it, ok := s.items[key]
+	if !ok {
+		s.policy.miss(hash)
+		s.mu.Unlock()
+		m.misses.Add(1)
+		return nil, false
+	}
+	if it.expired(time.Now()) {
+		m.removeItem(s, it)
+		s.mu.Unlock()
+		m.misses.Add(1)
+		m.notify([]evicted{{key: key, value: it.value, reason: EvictionExpired}})
+		return nil, false
+	}
+	s.policy.access(it)
+	value := make([]byte, len(it.value))
+	copy(value, it.value)
+	s.mu.Unlock()
+	m.hits.Add(1)
+	return value, true
 }
 
 // Set stores a value with the given TTL.
 func (m *Memory) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
-	m.mu.Lock()
-	defer m.mu.Unlock()
+	return m.SetWithTags(ctx, key, value, ttl)
+}
 
-	if m.closed {
+// SetWithTags stores a value with the given TTL and tags.
+func (m *Memory) SetWithTags(ctx context.Context, key string, value []byte, ttl time.Duration, tags ...string) error {
+	if m.closed.Load() {
 		return ErrClosed
 	}
 
+	m.set(key, value, ttl, tags)
+	return nil
+}
+
+// set stores a copy of value, evicting entries if the shard goes over
+// budget.
+func (m *Memory) set(key string, value []byte, ttl time.Duration, tags []string) {
 	// Copy value to prevent external mutation
 	valueCopy := make([]byte, len(value))
 	copy(valueCopy, value)
 
 	it := &item{
+		key:   key,
 		value: valueCopy,
+		size:  int64(len(key) + len(value)),
+		hash:  hashKey(key),
 	}
 
 	if ttl > 0 {
@@ -114,67 +289,186 @@ func (m *Memory) Set(ctx context.Context, key string, value []byte, ttl time.Dur
 		it.noExpiry = true
 	}
 
-	m.items[key] = it
-	return nil
+	s := m.shard(it.hash)
+	s.mu.Lock()
+	ev := m.store(s, it, tags)
+	s.mu.Unlock()
+	m.notify(ev)
+}
+
+// store adds an item to a shard, replacing any entry with its key, and
+// evicts until the shard is within budget. Caller must hold s.mu.
+func (m *Memory) store(s *memoryShard, it *item, tags []string) []evicted {
+	if old, ok := s.items[it.key]; ok {
+		m.removeItem(s, old)
+	}
+
+	if s.maxBytes > 0 && it.size > s.maxBytes {
+		return []evicted{{key: it.key, value: it.value, reason: EvictionRejected}}
+	}
+
+	if len(tags) > 0 {
+		it.tags = append([]string(nil), tags...)
+		m.linkTags(it.key, it.tags)
+	}
+	s.items[it.key] = it
+	s.bytes += it.size
+
+	if s.policy == nil {
+		return nil
+	}
+	s.policy.add(it)
+
+	var ev []evicted
+	for s.over() {
+		victim := s.policy.evict()
+		if victim == nil {
+			break
+		}
+		m.dropItem(s, victim)
+		reason := EvictionCapacity
+		if victim == it {
+			reason = EvictionRejected
+		}
+		ev = append(ev, evicted{key: victim.key, value: victim.value, reason: reason})
+	}
+	return ev
+}
+
+// over reports whether the shard exceeds its budget.
+func (s *memoryShard) over() bool {
+	return (s.maxItems > 0 && len(s.items) > s.maxItems) ||
+		(s.maxBytes > 0 && s.bytes > s.maxBytes)
+}
+
+// removeItem deletes an item from its shard and eviction policy. Caller
+// must hold s.mu.
+func (m *Memory) removeItem(s *memoryShard, it *item) {
+	if s.policy != nil {
+		s.policy.remove(it)
+	}
+	m.dropItem(s, it)
+}
+
+// dropItem deletes an item the eviction policy has already forgotten.
+// Caller must hold s.mu.
+func (m *Memory) dropItem(s *memoryShard, it *item) {
+	delete(s.items, it.key)
+	s.bytes -= it.size
+	if len(it.tags) > 0 {
+		m.unlinkTags(it.key, it.tags)
+	}
+}
+
+// notify counts evictions and reports them to OnEvict.
+func (m *Memory) notify(ev []evicted) {
+	for _, e := range ev {
+		switch e.reason {
+		case EvictionCapacity:
+			m.evictions.Add(1)
+		case EvictionRejected:
+			m.rejections.Add(1)
+		case EvictionExpired:
+			m.expirations.Add(1)
+		}
+		if m.onEvict != nil {
+			m.onEvict(e.key, e.value, e.reason)
+		}
+	}
+}
+
+// linkTags indexes a key under its tags.
+func (m *Memory) linkTags(key string, tags []string) {
+	m.tagMu.Lock()
+	defer m.tagMu.Unlock()
+	for _, tag := range tags {
+		keys, ok := m.tags[tag]
+		if !ok {
+			keys = make(map[string]struct{})
+			m.tags[tag] = keys
+		}
+		keys[key] = struct{}{}
+	}
+}
+
+// unlinkTags drops a key from its tags.
+func (m *Memory) unlinkTags(key string, tags []string) {
+	m.tagMu.Lock()
+	defer m.tagMu.Unlock()
+	for _, tag := range tags {
+		keys := m.tags[tag]
+		delete(keys, key)
+		if len(keys) == 0 {
+			delete(m.tags, tag)
+		}
+	}
 }
 
 // Delete removes a key from the cache.
 func (m *Memory) Delete(ctx context.Context, key string) error {
-	m.mu.Lock()
-	defer m.mu.Unlock()
-
-	if m.closed {
+	if m.closed.Load() {
 		return ErrClosed
 	}
 
-	delete(m.items, key)
+	m.delete(key)
 	return nil
 }
 
+func (m *Memory) delete(key string) {
+	s := m.shard(hashKey(key))
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if it, ok := s.items[key]; ok {
+		m.removeItem(s, it)
+	}
+}
+
 // Exists checks if a key exists and is not expired.
 func (m *Memory) Exists(ctx context.Context, key string) (bool, error) {
-	m.mu.RLock()
-	defer m.mu.RUnlock()
-
-	if m.closed {
+	if m.closed.Load() {
 		return false, ErrClosed
 	}
 
-	it, exists := m.items[key]
-	if !exists {
-		return false, nil
-	}
+	s := m.shard(hashKey(key))
+	s.mu.RLock()
+	defer s.mu.RUnlock()
 
-	if !it.noExpiry && time.Now().After(it.expiresAt) {
+	it, exists := s.items[key]
+	if !exists {
 		return false, nil
 	}
 
-	return true, nil
+	return !it.expired(time.Now()), nil
 }
 
 // Clear removes all entries from the cache.
 func (m *Memory) Clear(ctx context.Context) error {
-	m.mu.Lock()
-	defer m.mu.Unlock()
-
-	if m.closed {
+	if m.closed.Load() {
 		return ErrClosed
 	}
 
-	m.items = make(map[string]*item, 100)
+	for _, s := range m.shards {
+		s.mu.Lock()
+		for _, it := range s.items {
+			m.removeItem(s, it)
+		}
+		s.items = make(map[string]*item, 100/len(m.shards)+1)
+		s.mu.Unlock()
+	}
 	return nil
 }
 
 // Close stops the cleanup goroutine and releases resources.
 func (m *Memory) Close() error {
-	m.mu.Lock()
-	defer m.mu.Unlock()
+	m.closeMu.Lock()
+	defer m.closeMu.Unlock()
 
-	if m.closed {
+	if m.closed.Load() {
 		return nil
 	}
 
-	m.closed = true
+	m.closed.Store(true)
 	close(m.stopCh)
 	<-m.cleanCh // Wait for cleanup goroutine to stop
 	return nil
@@ -182,35 +476,55 @@ func (m *Memory) Close() error {
 
 // Size returns the number of items in the cache (including expired).
 func (m *Memory) Size() int {
-	m.mu.RLock()
-	defer m.mu.RUnlock()
-	return len(m.items)
+	n := 0
+	for _, s := range m.shards {
+		s.mu.RLock()
+		n += len(s.items)
+		s.mu.RUnlock()
+	}
+	return n
+}
+
+// MemoryStats holds a Memory cache's size and counters.
+type MemoryStats struct {
+	Entries     int    `json:"entries"` // including expired
+	Bytes       int64  `json:"bytes"`   // keys and values
+	Hits        uint64 `json:"hits"`
+	Misses      uint64 `json:"misses"`
+	Evictions   uint64 `json:"evictions"`   // evicted to stay within budget
+	Rejections  uint64 `json:"rejections"`  // new entries not admitted
+	Expirations uint64 `json:"expirations"` // removed after their TTL
+}
+
+// Stats returns the cache's size and counters.
+func (m *Memory) Stats() MemoryStats {
+	st := MemoryStats{
+		Hits:        m.hits.Load(),
+		Misses:      m.misses.Load(),
+		Evictions:   m.evictions.Load(),
+		Rejections:  m.rejections.Load(),
+		Expirations: m.expirations.Load(),
+	}
+	for _, s := range m.shards {
+		s.mu.RLock()
+		st.Entries += len(s.items)
+		st.Bytes += s.bytes
+		s.mu.RUnlock()
+	}
+	return st
 }
 
 // GetMulti retrieves multiple values at once.
 func (m *Memory) GetMulti(ctx context.Context, keys []string) (map[string][]byte, error) {
-	m.mu.RLock()
-	defer m.mu.RUnlock()
-
-	if m.closed {
+	if m.closed.Load() {
 		return nil, ErrClosed
 	}
 
-	now := time.Now()
 	result := make(map[string][]byte, len(keys))
-
 	for _, key := range keys {
-		it, exists := m.items[key]
-		if !exists {
-			continue
+		if value, ok := m.get(key); ok {
+			result[key] = value
 		}
-		if !it.noExpiry && now.After(it.expiresAt) {
-			continue
-		}
-		// Copy value
-		val := make([]byte, len(it.value))
-		copy(val, it.value)
-		result[key] = val
 	}
 
 	return result, nil
@@ -218,27 +532,12 @@ func (m *Memory) GetMulti(ctx context.Context, keys []string) (map[string][]byte
 
 // SetMulti stores multiple values at once.
 func (m *Memory) SetMulti(ctx context.Context, items map[string][]byte, ttl time.Duration) error {
-	m.mu.Lock()
-	defer m.mu.Unlock()
-
-	if m.closed {
+	if m.closed.Load() {
 		return ErrClosed
 	}
 
-	var expiresAt time.Time
-	noExpiry := ttl <= 0
-	if !noExpiry {
-		expiresAt = time.Now().Add(ttl)
-	}
-
 	for key, value := range items {
-		valueCopy := make([]byte, len(value))
-		copy(valueCopy, value)
-		m.items[key] = &item{
-			value:     valueCopy,
-			expiresAt: expiresAt,
-			noExpiry:  noExpiry,
-		}
+		m.set(key, value, ttl, nil)
 	}
 
 	return nil
@@ -246,15 +545,34 @@ func (m *Memory) SetMulti(ctx context.Context, items map[string][]byte, ttl time
 
 // DeleteMulti removes multiple keys at once.
 func (m *Memory) DeleteMulti(ctx context.Context, keys []string) error {
-	m.mu.Lock()
-	defer m.mu.Unlock()
+	if m.closed.Load() {
+		return ErrClosed
+	}
+
+	for _, key := range keys {
+		m.delete(key)
+	}
 
-	if m.closed {
+	return nil
+}
+
+// InvalidateTags removes every entry stored with any of the tags.
+func (m *Memory) InvalidateTags(ctx context.Context, tags ...string) error {
+	if m.closed.Load() {
 		return ErrClosed
 	}
 
+	m.tagMu.Lock()
+	var keys []string
+	for _, tag := range tags {
+		for key := range m.tags[tag] {
+			keys = append(keys, key)
+		}
+	}
+	m.tagMu.Unlock()
+
 	for _, key := range keys {
-		delete(m.items, key)
+		m.delete(key)
 	}
 
 	return nil
@@ -279,13 +597,17 @@ func (m *Memory) cleanup(interval time.Duration) {
 
 // removeExpired removes all expired items.
 func (m *Memory) removeExpired() {
-	m.mu.Lock()
-	defer m.mu.Unlock()
-
 	now := time.Now()
-	for key, it := range m.items {
-		if !it.noExpiry && now.After(it.expiresAt) {
-			delete(m.items, key)
+	for _, s := range m.shards {
+		var ev []evicted
+		s.mu.Lock()
+		for _, it := range s.items {
+			if it.expired(now) {
+				m.removeItem(s, it)
+				ev = append(ev, evicted{key: it.key, value: it.value, reason: EvictionExpired})
+			}
 		}
+		s.mu.Unlock()
+		m.notify(ev)
 	}
 }
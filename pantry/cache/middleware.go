@@ -78,7 +78,11 @@ func Middleware(c Cache, cfg MiddlewareConfig) func(http.Handler) http.Handler {
 			}
 
 			if encoded, err := encodeCacheEntry(entry); err == nil {
-				c.Set(r.Context(), key, encoded, cfg.TTL)
+				var tags []string
+				if cfg.Tags != nil {
+					tags = cfg.Tags(r)
+				}
+				SetWithTags(r.Context(), c, key, encoded, cfg.TTL, tags...)
 			}
 		})
 	}
@@ -101,6 +105,12 @@ type MiddlewareConfig struct {
 
 	// CacheErrors caches non-2xx responses. Default: false.
 	CacheErrors bool
+
+	// Tags returns the tags to store a response with, for invalidation
+	// with InvalidateTags. It is called after the handler, so chi URL
+	// parameters are available. Ignored if the cache doesn't implement
+	// Tagger.
+	Tags func(r *http.Request) []string
 }
 
 // DefaultKeyFunc generates a cache key from method, path, and query string.
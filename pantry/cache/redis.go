@@ -4,6 +4,7 @@ package cache
 import (
 	"context"
 	"errors"
+	"strings"
 	"time"
 
 	"github.com/redis/go-redis/v9"
@@ -144,14 +145,18 @@ func (r *Redis) Get(ctx context.Context, key string) ([]byte, error) {
 	return result, nil
 }
 
-// Set stores a value with the given TTL.
+// Set stores a value with the given TTL, dropping any tags the key had.
 func (r *Redis) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
-	return r.client.Set(ctx, r.prefixKey(key), value, ttl).Err()
+	return r.untagged(ctx, []string{key}, func(pipe redis.Pipeliner) {
+		pipe.Set(ctx, r.prefixKey(key), value, ttl)
+	})
 }
 
-// Delete removes a key from the cache.
+// Delete removes a key from the cache and from its tags.
 func (r *Redis) Delete(ctx context.Context, key string) error {
-	return r.client.Del(ctx, r.prefixKey(key)).Err()
+	return r.untagged(ctx, []string{key}, func(pipe redis.Pipeliner) {
+		pipe.Del(ctx, r.prefixKey(key))
+	})
 }
 
 // Exists checks if a key exists.
@@ -228,34 +233,34 @@ func (r *Redis) GetMulti(ctx context.Context, keys []string) (map[string][]byte,
 	return result, nil
 }
 
-// SetMulti stores multiple values at once.
+// SetMulti stores multiple values at once, dropping any tags the keys had.
 func (r *Redis) SetMulti(ctx context.Context, items map[string][]byte, ttl time.Duration) error {
 	if len(items) == 0 {
 		return nil
 	}
 
-	pipe := r.client.Pipeline()
-
-	for key, value := range items {
-		pipe.Set(ctx, r.prefixKey(key), value, ttl)
+	keys := make([]string, 0, len(items))
+	for key := range items {
+		keys = append(keys, key)
 	}
 
-	_, err := pipe.Exec(ctx)
-	return err
+	return r.untagged(ctx, keys, func(pipe redis.Pipeliner) {
+		for key, value := range items {
+			pipe.Set(ctx, r.prefixKey(key), value, ttl)
+		}
+	})
 }
 
-// DeleteMulti removes multiple keys at once.
+// DeleteMulti removes multiple keys at once, and from their tags.
 func (r *Redis) DeleteMulti(ctx context.Context, keys []string) error {
 	if len(keys) == 0 {
 		return nil
 	}
-
-	prefixedKeys := make([]string, len(keys))
-	for i, key := range keys {
-		prefixedKeys[i] = r.prefixKey(key)
-	}
-
-	return r.client.Del(ctx, prefixedKeys...).Err()
+	return r.untagged(ctx, keys, func(pipe redis.Pipeliner) {
+		for _, key := range keys {
+			pipe.Del(ctx, r.prefixKey(key))
+		}
+	})
 }
 
 // SetNX sets a value only if the key doesn't exist.
@@ -302,6 +307,166 @@ func (r *Redis) Expire(ctx context.Context, key string, ttl time.Duration) error
 	return r.client.Expire(ctx, r.prefixKey(key), ttl).Err()
 }
 
+// tagNamespace starts the names of the tag sets, after the key prefix. Its
+// NUL byte keeps them apart from cache keys such as "tag:foo".
+const tagNamespace = "\x00"
+
+// tagKey returns the Redis key of the set holding a tag's keys.
+func (r *Redis) tagKey(tag string) string {
+	return r.prefixKey(tagNamespace + "tag:" + tag)
+}
+
+// keyTagsKey returns the Redis key of the set holding a key's tag keys.
+func (r *Redis) keyTagsKey(key string) string {
+	return r.prefixKey(tagNamespace + "tags:" + key)
+}
+
+// untagged runs write, a batch of plain SET or DEL commands on keys, and
+// then drops the keys from any tag sets they were in. Every command
+// touches a single key, so this works on Redis Cluster, but the cleanup
+// isn't atomic with the write and is best effort: a key left in a tag set
+// is only deleted again by InvalidateTags. Untagged keys cost no extra
+// round trip.
+func (r *Redis) untagged(ctx context.Context, keys []string, write func(pipe redis.Pipeliner)) error {
+	pipe := r.client.Pipeline()
+	write(pipe)
+	members := make([]*redis.StringSliceCmd, len(keys))
+	for i, key := range keys {
+		members[i] = pipe.SMembers(ctx, r.keyTagsKey(key))
+	}
+
+	cmds, err := pipe.Exec(ctx)
+	if len(cmds) == 0 {
+		return err
+	}
+	for _, cmd := range cmds[:len(cmds)-len(keys)] {
+		if err := cmd.Err(); err != nil {
+			return err
+		}
+	}
+
+	cleanup := r.client.Pipeline()
+	for i, key := range keys {
+		tags := members[i].Val()
+		if len(tags) == 0 {
+			continue
+		}
+		for _, tag := range tags {
+			cleanup.SRem(ctx, tag, r.prefixKey(key))
+		}
+		cleanup.Del(ctx, r.keyTagsKey(key))
+	}
+	if cleanup.Len() > 0 {
+		cleanup.Exec(ctx)
+	}
+	return nil
+}
+
+// setWithTagsScript sets KEYS[1] and adds it to the tag sets KEYS[3:].
+// KEYS[2] lists the key's tag sets, so it can be dropped from the ones it
+// leaves. A tag set lives as long as its longest-lived key. Expiries pass
+// ARGV[2] through as given, since some Lua implementations format large
+// numbers in exponent form.
+var setWithTagsScript = redis.NewScript(`
+local ttl = tonumber(ARGV[2])
+if ttl > 0 then
+	redis.call("SET", KEYS[1], ARGV[1], "PX", ARGV[2])
+else
+	redis.call("SET", KEYS[1], ARGV[1])
+end
+for _, tag in ipairs(redis.call("SMEMBERS", KEYS[2])) do
+	redis.call("SREM", tag, KEYS[1])
+end
+redis.call("DEL", KEYS[2])
+for i = 3, #KEYS do
+	local existed = redis.call("EXISTS", KEYS[i])
+	redis.call("SADD", KEYS[i], KEYS[1])
+	redis.call("SADD", KEYS[2], KEYS[i])
+	if ttl > 0 then
+		local remaining = redis.call("PTTL", KEYS[i])
+		if existed == 0 or (remaining >= 0 and remaining < ttl) then
+			redis.call("PEXPIRE", KEYS[i], ARGV[2])
+		end
+	else
+		redis.call("PERSIST", KEYS[i])
+	end
+end
+if ttl > 0 then
+	redis.call("PEXPIRE", KEYS[2], ARGV[2])
+end
+return 1
+`)
+
+// invalidateTagsScript deletes every key in the tag sets KEYS, drops those
+// keys from their other tag sets, and deletes the sets. Tag set members are
+// prefixed keys: ARGV[1] is the key prefix, stripped before appending a
+// key to ARGV[2], the prefix of the key-to-tags sets. Returns the keys
+// that were deleted.
+var invalidateTagsScript = redis.NewScript(`
+local deleted = {}
+for i = 1, #KEYS do
+	for _, key in ipairs(redis.call("SMEMBERS", KEYS[i])) do
+		local keyTags = ARGV[2] .. string.sub(key, #ARGV[1] + 1)
+		for _, tag in ipairs(redis.call("SMEMBERS", keyTags)) do
+			if tag ~= KEYS[i] then
+				redis.call("SREM", tag, key)
+			end
+		end
+		redis.call("DEL", key, keyTags)
+		table.insert(deleted, key)
+	end
+	redis.call("DEL", KEYS[i])
+end
+return deleted
+`)
+
+// SetWithTags stores a value with the given TTL and tags. The key and its
+// tag sets are updated atomically. The script touches keys in different hash
+// slots, so tags aren't supported on Redis Cluster.
+func (r *Redis) SetWithTags(ctx context.Context, key string, value []byte, ttl time.Duration, tags ...string) error {
+	if len(tags) == 0 {
+		return r.Set(ctx, key, value, ttl)
+	}
+
+	keys := make([]string, 0, len(tags)+2)
+	keys = append(keys, r.prefixKey(key), r.keyTagsKey(key))
+	for _, tag := range tags {
+		keys = append(keys, r.tagKey(tag))
+	}
+
+	return setWithTagsScript.Run(ctx, r.client, keys, value, ttl.Milliseconds()).Err()
+}
+
+// InvalidateTags atomically removes every entry stored with any of the tags.
+func (r *Redis) InvalidateTags(ctx context.Context, tags ...string) error {
+	_, err := r.invalidateTags(ctx, tags)
+	return err
+}
+
+// invalidateTags removes the entries stored with tags and returns their
+// (unprefixed) keys.
+func (r *Redis) invalidateTags(ctx context.Context, tags []string) ([]string, error) {
+	if len(tags) == 0 {
+		return nil, nil
+	}
+
+	tagKeys := make([]string, len(tags))
+	for i, tag := range tags {
+		tagKeys[i] = r.tagKey(tag)
+	}
+
+	members, err := invalidateTagsScript.Run(ctx, r.client, tagKeys, r.keyPrefix, r.keyTagsKey("")).StringSlice()
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]string, len(members))
+	for i, member := range members {
+		keys[i] = strings.TrimPrefix(member, r.keyPrefix)
+	}
+	return keys, nil
+}
+
 // Client returns the underlying Redis client for advanced operations.
 func (r *Redis) Client() redis.UniversalClient {
 	return r.client
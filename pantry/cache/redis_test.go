@@ -0,0 +1,190 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+)
+
+// newTestRedis connects to the server at REDIS_ADDR, if set, with a key
+// prefix of its own, and deletes the prefix's keys when the test ends.
+func newTestRedis(t *testing.T) *Redis {
+	t.Helper()
+	addr := os.Getenv("REDIS_ADDR")
+	if addr == "" {
+		t.Skip("REDIS_ADDR not set")
+	}
+
+	r, err := NewRedisWithConfig(RedisConfig{
+		Address:   addr,
+		KeyPrefix: fmt.Sprintf("cachetest:%d:", time.Now().UnixNano()),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		ctx := context.Background()
+		if keys, err := r.client.Keys(ctx, r.keyPrefix+"*").Result(); err == nil && len(keys) > 0 {
+			r.client.Del(ctx, keys...)
+		}
+		r.Close()
+	})
+	return r
+}
+
+// prefixKeys returns the Redis keys under r's prefix.
+func prefixKeys(t *testing.T, r *Redis) []string {
+	t.Helper()
+	keys, err := r.client.Keys(context.Background(), r.keyPrefix+"*").Result()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return keys
+}
+
+func TestRedisTags(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name string
+		// setup writes entries; the test then invalidates "t1".
+		setup func(r *Redis) error
+		gone  []string
+		kept  []string
+	}{
+		{
+			name: "invalidate",
+			setup: func(r *Redis) error {
+				return errors.Join(
+					r.SetWithTags(ctx, "a", []byte("1"), 0, "t1"),
+					r.SetWithTags(ctx, "b", []byte("1"), time.Hour, "t1", "t2"),
+					r.SetWithTags(ctx, "c", []byte("1"), 0, "t2"),
+					r.Set(ctx, "d", []byte("1"), 0),
+				)
+			},
+			gone: []string{"a", "b"},
+			kept: []string{"c", "d"},
+		},
+		{
+			name: "retag",
+			setup: func(r *Redis) error {
+				return errors.Join(
+					r.SetWithTags(ctx, "a", []byte("1"), 0, "t1"),
+					r.SetWithTags(ctx, "a", []byte("2"), 0, "t2"),
+				)
+			},
+			kept: []string{"a"},
+		},
+		{
+			name: "set drops tags",
+			setup: func(r *Redis) error {
+				return errors.Join(
+					r.SetWithTags(ctx, "a", []byte("1"), 0, "t1"),
+					r.Set(ctx, "a", []byte("2"), 0),
+				)
+			},
+			kept: []string{"a"},
+		},
+		{
+			name: "set multi drops tags",
+			setup: func(r *Redis) error {
+				return errors.Join(
+					r.SetWithTags(ctx, "a", []byte("1"), 0, "t1"),
+					r.SetWithTags(ctx, "b", []byte("1"), 0, "t1"),
+					r.SetMulti(ctx, map[string][]byte{"a": []byte("2")}, 0),
+				)
+			},
+			gone: []string{"b"},
+			kept: []string{"a"},
+		},
+		{
+			name: "tag names don't collide with keys",
+			setup: func(r *Redis) error {
+				return errors.Join(
+					r.Set(ctx, "tag:t1", []byte("1"), 0),
+					r.Set(ctx, "tags:a", []byte("1"), 0),
+					r.SetWithTags(ctx, "a", []byte("1"), 0, "t1"),
+				)
+			},
+			gone: []string{"a"},
+			kept: []string{"tag:t1", "tags:a"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newTestRedis(t)
+			if err := tt.setup(r); err != nil {
+				t.Fatal(err)
+			}
+			if err := r.InvalidateTags(ctx, "t1"); err != nil {
+				t.Fatal(err)
+			}
+			for _, key := range tt.gone {
+				if _, err := r.Get(ctx, key); !errors.Is(err, ErrNotFound) {
+					t.Errorf("Get(%q) = %v, want ErrNotFound", key, err)
+				}
+			}
+			for _, key := range tt.kept {
+				if _, err := r.Get(ctx, key); err != nil {
+					t.Errorf("Get(%q) = %v", key, err)
+				}
+			}
+		})
+	}
+}
+
+func TestRedisTagCleanup(t *testing.T) {
+	ctx := context.Background()
+	r := newTestRedis(t)
+
+	// Deleting or invalidating every key leaves no tag sets behind.
+	if err := errors.Join(
+		r.SetWithTags(ctx, "a", []byte("1"), 0, "t1", "t2"),
+		r.SetWithTags(ctx, "b", []byte("1"), 0, "t2"),
+		r.SetWithTags(ctx, "c", []byte("1"), 0, "t3"),
+		r.Delete(ctx, "a"),
+		r.DeleteMulti(ctx, []string{"c"}),
+		r.InvalidateTags(ctx, "t2"),
+	); err != nil {
+		t.Fatal(err)
+	}
+	if keys := prefixKeys(t, r); len(keys) != 0 {
+		t.Errorf("keys left: %q", keys)
+	}
+}
+
+func TestRedisTagExpiry(t *testing.T) {
+	ctx := context.Background()
+	r := newTestRedis(t)
+
+	// A tag set lives as long as its longest-lived key, and forever once
+	// it holds a key that doesn't expire.
+	if err := errors.Join(
+		r.SetWithTags(ctx, "a", []byte("1"), time.Hour, "t"),
+		r.SetWithTags(ctx, "b", []byte("1"), time.Minute, "t"),
+	); err != nil {
+		t.Fatal(err)
+	}
+	ttl, err := r.client.PTTL(ctx, r.tagKey("t")).Result()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ttl < 59*time.Minute || ttl > time.Hour {
+		t.Errorf("tag set TTL = %v, want about 1h", ttl)
+	}
+
+	if err := r.SetWithTags(ctx, "c", []byte("1"), 0, "t"); err != nil {
+		t.Fatal(err)
+	}
+	ttl, err = r.client.PTTL(ctx, r.tagKey("t")).Result()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ttl >= 0 {
+		t.Errorf("tag set TTL = %v, want none", ttl)
+	}
+}
@@ -0,0 +1,453 @@
+// cache/sql.go
+package cache
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SQLDialect selects SQL syntax for the SQL cache.
+type SQLDialect int
+
+const (
+	// DialectPostgres uses $n placeholders and BYTEA values.
+	DialectPostgres SQLDialect = iota
+
+	// DialectSQLite uses ? placeholders and BLOB values.
+	DialectSQLite
+
+	// DialectMySQL uses ? placeholders, LONGBLOB values and MySQL upserts.
+	DialectMySQL
+)
+
+// SQL implements a cache backed by a database/sql table, for deployments
+// without Redis. With SQLite it gives a persistent cache that survives
+// restarts and is shared by every process using the database file.
+//
+// Expiry times are stored as unix milliseconds; expired rows are ignored
+// on read and deleted by a background cleanup.
+type SQL struct {
+	db        *sql.DB
+	dialect   SQLDialect
+	table     string
+	batchSize int
+
+	mu      sync.Mutex
+	closed  bool
+	stopCh  chan struct{}
+	cleanCh chan struct{}
+}
+
+// SQLConfig configures the SQL cache.
+type SQLConfig struct {
+	// DB is the database handle. The cache doesn't close it.
+	DB *sql.DB
+
+	// Dialect selects PostgreSQL, MySQL or SQLite syntax.
+	// Default: DialectPostgres.
+	Dialect SQLDialect
+
+	// Table is the cache table name.
+	// Default: "cache_entries".
+	Table string
+
+	// CleanupInterval is how often to delete expired rows.
+	// Default: 1 minute. Set to a negative value to disable background
+	// cleanup (call DeleteExpired yourself, e.g. from a scheduled job).
+	CleanupInterval time.Duration
+
+	// BatchSize is the maximum number of keys per statement in GetMulti,
+	// SetMulti and DeleteMulti.
+	// Default: 500.
+	BatchSize int
+}
+
+// NewSQL creates a SQL-backed cache. Call CreateTable once (or apply
+// Schema as a migration) before use.
+func NewSQL(cfg SQLConfig) *SQL {
+	if cfg.Table == "" {
+		cfg.Table = "cache_entries"
+	}
+	if cfg.CleanupInterval == 0 {
+		cfg.CleanupInterval = time.Minute
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 500
+	}
+
+	s := &SQL{
+		db:        cfg.DB,
+		dialect:   cfg.Dialect,
+		table:     cfg.Table,
+		batchSize: cfg.BatchSize,
+		stopCh:    make(chan struct{}),
+		cleanCh:   make(chan struct{}),
+	}
+
+	if cfg.CleanupInterval > 0 {
+		go s.cleanup(cfg.CleanupInterval)
+	} else {
+		close(s.cleanCh)
+	}
+
+	return s
+}
+
+// NewSQLite creates a SQL cache using SQLite.
+func NewSQLite(db *sql.DB) *SQL {
+	return NewSQL(SQLConfig{DB: db, Dialect: DialectSQLite})
+}
+
+// NewPostgres creates a SQL cache using PostgreSQL.
+func NewPostgres(db *sql.DB) *SQL {
+	return NewSQL(SQLConfig{DB: db, Dialect: DialectPostgres})
+}
+
+// NewMySQL creates a SQL cache using MySQL.
+func NewMySQL(db *sql.DB) *SQL {
+	return NewSQL(SQLConfig{DB: db, Dialect: DialectMySQL})
+}
+
+// Schema returns the statements that create the cache table and its
+// expiry index, for use in migrations.
+func (s *SQL) Schema() []string {
+	valueType := "BYTEA"
+	switch s.dialect {
+	case DialectSQLite:
+		valueType = "BLOB"
+	case DialectMySQL:
+		valueType = "LONGBLOB"
+	}
+	columns := `
+			cache_key  VARCHAR(255) PRIMARY KEY,
+			value      ` + valueType + ` NOT NULL,
+			expires_at BIGINT`
+
+	// MySQL has no CREATE INDEX IF NOT EXISTS, so its index is declared
+	// with the table.
+	if s.dialect == DialectMySQL {
+		return []string{
+			`CREATE TABLE IF NOT EXISTS ` + s.table + ` (` + columns + `,
+			INDEX ` + s.table + `_expires_idx (expires_at)
+		)`,
+		}
+	}
+	return []string{
+		`CREATE TABLE IF NOT EXISTS ` + s.table + ` (` + columns + `
+		)`,
+		`CREATE INDEX IF NOT EXISTS ` + s.table + `_expires_idx ON ` + s.table + ` (expires_at)`,
+	}
+}
+
+// CreateTable creates the cache table if it doesn't exist.
+func (s *SQL) CreateTable(ctx context.Context) error {
+	for _, q := range s.Schema() {
+		if _, err := s.db.ExecContext(ctx, q); err != nil {
+			return fmt.Errorf("cache: failed to create cache table: %w", err)
+		}
+	}
+	return nil
+}
+
+// rebind converts ? placeholders to $n for PostgreSQL.
+func (s *SQL) rebind(q string) string {
+	if s.dialect != DialectPostgres {
+		return q
+	}
+	var b strings.Builder
+	n := 0
+	for _, r := range q {
+		if r == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// expiresAt converts a TTL to the stored expiry, nil meaning never.
+func expiresAt(ttl time.Duration) any {
+	if ttl <= 0 {
+		return nil
+	}
+	return time.Now().Add(ttl).UnixMilli()
+}
+
+// maxKeyLen is the length of the cache_key column.
+const maxKeyLen = 255
+
+// hashedKeyPrefix starts the stored form of hashed keys.
+const hashedKeyPrefix = "sha256:"
+
+// storedKey returns the cache_key of key. Keys longer than the column, and
+// keys that start with hashedKeyPrefix, are stored as the prefix and their
+// SHA-256, so any key fits and a hashed key can't collide with a plain one.
+func storedKey(key string) string {
+	if len(key) <= maxKeyLen && !strings.HasPrefix(key, hashedKeyPrefix) {
+		return key
+	}
+	sum := sha256.Sum256([]byte(key))
+	return hashedKeyPrefix + hex.EncodeToString(sum[:])
+}
+
+// nonNil keeps empty values from being stored as NULL.
+func nonNil(value []byte) []byte {
+	if value == nil {
+		return []byte{}
+	}
+	return value
+}
+
+// placeholders returns n comma-separated groups of group.
+func placeholders(n int, group string) string {
+	return strings.TrimSuffix(strings.Repeat(group+", ", n), ", ")
+}
+
+// upsert returns an INSERT of n rows that replaces existing keys.
+func (s *SQL) upsert(n int) string {
+	q := `INSERT INTO ` + s.table + ` (cache_key, value, expires_at) VALUES ` + placeholders(n, "(?, ?, ?)")
+	if s.dialect == DialectMySQL {
+		return q + ` ON DUPLICATE KEY UPDATE value = VALUES(value), expires_at = VALUES(expires_at)`
+	}
+	return s.rebind(q + ` ON CONFLICT (cache_key) DO UPDATE SET value = excluded.value, expires_at = excluded.expires_at`)
+}
+
+func (s *SQL) isClosed() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.closed
+}
+
+// Get retrieves a value by key.
+func (s *SQL) Get(ctx context.Context, key string) ([]byte, error) {
+	if s.isClosed() {
+		return nil, ErrClosed
+	}
+
+	var value []byte
+	err := s.db.QueryRowContext(ctx, s.rebind(`SELECT value FROM `+s.table+`
+		WHERE cache_key = ? AND (expires_at IS NULL OR expires_at > ?)`),
+		storedKey(key), time.Now().UnixMilli()).Scan(&value)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+// Set stores a value with the given TTL.
+func (s *SQL) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	if s.isClosed() {
+		return ErrClosed
+	}
+
+	_, err := s.db.ExecContext(ctx, s.upsert(1), storedKey(key), nonNil(value), expiresAt(ttl))
+	return err
+}
+
+// Delete removes a key from the cache.
+func (s *SQL) Delete(ctx context.Context, key string) error {
+	if s.isClosed() {
+		return ErrClosed
+	}
+
+	_, err := s.db.ExecContext(ctx, s.rebind(`DELETE FROM `+s.table+` WHERE cache_key = ?`), storedKey(key))
+	return err
+}
+
+// Exists checks if a key exists and is not expired.
+func (s *SQL) Exists(ctx context.Context, key string) (bool, error) {
+	if s.isClosed() {
+		return false, ErrClosed
+	}
+
+	var one int
+	err := s.db.QueryRowContext(ctx, s.rebind(`SELECT 1 FROM `+s.table+`
+		WHERE cache_key = ? AND (expires_at IS NULL OR expires_at > ?)`),
+		storedKey(key), time.Now().UnixMilli()).Scan(&one)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Clear removes all entries from the cache.
+func (s *SQL) Clear(ctx context.Context) error {
+	if s.isClosed() {
+		return ErrClosed
+	}
+
+	_, err := s.db.ExecContext(ctx, `DELETE FROM `+s.table)
+	return err
+}
+
+// Close stops the cleanup goroutine. It doesn't close the database.
+func (s *SQL) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return nil
+	}
+
+	s.closed = true
+	close(s.stopCh)
+	<-s.cleanCh // Wait for cleanup goroutine to stop
+	return nil
+}
+
+// batches splits keys into slices of at most batchSize.
+func (s *SQL) batches(keys []string) [][]string {
+	var out [][]string
+	for len(keys) > s.batchSize {
+		out = append(out, keys[:s.batchSize])
+		keys = keys[s.batchSize:]
+	}
+	if len(keys) > 0 {
+		out = append(out, keys)
+	}
+	return out
+}
+
+// GetMulti retrieves multiple values, batchSize keys per query.
+func (s *SQL) GetMulti(ctx context.Context, keys []string) (map[string][]byte, error) {
+	if s.isClosed() {
+		return nil, ErrClosed
+	}
+
+	result := make(map[string][]byte, len(keys))
+	now := time.Now().UnixMilli()
+
+	for _, batch := range s.batches(keys) {
+		args := make([]any, 0, len(batch)+1)
+		stored := make(map[string]string, len(batch))
+		for _, key := range batch {
+			sk := storedKey(key)
+			stored[sk] = key
+			args = append(args, sk)
+		}
+		args = append(args, now)
+
+		rows, err := s.db.QueryContext(ctx, s.rebind(`SELECT cache_key, value FROM `+s.table+`
+			WHERE cache_key IN (`+placeholders(len(batch), "?")+`) AND (expires_at IS NULL OR expires_at > ?)`),
+			args...)
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			var key string
+			var value []byte
+			if err := rows.Scan(&key, &value); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			result[stored[key]] = value
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return result, nil
+}
+
+// SetMulti stores multiple values in one transaction, batchSize rows per
+// statement.
+func (s *SQL) SetMulti(ctx context.Context, items map[string][]byte, ttl time.Duration) error {
+	if s.isClosed() {
+		return ErrClosed
+	}
+	if len(items) == 0 {
+		return nil
+	}
+
+	keys := make([]string, 0, len(items))
+	for key := range items {
+		keys = append(keys, key)
+	}
+	expires := expiresAt(ttl)
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, batch := range s.batches(keys) {
+		args := make([]any, 0, len(batch)*3)
+		for _, key := range batch {
+			args = append(args, storedKey(key), nonNil(items[key]), expires)
+		}
+		if _, err := tx.ExecContext(ctx, s.upsert(len(batch)), args...); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// DeleteMulti removes multiple keys, batchSize keys per statement.
+func (s *SQL) DeleteMulti(ctx context.Context, keys []string) error {
+	if s.isClosed() {
+		return ErrClosed
+	}
+
+	for _, batch := range s.batches(keys) {
+		args := make([]any, len(batch))
+		for i, key := range batch {
+			args[i] = storedKey(key)
+		}
+		if _, err := s.db.ExecContext(ctx, s.rebind(`DELETE FROM `+s.table+`
+			WHERE cache_key IN (`+placeholders(len(batch), "?")+`)`), args...); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// DeleteExpired deletes expired rows and returns how many were deleted.
+func (s *SQL) DeleteExpired(ctx context.Context) (int64, error) {
+	res, err := s.db.ExecContext(ctx, s.rebind(`DELETE FROM `+s.table+`
+		WHERE expires_at IS NOT NULL AND expires_at <= ?`), time.Now().UnixMilli())
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// cleanup periodically deletes expired rows.
+func (s *SQL) cleanup(interval time.Duration) {
+	defer close(s.cleanCh)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stopCh:
+			return
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), interval)
+			s.DeleteExpired(ctx)
+			cancel()
+		}
+	}
+}
@@ -0,0 +1,320 @@
+// cache/stampede.go
+package cache
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// ErrComputeFailed is returned by GetOrSet while a compute error cached
+// with WithErrorTTL is live. The original error message is appended.
+var ErrComputeFailed = errors.New("cache: compute failed")
+
+// GetOrSetOption configures GetOrSet and GetOrSetJSON.
+type GetOrSetOption func(*getOrSetOptions)
+
+type getOrSetOptions struct {
+	lock     *Redis
+	lockTTL  time.Duration
+	softTTL  time.Duration
+	beta     float64
+	errorTTL time.Duration
+}
+
+// lockPollInterval is how often callers waiting on another instance's
+// lock check whether the value has been stored.
+const lockPollInterval = 50 * time.Millisecond
+
+// loadTimeout bounds the cache and lock calls of a shared load, on top of
+// the time spent waiting for another instance's lock. A shared load runs
+// detached from its callers' contexts, so one canceled request doesn't
+// fail the others.
+const loadTimeout = 30 * time.Second
+
+// WithLock coalesces misses across instances: the caller that takes a
+// short lock in r (with SetNX) computes the value, and callers on other
+// instances wait for it to be stored, for up to ttl. If the value doesn't
+// appear in time, they compute it themselves. ttl should exceed the time
+// compute usually takes. Default ttl: 10 seconds.
+func WithLock(r *Redis, ttl time.Duration) GetOrSetOption {
+	return func(o *getOrSetOptions) {
+		if ttl <= 0 {
+			ttl = 10 * time.Second
+		}
+		o.lock = r
+		o.lockTTL = ttl
+	}
+}
+
+// WithSoftTTL makes a value stale after soft, while it stays in the cache
+// for the full TTL. A stale value is returned immediately and one caller
+// refreshes it in the background (stale-while-revalidate). If the refresh
+// fails, the stale value keeps being served until it expires.
+func WithSoftTTL(soft time.Duration) GetOrSetOption {
+	return func(o *getOrSetOptions) {
+		o.softTTL = soft
+	}
+}
+
+// WithEarlyExpiration refreshes values probabilistically before they
+// expire (or become stale, with WithSoftTTL), so a hot key is usually
+// recomputed by a single caller before it misses. The chance of an early
+// refresh grows as expiry nears and with how long compute took. beta
+// scales it: 1 is a good default, larger values refresh earlier. The
+// refresh runs in the background while the current value is returned.
+func WithEarlyExpiration(beta float64) GetOrSetOption {
+	return func(o *getOrSetOptions) {
+		o.beta = beta
+	}
+}
+
+// WithErrorTTL caches compute errors for d, so a failing backend isn't
+// hit by every caller. While the error is cached, GetOrSet returns an
+// error wrapping ErrComputeFailed without calling compute.
+func WithErrorTTL(d time.Duration) GetOrSetOption {
+	return func(o *getOrSetOptions) {
+		o.errorTTL = d
+	}
+}
+
+// enveloped reports whether values need refresh metadata stored with them.
+func (o *getOrSetOptions) enveloped() bool {
+	return o.softTTL > 0 || o.beta > 0
+}
+
+var (
+	flights    singleflight.Group
+	refreshing sync.Map // flight keys with a background refresh running
+)
+
+// flightKey identifies a key in a specific cache.
+func flightKey(c Cache, key string) string {
+	return fmt.Sprintf("%p:%s", c, key)
+}
+
+// getOrSet implements GetOrSet.
+func getOrSet(ctx context.Context, c Cache, key string, ttl time.Duration, compute func() ([]byte, error), o *getOrSetOptions) ([]byte, error) {
+	data, err := c.Get(ctx, key)
+	if err == nil {
+		e := decodeEntry(data)
+		if e.failed {
+			return nil, fmt.Errorf("%w: %s", ErrComputeFailed, e.value)
+		}
+		if e.due(time.Now(), o.beta) {
+			refreshInBackground(ctx, c, key, ttl, compute, o)
+		}
+		return e.value, nil
+	}
+	if !errors.Is(err, ErrNotFound) {
+		return nil, err
+	}
+
+	// Concurrent misses in this process share one load. Each caller stops
+	// waiting when its own context ends.
+	ch := flights.DoChan(flightKey(c, key), func() (any, error) {
+		lctx, cancel := detached(ctx, o)
+		defer cancel()
+		return load(lctx, c, key, ttl, compute, o, false)
+	})
+	var res singleflight.Result
+	select {
+	case res = <-ch:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	if res.Err != nil {
+		return nil, res.Err
+	}
+	value := res.Val.([]byte)
+	result := make([]byte, len(value))
+	copy(result, value)
+	return result, nil
+}
+
+// refreshInBackground recomputes a stale or soon-to-expire value, unless
+// a refresh of the key is already running in this process.
+func refreshInBackground(ctx context.Context, c Cache, key string, ttl time.Duration, compute func() ([]byte, error), o *getOrSetOptions) {
+	fk := flightKey(c, key)
+	if _, running := refreshing.LoadOrStore(fk, struct{}{}); running {
+		return
+	}
+
+	go func() {
+		defer refreshing.Delete(fk)
+		flights.Do(fk, func() (any, error) {
+			lctx, cancel := detached(ctx, o)
+			defer cancel()
+			return load(lctx, c, key, ttl, compute, o, true)
+		})
+	}()
+}
+
+// detached returns a context for a shared load: it keeps ctx's values but
+// not its cancellation, and times out after loadTimeout plus the lock wait.
+func detached(ctx context.Context, o *getOrSetOptions) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.WithoutCancel(ctx), loadTimeout+o.lockTTL)
+}
+
+// load computes and stores a value, coordinating with other instances if
+// WithLock is set. A background refresh gives up if another instance holds
+// the lock, and doesn't cache errors over the stale value.
+func load(ctx context.Context, c Cache, key string, ttl time.Duration, compute func() ([]byte, error), o *getOrSetOptions, background bool) ([]byte, error) {
+	if o.lock != nil {
+		token := fmt.Sprintf("%016x", rand.Uint64())
+		lockKey := "lock:" + key
+		acquired, err := o.lock.SetNX(ctx, lockKey, []byte(token), o.lockTTL)
+		if err == nil && acquired {
+			defer releaseLock(context.WithoutCancel(ctx), o.lock, lockKey, token)
+		} else if err == nil {
+			if background {
+				return nil, nil
+			}
+			if value, err, ok := waitForValue(ctx, c, key, o.lockTTL); ok {
+				return value, err
+			}
+		}
+		// On lock errors or timeout, compute without the lock
+	}
+
+	start := time.Now()
+	value, err := compute()
+	delta := time.Since(start)
+
+	if err != nil {
+		if o.errorTTL > 0 && !background {
+			c.Set(ctx, key, encodeEntry(entry{failed: true, value: []byte(err.Error())}), o.errorTTL)
+		}
+		return nil, err
+	}
+
+	stored := value
+	if o.enveloped() {
+		e := entry{value: value, delta: delta}
+		switch {
+		case o.softTTL > 0:
+			e.refreshAt = time.Now().Add(o.softTTL)
+		case ttl > 0:
+			e.refreshAt = time.Now().Add(ttl)
+		}
+		stored = encodeEntry(e)
+	}
+	if err := c.Set(ctx, key, stored, ttl); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+// waitForValue polls the cache while another instance computes key. ok is
+// false if the value didn't appear within wait.
+func waitForValue(ctx context.Context, c Cache, key string, wait time.Duration) ([]byte, error, bool) {
+	ticker := time.NewTicker(lockPollInterval)
+	defer ticker.Stop()
+	deadline := time.After(wait)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err(), true
+		case <-deadline:
+			return nil, nil, false
+		case <-ticker.C:
+		}
+
+		data, err := c.Get(ctx, key)
+		if errors.Is(err, ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err, true
+		}
+		e := decodeEntry(data)
+		if e.failed {
+			return nil, fmt.Errorf("%w: %s", ErrComputeFailed, e.value), true
+		}
+		return e.value, nil, true
+	}
+}
+
+// releaseLockScript deletes a lock only if it still holds our token.
+const releaseLockScript = `
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`
+
+func releaseLock(ctx context.Context, r *Redis, lockKey, token string) {
+	r.client.Eval(ctx, releaseLockScript, []string{r.prefixKey(lockKey)}, token)
+}
+
+// entryMagic marks values stored with refresh metadata or cached errors.
+var entryMagic = []byte("\x00wfc\x01")
+
+const entryHeaderLen = 5 + 1 + 8 + 8 // magic, flags, refreshAt, delta
+
+// entry is a value read by GetOrSet, with its refresh metadata if any.
+type entry struct {
+	value     []byte
+	failed    bool          // value is a cached error message
+	refreshAt time.Time     // when the value becomes stale; zero if never
+	delta     time.Duration // how long compute took
+}
+
+func encodeEntry(e entry) []byte {
+	buf := make([]byte, entryHeaderLen, entryHeaderLen+len(e.value))
+	copy(buf, entryMagic)
+	if e.failed {
+		buf[5] = 1
+	}
+	var refreshAt int64
+	if !e.refreshAt.IsZero() {
+		refreshAt = e.refreshAt.UnixNano()
+	}
+	binary.BigEndian.PutUint64(buf[6:], uint64(refreshAt))
+	binary.BigEndian.PutUint64(buf[14:], uint64(e.delta))
+	return append(buf, e.value...)
+}
+
+// decodeEntry parses a stored value. Values without the header are
+// returned as they are.
+func decodeEntry(data []byte) entry {
+	if len(data) < entryHeaderLen || !bytes.HasPrefix(data, entryMagic) {
+		return entry{value: data}
+	}
+	e := entry{
+		value:  data[entryHeaderLen:],
+		failed: data[5] == 1,
+		delta:  time.Duration(binary.BigEndian.Uint64(data[14:])),
+	}
+	if ns := int64(binary.BigEndian.Uint64(data[6:])); ns != 0 {
+		e.refreshAt = time.Unix(0, ns)
+	}
+	return e
+}
+
+// due reports whether a value should be refreshed: it is stale, or an
+// early expiration was drawn (the XFetch algorithm).
+func (e entry) due(now time.Time, beta float64) bool {
+	if e.refreshAt.IsZero() {
+		return false
+	}
+	if !now.Before(e.refreshAt) {
+		return true
+	}
+	if beta <= 0 || e.delta <= 0 {
+		return false
+	}
+	// -log(rand) is exponentially distributed, so the refresh is pulled
+	// forward by a random multiple of the compute time.
+	early := time.Duration(float64(e.delta) * beta * -math.Log(1-rand.Float64()))
+	return !now.Add(early).Before(e.refreshAt)
+}
@@ -0,0 +1,553 @@
+// cache/tiered.go
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+// Tiered is a two-tier cache: an in-process Memory cache (L1) in front of a
+// Redis cache (L2). Reads are served from L1 when possible and fall back to
+// L2, filling L1 on the way. Set, Delete and Clear write through to both
+// tiers and publish an invalidation over Redis pub/sub, so every other
+// instance evicts the affected keys from its L1.
+//
+// Pub/sub delivery is best effort: messages published while an instance is
+// disconnected from Redis are lost. L1TTL bounds how long such an instance
+// can serve a stale value.
+type Tiered struct {
+	l1       *Memory
+	l2       *Redis
+	neg      *Memory // negative (miss) entries, kept apart from L1 values
+	id       string
+	channel  string
+	l1TTL    time.Duration
+	negTTL   time.Duration
+	jitter   float64
+	logger   *zap.Logger
+	pubsub   *redis.PubSub
+	subDone  chan struct{}
+	closeMu  sync.Mutex
+	closed   bool
+	gen      atomic.Uint64 // bumped whenever L1 entries change
+	l1Hits   atomic.Uint64
+	l2Hits   atomic.Uint64
+	negHits  atomic.Uint64
+	misses   atomic.Uint64
+	received atomic.Uint64
+}
+
+// TieredConfig configures a two-tier cache.
+type TieredConfig struct {
+	// L2 is the shared Redis cache. Required.
+	L2 *Redis
+
+	// L1 is the in-process cache.
+	// Default: NewMemory().
+	L1 *Memory
+
+	// Channel is the Redis pub/sub channel used for invalidations. All
+	// instances sharing L2 must use the same channel.
+	// Default: "cache:invalidate".
+	Channel string
+
+	// L1TTL caps how long an entry lives in L1, whatever its TTL in L2.
+	// It bounds staleness if an invalidation is missed.
+	// Default: 1 minute.
+	L1TTL time.Duration
+
+	// Jitter randomizes TTLs by up to this fraction (0.1 means ±10%), so
+	// keys set together don't all expire together.
+	// Default: 0 (no jitter).
+	Jitter float64
+
+	// NegativeTTL caches misses in L1 for this long, so repeated lookups
+	// of absent keys don't reach Redis. Setting the key on any instance
+	// clears the negative entry.
+	// Default: 0 (disabled).
+	NegativeTTL time.Duration
+
+	// Logger for subscription errors. Default: no-op logger.
+	Logger *zap.Logger
+}
+
+// TieredStats holds per-tier hit counters.
+type TieredStats struct {
+	L1Hits        uint64 `json:"l1_hits"`
+	L2Hits        uint64 `json:"l2_hits"`
+	NegativeHits  uint64 `json:"negative_hits"`
+	Misses        uint64 `json:"misses"`
+	Invalidations uint64 `json:"invalidations"` // messages received from other instances
+}
+
+// invalidation is the pub/sub message sent on Set, Delete and Clear.
+type invalidation struct {
+	Source string   `json:"src"`
+	Keys   []string `json:"keys,omitempty"`
+	Clear  bool     `json:"clear,omitempty"`
+}
+
+// NewTiered creates a two-tier cache and subscribes to invalidations.
+func NewTiered(cfg TieredConfig) (*Tiered, error) {
+	if cfg.L2 == nil {
+		return nil, errors.New("cache: tiered cache requires an L2 redis cache")
+	}
+	if cfg.L1 == nil {
+		cfg.L1 = NewMemory()
+	}
+	if cfg.Channel == "" {
+		cfg.Channel = "cache:invalidate"
+	}
+	if cfg.L1TTL <= 0 {
+		cfg.L1TTL = time.Minute
+	}
+	if cfg.Jitter < 0 {
+		cfg.Jitter = 0
+	}
+	if cfg.Logger == nil {
+		cfg.Logger = zap.NewNop()
+	}
+
+	t := &Tiered{
+		l1:      cfg.L1,
+		l2:      cfg.L2,
+		neg:     NewMemory(),
+		id:      instanceID(),
+		channel: cfg.Channel,
+		l1TTL:   cfg.L1TTL,
+		negTTL:  cfg.NegativeTTL,
+		jitter:  cfg.Jitter,
+		logger:  cfg.Logger.With(zap.String("channel", cfg.Channel)),
+		subDone: make(chan struct{}),
+	}
+
+	// Wait for the subscription to be confirmed so no invalidation
+	// published after NewTiered returns is missed.
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	t.pubsub = cfg.L2.client.Subscribe(ctx, cfg.Channel)
+	if _, err := t.pubsub.Receive(ctx); err != nil {
+		t.pubsub.Close()
+		t.neg.Close()
+		return nil, err
+	}
+
+	go t.subscribe()
+	return t, nil
+}
+
+// instanceID generates an ID identifying this instance's invalidations.
+func instanceID() string {
+	return fmt.Sprintf("%016x", rand.Uint64())
+}
+
+// subscribe applies invalidations published by other instances.
+func (t *Tiered) subscribe() {
+	defer close(t.subDone)
+
+	for msg := range t.pubsub.Channel() {
+		var inv invalidation
+		if err := json.Unmarshal([]byte(msg.Payload), &inv); err != nil {
+			t.logger.Warn("invalid cache invalidation message", zap.Error(err))
+			continue
+		}
+		if inv.Source == t.id {
+			continue
+		}
+		t.received.Add(1)
+		ctx := context.Background()
+		if inv.Clear {
+			t.evictAll(ctx)
+		} else {
+			t.evict(ctx, inv.Keys)
+		}
+	}
+}
+
+// evict removes keys from L1 and the negative cache.
+func (t *Tiered) evict(ctx context.Context, keys []string) {
+	t.gen.Add(1)
+	t.l1.DeleteMulti(ctx, keys)
+	t.neg.DeleteMulti(ctx, keys)
+}
+
+// evictAll empties L1 and the negative cache.
+func (t *Tiered) evictAll(ctx context.Context) {
+	t.gen.Add(1)
+	t.l1.Clear(ctx)
+	t.neg.Clear(ctx)
+}
+
+// publish tells other instances to evict keys (or everything) from L1.
+func (t *Tiered) publish(ctx context.Context, inv invalidation) error {
+	inv.Source = t.id
+	data, err := json.Marshal(inv)
+	if err != nil {
+		return err
+	}
+	return t.l2.client.Publish(ctx, t.channel, data).Err()
+}
+
+// jittered randomizes ttl by up to ±Jitter.
+func (t *Tiered) jittered(ttl time.Duration) time.Duration {
+	if ttl <= 0 || t.jitter == 0 {
+		return ttl
+	}
+	delta := time.Duration((rand.Float64()*2 - 1) * t.jitter * float64(ttl))
+	if ttl+delta <= 0 {
+		return ttl
+	}
+	return ttl + delta
+}
+
+// localTTL returns the L1 TTL for an entry that lives for ttl in L2
+// (0 meaning forever).
+func (t *Tiered) localTTL(ttl time.Duration) time.Duration {
+	if ttl <= 0 || ttl > t.l1TTL {
+		return t.l1TTL
+	}
+	return ttl
+}
+
+// fetch reads keys from L2 along with their remaining TTLs.
+func (t *Tiered) fetch(ctx context.Context, keys []string) (map[string][]byte, map[string]time.Duration, error) {
+	pipe := t.l2.client.Pipeline()
+	gets := make([]*redis.StringCmd, len(keys))
+	ttls := make([]*redis.DurationCmd, len(keys))
+	for i, key := range keys {
+		gets[i] = pipe.Get(ctx, t.l2.prefixKey(key))
+		ttls[i] = pipe.PTTL(ctx, t.l2.prefixKey(key))
+	}
+	if _, err := pipe.Exec(ctx); err != nil && !errors.Is(err, redis.Nil) {
+		return nil, nil, err
+	}
+
+	values := make(map[string][]byte, len(keys))
+	remaining := make(map[string]time.Duration, len(keys))
+	for i, key := range keys {
+		val, err := gets[i].Bytes()
+		if err != nil {
+			continue
+		}
+		values[key] = val
+		remaining[key] = ttls[i].Val() // negative if the key has no TTL
+	}
+	return values, remaining, nil
+}
+
+// fill stores values read from L2 in L1, unless an invalidation arrived
+// since gen was read, in which case they may already be stale.
+func (t *Tiered) fill(ctx context.Context, gen uint64, values map[string][]byte, remaining map[string]time.Duration) {
+	for key, val := range values {
+		if t.gen.Load() != gen {
+			return
+		}
+		t.l1.Set(ctx, key, val, t.localTTL(remaining[key]))
+	}
+}
+
+// Get retrieves a value by key from L1, then L2.
+func (t *Tiered) Get(ctx context.Context, key string) ([]byte, error) {
+	if t.isClosed() {
+		return nil, ErrClosed
+	}
+
+	if val, err := t.l1.Get(ctx, key); err == nil {
+		t.l1Hits.Add(1)
+		return val, nil
+	}
+	if t.negTTL > 0 {
+		if ok, _ := t.neg.Exists(ctx, key); ok {
+			t.negHits.Add(1)
+			return nil, ErrNotFound
+		}
+	}
+
+	gen := t.gen.Load()
+	values, remaining, err := t.fetch(ctx, []string{key})
+	if err != nil {
+		return nil, err
+	}
+	val, ok := values[key]
+	if !ok {
+		t.misses.Add(1)
+		t.remember(ctx, gen, []string{key})
+		return nil, ErrNotFound
+	}
+
+	t.l2Hits.Add(1)
+	t.fill(ctx, gen, values, remaining)
+	return val, nil
+}
+
+// remember records misses in the negative cache, unless an invalidation
+// arrived since gen was read.
+func (t *Tiered) remember(ctx context.Context, gen uint64, keys []string) {
+	if t.negTTL <= 0 || len(keys) == 0 || t.gen.Load() != gen {
+		return
+	}
+	items := make(map[string][]byte, len(keys))
+	for _, key := range keys {
+		items[key] = nil
+	}
+	t.neg.SetMulti(ctx, items, t.jittered(t.negTTL))
+}
+
+// Set stores a value in both tiers and invalidates it on other instances.
+func (t *Tiered) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	if t.isClosed() {
+		return ErrClosed
+	}
+
+	ttl = t.jittered(ttl)
+	if err := t.l2.Set(ctx, key, value, ttl); err != nil {
+		return err
+	}
+	t.gen.Add(1)
+	t.neg.Delete(ctx, key)
+	t.l1.Set(ctx, key, value, t.localTTL(ttl))
+	return t.publish(ctx, invalidation{Keys: []string{key}})
+}
+
+// Delete removes a key from both tiers and from L1 on other instances.
+func (t *Tiered) Delete(ctx context.Context, key string) error {
+	if t.isClosed() {
+		return ErrClosed
+	}
+
+	if err := t.l2.Delete(ctx, key); err != nil {
+		return err
+	}
+	t.evict(ctx, []string{key})
+	return t.publish(ctx, invalidation{Keys: []string{key}})
+}
+
+// Exists checks if a key exists in L1 or L2.
+func (t *Tiered) Exists(ctx context.Context, key string) (bool, error) {
+	if t.isClosed() {
+		return false, ErrClosed
+	}
+
+	if ok, _ := t.l1.Exists(ctx, key); ok {
+		return true, nil
+	}
+	if t.negTTL > 0 {
+		if ok, _ := t.neg.Exists(ctx, key); ok {
+			return false, nil
+		}
+	}
+	return t.l2.Exists(ctx, key)
+}
+
+// Clear removes all entries from both tiers and from L1 on other instances.
+func (t *Tiered) Clear(ctx context.Context) error {
+	if t.isClosed() {
+		return ErrClosed
+	}
+
+	if err := t.l2.Clear(ctx); err != nil {
+		return err
+	}
+	t.evictAll(ctx)
+	return t.publish(ctx, invalidation{Clear: true})
+}
+
+// Close unsubscribes from invalidations and closes both tiers.
+func (t *Tiered) Close() error {
+	t.closeMu.Lock()
+	if t.closed {
+		t.closeMu.Unlock()
+		return nil
+	}
+	t.closed = true
+	t.closeMu.Unlock()
+
+	err := t.pubsub.Close()
+	<-t.subDone
+	t.neg.Close()
+	t.l1.Close()
+	if cerr := t.l2.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+func (t *Tiered) isClosed() bool {
+	t.closeMu.Lock()
+	defer t.closeMu.Unlock()
+	return t.closed
+}
+
+// GetMulti retrieves multiple values, reading from L2 only the keys
+// missing from L1.
+func (t *Tiered) GetMulti(ctx context.Context, keys []string) (map[string][]byte, error) {
+	if t.isClosed() {
+		return nil, ErrClosed
+	}
+
+	result, err := t.l1.GetMulti(ctx, keys)
+	if err != nil {
+		return nil, err
+	}
+	t.l1Hits.Add(uint64(len(result)))
+
+	var missing []string
+	for _, key := range keys {
+		if _, ok := result[key]; ok {
+			continue
+		}
+		if t.negTTL > 0 {
+			if ok, _ := t.neg.Exists(ctx, key); ok {
+				t.negHits.Add(1)
+				continue
+			}
+		}
+		missing = append(missing, key)
+	}
+	if len(missing) == 0 {
+		return result, nil
+	}
+
+	gen := t.gen.Load()
+	values, remaining, err := t.fetch(ctx, missing)
+	if err != nil {
+		return nil, err
+	}
+
+	var absent []string
+	for _, key := range missing {
+		if val, ok := values[key]; ok {
+			result[key] = val
+		} else {
+			absent = append(absent, key)
+		}
+	}
+	t.l2Hits.Add(uint64(len(values)))
+	t.misses.Add(uint64(len(absent)))
+	t.fill(ctx, gen, values, remaining)
+	t.remember(ctx, gen, absent)
+	return result, nil
+}
+
+// SetMulti stores multiple values in both tiers and invalidates them on
+// other instances. Each key gets its own jittered TTL, and like Set drops
+// any tags it had.
+func (t *Tiered) SetMulti(ctx context.Context, items map[string][]byte, ttl time.Duration) error {
+	if t.isClosed() {
+		return ErrClosed
+	}
+	if len(items) == 0 {
+		return nil
+	}
+
+	ttls := make(map[string]time.Duration, len(items))
+	keys := make([]string, 0, len(items))
+	for key := range items {
+		ttls[key] = t.jittered(ttl)
+		keys = append(keys, key)
+	}
+	err := t.l2.untagged(ctx, keys, func(pipe redis.Pipeliner) {
+		for key, value := range items {
+			pipe.Set(ctx, t.l2.prefixKey(key), value, ttls[key])
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	t.gen.Add(1)
+	t.neg.DeleteMulti(ctx, keys)
+	for key, value := range items {
+		t.l1.Set(ctx, key, value, t.localTTL(ttls[key]))
+	}
+	return t.publish(ctx, invalidation{Keys: keys})
+}
+
+// DeleteMulti removes multiple keys from both tiers and from L1 on other
+// instances.
+func (t *Tiered) DeleteMulti(ctx context.Context, keys []string) error {
+	if t.isClosed() {
+		return ErrClosed
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+
+	if err := t.l2.DeleteMulti(ctx, keys); err != nil {
+		return err
+	}
+	t.evict(ctx, keys)
+	return t.publish(ctx, invalidation{Keys: keys})
+}
+
+// SetWithTags stores a value with tags in both tiers and invalidates it on
+// other instances.
+func (t *Tiered) SetWithTags(ctx context.Context, key string, value []byte, ttl time.Duration, tags ...string) error {
+	if t.isClosed() {
+		return ErrClosed
+	}
+
+	ttl = t.jittered(ttl)
+	if err := t.l2.SetWithTags(ctx, key, value, ttl, tags...); err != nil {
+		return err
+	}
+	t.gen.Add(1)
+	t.neg.Delete(ctx, key)
+	t.l1.SetWithTags(ctx, key, value, t.localTTL(ttl), tags...)
+	return t.publish(ctx, invalidation{Keys: []string{key}})
+}
+
+// InvalidateTags removes every entry stored with any of the tags from both
+// tiers, and from L1 on other instances.
+func (t *Tiered) InvalidateTags(ctx context.Context, tags ...string) error {
+	if t.isClosed() {
+		return ErrClosed
+	}
+	if len(tags) == 0 {
+		return nil
+	}
+
+	// Other instances filled their L1 from Redis without the tags, so they
+	// are sent the invalidated keys.
+	keys, err := t.l2.invalidateTags(ctx, tags)
+	if err != nil {
+		return err
+	}
+	t.l1.InvalidateTags(ctx, tags...)
+	if len(keys) == 0 {
+		return nil
+	}
+	t.evict(ctx, keys)
+	return t.publish(ctx, invalidation{Keys: keys})
+}
+
+// Stats returns the cache's hit counters.
+func (t *Tiered) Stats() TieredStats {
+	return TieredStats{
+		L1Hits:        t.l1Hits.Load(),
+		L2Hits:        t.l2Hits.Load(),
+		NegativeHits:  t.negHits.Load(),
+		Misses:        t.misses.Load(),
+		Invalidations: t.received.Load(),
+	}
+}
+
+// L1 returns the in-process tier.
+func (t *Tiered) L1() *Memory {
+	return t.l1
+}
+
+// L2 returns the Redis tier.
+func (t *Tiered) L2() *Redis {
+	return t.l2
+}
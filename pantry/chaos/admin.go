@@ -0,0 +1,107 @@
+// chaos/admin.go
+package chaos
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/dalemusser/waffle/httputil"
+	"github.com/go-chi/chi/v5"
+)
+
+// AdminHandler returns an HTTP handler for changing fault injection at
+// runtime.
+//
+//	GET    /              status and rules
+//	PUT    /              {"enabled": true} and/or {"rules": [...]} (replaces all rules)
+//	POST   /rules         add or replace one rule
+//	DELETE /rules/{id}    remove one rule
+//	DELETE /rules         remove all rules
+//
+// Where fault injection is not allowed (prod), every change is rejected
+// with 403. Mount it behind admin authentication:
+//
+//	r.With(requireAdmin).Mount("/admin/chaos", inj.AdminHandler())
+func (i *Injector) AdminHandler() http.Handler {
+	r := chi.NewRouter()
+
+	r.Get("/", func(w http.ResponseWriter, req *http.Request) {
+		i.writeStatus(w, http.StatusOK)
+	})
+
+	r.Group(func(r chi.Router) {
+		r.Use(i.requireAllowed)
+
+		r.Put("/", func(w http.ResponseWriter, req *http.Request) {
+			var body struct {
+				Enabled *bool   `json:"enabled"`
+				Rules   *[]Rule `json:"rules"`
+			}
+			if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+				httputil.JSONError(w, http.StatusBadRequest, "bad_request", "Invalid JSON: "+err.Error())
+				return
+			}
+			if body.Rules != nil {
+				if err := i.SetRules(*body.Rules); err != nil {
+					httputil.JSONError(w, http.StatusBadRequest, "invalid_rule", err.Error())
+					return
+				}
+			}
+			if body.Enabled != nil {
+				if err := i.SetEnabled(*body.Enabled); err != nil {
+					httputil.JSONError(w, http.StatusForbidden, "forbidden", err.Error())
+					return
+				}
+			}
+			i.writeStatus(w, http.StatusOK)
+		})
+
+		r.Post("/rules", func(w http.ResponseWriter, req *http.Request) {
+			var rule Rule
+			if err := json.NewDecoder(req.Body).Decode(&rule); err != nil {
+				httputil.JSONError(w, http.StatusBadRequest, "bad_request", "Invalid JSON: "+err.Error())
+				return
+			}
+			stored, err := i.AddRule(rule)
+			if err != nil {
+				httputil.JSONError(w, http.StatusBadRequest, "invalid_rule", err.Error())
+				return
+			}
+			httputil.WriteJSON(w, http.StatusCreated, stored)
+		})
+
+		r.Delete("/rules", func(w http.ResponseWriter, req *http.Request) {
+			_ = i.SetRules(nil)
+			w.WriteHeader(http.StatusNoContent)
+		})
+
+		r.Delete("/rules/{id}", func(w http.ResponseWriter, req *http.Request) {
+			if !i.RemoveRule(chi.URLParam(req, "id")) {
+				httputil.JSONError(w, http.StatusNotFound, "not_found", "rule not found")
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		})
+	})
+
+	return r
+}
+
+func (i *Injector) requireAllowed(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !i.allowed {
+			httputil.JSONError(w, http.StatusForbidden, "forbidden", ErrNotAllowed.Error())
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (i *Injector) writeStatus(w http.ResponseWriter, status int) {
+	httputil.WriteJSON(w, status, map[string]any{
+		"allowed": i.allowed,
+		"enabled": i.Enabled(),
+		"header":  i.header,
+		"rules":   i.Rules(),
+	})
+}
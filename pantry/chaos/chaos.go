@@ -0,0 +1,299 @@
+// chaos/chaos.go
+package chaos
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/dalemusser/waffle/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+)
+
+// ErrNotAllowed is returned when enabling fault injection in an
+// environment where it is not allowed (prod, or an unset environment).
+var ErrNotAllowed = errors.New("chaos: fault injection is not allowed in this environment")
+
+// DefaultHeader is the request header that triggers faults
+// deterministically.
+const DefaultHeader = "X-Chaos-Fault"
+
+// Config configures an Injector.
+type Config struct {
+	// Env is the application environment (cfg.Env). Fault injection can
+	// only be enabled when Env is set and is not "prod".
+	Env string
+
+	// Enabled turns fault injection on at startup. Default: false.
+	Enabled bool
+
+	// Rules are the initial fault rules.
+	Rules []Rule
+
+	// Header names the request header that triggers a fault on demand.
+	// Its value is a rule ID, or an inline fault such as "status=503" or
+	// "latency=2s,reset". Default: DefaultHeader. Set to "-" to disable.
+	Header string
+
+	// Logger logs injected faults at Debug and rule changes at Info.
+	Logger *zap.Logger
+}
+
+// Injector decides which requests to fault. One Injector can back both
+// inbound middleware and outbound transports so a single admin endpoint
+// controls all of them.
+type Injector struct {
+	allowed bool
+	header  string
+	logger  *zap.Logger
+
+	enabled atomic.Bool
+
+	mu    sync.RWMutex
+	rules []Rule
+	seq   int
+}
+
+// New creates an Injector. If cfg.Enabled is set in an environment where
+// fault injection is not allowed, the Injector stays disabled and a
+// warning is logged.
+func New(cfg Config) (*Injector, error) {
+	registerMetrics()
+
+	if cfg.Logger == nil {
+		cfg.Logger = zap.NewNop()
+	}
+	if cfg.Header == "" {
+		cfg.Header = DefaultHeader
+	} else if cfg.Header == "-" {
+		cfg.Header = ""
+	}
+
+	i := &Injector{
+		allowed: cfg.Env != "" && cfg.Env != "prod",
+		header:  cfg.Header,
+		logger:  cfg.Logger,
+	}
+	if err := i.SetRules(cfg.Rules); err != nil {
+		return nil, err
+	}
+	if cfg.Enabled {
+		if err := i.SetEnabled(true); err != nil {
+			cfg.Logger.Warn("chaos fault injection requested but not allowed",
+				zap.String("env", cfg.Env))
+		}
+	}
+	return i, nil
+}
+
+// Allowed reports whether fault injection may be enabled in this
+// environment.
+func (i *Injector) Allowed() bool {
+	return i.allowed
+}
+
+// Enabled reports whether faults are currently being injected.
+func (i *Injector) Enabled() bool {
+	return i.enabled.Load()
+}
+
+// SetEnabled turns fault injection on or off. Enabling returns
+// ErrNotAllowed if the environment does not allow it.
+func (i *Injector) SetEnabled(on bool) error {
+	if on && !i.allowed {
+		return ErrNotAllowed
+	}
+	if i.enabled.Swap(on) != on {
+		i.logger.Info("chaos fault injection toggled", zap.Bool("enabled", on))
+	}
+	return nil
+}
+
+// Rules returns a copy of the current rules.
+func (i *Injector) Rules() []Rule {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	return append([]Rule(nil), i.rules...)
+}
+
+// SetRules replaces all rules. Rules without an ID are assigned one.
+func (i *Injector) SetRules(rules []Rule) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	next := make([]Rule, 0, len(rules))
+	seen := make(map[string]bool, len(rules))
+	for _, r := range rules {
+		if r.ID == "" {
+			i.seq++
+			r.ID = "rule-" + strconv.Itoa(i.seq)
+		}
+		if seen[r.ID] {
+			return errors.New("chaos: duplicate rule id " + strconv.Quote(r.ID))
+		}
+		if err := r.validate(); err != nil {
+			return errors.New("chaos: " + err.Error())
+		}
+		seen[r.ID] = true
+		next = append(next, r)
+	}
+	i.rules = next
+	i.logger.Info("chaos rules updated", zap.Int("rules", len(next)))
+	return nil
+}
+
+// AddRule adds a rule, replacing any rule with the same ID, and returns
+// the stored rule.
+func (i *Injector) AddRule(r Rule) (Rule, error) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	if r.ID == "" {
+		i.seq++
+		r.ID = "rule-" + strconv.Itoa(i.seq)
+	}
+	if err := r.validate(); err != nil {
+		return Rule{}, errors.New("chaos: " + err.Error())
+	}
+	for idx := range i.rules {
+		if i.rules[idx].ID == r.ID {
+			i.rules[idx] = r
+			i.logger.Info("chaos rule replaced", zap.String("rule", r.ID))
+			return r, nil
+		}
+	}
+	i.rules = append(i.rules, r)
+	i.logger.Info("chaos rule added", zap.String("rule", r.ID))
+	return r, nil
+}
+
+// RemoveRule deletes the rule with the given ID and reports whether it
+// existed.
+func (i *Injector) RemoveRule(id string) bool {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	for idx, r := range i.rules {
+		if r.ID == id {
+			i.rules = append(i.rules[:idx], i.rules[idx+1:]...)
+			i.logger.Info("chaos rule removed", zap.String("rule", id))
+			return true
+		}
+	}
+	return false
+}
+
+// decide returns the rule to apply to req, or nil. The fault header takes
+// precedence over percentage rules; it is removed from req so it doesn't
+// reach handlers or upstream servers.
+func (i *Injector) decide(dir Direction, req *http.Request) *Rule {
+	if !i.enabled.Load() {
+		return nil
+	}
+
+	if i.header != "" {
+		if v := req.Header.Get(i.header); v != "" {
+			req.Header.Del(i.header)
+			if r := i.fromHeader(v); r != nil {
+				return r
+			}
+		}
+	}
+
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	for idx := range i.rules {
+		r := &i.rules[idx]
+		if !r.matches(dir, req) {
+			continue
+		}
+		if r.Percent >= 100 || (r.Percent > 0 && rand.Float64()*100 < r.Percent) {
+			rc := *r
+			return &rc
+		}
+	}
+	return nil
+}
+
+// fromHeader resolves a fault header value to a rule: a rule ID, or an
+// inline fault spec.
+func (i *Injector) fromHeader(v string) *Rule {
+	i.mu.RLock()
+	for _, r := range i.rules {
+		if r.ID == v {
+			i.mu.RUnlock()
+			return &r
+		}
+	}
+	i.mu.RUnlock()
+
+	r, err := parseSpec(v)
+	if err != nil {
+		i.logger.Debug("ignoring invalid chaos header", zap.String("value", v), zap.Error(err))
+		return nil
+	}
+	return &r
+}
+
+// delay sleeps for the rule's latency, returning early if ctx is done.
+func (r *Rule) delay(ctx context.Context) error {
+	d := r.Latency
+	if r.LatencyJitter > 0 {
+		d += rand.N(r.LatencyJitter)
+	}
+	if d <= 0 {
+		return nil
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// kind names the primary fault for metrics and logs.
+func (r *Rule) kind() string {
+	switch {
+	case r.Reset:
+		return "reset"
+	case r.Status != 0:
+		return "status"
+	case r.Truncate:
+		return "truncate"
+	default:
+		return "latency"
+	}
+}
+
+func (i *Injector) record(dir Direction, r *Rule, req *http.Request) {
+	faultsInjected.WithLabelValues(string(dir), r.ID, r.kind()).Inc()
+	i.logger.Debug("chaos fault injected",
+		zap.String("direction", string(dir)),
+		zap.String("rule", r.ID),
+		zap.String("fault", r.kind()),
+		zap.String("method", req.Method),
+		zap.String("host", req.URL.Host),
+		zap.String("path", req.URL.Path),
+	)
+}
+
+var faultsInjected = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "chaos_faults_injected_total",
+	Help: "Faults injected by direction (inbound, outbound), rule and fault type.",
+}, []string{"direction", "rule", "fault"})
+
+var registerOnce sync.Once
+
+func registerMetrics() {
+	registerOnce.Do(func() {
+		metrics.MustRegister(nil, "chaos faults counter", faultsInjected)
+	})
+}
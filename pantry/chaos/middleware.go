@@ -0,0 +1,118 @@
+// chaos/middleware.go
+package chaos
+
+import (
+	"bytes"
+	"net"
+	"net/http"
+	"strconv"
+
+	"github.com/dalemusser/waffle/httputil"
+)
+
+// Middleware injects faults into matching inbound requests. It is a no-op
+// while the Injector is disabled.
+//
+//	inj, _ := chaos.New(chaos.Config{Env: cfg.Env, Enabled: appCfg.ChaosEnabled})
+//	r.Use(inj.Middleware)
+func (i *Injector) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rule := i.decide(Inbound, r)
+		if rule == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		i.record(Inbound, rule, r)
+
+		if err := rule.delay(r.Context()); err != nil {
+			return
+		}
+
+		switch {
+		case rule.Reset:
+			resetConn(w)
+		case rule.Status != 0:
+			httputil.JSONError(w, rule.Status, "chaos_fault",
+				"Injected fault ("+rule.ID+")")
+		case rule.Truncate:
+			tw := &truncateWriter{ResponseWriter: w}
+			next.ServeHTTP(tw, r)
+			tw.flush()
+		default:
+			next.ServeHTTP(w, r)
+		}
+	})
+}
+
+// resetConn drops the client connection. On HTTP/1 the TCP connection is
+// closed with SO_LINGER=0 so the client sees a reset; otherwise the
+// handler is aborted, which resets the HTTP/2 stream.
+func resetConn(w http.ResponseWriter) {
+	conn, _, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		panic(http.ErrAbortHandler)
+	}
+	// Close the raw connection first so a TLS close_notify can't turn
+	// the reset into a clean EOF, then the wrappers so they clean up.
+	if raw, ok := lingerer(conn); ok {
+		_ = raw.SetLinger(0)
+		_ = raw.Close()
+	}
+	_ = conn.Close()
+}
+
+// lingerConn is a connection that can set SO_LINGER, such as a
+// *net.TCPConn.
+type lingerConn interface {
+	net.Conn
+	SetLinger(sec int) error
+}
+
+// lingerer finds the lingerConn under conn, unwrapping TLS and wrappers
+// such as the server's connection tracking through their NetConn methods.
+func lingerer(conn net.Conn) (lingerConn, bool) {
+	for {
+		if l, ok := conn.(lingerConn); ok {
+			return l, true
+		}
+		u, ok := conn.(interface{ NetConn() net.Conn })
+		if !ok {
+			return nil, false
+		}
+		conn = u.NetConn()
+	}
+}
+
+// truncateWriter buffers the response so it can declare the full
+// Content-Length and then send only half of the body. The server closes the
+// connection after the short write, so the client sees an unexpected EOF.
+type truncateWriter struct {
+	http.ResponseWriter
+	status int
+	buf    bytes.Buffer
+}
+
+func (tw *truncateWriter) WriteHeader(status int) {
+	if tw.status == 0 {
+		tw.status = status
+	}
+}
+
+func (tw *truncateWriter) Write(p []byte) (int, error) {
+	if tw.status == 0 {
+		tw.status = http.StatusOK
+	}
+	return tw.buf.Write(p)
+}
+
+func (tw *truncateWriter) flush() {
+	if tw.status == 0 {
+		tw.status = http.StatusOK
+	}
+	body := tw.buf.Bytes()
+	h := tw.ResponseWriter.Header()
+	h.Set("Content-Length", strconv.Itoa(len(body)))
+	h.Set("Connection", "close")
+	tw.ResponseWriter.WriteHeader(tw.status)
+	_, _ = tw.ResponseWriter.Write(body[:len(body)/2])
+}
@@ -0,0 +1,229 @@
+// chaos/rule.go
+package chaos
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Direction limits a rule to inbound requests (Middleware), outbound
+// requests (Transport), or both.
+type Direction string
+
+const (
+	Both     Direction = ""
+	Inbound  Direction = "inbound"
+	Outbound Direction = "outbound"
+)
+
+// Rule describes faults to inject into matching requests.
+//
+// A rule can combine latency with one of Reset, Status or Truncate. Latency
+// is applied first; if several of the others are set, Reset wins over
+// Status, which wins over Truncate.
+type Rule struct {
+	// ID identifies the rule. It is also the value that triggers the rule
+	// deterministically via the fault header. Generated if empty.
+	ID string
+
+	// Description is free text shown in the admin endpoint.
+	Description string
+
+	// Direction limits the rule to inbound or outbound requests.
+	Direction Direction
+
+	// Methods limits the rule to these HTTP methods. Empty matches all.
+	Methods []string
+
+	// Path matches the request path exactly, or as a prefix when it ends
+	// in "*" (e.g. "/api/*"). Empty matches all paths.
+	Path string
+
+	// Host matches the outbound request host exactly, or as a suffix when
+	// it starts with "*." (e.g. "*.example.com"). Ignored for inbound
+	// requests. Empty matches all hosts.
+	Host string
+
+	// Percent is the chance, 0–100, that a matching request is faulted.
+	Percent float64
+
+	// Latency delays the request. LatencyJitter adds a random extra delay
+	// in [0, LatencyJitter).
+	Latency       time.Duration
+	LatencyJitter time.Duration
+
+	// Status returns this HTTP status instead of calling the handler or
+	// the upstream server.
+	Status int
+
+	// Reset drops the connection without a response.
+	Reset bool
+
+	// Truncate cuts the response body in half and closes the connection.
+	Truncate bool
+}
+
+// validate checks the rule for obvious mistakes.
+func (r Rule) validate() error {
+	if r.Percent < 0 || r.Percent > 100 {
+		return fmt.Errorf("rule %q: percent must be between 0 and 100", r.ID)
+	}
+	if r.Status != 0 && (r.Status < 100 || r.Status > 599) {
+		return fmt.Errorf("rule %q: invalid status %d", r.ID, r.Status)
+	}
+	if r.Latency < 0 || r.LatencyJitter < 0 {
+		return fmt.Errorf("rule %q: latency must not be negative", r.ID)
+	}
+	if r.Direction != Both && r.Direction != Inbound && r.Direction != Outbound {
+		return fmt.Errorf("rule %q: invalid direction %q", r.ID, r.Direction)
+	}
+	if r.Latency == 0 && r.LatencyJitter == 0 && r.Status == 0 && !r.Reset && !r.Truncate {
+		return fmt.Errorf("rule %q: no fault configured", r.ID)
+	}
+	return nil
+}
+
+// matches reports whether the rule applies to req.
+func (r Rule) matches(dir Direction, req *http.Request) bool {
+	if r.Direction != Both && r.Direction != dir {
+		return false
+	}
+	if len(r.Methods) > 0 {
+		ok := false
+		for _, m := range r.Methods {
+			if strings.EqualFold(m, req.Method) {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			return false
+		}
+	}
+	if r.Path != "" {
+		if prefix, ok := strings.CutSuffix(r.Path, "*"); ok {
+			if !strings.HasPrefix(req.URL.Path, prefix) {
+				return false
+			}
+		} else if req.URL.Path != r.Path {
+			return false
+		}
+	}
+	if dir == Outbound && r.Host != "" {
+		host := req.URL.Hostname()
+		if suffix, ok := strings.CutPrefix(r.Host, "*"); ok {
+			if !strings.HasSuffix(host, suffix) {
+				return false
+			}
+		} else if !strings.EqualFold(host, r.Host) {
+			return false
+		}
+	}
+	return true
+}
+
+// ruleJSON is the wire form of Rule, with durations as strings ("250ms").
+type ruleJSON struct {
+	ID            string    `json:"id"`
+	Description   string    `json:"description,omitempty"`
+	Direction     Direction `json:"direction,omitempty"`
+	Methods       []string  `json:"methods,omitempty"`
+	Path          string    `json:"path,omitempty"`
+	Host          string    `json:"host,omitempty"`
+	Percent       float64   `json:"percent"`
+	Latency       string    `json:"latency,omitempty"`
+	LatencyJitter string    `json:"latency_jitter,omitempty"`
+	Status        int       `json:"status,omitempty"`
+	Reset         bool      `json:"reset,omitempty"`
+	Truncate      bool      `json:"truncate,omitempty"`
+}
+
+// MarshalJSON encodes the rule with durations as strings.
+func (r Rule) MarshalJSON() ([]byte, error) {
+	j := ruleJSON{
+		ID:          r.ID,
+		Description: r.Description,
+		Direction:   r.Direction,
+		Methods:     r.Methods,
+		Path:        r.Path,
+		Host:        r.Host,
+		Percent:     r.Percent,
+		Status:      r.Status,
+		Reset:       r.Reset,
+		Truncate:    r.Truncate,
+	}
+	if r.Latency > 0 {
+		j.Latency = r.Latency.String()
+	}
+	if r.LatencyJitter > 0 {
+		j.LatencyJitter = r.LatencyJitter.String()
+	}
+	return json.Marshal(j)
+}
+
+// UnmarshalJSON decodes a rule with durations as strings.
+func (r *Rule) UnmarshalJSON(data []byte) error {
+	var j ruleJSON
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+	*r = Rule{
+		ID:          j.ID,
+		Description: j.Description,
+		Direction:   j.Direction,
+		Methods:     j.Methods,
+		Path:        j.Path,
+		Host:        j.Host,
+		Percent:     j.Percent,
+		Status:      j.Status,
+		Reset:       j.Reset,
+		Truncate:    j.Truncate,
+	}
+	var err error
+	if j.Latency != "" {
+		if r.Latency, err = time.ParseDuration(j.Latency); err != nil {
+			return fmt.Errorf("latency: %w", err)
+		}
+	}
+	if j.LatencyJitter != "" {
+		if r.LatencyJitter, err = time.ParseDuration(j.LatencyJitter); err != nil {
+			return fmt.Errorf("latency_jitter: %w", err)
+		}
+	}
+	return nil
+}
+
+// parseSpec parses an inline fault from the fault header, e.g.
+// "latency=2s", "status=503", "reset", "truncate", or a combination such
+// as "latency=500ms,status=502".
+func parseSpec(spec string) (Rule, error) {
+	r := Rule{ID: "header", Percent: 100}
+	for _, part := range strings.Split(spec, ",") {
+		key, val, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch strings.ToLower(key) {
+		case "latency", "delay":
+			d, err := time.ParseDuration(val)
+			if err != nil {
+				return Rule{}, fmt.Errorf("latency: %w", err)
+			}
+			r.Latency = d
+		case "status", "error":
+			n, err := strconv.Atoi(val)
+			if err != nil {
+				return Rule{}, fmt.Errorf("status: %w", err)
+			}
+			r.Status = n
+		case "reset":
+			r.Reset = true
+		case "truncate":
+			r.Truncate = true
+		default:
+			return Rule{}, fmt.Errorf("unknown fault %q", key)
+		}
+	}
+	return r, r.validate()
+}
@@ -0,0 +1,127 @@
+// chaos/transport.go
+package chaos
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// Transport injects faults into outbound requests. Put it beneath retry
+// logic so retries see the injected failures:
+//
+//	client := retry.ClientWithBase(inj.Transport(nil), retry.DefaultHTTPConfig())
+//
+// or wrap an existing client:
+//
+//	client := inj.WrapClient(timeout.NewClient(timeout.DefaultClientConfig()))
+type Transport struct {
+	// Base is the underlying transport. If nil, http.DefaultTransport is used.
+	Base http.RoundTripper
+
+	// Injector decides which requests to fault.
+	Injector *Injector
+}
+
+// Transport returns a RoundTripper that injects faults before calling base.
+func (i *Injector) Transport(base http.RoundTripper) http.RoundTripper {
+	return &Transport{Base: base, Injector: i}
+}
+
+// WrapClient installs a fault-injecting transport on c and returns it.
+func (i *Injector) WrapClient(c *http.Client) *http.Client {
+	c.Transport = i.Transport(c.Transport)
+	return c
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	if t.Injector == nil || !t.Injector.Enabled() {
+		return base.RoundTrip(req)
+	}
+
+	// decide strips the fault header, and RoundTrippers must not modify
+	// the caller's request.
+	if t.Injector.header != "" && req.Header.Get(t.Injector.header) != "" {
+		req = req.Clone(req.Context())
+	}
+	rule := t.Injector.decide(Outbound, req)
+	if rule == nil {
+		return base.RoundTrip(req)
+	}
+	t.Injector.record(Outbound, rule, req)
+
+	if err := rule.delay(req.Context()); err != nil {
+		closeBody(req)
+		return nil, err
+	}
+
+	switch {
+	case rule.Reset:
+		closeBody(req)
+		return nil, &net.OpError{Op: "read", Net: "tcp", Err: syscall.ECONNRESET}
+	case rule.Status != 0:
+		closeBody(req)
+		body := `{"error":"chaos_fault","message":"Injected fault (` + rule.ID + `)"}`
+		return &http.Response{
+			Status:        strconv.Itoa(rule.Status) + " " + http.StatusText(rule.Status),
+			StatusCode:    rule.Status,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        http.Header{"Content-Type": {"application/json"}},
+			Body:          io.NopCloser(strings.NewReader(body)),
+			ContentLength: int64(len(body)),
+			Request:       req,
+		}, nil
+	case rule.Truncate:
+		resp, err := base.RoundTrip(req)
+		if err != nil {
+			return resp, err
+		}
+		resp.Body = truncateBody(resp.Body, resp.ContentLength)
+		return resp, nil
+	default:
+		return base.RoundTrip(req)
+	}
+}
+
+func closeBody(req *http.Request) {
+	if req.Body != nil {
+		_ = req.Body.Close()
+	}
+}
+
+// truncateBody returns a body that yields half of the original (or a
+// fixed prefix when the length is unknown) and then io.ErrUnexpectedEOF.
+func truncateBody(body io.ReadCloser, length int64) io.ReadCloser {
+	limit := length / 2
+	if length < 0 {
+		limit = 512
+	}
+	return &truncatedBody{r: io.LimitReader(body, limit), c: body}
+}
+
+type truncatedBody struct {
+	r io.Reader
+	c io.Closer
+}
+
+func (b *truncatedBody) Read(p []byte) (int, error) {
+	n, err := b.r.Read(p)
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return n, err
+}
+
+func (b *truncatedBody) Close() error {
+	return b.c.Close()
+}
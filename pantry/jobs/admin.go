@@ -0,0 +1,591 @@
+// jobs/admin.go
+package jobs
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/dalemusser/waffle/httputil"
+	"github.com/dalemusser/waffle/pantry/audit"
+	"github.com/go-chi/chi/v5"
+)
+
+// AdminConfig configures the jobs admin handler.
+type AdminConfig struct {
+	// Scheduler is the scheduler to manage. Optional.
+	Scheduler *Scheduler
+
+	// Runner is the runner whose dead letters and workflows to manage.
+	// Optional.
+	Runner *Runner
+
+	// Audit records every change made through the handler. Optional.
+	Audit audit.Logger
+
+	// Actor returns who made a request. By default the actor is read from
+	// the X-User-ID header.
+	Actor func(r *http.Request) *audit.Actor
+
+	// HistoryLimit is the default number of executions returned for a job.
+	// Default: 20
+	HistoryLimit int
+}
+
+// AdminHandler is an HTTP API and dashboard for the scheduler and runner.
+//
+//	GET    /                        HTML dashboard
+//	GET    /jobs                    scheduled jobs with next/last run and stats
+//	GET    /jobs/{name}             one job with executions (?limit=) and failure rate
+//	POST   /jobs/{name}/pause       pause a job
+//	POST   /jobs/{name}/resume      resume a job
+//	POST   /jobs/{name}/run         run a job now
+//	GET    /health                  health of each job (last run succeeded)
+//	GET    /queue                   runner queue counts
+//	GET    /dead                    dead-lettered jobs (?limit=)
+//	GET    /dead/{id}               one dead job
+//	POST   /dead/{id}/retry         requeue a dead job
+//	DELETE /dead/{id}               delete a dead job
+//	GET    /workflows               recent workflows (?limit=)
+//	GET    /workflows/{id}          one workflow
+//	POST   /workflows/{id}/retry    rerun a workflow's failed steps
+//
+// Every change is recorded to the audit logger. Mount it behind admin
+// authentication:
+//
+//	r.With(requireAdmin).Mount("/admin/jobs", jobs.NewAdminHandler(jobs.AdminConfig{
+//	    Scheduler: scheduler,
+//	    Runner:    runner,
+//	    Audit:     auditLogger,
+//	}))
+type AdminHandler struct {
+	scheduler    *Scheduler
+	runner       *Runner
+	monitor      *Monitor
+	audit        audit.Logger
+	actor        func(r *http.Request) *audit.Actor
+	historyLimit int
+	router       chi.Router
+}
+
+// NewAdminHandler creates a new admin handler.
+func NewAdminHandler(cfg AdminConfig) *AdminHandler {
+	if cfg.HistoryLimit <= 0 {
+		cfg.HistoryLimit = 20
+	}
+	h := &AdminHandler{
+		scheduler:    cfg.Scheduler,
+		runner:       cfg.Runner,
+		audit:        cfg.Audit,
+		actor:        cfg.Actor,
+		historyLimit: cfg.HistoryLimit,
+	}
+	if h.scheduler != nil && h.scheduler.History() != nil {
+		h.monitor = NewMonitor(h.scheduler.History())
+	}
+	h.router = h.routes()
+	return h
+}
+
+// ServeHTTP handles admin requests.
+func (h *AdminHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.router.ServeHTTP(w, r)
+}
+
+func (h *AdminHandler) routes() chi.Router {
+	r := chi.NewRouter()
+
+	r.Get("/", func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte(adminDashboard))
+	})
+
+	r.Group(func(r chi.Router) {
+		r.Use(h.requireScheduler)
+		r.Get("/jobs", h.listJobs)
+		r.Get("/jobs/{name}", h.getJob)
+		r.Post("/jobs/{name}/pause", h.pauseJob)
+		r.Post("/jobs/{name}/resume", h.resumeJob)
+		r.Post("/jobs/{name}/run", h.runJob)
+		r.Get("/health", h.health)
+	})
+
+	r.Group(func(r chi.Router) {
+		r.Use(h.requireRunner)
+		r.Get("/queue", h.queue)
+		r.Get("/dead", h.listDead)
+		r.Get("/dead/{id}", h.getDead)
+		r.Post("/dead/{id}/retry", h.retryDead)
+		r.Delete("/dead/{id}", h.deleteDead)
+		r.Get("/workflows", h.listWorkflows)
+		r.Get("/workflows/{id}", h.getWorkflow)
+		r.Post("/workflows/{id}/retry", h.retryWorkflow)
+	})
+
+	return r
+}
+
+func (h *AdminHandler) requireScheduler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if h.scheduler == nil {
+			httputil.JSONError(w, http.StatusNotFound, "not_configured", "no scheduler configured")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (h *AdminHandler) requireRunner(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if h.runner == nil {
+			httputil.JSONError(w, http.StatusNotFound, "not_configured", "no runner configured")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// scheduledJobView is the JSON form of a scheduled job.
+type scheduledJobView struct {
+	Name        string          `json:"name"`
+	Type        string          `json:"type"`
+	Interval    string          `json:"interval,omitempty"`
+	Cron        string          `json:"cron,omitempty"`
+	Location    string          `json:"location,omitempty"`
+	Timeout     string          `json:"timeout,omitempty"`
+	Jitter      string          `json:"jitter,omitempty"`
+	Misfire     string          `json:"misfire,omitempty"`
+	Paused      bool            `json:"paused"`
+	NextRun     *time.Time      `json:"next_run,omitempty"`
+	LastRun     *time.Time      `json:"last_run,omitempty"`
+	Stats       *JobStats       `json:"stats,omitempty"`
+	FailureRate *float64        `json:"failure_rate,omitempty"`
+	Executions  []*JobExecution `json:"executions,omitempty"`
+}
+
+func (h *AdminHandler) jobView(ctx context.Context, info *JobInfo) *scheduledJobView {
+	v := &scheduledJobView{
+		Name:   info.Name,
+		Type:   info.Type,
+		Cron:   info.Cron,
+		Paused: info.Paused,
+	}
+	if info.Interval > 0 {
+		v.Interval = info.Interval.String()
+	}
+	if info.Timeout > 0 {
+		v.Timeout = info.Timeout.String()
+	}
+	if info.Type == "cron" {
+		v.Misfire = info.Misfire.String()
+		if info.Jitter > 0 {
+			v.Jitter = info.Jitter.String()
+		}
+		if info.Location != nil {
+			v.Location = info.Location.String()
+		}
+		if !info.NextRun.IsZero() {
+			next := info.NextRun
+			v.NextRun = &next
+		}
+	}
+
+	if h.monitor != nil {
+		if stats, err := h.scheduler.History().GetStats(ctx, info.Name); err == nil && stats != nil {
+			v.Stats = stats
+			v.LastRun = stats.LastExecution
+			if rate, err := h.monitor.FailureRate(ctx, info.Name); err == nil {
+				v.FailureRate = &rate
+			}
+		}
+	}
+	return v
+}
+
+func (h *AdminHandler) listJobs(w http.ResponseWriter, r *http.Request) {
+	infos := h.scheduler.ListJobs()
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name < infos[j].Name })
+
+	out := make([]*scheduledJobView, len(infos))
+	for i, info := range infos {
+		out[i] = h.jobView(r.Context(), info)
+	}
+	httputil.WriteJSON(w, http.StatusOK, out)
+}
+
+func (h *AdminHandler) getJob(w http.ResponseWriter, r *http.Request) {
+	info, ok := h.scheduler.Get(chi.URLParam(r, "name"))
+	if !ok {
+		httputil.JSONError(w, http.StatusNotFound, "not_found", "job not found")
+		return
+	}
+
+	v := h.jobView(r.Context(), info)
+	if history := h.scheduler.History(); history != nil {
+		execs, err := history.GetExecutions(r.Context(), info.Name, queryLimit(r, h.historyLimit))
+		if err != nil {
+			httputil.JSONError(w, http.StatusInternalServerError, "history_error", err.Error())
+			return
+		}
+		v.Executions = execs
+	}
+	httputil.WriteJSON(w, http.StatusOK, v)
+}
+
+func (h *AdminHandler) pauseJob(w http.ResponseWriter, r *http.Request) {
+	h.setPaused(w, r, true)
+}
+
+func (h *AdminHandler) resumeJob(w http.ResponseWriter, r *http.Request) {
+	h.setPaused(w, r, false)
+}
+
+func (h *AdminHandler) setPaused(w http.ResponseWriter, r *http.Request, paused bool) {
+	name := chi.URLParam(r, "name")
+	action, change := "jobs.resume", h.scheduler.Resume
+	if paused {
+		action, change = "jobs.pause", h.scheduler.Pause
+	}
+	res := &audit.Resource{Type: "scheduled_job", ID: name, Name: name}
+
+	if _, ok := h.scheduler.Get(name); !ok {
+		h.logFailure(r, action, res, "job not found")
+		httputil.JSONError(w, http.StatusNotFound, "not_found", "job not found")
+		return
+	}
+	if err := change(name); err != nil {
+		h.logFailure(r, action, res, err.Error())
+		httputil.JSONError(w, http.StatusNotFound, "not_found", err.Error())
+		return
+	}
+	h.logSuccess(r, action, res)
+
+	info, _ := h.scheduler.Get(name)
+	httputil.WriteJSON(w, http.StatusOK, h.jobView(r.Context(), info))
+}
+
+func (h *AdminHandler) runJob(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+	res := &audit.Resource{Type: "scheduled_job", ID: name, Name: name}
+
+	if _, ok := h.scheduler.Get(name); !ok {
+		h.logFailure(r, "jobs.run", res, "job not found")
+		httputil.JSONError(w, http.StatusNotFound, "not_found", "job not found")
+		return
+	}
+
+	// Run in the background so long jobs don't hold the request open.
+	// The outcome shows up in the job's history.
+	go h.scheduler.RunNow(context.Background(), name)
+
+	h.logSuccess(r, "jobs.run", res)
+	httputil.WriteJSON(w, http.StatusAccepted, map[string]string{"status": "started", "name": name})
+}
+
+func (h *AdminHandler) health(w http.ResponseWriter, r *http.Request) {
+	if h.monitor == nil {
+		httputil.JSONError(w, http.StatusNotFound, "not_configured", "no history store configured")
+		return
+	}
+	health, err := h.monitor.HealthCheck(r.Context())
+	if err != nil {
+		httputil.JSONError(w, http.StatusInternalServerError, "history_error", err.Error())
+		return
+	}
+	httputil.WriteJSON(w, http.StatusOK, health)
+}
+
+func (h *AdminHandler) queue(w http.ResponseWriter, r *http.Request) {
+	out := map[string]any{"queue_len": h.runner.QueueLen()}
+	if store := h.runner.Store(); store != nil {
+		stats, err := store.Stats(r.Context())
+		if err != nil {
+			httputil.JSONError(w, http.StatusInternalServerError, "store_error", err.Error())
+			return
+		}
+		out["store"] = stats
+	}
+	httputil.WriteJSON(w, http.StatusOK, out)
+}
+
+// jobView is the JSON form of a runner job.
+type jobView struct {
+	ID         string     `json:"id"`
+	Type       string     `json:"type"`
+	Payload    any        `json:"payload,omitempty"`
+	Priority   int        `json:"priority"`
+	Attempts   int        `json:"attempts"`
+	MaxRetries int        `json:"max_retries"`
+	CreatedAt  time.Time  `json:"created_at"`
+	RunAt      *time.Time `json:"run_at,omitempty"`
+	Error      string     `json:"error,omitempty"`
+	WorkflowID string     `json:"workflow_id,omitempty"`
+	StepID     string     `json:"step_id,omitempty"`
+}
+
+func newJobView(job *Job) *jobView {
+	v := &jobView{
+		ID:         job.ID,
+		Type:       job.Type,
+		Payload:    job.Payload,
+		Priority:   job.Priority,
+		Attempts:   job.Attempts,
+		MaxRetries: job.MaxRetries,
+		CreatedAt:  job.CreatedAt,
+		Error:      errorString(job.Error),
+		WorkflowID: job.WorkflowID,
+		StepID:     job.StepID,
+	}
+	if !job.RunAt.IsZero() {
+		runAt := job.RunAt
+		v.RunAt = &runAt
+	}
+	return v
+}
+
+func (h *AdminHandler) listDead(w http.ResponseWriter, r *http.Request) {
+	dead, err := h.runner.DeadLetters(r.Context(), queryLimit(r, 100))
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	out := make([]*jobView, len(dead))
+	for i, job := range dead {
+		out[i] = newJobView(job)
+	}
+	httputil.WriteJSON(w, http.StatusOK, out)
+}
+
+func (h *AdminHandler) getDead(w http.ResponseWriter, r *http.Request) {
+	job, err := h.runner.DeadLetter(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	httputil.WriteJSON(w, http.StatusOK, newJobView(job))
+}
+
+func (h *AdminHandler) retryDead(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	res := h.deadResource(r.Context(), id)
+
+	if err := h.runner.Requeue(r.Context(), id); err != nil {
+		h.logFailure(r, "jobs.dead.retry", res, err.Error())
+		writeStoreError(w, err)
+		return
+	}
+	h.logSuccess(r, "jobs.dead.retry", res)
+	httputil.WriteJSON(w, http.StatusOK, map[string]string{"status": "requeued", "id": id})
+}
+
+func (h *AdminHandler) deleteDead(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	res := h.deadResource(r.Context(), id)
+
+	if err := h.runner.Discard(r.Context(), id); err != nil {
+		h.logFailure(r, "jobs.dead.delete", res, err.Error())
+		writeStoreError(w, err)
+		return
+	}
+	h.logSuccess(r, "jobs.dead.delete", res)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// deadResource describes a dead job for the audit log, including its type
+// if it can still be loaded.
+func (h *AdminHandler) deadResource(ctx context.Context, id string) *audit.Resource {
+	res := &audit.Resource{Type: "job", ID: id}
+	if job, err := h.runner.DeadLetter(ctx, id); err == nil {
+		res.Name = job.Type
+	}
+	return res
+}
+
+func (h *AdminHandler) listWorkflows(w http.ResponseWriter, r *http.Request) {
+	wfs, err := h.runner.Workflows(r.Context(), queryLimit(r, 100))
+	if err != nil {
+		httputil.JSONError(w, http.StatusInternalServerError, "store_error", err.Error())
+		return
+	}
+	httputil.WriteJSON(w, http.StatusOK, wfs)
+}
+
+func (h *AdminHandler) getWorkflow(w http.ResponseWriter, r *http.Request) {
+	wf, err := h.runner.Workflow(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	httputil.WriteJSON(w, http.StatusOK, wf)
+}
+
+func (h *AdminHandler) retryWorkflow(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	res := &audit.Resource{Type: "workflow", ID: id}
+
+	wf, err := h.runner.RetryWorkflow(r.Context(), id)
+	if err != nil {
+		h.logFailure(r, "jobs.workflow.retry", res, err.Error())
+		writeStoreError(w, err)
+		return
+	}
+	res.Name = wf.Name
+	h.logSuccess(r, "jobs.workflow.retry", res)
+	httputil.WriteJSON(w, http.StatusOK, wf)
+}
+
+func (h *AdminHandler) auditor(r *http.Request) *audit.RequestAuditor {
+	a := audit.NewRequestAuditor(h.audit, r)
+	if h.actor != nil {
+		a.WithActor(h.actor(r))
+	}
+	return a
+}
+
+func (h *AdminHandler) logSuccess(r *http.Request, action string, res *audit.Resource) {
+	if h.audit != nil {
+		h.auditor(r).LogSuccess(action, res)
+	}
+}
+
+func (h *AdminHandler) logFailure(r *http.Request, action string, res *audit.Resource, reason string) {
+	if h.audit != nil {
+		h.auditor(r).LogFailure(action, res, reason)
+	}
+}
+
+// writeStoreError maps store errors to HTTP statuses.
+func writeStoreError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrJobNotFound), errors.Is(err, ErrWorkflowNotFound):
+		httputil.JSONError(w, http.StatusNotFound, "not_found", err.Error())
+	case errors.Is(err, ErrNoStore):
+		httputil.JSONError(w, http.StatusNotFound, "not_configured", err.Error())
+	case errors.Is(err, ErrWorkflowConflict):
+		httputil.JSONError(w, http.StatusConflict, "conflict", err.Error())
+	default:
+		httputil.JSONError(w, http.StatusInternalServerError, "store_error", err.Error())
+	}
+}
+
+// queryLimit reads ?limit=, falling back to def.
+func queryLimit(r *http.Request, def int) int {
+	if n, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && n > 0 {
+		return min(n, 1000)
+	}
+	return def
+}
+
+// adminDashboard is a small page over the JSON API. It uses relative URLs,
+// so it works wherever the handler is mounted (with a trailing slash).
+const adminDashboard = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Jobs</title>
+<style>
+body { font: 14px system-ui, sans-serif; margin: 2em; color: #222; }
+h2 { margin-top: 2em; }
+table { border-collapse: collapse; width: 100%; }
+th, td { text-align: left; padding: 4px 8px; border-bottom: 1px solid #ddd; vertical-align: top; }
+th { background: #f5f5f5; }
+.bad { color: #b00; }
+.muted { color: #888; }
+button { margin-right: 4px; }
+pre { margin: 0; white-space: pre-wrap; }
+</style>
+</head>
+<body>
+<h1>Jobs</h1>
+<div id="msg" class="muted"></div>
+
+<h2>Scheduled</h2>
+<table id="jobs"><thead><tr>
+<th>Name</th><th>Schedule</th><th>Next run</th><th>Last run</th><th>Runs</th><th>Failure rate</th><th>Last error</th><th></th>
+</tr></thead><tbody></tbody></table>
+
+<h2>Queue</h2>
+<pre id="queue" class="muted"></pre>
+
+<h2>Dead letters</h2>
+<table id="dead"><thead><tr>
+<th>ID</th><th>Type</th><th>Attempts</th><th>Error</th><th>Payload</th><th></th>
+</tr></thead><tbody></tbody></table>
+
+<script>
+function esc(s) {
+  return String(s == null ? "" : s).replace(/[&<>"']/g, function (c) {
+    return {"&": "&amp;", "<": "&lt;", ">": "&gt;", '"': "&quot;", "'": "&#39;"}[c];
+  });
+}
+function when(t) { return t ? new Date(t).toLocaleString() : ""; }
+function msg(s) { document.getElementById("msg").textContent = s || ""; }
+
+async function get(path) {
+  const res = await fetch(path);
+  if (!res.ok) return null;
+  return res.json();
+}
+
+async function act(method, path, label) {
+  const res = await fetch(path, {method: method});
+  msg(res.ok ? label + ": done" : label + ": failed (" + res.status + ")");
+  load();
+}
+
+function button(label, method, path) {
+  return '<button data-method="' + method + '" data-path="' + esc(path) + '" data-label="' + esc(label) + '">' + label + '</button>';
+}
+
+async function load() {
+  const jobs = await get("jobs");
+  const jb = document.querySelector("#jobs tbody");
+  jb.innerHTML = "";
+  (jobs || []).forEach(function (j) {
+    const s = j.stats || {};
+    const rate = j.failure_rate == null ? "" : (j.failure_rate * 100).toFixed(1) + "%";
+    const path = "jobs/" + encodeURIComponent(j.name);
+    jb.insertAdjacentHTML("beforeend", "<tr>" +
+      "<td>" + esc(j.name) + (j.paused ? ' <span class="bad">(paused)</span>' : "") + "</td>" +
+      "<td>" + esc(j.cron || ("every " + j.interval)) + (j.location ? " " + esc(j.location) : "") + "</td>" +
+      "<td>" + when(j.next_run) + "</td>" +
+      "<td>" + when(j.last_run) + "</td>" +
+      "<td>" + (s.total_executions || 0) + "</td>" +
+      "<td>" + rate + "</td>" +
+      '<td class="bad">' + esc(s.last_error) + "</td>" +
+      "<td>" + (j.paused ? button("Resume", "POST", path + "/resume") : button("Pause", "POST", path + "/pause")) +
+      button("Run now", "POST", path + "/run") + "</td></tr>");
+  });
+
+  const q = await get("queue");
+  document.getElementById("queue").textContent = q ? JSON.stringify(q, null, 2) : "no runner";
+
+  const dead = await get("dead");
+  const db = document.querySelector("#dead tbody");
+  db.innerHTML = "";
+  (dead || []).forEach(function (d) {
+    const path = "dead/" + encodeURIComponent(d.id);
+    db.insertAdjacentHTML("beforeend", "<tr>" +
+      "<td>" + esc(d.id) + "</td><td>" + esc(d.type) + "</td><td>" + d.attempts + "</td>" +
+      '<td class="bad">' + esc(d.error) + "</td>" +
+      "<td><pre>" + esc(JSON.stringify(d.payload)) + "</pre></td>" +
+      "<td>" + button("Retry", "POST", path + "/retry") + button("Delete", "DELETE", path) + "</td></tr>");
+  });
+}
+
+document.addEventListener("click", function (e) {
+  const b = e.target.closest("button[data-path]");
+  if (!b) return;
+  if (b.dataset.method === "DELETE" && !confirm("Delete this job?")) return;
+  act(b.dataset.method, b.dataset.path, b.dataset.label);
+});
+
+load();
+setInterval(load, 10000);
+</script>
+</body>
+</html>
+`
@@ -3,6 +3,7 @@ package jobs
 import (
 	"context"
 	"fmt"
+	mrand "math/rand/v2"
 	"strconv"
 	"strings"
 	"time"
@@ -10,12 +11,19 @@ import (
 
 // CronExpr represents a parsed cron expression.
 type CronExpr struct {
+	second     []int // 0-59
 	minute     []int // 0-59
 	hour       []int // 0-23
 	dayOfMonth []int // 1-31
 	month      []int // 1-12
 	dayOfWeek  []int // 0-6 (Sunday = 0)
 	raw        string
+
+	// loc is set by a CRON_TZ= or TZ= prefix.
+	loc *time.Location
+
+	// every is set by @every; fields are unused.
+	every time.Duration
 }
 
 // ParseCron parses a cron expression.
@@ -23,6 +31,10 @@ type CronExpr struct {
 //   - Standard cron: "minute hour day-of-month month day-of-week"
 //   - With seconds: "second minute hour day-of-month month day-of-week"
 //   - Predefined: @yearly, @annually, @monthly, @weekly, @daily, @midnight, @hourly
+//   - Fixed interval: @every <duration> (e.g., "@every 90s")
+//
+// Any of these may start with "CRON_TZ=<zone> " (or "TZ=<zone> ") to
+// evaluate the schedule in an IANA time zone.
 //
 // Field values:
 //   - * (any value)
@@ -39,12 +51,42 @@ type CronExpr struct {
 //	"0 9 * * 1-5"   - At 9:00 AM, Monday through Friday
 //	"0 0 1 * *"     - At midnight on the 1st of every month
 //	"@daily"        - Every day at midnight
+//	"30 0 6 * * *"  - At 6:00:30 AM every day
+//	"CRON_TZ=America/Chicago 0 6 * * *" - At 6:00 AM Chicago time
 func ParseCron(expr string) (*CronExpr, error) {
-	expr = strings.TrimSpace(expr)
+	raw := strings.TrimSpace(expr)
+	expr = raw
 	if expr == "" {
 		return nil, fmt.Errorf("cron: empty expression")
 	}
 
+	// Handle time zone prefix
+	var loc *time.Location
+	if strings.HasPrefix(expr, "CRON_TZ=") || strings.HasPrefix(expr, "TZ=") {
+		zone, rest, _ := strings.Cut(expr, " ")
+		_, name, _ := strings.Cut(zone, "=")
+		var err error
+		loc, err = time.LoadLocation(name)
+		if err != nil {
+			return nil, fmt.Errorf("cron: invalid time zone %q: %w", name, err)
+		}
+		expr = strings.TrimSpace(rest)
+		if expr == "" {
+			return nil, fmt.Errorf("cron: empty expression")
+		}
+	}
+
+	c, err := parseExpr(expr)
+	if err != nil {
+		return nil, err
+	}
+	c.raw = raw
+	c.loc = loc
+	return c, nil
+}
+
+// parseExpr parses an expression without a time zone prefix.
+func parseExpr(expr string) (*CronExpr, error) {
 	// Handle predefined expressions
 	if strings.HasPrefix(expr, "@") {
 		return parsePredefined(expr)
@@ -55,15 +97,19 @@ func ParseCron(expr string) (*CronExpr, error) {
 		return nil, fmt.Errorf("cron: expected 5 or 6 fields, got %d", len(fields))
 	}
 
-	// Handle 6-field format (with seconds) by ignoring seconds
+	c := &CronExpr{raw: expr, second: []int{0}}
+	var err error
+
+	// Handle 6-field format (with seconds)
 	offset := 0
 	if len(fields) == 6 {
 		offset = 1
+		c.second, err = parseField(fields[0], 0, 59)
+		if err != nil {
+			return nil, fmt.Errorf("cron: second field: %w", err)
+		}
 	}
 
-	c := &CronExpr{raw: expr}
-	var err error
-
 	// Parse minute (0-59)
 	c.minute, err = parseField(fields[offset+0], 0, 59)
 	if err != nil {
@@ -102,6 +148,7 @@ func parsePredefined(expr string) (*CronExpr, error) {
 	switch strings.ToLower(expr) {
 	case "@yearly", "@annually":
 		return &CronExpr{
+			second:     []int{0},
 			minute:     []int{0},
 			hour:       []int{0},
 			dayOfMonth: []int{1},
@@ -111,6 +158,7 @@ func parsePredefined(expr string) (*CronExpr, error) {
 		}, nil
 	case "@monthly":
 		return &CronExpr{
+			second:     []int{0},
 			minute:     []int{0},
 			hour:       []int{0},
 			dayOfMonth: []int{1},
@@ -120,6 +168,7 @@ func parsePredefined(expr string) (*CronExpr, error) {
 		}, nil
 	case "@weekly":
 		return &CronExpr{
+			second:     []int{0},
 			minute:     []int{0},
 			hour:       []int{0},
 			dayOfMonth: allValues(1, 31),
@@ -129,6 +178,7 @@ func parsePredefined(expr string) (*CronExpr, error) {
 		}, nil
 	case "@daily", "@midnight":
 		return &CronExpr{
+			second:     []int{0},
 			minute:     []int{0},
 			hour:       []int{0},
 			dayOfMonth: allValues(1, 31),
@@ -138,6 +188,7 @@ func parsePredefined(expr string) (*CronExpr, error) {
 		}, nil
 	case "@hourly":
 		return &CronExpr{
+			second:     []int{0},
 			minute:     []int{0},
 			hour:       allValues(0, 23),
 			dayOfMonth: allValues(1, 31),
@@ -148,7 +199,14 @@ func parsePredefined(expr string) (*CronExpr, error) {
 	default:
 		// Check for @every format
 		if strings.HasPrefix(expr, "@every ") {
-			return nil, fmt.Errorf("cron: @every is not supported, use interval-based scheduling instead")
+			every, err := time.ParseDuration(strings.TrimSpace(expr[len("@every "):]))
+			if err != nil {
+				return nil, fmt.Errorf("cron: invalid @every duration: %w", err)
+			}
+			if every < time.Second {
+				return nil, fmt.Errorf("cron: @every duration must be at least 1s")
+			}
+			return &CronExpr{every: every, raw: expr}, nil
 		}
 		return nil, fmt.Errorf("cron: unknown predefined expression: %s", expr)
 	}
@@ -318,82 +376,89 @@ func uniqueSorted(values []int) []int {
 }
 
 // Next returns the next time this cron expression will fire after the given time.
+//
+// The schedule is evaluated in the expression's CRON_TZ zone if it has one,
+// otherwise in after's location. Fields match wall-clock time, so "0 6 * * *"
+// fires at 6:00 local time on both sides of a DST change. A time that falls
+// in a spring-forward gap fires at the end of the gap (2:30 becomes 3:30);
+// a time in a fall-back overlap fires once, at its first occurrence.
 func (c *CronExpr) Next(after time.Time) time.Time {
-	// Start from the next minute
-	t := after.Truncate(time.Minute).Add(time.Minute)
+	if c.every > 0 {
+		return after.Add(c.every)
+	}
+
+	loc := after.Location()
+	if c.loc != nil {
+		loc = c.loc
+		after = after.In(loc)
+	}
+
+	// Search wall-clock time in UTC, where every day has 24 hours.
+	wall := time.Date(after.Year(), after.Month(), after.Day(),
+		after.Hour(), after.Minute(), after.Second(), 0, time.UTC)
 
 	// Search for up to 5 years
-	maxTime := after.AddDate(5, 0, 0)
+	maxTime := wall.AddDate(5, 0, 0)
+
+	for {
+		wall = c.nextWall(wall, maxTime)
+		if wall.IsZero() {
+			return time.Time{}
+		}
+		t := time.Date(wall.Year(), wall.Month(), wall.Day(),
+			wall.Hour(), wall.Minute(), wall.Second(), 0, loc)
+		if t.Hour() != wall.Hour() || t.Minute() != wall.Minute() {
+			// The wall time is in a spring-forward gap. Applying the
+			// offset from before the gap lands the same distance past
+			// its end.
+			_, offset := t.Add(-24 * time.Hour).Zone()
+			t = wall.Add(-time.Duration(offset) * time.Second).In(loc)
+		}
+		// In a fall-back overlap, the wall time may map to an instant that
+		// has already passed.
+		if t.After(after) {
+			return t
+		}
+	}
+}
+
+// nextWall returns the first wall-clock time after t (a UTC time holding
+// wall-clock fields) that matches the expression, or zero if there is none
+// before maxTime.
+func (c *CronExpr) nextWall(t, maxTime time.Time) time.Time {
+	t = t.Add(time.Second)
 
 	for t.Before(maxTime) {
 		// Check month
 		if !contains(c.month, int(t.Month())) {
 			// Advance to next month
-			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, time.UTC)
 			continue
 		}
 
 		// Check day of month and day of week
-		dayMatches := contains(c.dayOfMonth, t.Day())
-		dowMatches := contains(c.dayOfWeek, int(t.Weekday()))
-
-		// Both day-of-month and day-of-week must match (standard cron behavior)
-		// unless one of them is * (all values)
-		domIsAll := len(c.dayOfMonth) == 31
-		dowIsAll := len(c.dayOfWeek) == 7
-
-		var dayOk bool
-		if domIsAll && dowIsAll {
-			dayOk = true
-		} else if domIsAll {
-			dayOk = dowMatches
-		} else if dowIsAll {
-			dayOk = dayMatches
-		} else {
-			// If both are restricted, either can match (OR behavior)
-			dayOk = dayMatches || dowMatches
-		}
-
-		if !dayOk {
+		if !c.dayMatches(t) {
 			// Advance to next day
-			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, time.UTC)
 			continue
 		}
 
 		// Check hour
 		if !contains(c.hour, t.Hour()) {
-			// Find next matching hour
-			nextHour := -1
-			for _, h := range c.hour {
-				if h > t.Hour() {
-					nextHour = h
-					break
-				}
-			}
-			if nextHour == -1 {
-				// No matching hour today, try tomorrow
-				t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
-				continue
-			}
-			t = time.Date(t.Year(), t.Month(), t.Day(), nextHour, 0, 0, 0, t.Location())
+			t = t.Truncate(time.Hour).Add(time.Hour)
+			continue
 		}
 
 		// Check minute
 		if !contains(c.minute, t.Minute()) {
-			// Find next matching minute
-			nextMinute := -1
-			for _, m := range c.minute {
-				if m > t.Minute() {
-					nextMinute = m
-					break
-				}
-			}
-			if nextMinute == -1 {
-				// No matching minute this hour, try next hour
-				t = t.Add(time.Hour).Truncate(time.Hour)
-				continue
-			}
-			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), nextMinute, 0, 0, t.Location())
+			t = t.Truncate(time.Minute).Add(time.Minute)
+			continue
+		}
+
+		// Check second
+		if !contains(c.second, t.Second()) {
+			t = t.Add(time.Second)
+			continue
 		}
 
 		// All conditions met
@@ -404,6 +469,35 @@ func (c *CronExpr) Next(after time.Time) time.Time {
 	return time.Time{}
 }
 
+// dayMatches reports whether t's day matches the day-of-month and
+// day-of-week fields.
+func (c *CronExpr) dayMatches(t time.Time) bool {
+	dayMatches := contains(c.dayOfMonth, t.Day())
+	dowMatches := contains(c.dayOfWeek, int(t.Weekday()))
+
+	// Both day-of-month and day-of-week must match (standard cron behavior)
+	// unless one of them is * (all values)
+	domIsAll := len(c.dayOfMonth) == 31
+	dowIsAll := len(c.dayOfWeek) == 7
+
+	switch {
+	case domIsAll && dowIsAll:
+		return true
+	case domIsAll:
+		return dowMatches
+	case dowIsAll:
+		return dayMatches
+	default:
+		// If both are restricted, either can match (OR behavior)
+		return dayMatches || dowMatches
+	}
+}
+
+// Location returns the time zone set with CRON_TZ= or TZ=, or nil.
+func (c *CronExpr) Location() *time.Location {
+	return c.loc
+}
+
 // String returns the original cron expression.
 func (c *CronExpr) String() string {
 	return c.raw
@@ -442,14 +536,59 @@ type CronJob struct {
 	// Timeout for each execution. Default: 5 minutes.
 	Timeout time.Duration
 
-	// Location is the timezone for the cron schedule.
+	// Location is the timezone for the cron schedule. A CRON_TZ= prefix
+	// in Cron takes precedence.
 	// Default: time.Local
 	Location *time.Location
 
+	// Jitter delays each run by a random duration in [0, Jitter) to
+	// spread load when many jobs share a schedule.
+	Jitter time.Duration
+
+	// Misfire decides what happens to runs missed while no scheduler was
+	// running (or while a previous run overran). It needs a history store
+	// that implements LastRunStore. Default: MisfireSkip.
+	Misfire MisfirePolicy
+
 	// parsed is the parsed cron expression.
 	parsed *CronExpr
 }
 
+// MisfirePolicy controls how a cron job handles missed runs.
+type MisfirePolicy int
+
+const (
+	// MisfireSkip ignores missed runs and waits for the next one.
+	MisfireSkip MisfirePolicy = iota
+
+	// MisfireRunOnce runs once for any number of missed runs.
+	MisfireRunOnce
+
+	// MisfireCatchUp runs every missed run in order, up to maxCatchUp.
+	MisfireCatchUp
+)
+
+const (
+	// maxCatchUp bounds the runs MisfireCatchUp replays at once.
+	maxCatchUp = 100
+
+	// maxMisfireScan bounds the search for missed runs of frequent
+	// schedules after a long outage.
+	maxMisfireScan = 100000
+)
+
+// String returns the policy name.
+func (p MisfirePolicy) String() string {
+	switch p {
+	case MisfireRunOnce:
+		return "run_once"
+	case MisfireCatchUp:
+		return "catch_up"
+	default:
+		return "skip"
+	}
+}
+
 // NextRun returns the next time this job should run after the given time.
 func (j *CronJob) NextRun(after time.Time) time.Time {
 	if j.parsed == nil {
@@ -461,6 +600,38 @@ func (j *CronJob) NextRun(after time.Time) time.Time {
 	return j.parsed.Next(after)
 }
 
+// wait returns how long to wait at now before the run scheduled at next,
+// delayed by a random duration in [0, Jitter).
+func (j *CronJob) wait(next, now time.Time) time.Duration {
+	d := next.Sub(now)
+	if j.Jitter > 0 {
+		d += mrand.N(j.Jitter)
+	}
+	return d
+}
+
+// missedRuns returns the scheduled times in (last, now] that the job's
+// misfire policy says to run.
+func (j *CronJob) missedRuns(last, now time.Time) []time.Time {
+	if last.IsZero() || j.Misfire == MisfireSkip {
+		return nil
+	}
+
+	var missed []time.Time
+	n := 0
+	for t := j.NextRun(last); !t.IsZero() && !t.After(now) && n < maxMisfireScan; t = j.NextRun(t) {
+		n++
+		missed = append(missed, t)
+		if j.Misfire == MisfireCatchUp && len(missed) == maxCatchUp {
+			break
+		}
+		if j.Misfire == MisfireRunOnce && len(missed) > 1 {
+			missed = missed[1:]
+		}
+	}
+	return missed
+}
+
 // cronEntry represents an internal entry for a cron job.
 type cronEntry struct {
 	job     *CronJob
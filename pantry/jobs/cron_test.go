@@ -0,0 +1,175 @@
+package jobs
+
+import (
+	"testing"
+	"time"
+	_ "time/tzdata"
+)
+
+func mustLoad(t *testing.T, name string) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return loc
+}
+
+func TestParseCronErrors(t *testing.T) {
+	tests := []string{
+		"",
+		"* * * *",
+		"* * * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"*/0 * * * *",
+		"@bogus",
+		"@every 500ms",
+		"@every soon",
+		"CRON_TZ=Nowhere/Zone 0 6 * * *",
+		"CRON_TZ=UTC",
+	}
+	for _, expr := range tests {
+		if _, err := ParseCron(expr); err == nil {
+			t.Errorf("ParseCron(%q): expected an error", expr)
+		}
+	}
+}
+
+func TestCronNext(t *testing.T) {
+	chicago := mustLoad(t, "America/Chicago")
+	utc := func(month time.Month, day, hour, min, sec int) time.Time {
+		return time.Date(2026, month, day, hour, min, sec, 0, time.UTC)
+	}
+
+	tests := []struct {
+		name  string
+		expr  string
+		after time.Time
+		want  time.Time
+	}{
+		{"daily", "0 6 * * *", utc(3, 1, 5, 0, 0), utc(3, 1, 6, 0, 0)},
+		{"daily, already passed", "0 6 * * *", utc(3, 1, 6, 0, 0), utc(3, 2, 6, 0, 0)},
+		{"hourly", "@hourly", utc(3, 1, 5, 59, 59), utc(3, 1, 6, 0, 0)},
+		{"seconds field", "30 0 6 * * *", utc(3, 1, 6, 0, 0), utc(3, 1, 6, 0, 30)},
+		{"seconds step", "*/15 * * * * *", utc(3, 1, 10, 0, 7), utc(3, 1, 10, 0, 15)},
+		{"day of month or day of week", "0 0 13 * 5", utc(3, 1, 0, 0, 0), utc(3, 6, 0, 0, 0)},
+		{"every", "@every 90s", utc(3, 1, 10, 0, 7), utc(3, 1, 10, 1, 37)},
+		{"after's location", "0 6 * * *", time.Date(2026, 1, 15, 0, 0, 0, 0, chicago), utc(1, 15, 12, 0, 0)},
+
+		// Chicago is UTC-6 in winter and UTC-5 in summer.
+		{"CRON_TZ", "CRON_TZ=America/Chicago 0 6 * * *", utc(1, 15, 0, 0, 0), utc(1, 15, 12, 0, 0)},
+		{"TZ", "TZ=America/Chicago 0 6 * * *", utc(7, 15, 0, 0, 0), utc(7, 15, 11, 0, 0)},
+		{"same wall time across DST", "CRON_TZ=America/Chicago 0 6 * * *", utc(3, 8, 12, 0, 0), utc(3, 9, 11, 0, 0)},
+
+		// Clocks go from 2:00 to 3:00 on March 8, 2026: 2:30 doesn't exist
+		// and runs at 3:30 CDT.
+		{"DST gap", "CRON_TZ=America/Chicago 30 2 * * *", utc(3, 8, 6, 0, 0), utc(3, 8, 8, 30, 0)},
+		{"after DST gap", "CRON_TZ=America/Chicago 30 2 * * *", utc(3, 8, 8, 30, 0), utc(3, 9, 7, 30, 0)},
+
+		// Clocks go from 2:00 back to 1:00 on November 1, 2026: 1:30
+		// happens twice and runs at the first, 1:30 CDT.
+		{"DST overlap", "CRON_TZ=America/Chicago 30 1 * * *", utc(11, 1, 5, 0, 0), utc(11, 1, 6, 30, 0)},
+		{"DST overlap runs once", "CRON_TZ=America/Chicago 30 1 * * *", utc(11, 1, 6, 30, 0), utc(11, 2, 7, 30, 0)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := ParseCron(tt.expr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := c.Next(tt.after); !got.Equal(tt.want) {
+				t.Errorf("Next(%v) = %v, want %v", tt.after, got.UTC(), tt.want)
+			}
+		})
+	}
+}
+
+func TestCronJobNextRunLocation(t *testing.T) {
+	chicago := mustLoad(t, "America/Chicago")
+	job := &CronJob{parsed: MustParseCron("0 6 * * *"), Location: chicago}
+
+	after := time.Date(2026, 1, 15, 0, 0, 0, 0, time.UTC)
+	want := time.Date(2026, 1, 15, 12, 0, 0, 0, time.UTC)
+	if got := job.NextRun(after); !got.Equal(want) {
+		t.Errorf("NextRun = %v, want %v", got.UTC(), want)
+	}
+}
+
+func TestCronJobWait(t *testing.T) {
+	now := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+	next := now.Add(time.Hour)
+
+	tests := []struct {
+		name   string
+		jitter time.Duration
+	}{
+		{"no jitter", 0},
+		{"jitter", time.Minute},
+		{"tiny jitter", time.Nanosecond},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			job := &CronJob{Jitter: tt.jitter}
+			for i := 0; i < 100; i++ {
+				got := job.wait(next, now)
+				if tt.jitter == 0 && got != time.Hour {
+					t.Fatalf("wait = %v, want 1h", got)
+				}
+				if tt.jitter > 0 && (got < time.Hour || got >= time.Hour+tt.jitter) {
+					t.Fatalf("wait = %v, want [1h, 1h+%v)", got, tt.jitter)
+				}
+			}
+		})
+	}
+}
+
+func TestMissedRuns(t *testing.T) {
+	at := func(day, hour, min int) time.Time {
+		return time.Date(2026, 3, day, hour, min, 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		name    string
+		expr    string
+		misfire MisfirePolicy
+		last    time.Time
+		now     time.Time
+		want    []time.Time
+		wantLen int // checked instead of want when want is nil
+	}{
+		{"skip", "0 * * * *", MisfireSkip, at(1, 10, 0), at(1, 13, 30), nil, 0},
+		{"run once", "0 * * * *", MisfireRunOnce, at(1, 10, 0), at(1, 13, 30), []time.Time{at(1, 13, 0)}, 0},
+		{"catch up", "0 * * * *", MisfireCatchUp, at(1, 10, 0), at(1, 13, 30), []time.Time{at(1, 11, 0), at(1, 12, 0), at(1, 13, 0)}, 0},
+		{"includes now", "0 * * * *", MisfireCatchUp, at(1, 10, 0), at(1, 11, 0), []time.Time{at(1, 11, 0)}, 0},
+		{"nothing missed", "0 * * * *", MisfireCatchUp, at(1, 10, 0), at(1, 10, 59), nil, 0},
+		{"never ran", "0 * * * *", MisfireCatchUp, time.Time{}, at(1, 13, 30), nil, 0},
+		{"run once after long outage", "* * * * *", MisfireRunOnce, at(1, 0, 0), at(3, 0, 0), []time.Time{at(3, 0, 0)}, 0},
+		{"catch up is bounded", "* * * * *", MisfireCatchUp, at(1, 0, 0), at(3, 0, 0), nil, maxCatchUp},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			job := &CronJob{parsed: MustParseCron(tt.expr), Location: time.UTC, Misfire: tt.misfire}
+			got := job.missedRuns(tt.last, tt.now)
+			if tt.want == nil {
+				if len(got) != tt.wantLen {
+					t.Fatalf("got %d runs, want %d", len(got), tt.wantLen)
+				}
+				if tt.wantLen > 0 && !got[0].Equal(tt.last.Add(time.Minute)) {
+					t.Errorf("first run = %v, want the first one missed", got[0])
+				}
+				return
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if !got[i].Equal(tt.want[i]) {
+					t.Errorf("run %d = %v, want %v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
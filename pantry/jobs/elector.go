@@ -0,0 +1,351 @@
+// jobs/elector.go
+package jobs
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// ErrNoLeader is returned by Elector.Check when no instance leads the key.
+var ErrNoLeader = errors.New("jobs: no leader elected")
+
+// LockOwner is implemented by lockers that can report who holds a lock.
+// All the lockers in this package implement it.
+type LockOwner interface {
+	// Owner returns the owner ID of the instance holding key, or "" if
+	// the lock is free.
+	Owner(ctx context.Context, key string) (string, error)
+}
+
+// Elector runs leader election on a Locker key, for singleton loops (roster
+// sync, search reindexing) that must run on exactly one instance.
+//
+// Every instance runs an Elector for the same key. The one that acquires
+// the lock becomes leader: OnElected is called with a context that is
+// canceled as soon as leadership is lost, and the lease is renewed in the
+// background. The others retry until the lease is released or expires.
+//
+// Leadership is lost when a renewal reports the lock taken over, or when
+// renewals keep failing (for example, the lock store is unreachable) until
+// the lease is about to expire: the context is canceled a tenth of the TTL
+// before then, counted from when the last successful renewal started, even
+// if a renewal is still hanging. OnDemoted is called once OnElected has
+// returned, so OnElected must return promptly when its context is done.
+type Elector struct {
+	locker    Locker
+	key       string
+	id        string
+	ttl       time.Duration
+	renew     time.Duration
+	retry     time.Duration
+	onElected func(ctx context.Context)
+	onDemoted func()
+	logger    *zap.Logger
+
+	mu      sync.Mutex
+	leader  bool
+	since   time.Time
+	running bool
+	stopCh  chan struct{}
+	done    chan struct{}
+}
+
+// ElectorConfig configures an Elector.
+type ElectorConfig struct {
+	// Locker coordinates the election. Use a RedisLocker or SQLLocker
+	// across instances; a MemoryLocker has one identity per process.
+	Locker Locker
+
+	// Key is the lock key, e.g. "leader:roster_sync".
+	Key string
+
+	// TTL is the leader's lease. A crashed leader is replaced within TTL.
+	// Default: 15 seconds
+	TTL time.Duration
+
+	// RenewInterval is how often the leader renews its lease.
+	// Default: TTL / 3
+	RenewInterval time.Duration
+
+	// RetryInterval is how often other instances try to become leader.
+	// Default: TTL / 3
+	RetryInterval time.Duration
+
+	// OnElected is called in its own goroutine when this instance becomes
+	// leader. ctx is canceled when leadership is lost or the Elector stops.
+	OnElected func(ctx context.Context)
+
+	// OnDemoted is called when this instance stops being leader, after
+	// OnElected has returned.
+	OnDemoted func()
+
+	// Logger for election events. Default: no-op logger.
+	Logger *zap.Logger
+}
+
+// NewElector creates an Elector. Call Start to begin campaigning.
+func NewElector(cfg ElectorConfig) *Elector {
+	if cfg.TTL <= 0 {
+		cfg.TTL = 15 * time.Second
+	}
+	if cfg.RenewInterval <= 0 {
+		cfg.RenewInterval = cfg.TTL / 3
+	}
+	if cfg.RetryInterval <= 0 {
+		cfg.RetryInterval = cfg.TTL / 3
+	}
+	if cfg.Logger == nil {
+		cfg.Logger = zap.NewNop()
+	}
+
+	id := ""
+	if o, ok := cfg.Locker.(interface{ OwnerID() string }); ok {
+		id = o.OwnerID()
+	}
+
+	return &Elector{
+		locker:    cfg.Locker,
+		key:       cfg.Key,
+		id:        id,
+		ttl:       cfg.TTL,
+		renew:     cfg.RenewInterval,
+		retry:     cfg.RetryInterval,
+		onElected: cfg.OnElected,
+		onDemoted: cfg.OnDemoted,
+		logger:    cfg.Logger.With(zap.String("key", cfg.Key)),
+	}
+}
+
+// Start begins campaigning in the background.
+func (e *Elector) Start() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.running {
+		return
+	}
+	e.running = true
+	e.stopCh = make(chan struct{})
+	e.done = make(chan struct{})
+	go e.run(e.stopCh, e.done)
+}
+
+// Stop stops campaigning. If this instance is leader, it cancels
+// OnElected's context, waits for it to return and releases the lock so
+// another instance can take over without waiting for the lease to expire.
+func (e *Elector) Stop(ctx context.Context) error {
+	e.mu.Lock()
+	if !e.running {
+		e.mu.Unlock()
+		return nil
+	}
+	e.running = false
+	close(e.stopCh)
+	done := e.done
+	e.mu.Unlock()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// run campaigns until stopCh is closed.
+func (e *Elector) run(stopCh, done chan struct{}) {
+	defer close(done)
+
+	for {
+		start := time.Now()
+		ok, err := e.locker.Acquire(context.Background(), e.key, e.ttl)
+		if err != nil {
+			e.logger.Warn("leader election failed", zap.Error(err))
+		}
+		if ok {
+			e.lead(stopCh, start)
+		}
+
+		select {
+		case <-stopCh:
+			return
+		case <-time.After(e.retry):
+		}
+	}
+}
+
+// lead runs OnElected and renews the lease until leadership is lost or
+// stopCh is closed. acquired is when the Acquire call that won started.
+func (e *Elector) lead(stopCh chan struct{}, acquired time.Time) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Cancel OnElected before the lease can expire, even while a renewal
+	// hangs on an unreachable store.
+	expiry := time.AfterFunc(time.Until(e.deadline(acquired)), cancel)
+	defer expiry.Stop()
+
+	e.setLeader(true)
+	e.logger.Info("elected leader", zap.String("id", e.id))
+
+	var wg sync.WaitGroup
+	if e.onElected != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			e.onElected(ctx)
+		}()
+	}
+
+	resign := e.keepLease(ctx, stopCh, expiry)
+
+	e.setLeader(false)
+	cancel()
+	wg.Wait()
+
+	if resign {
+		rctx, rcancel := context.WithTimeout(context.Background(), e.renew)
+		if _, err := e.locker.Release(rctx, e.key); err != nil {
+			e.logger.Warn("failed to release leadership", zap.Error(err))
+		}
+		rcancel()
+	}
+
+	e.logger.Info("no longer leader", zap.String("id", e.id))
+	if e.onDemoted != nil {
+		e.onDemoted()
+	}
+}
+
+// keepLease renews the lease every RenewInterval, pushing back expiry on
+// each success. It returns true when stopCh is closed, and false when
+// leadership is lost: the lock was taken over, or ctx was canceled by
+// expiry because renewals kept failing.
+func (e *Elector) keepLease(ctx context.Context, stopCh chan struct{}, expiry *time.Timer) bool {
+	ticker := time.NewTicker(e.renew)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stopCh:
+			return true
+		case <-ctx.Done():
+			e.logger.Warn("leadership lease expiring, stepping down", zap.String("id", e.id))
+			return false
+		case <-ticker.C:
+		}
+
+		start := time.Now()
+		err := e.extend(ctx)
+		switch {
+		case ctx.Err() != nil:
+			// Expired while renewing; reported on the next pass
+		case err == nil:
+			expiry.Reset(time.Until(e.deadline(start)))
+		case errors.Is(err, ErrLockNotHeld):
+			e.logger.Warn("leadership lost", zap.String("id", e.id))
+			return false
+		default:
+			e.logger.Warn("failed to renew leadership", zap.Error(err))
+		}
+	}
+}
+
+// deadline returns when a lease taken or renewed by a call started at
+// start stops being trusted: a tenth of the TTL before it expires, to
+// allow for clock drift and store latency.
+func (e *Elector) deadline(start time.Time) time.Time {
+	return start.Add(e.ttl - e.ttl/10)
+}
+
+// extend renews the lease, bounded by the renew interval and by ctx, the
+// leader's context.
+func (e *Elector) extend(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, e.renew)
+	defer cancel()
+	return e.locker.Extend(ctx, e.key, e.ttl)
+}
+
+func (e *Elector) setLeader(leader bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.leader = leader
+	e.since = time.Time{}
+	if leader {
+		e.since = time.Now()
+	}
+}
+
+// IsLeader reports whether this instance is currently leader.
+func (e *Elector) IsLeader() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.leader
+}
+
+// ID returns this instance's identity: the locker's owner ID. Set OwnerID
+// in the locker config (to a hostname, say) to make it readable.
+func (e *Elector) ID() string {
+	return e.id
+}
+
+// Leader returns the identity of the current leader, or "" if there is
+// none. If the Locker doesn't implement LockOwner, only this instance's
+// own leadership is known.
+func (e *Elector) Leader(ctx context.Context) (string, error) {
+	if o, ok := e.locker.(LockOwner); ok {
+		return o.Owner(ctx, e.key)
+	}
+	if e.IsLeader() {
+		return e.id, nil
+	}
+	return "", nil
+}
+
+// ElectorStatus describes an election, for health and admin endpoints.
+type ElectorStatus struct {
+	Key         string     `json:"key"`
+	ID          string     `json:"id"`
+	Leader      string     `json:"leader,omitempty"`
+	IsLeader    bool       `json:"is_leader"`
+	LeaderSince *time.Time `json:"leader_since,omitempty"` // When this instance became leader
+}
+
+// Status returns the election's current state.
+func (e *Elector) Status(ctx context.Context) (ElectorStatus, error) {
+	leader, err := e.Leader(ctx)
+	if err != nil {
+		return ElectorStatus{}, err
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	st := ElectorStatus{
+		Key:      e.key,
+		ID:       e.id,
+		Leader:   leader,
+		IsLeader: e.leader,
+	}
+	if e.leader {
+		since := e.since
+		st.LeaderSince = &since
+	}
+	return st, nil
+}
+
+// Check returns ErrNoLeader if no instance leads the key. It has the
+// signature of a health.Check.
+func (e *Elector) Check(ctx context.Context) error {
+	leader, err := e.Leader(ctx)
+	if err != nil {
+		return err
+	}
+	if leader == "" {
+		return ErrNoLeader
+	}
+	return nil
+}
@@ -3,8 +3,12 @@ package jobs
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"sync"
 	"time"
+
+	"github.com/redis/go-redis/v9"
 )
 
 // JobStatus represents the status of a job execution.
@@ -112,12 +116,27 @@ type HistoryStore interface {
 	Cleanup(ctx context.Context, maxAge time.Duration) error
 }
 
+// LastRunStore records when each cron job last ran, by scheduled time. The
+// scheduler uses it to find runs missed while it was down (see
+// MisfirePolicy) and to avoid running the same scheduled time twice across
+// instances. History stores implement it optionally; the memory and Redis
+// stores do.
+type LastRunStore interface {
+	// GetLastRun returns the last scheduled time the job ran, or the zero
+	// time if it never ran.
+	GetLastRun(ctx context.Context, jobName string) (time.Time, error)
+
+	// SetLastRun records the scheduled time of a run.
+	SetLastRun(ctx context.Context, jobName string, t time.Time) error
+}
+
 // MemoryHistoryStore stores job history in memory.
 type MemoryHistoryStore struct {
 	mu         sync.RWMutex
 	executions map[string][]*JobExecution // jobName -> executions
 	byID       map[string]*JobExecution   // id -> execution
 	stats      map[string]*JobStats       // jobName -> stats
+	lastRuns   map[string]time.Time       // jobName -> last scheduled run
 	maxPerJob  int
 }
 
@@ -130,6 +149,7 @@ func NewMemoryHistoryStore(maxPerJob int) *MemoryHistoryStore {
 		executions: make(map[string][]*JobExecution),
 		byID:       make(map[string]*JobExecution),
 		stats:      make(map[string]*JobStats),
+		lastRuns:   make(map[string]time.Time),
 		maxPerJob:  maxPerJob,
 	}
 }
@@ -296,6 +316,21 @@ func (s *MemoryHistoryStore) Cleanup(ctx context.Context, maxAge time.Duration)
 	return nil
 }
 
+// GetLastRun returns the last scheduled run time of a job.
+func (s *MemoryHistoryStore) GetLastRun(ctx context.Context, jobName string) (time.Time, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.lastRuns[jobName], nil
+}
+
+// SetLastRun records the scheduled run time of a job.
+func (s *MemoryHistoryStore) SetLastRun(ctx context.Context, jobName string, t time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lastRuns[jobName] = t
+	return nil
+}
+
 // RedisHistoryStore stores job history in Redis.
 type RedisHistoryStore struct {
 	client    RedisHistoryClient
@@ -318,7 +353,8 @@ type RedisHistoryClient interface {
 	// Set sets a key with TTL.
 	Set(ctx context.Context, key, value string, ttl time.Duration) error
 
-	// Get gets a value by key.
+	// Get gets a value by key. A missing key must be reported as
+	// redis.Nil, as go-redis does, or as an empty value.
 	Get(ctx context.Context, key string) (string, error)
 
 	// HSet sets a hash field.
@@ -558,6 +594,25 @@ func (s *RedisHistoryStore) Cleanup(ctx context.Context, maxAge time.Duration) e
 	return nil
 }
 
+// GetLastRun returns the last scheduled run time of a job. Redis errors
+// are returned, so an outage isn't mistaken for a job that never ran.
+func (s *RedisHistoryStore) GetLastRun(ctx context.Context, jobName string) (time.Time, error) {
+	data, err := s.client.Get(ctx, s.prefix+"last_run:"+jobName)
+	if errors.Is(err, redis.Nil) || (err == nil && data == "") {
+		return time.Time{}, nil // Never ran
+	}
+	if err != nil {
+		return time.Time{}, fmt.Errorf("jobs: failed to load last run: %w", err)
+	}
+	return time.Parse(time.RFC3339Nano, data)
+}
+
+// SetLastRun records the scheduled run time of a job. Last-run times don't
+// expire, so missed runs are found after any length of outage.
+func (s *RedisHistoryStore) SetLastRun(ctx context.Context, jobName string, t time.Time) error {
+	return s.client.Set(ctx, s.prefix+"last_run:"+jobName, t.UTC().Format(time.RFC3339Nano), 0)
+}
+
 func parseInt64(s string) (int64, error) {
 	var result int64
 	err := json.Unmarshal([]byte(s), &result)
@@ -3,6 +3,8 @@ package jobs
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -43,8 +45,90 @@ type Job struct {
 	// CreatedAt is when the job was created.
 	CreatedAt time.Time
 
+	// RunAt delays the job until this time. Zero means run as soon as
+	// possible.
+	RunAt time.Time
+
+	// UniqueKey deduplicates jobs. While a job holding the key is waiting
+	// or running, enqueueing another job with the same key returns
+	// ErrDuplicateJob (or merges it, see OnDuplicate). The key is released
+	// when the job succeeds or fails permanently, or after UniqueFor.
+	UniqueKey string
+
+	// UniqueFor bounds how long UniqueKey is held.
+	// Default is 1 hour when UniqueKey is set.
+	UniqueFor time.Duration
+
+	// OnDuplicate chooses what happens when UniqueKey is already held.
+	// Default is DuplicateDrop.
+	OnDuplicate DuplicatePolicy
+
 	// Error holds the last error if the job failed.
 	Error error
+
+	// WorkflowID and StepID link a job to a workflow step. They are set by
+	// Chain and Batch.
+	WorkflowID string
+	StepID     string
+
+	// Input is the output of the previous workflow step as JSON (for a
+	// step after a batch, a BatchResult). Nil outside workflows.
+	Input json.RawMessage
+
+	// Output is the job's result as JSON, set by the handler with
+	// SetOutput. In a workflow it becomes the next step's Input.
+	Output json.RawMessage
+
+	// lease identifies the current claim on a job dequeued from a JobStore.
+	lease string
+
+	// fanOut holds jobs added by FanOut, run as a batch after this step.
+	fanOut []*Job
+}
+
+// DecodePayload decodes the job payload into v. Jobs loaded from a JobStore
+// carry their payload as JSON; in-memory jobs carry the original value,
+// which is converted through JSON.
+func (j *Job) DecodePayload(v any) error {
+	switch p := j.Payload.(type) {
+	case json.RawMessage:
+		return json.Unmarshal(p, v)
+	case []byte:
+		return json.Unmarshal(p, v)
+	default:
+		data, err := json.Marshal(p)
+		if err != nil {
+			return err
+		}
+		return json.Unmarshal(data, v)
+	}
+}
+
+// DecodeInput decodes the previous workflow step's output into v.
+func (j *Job) DecodeInput(v any) error {
+	if len(j.Input) == 0 {
+		return ErrNoInput
+	}
+	return json.Unmarshal(j.Input, v)
+}
+
+// SetOutput records the job's result. In a workflow, the next step
+// receives it as Input.
+func (j *Job) SetOutput(v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	j.Output = data
+	return nil
+}
+
+// FanOut adds jobs to run as a batch after this workflow step succeeds and
+// before the next step, which receives a BatchResult as Input. Use it when
+// the number of jobs is only known at run time (for example, one job per
+// school returned by the step). It has no effect outside a workflow.
+func (j *Job) FanOut(jobs ...*Job) {
+	j.fanOut = append(j.fanOut, jobs...)
 }
 
 // Handler processes jobs of a specific type.
@@ -54,12 +138,19 @@ type Handler func(ctx context.Context, job *Job) error
 type Runner struct {
 	mu       sync.RWMutex
 	handlers map[string]Handler
+	limits   map[string]*typeLimits
+	unique   map[string]*localUnique
 	queue    chan *Job
 	wg       sync.WaitGroup
 	logger   *zap.Logger
 
 	workers    int
 	queueSize  int
+	store      JobStore
+	workflows  WorkflowStore
+	poll       time.Duration
+	visibility time.Duration
+	wake       chan struct{}
 	running    atomic.Bool
 	stopCh     chan struct{}
 	shutdownWg sync.WaitGroup
@@ -82,6 +173,25 @@ type Config struct {
 	// Logger for job processing. Default: no-op logger.
 	Logger *zap.Logger
 
+	// Store persists jobs so they survive restarts and can be shared by
+	// several instances. If nil, jobs are kept in an in-process channel of
+	// QueueSize.
+	Store JobStore
+
+	// PollInterval is how often idle workers check the Store for ready
+	// jobs. Default: 1 second.
+	PollInterval time.Duration
+
+	// VisibilityTimeout is how long a dequeued job stays hidden from other
+	// workers. Workers extend it while a job runs, so it only bounds how
+	// quickly a crashed worker's jobs are picked up again.
+	// Default: 30 seconds.
+	VisibilityTimeout time.Duration
+
+	// Workflows stores the state of chains and batches. Use a persistent
+	// store together with a persistent Store. Default: in-memory store.
+	Workflows WorkflowStore
+
 	// OnStart is called when a job starts processing.
 	OnStart func(*Job)
 
@@ -115,26 +225,47 @@ func New(cfg Config) *Runner {
 	if cfg.Logger == nil {
 		cfg.Logger = zap.NewNop()
 	}
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = time.Second
+	}
+	if cfg.VisibilityTimeout <= 0 {
+		cfg.VisibilityTimeout = 30 * time.Second
+	}
+	if cfg.Workflows == nil {
+		cfg.Workflows = NewMemoryWorkflowStore()
+	}
 
 	return &Runner{
-		handlers:  make(map[string]Handler),
-		queue:     make(chan *Job, cfg.QueueSize),
-		logger:    cfg.Logger,
-		workers:   cfg.Workers,
-		queueSize: cfg.QueueSize,
-		stopCh:    make(chan struct{}),
-		onStart:   cfg.OnStart,
-		onSuccess: cfg.OnSuccess,
-		onError:   cfg.OnError,
-		onRetry:   cfg.OnRetry,
+		handlers:   make(map[string]Handler),
+		limits:     make(map[string]*typeLimits),
+		unique:     make(map[string]*localUnique),
+		queue:      make(chan *Job, cfg.QueueSize),
+		logger:     cfg.Logger,
+		workers:    cfg.Workers,
+		queueSize:  cfg.QueueSize,
+		store:      cfg.Store,
+		workflows:  cfg.Workflows,
+		poll:       cfg.PollInterval,
+		visibility: cfg.VisibilityTimeout,
+		wake:       make(chan struct{}, 1),
+		stopCh:     make(chan struct{}),
+		onStart:    cfg.OnStart,
+		onSuccess:  cfg.OnSuccess,
+		onError:    cfg.OnError,
+		onRetry:    cfg.OnRetry,
 	}
 }
 
-// Register adds a handler for a job type.
-func (r *Runner) Register(jobType string, handler Handler) {
+// Register adds a handler for a job type. Options such as MaxConcurrency
+// and RateLimit limit how jobs of the type run.
+func (r *Runner) Register(jobType string, handler Handler, opts ...TypeOption) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 	r.handlers[jobType] = handler
+	delete(r.limits, jobType)
+	if len(opts) > 0 {
+		r.limits[jobType] = newTypeLimits(opts)
+	}
 }
 
 // Start begins processing jobs with the configured number of workers.
@@ -177,19 +308,56 @@ func (r *Runner) Stop(ctx context.Context) error {
 }
 
 // Enqueue adds a job to the queue for processing.
-// Returns false if the queue is full.
+// Returns false if the queue is full or the Store rejected the job.
 func (r *Runner) Enqueue(job *Job) bool {
-	if job.CreatedAt.IsZero() {
-		job.CreatedAt = time.Now()
-	}
-	if job.MaxRetries == 0 {
-		job.MaxRetries = 3
+	return r.EnqueueContext(context.Background(), job) == nil
+}
+
+// EnqueueContext adds a job to the queue, returning ErrQueueFull if the
+// in-process queue is full, ErrDuplicateJob if another job holds its
+// UniqueKey, or the Store's error.
+func (r *Runner) EnqueueContext(ctx context.Context, job *Job) error {
+	r.applyDefaults(job)
+
+	if r.store != nil {
+		if err := r.store.Enqueue(ctx, job); err != nil {
+			if errors.Is(err, ErrDuplicateJob) {
+				r.duplicate(job)
+				return err
+			}
+			r.logger.Warn("failed to enqueue job",
+				zap.String("id", job.ID),
+				zap.String("type", job.Type),
+				zap.Error(err),
+			)
+			return err
+		}
+		r.logger.Debug("job enqueued",
+			zap.String("id", job.ID),
+			zap.String("type", job.Type),
+		)
+		r.notify()
+		return nil
 	}
-	if job.RetryDelay == 0 {
-		job.RetryDelay = time.Second
+
+	switch err := r.claimUnique(job); err {
+	case nil:
+	case errMerged:
+		r.logger.Debug("job merged into duplicate",
+			zap.String("id", job.ID),
+			zap.String("type", job.Type),
+		)
+		return nil
+	default:
+		r.duplicate(job)
+		return err
 	}
-	if job.Timeout == 0 {
-		job.Timeout = 30 * time.Second
+
+	if d := time.Until(job.RunAt); d > 0 {
+		time.AfterFunc(d, func() {
+			r.Enqueue(job)
+		})
+		return nil
 	}
 
 	select {
@@ -198,16 +366,37 @@ func (r *Runner) Enqueue(job *Job) bool {
 			zap.String("id", job.ID),
 			zap.String("type", job.Type),
 		)
-		return true
+		return nil
 	default:
 		r.logger.Warn("job queue full, dropping job",
 			zap.String("id", job.ID),
 			zap.String("type", job.Type),
 		)
-		return false
+		r.releaseUnique(job)
+		return ErrQueueFull
 	}
 }
 
+// duplicate logs a job dropped because another job holds its UniqueKey.
+func (r *Runner) duplicate(job *Job) {
+	r.logger.Debug("duplicate job dropped",
+		zap.String("id", job.ID),
+		zap.String("type", job.Type),
+		zap.String("unique_key", job.UniqueKey),
+	)
+}
+
+// EnqueueAt schedules a job to run at t.
+func (r *Runner) EnqueueAt(job *Job, t time.Time) bool {
+	job.RunAt = t
+	return r.Enqueue(job)
+}
+
+// EnqueueIn schedules a job to run after d.
+func (r *Runner) EnqueueIn(job *Job, d time.Duration) bool {
+	return r.EnqueueAt(job, time.Now().Add(d))
+}
+
 // EnqueueFunc creates and enqueues a simple job.
 func (r *Runner) EnqueueFunc(jobType string, payload any) bool {
 	return r.Enqueue(&Job{
@@ -217,7 +406,33 @@ func (r *Runner) EnqueueFunc(jobType string, payload any) bool {
 }
 
 // MustEnqueue adds a job to the queue, blocking if full.
+// With a Store, errors are logged.
 func (r *Runner) MustEnqueue(job *Job) {
+	if r.store != nil {
+		r.EnqueueContext(context.Background(), job)
+		return
+	}
+
+	r.applyDefaults(job)
+	if err := r.claimUnique(job); err != nil {
+		if err != errMerged {
+			r.duplicate(job)
+		}
+		return
+	}
+
+	r.queue <- job
+	r.logger.Debug("job enqueued",
+		zap.String("id", job.ID),
+		zap.String("type", job.Type),
+	)
+}
+
+// applyDefaults fills in unset job fields.
+func (r *Runner) applyDefaults(job *Job) {
+	if job.ID == "" {
+		job.ID = generateOwnerID()
+	}
 	if job.CreatedAt.IsZero() {
 		job.CreatedAt = time.Now()
 	}
@@ -230,25 +445,84 @@ func (r *Runner) MustEnqueue(job *Job) {
 	if job.Timeout == 0 {
 		job.Timeout = 30 * time.Second
 	}
+	if job.UniqueKey != "" && job.UniqueFor == 0 {
+		job.UniqueFor = defaultUniqueFor
+	}
+}
 
-	r.queue <- job
-	r.logger.Debug("job enqueued",
-		zap.String("id", job.ID),
-		zap.String("type", job.Type),
-	)
+// notify wakes an idle store worker after a local enqueue.
+func (r *Runner) notify() {
+	select {
+	case r.wake <- struct{}{}:
+	default:
+	}
 }
 
 // QueueLen returns the current number of jobs in the queue.
+// With a Store, it returns the number of jobs ready to run.
 func (r *Runner) QueueLen() int {
+	if r.store != nil {
+		st, err := r.store.Stats(context.Background())
+		if err != nil {
+			return 0
+		}
+		return int(st.Ready)
+	}
 	return len(r.queue)
 }
 
+// Store returns the runner's JobStore, or nil.
+func (r *Runner) Store() JobStore {
+	return r.store
+}
+
+// DeadLetters returns jobs that exhausted MaxRetries. Requires a Store.
+func (r *Runner) DeadLetters(ctx context.Context, limit int) ([]*Job, error) {
+	if r.store == nil {
+		return nil, ErrNoStore
+	}
+	return r.store.DeadLetters(ctx, limit)
+}
+
+// DeadLetter returns one dead job. Requires a Store.
+func (r *Runner) DeadLetter(ctx context.Context, id string) (*Job, error) {
+	if r.store == nil {
+		return nil, ErrNoStore
+	}
+	return r.store.DeadLetter(ctx, id)
+}
+
+// Discard deletes a dead job. Requires a Store.
+func (r *Runner) Discard(ctx context.Context, id string) error {
+	if r.store == nil {
+		return ErrNoStore
+	}
+	return r.store.Discard(ctx, id)
+}
+
+// Requeue moves a dead job back to the queue. Requires a Store.
+func (r *Runner) Requeue(ctx context.Context, id string) error {
+	if r.store == nil {
+		return ErrNoStore
+	}
+	if err := r.store.Requeue(ctx, id); err != nil {
+		return err
+	}
+	r.notify()
+	return nil
+}
+
 // worker processes jobs from the queue.
 func (r *Runner) worker(id int) {
 	defer r.shutdownWg.Done()
 
 	r.logger.Debug("worker started", zap.Int("worker_id", id))
 
+	if r.store != nil {
+		r.storeWorker(id)
+		return
+	}
+
 	for {
 		select {
 		case <-r.stopCh:
@@ -260,31 +534,220 @@ func (r *Runner) worker(id int) {
 	}
 }
 
+// storeWorker polls the Store for ready jobs.
+func (r *Runner) storeWorker(id int) {
+	for {
+		select {
+		case <-r.stopCh:
+			r.logger.Debug("worker stopping", zap.Int("worker_id", id))
+			return
+		default:
+		}
+
+		job, err := r.store.Dequeue(context.Background(), r.types(), r.visibility)
+		if err != nil {
+			r.logger.Error("failed to dequeue job", zap.Int("worker_id", id), zap.Error(err))
+		}
+		if job != nil {
+			r.processStored(job)
+			continue
+		}
+
+		select {
+		case <-r.stopCh:
+			r.logger.Debug("worker stopping", zap.Int("worker_id", id))
+			return
+		case <-r.wake:
+		case <-time.After(r.poll):
+		}
+	}
+}
+
+// types returns the registered job types, leaving out types held back by
+// their limits.
+func (r *Runner) types() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	now := time.Now()
+	types := make([]string, 0, len(r.handlers))
+	for t := range r.handlers {
+		if l := r.limits[t]; l != nil && l.blocked(now) {
+			continue
+		}
+		types = append(types, t)
+	}
+	return types
+}
+
 // process handles a single job with retries.
 func (r *Runner) process(job *Job) {
-	r.mu.RLock()
-	handler, exists := r.handlers[job.Type]
-	r.mu.RUnlock()
+	handler, ok := r.handler(job)
+	if !ok {
+		r.releaseUnique(job)
+		r.failed(job, ErrNoHandler)
+		return
+	}
 
-	if !exists {
-		r.logger.Error("no handler for job type",
-			zap.String("id", job.ID),
-			zap.String("type", job.Type),
-		)
-		if r.onError != nil {
-			r.onError(job, ErrNoHandler)
-		}
+	done, wait := r.admit(job)
+	if done == nil {
+		r.requeueLater(job, wait)
 		return
 	}
 
+	r.startUnique(job)
 	job.Attempts++
 
+	err := r.execute(context.Background(), handler, job)
+	done()
+	if err == nil {
+		r.releaseUnique(job)
+		r.stepDone(job, nil)
+		return
+	}
+
+	// Check if we should retry
+	if job.Attempts < job.MaxRetries {
+		delay := r.retrying(job, err)
+
+		// Schedule retry
+		time.AfterFunc(delay, func() {
+			r.Enqueue(job)
+		})
+		return
+	}
+
+	r.releaseUnique(job)
+	r.failed(job, err)
+}
+
+// processStored handles a job claimed from the Store. The Store has
+// already counted the attempt. Retries and dead-lettering go back through
+// the Store so they survive restarts.
+func (r *Runner) processStored(job *Job) {
+	ctx := context.Background()
+
+	handler, ok := r.handler(job)
+	if !ok {
+		job.Error = ErrNoHandler
+		r.failed(job, job.Error)
+		if err := r.store.Fail(ctx, job); err != nil {
+			r.logger.Error("failed to dead-letter job", zap.String("id", job.ID), zap.Error(err))
+		}
+		return
+	}
+
+	// A job whose visibility expired repeatedly (e.g. its worker kept
+	// crashing) may come back with its attempts already used up.
+	if job.Attempts > job.MaxRetries {
+		job.Error = ErrVisibilityExpired
+		r.failed(job, job.Error)
+		if err := r.store.Fail(ctx, job); err != nil {
+			r.logger.Error("failed to dead-letter job", zap.String("id", job.ID), zap.Error(err))
+		}
+		return
+	}
+
+	done, wait := r.admit(job)
+	if done == nil {
+		if err := r.store.Release(ctx, job, time.Now().Add(wait)); err != nil {
+			r.logger.Warn("failed to release job", zap.String("id", job.ID), zap.Error(err))
+		}
+		return
+	}
+
+	leaseCtx, stop := r.heartbeat(job)
+	err := r.execute(leaseCtx, handler, job)
+	stop()
+	done()
+
+	if errors.Is(context.Cause(leaseCtx), ErrLeaseLost) {
+		// The job is visible again and may be running elsewhere; the
+		// worker that holds it now records the outcome.
+		return
+	}
+
+	if err == nil {
+		// Record workflow progress before acking: if the process dies in
+		// between, the job runs again and the duplicate is ignored.
+		r.stepDone(job, nil)
+		if err := r.store.Ack(ctx, job); err != nil {
+			r.logger.Warn("failed to ack job", zap.String("id", job.ID), zap.Error(err))
+		}
+		return
+	}
+
+	if job.Attempts < job.MaxRetries {
+		delay := r.retrying(job, err)
+		if err := r.store.Retry(ctx, job, time.Now().Add(delay)); err != nil {
+			r.logger.Warn("failed to schedule job retry", zap.String("id", job.ID), zap.Error(err))
+		}
+		return
+	}
+
+	r.failed(job, err)
+	if err := r.store.Fail(ctx, job); err != nil {
+		r.logger.Error("failed to dead-letter job", zap.String("id", job.ID), zap.Error(err))
+	}
+}
+
+// heartbeat extends the job's visibility while it runs. If the lease is
+// lost, the heartbeat stops and cancels the returned context with
+// ErrLeaseLost, since another worker may have claimed the job. The
+// returned function stops it.
+func (r *Runner) heartbeat(job *Job) (context.Context, func()) {
+	ctx, cancel := context.WithCancelCause(context.Background())
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(r.visibility / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				err := r.store.Extend(context.Background(), job, r.visibility)
+				if errors.Is(err, ErrLeaseLost) {
+					r.logger.Warn("job lease lost, canceling job",
+						zap.String("id", job.ID),
+						zap.String("type", job.Type),
+					)
+					cancel(ErrLeaseLost)
+					return
+				}
+				if err != nil {
+					r.logger.Warn("failed to extend job visibility",
+						zap.String("id", job.ID),
+						zap.Error(err),
+					)
+				}
+				r.extendSlot(job)
+			}
+		}
+	}()
+	return ctx, func() {
+		close(done)
+		cancel(nil)
+	}
+}
+
+// handler looks up the handler for a job. Callers fail jobs without one
+// with ErrNoHandler, so workflows record the failed step.
+func (r *Runner) handler(job *Job) (Handler, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	handler, exists := r.handlers[job.Type]
+	return handler, exists
+}
+
+// execute runs the handler with the job's timeout under ctx and calls the
+// start and success hooks.
+func (r *Runner) execute(ctx context.Context, handler Handler, job *Job) error {
 	if r.onStart != nil {
 		r.onStart(job)
 	}
 
 	// Create context with timeout
-	ctx, cancel := context.WithTimeout(context.Background(), job.Timeout)
+	ctx, cancel := context.WithTimeout(ctx, job.Timeout)
 	defer cancel()
 
 	// Execute handler
@@ -299,36 +762,34 @@ func (r *Runner) process(job *Job) {
 		if r.onSuccess != nil {
 			r.onSuccess(job)
 		}
-		return
+		return nil
 	}
 
 	job.Error = err
+	return err
+}
 
-	// Check if we should retry
-	if job.Attempts < job.MaxRetries {
-		delay := r.calculateRetryDelay(job)
-
-		r.logger.Warn("job failed, retrying",
-			zap.String("id", job.ID),
-			zap.String("type", job.Type),
-			zap.Int("attempt", job.Attempts),
-			zap.Int("max_retries", job.MaxRetries),
-			zap.Duration("retry_delay", delay),
-			zap.Error(err),
-		)
+// retrying logs and reports a retry, returning the backoff delay.
+func (r *Runner) retrying(job *Job, err error) time.Duration {
+	delay := r.calculateRetryDelay(job)
 
-		if r.onRetry != nil {
-			r.onRetry(job, err, job.Attempts)
-		}
+	r.logger.Warn("job failed, retrying",
+		zap.String("id", job.ID),
+		zap.String("type", job.Type),
+		zap.Int("attempt", job.Attempts),
+		zap.Int("max_retries", job.MaxRetries),
+		zap.Duration("retry_delay", delay),
+		zap.Error(err),
+	)
 
-		// Schedule retry
-		time.AfterFunc(delay, func() {
-			r.Enqueue(job)
-		})
-		return
+	if r.onRetry != nil {
+		r.onRetry(job, err, job.Attempts)
 	}
+	return delay
+}
 
-	// Permanent failure
+// failed logs and reports a permanent failure.
+func (r *Runner) failed(job *Job, err error) {
 	r.logger.Error("job failed permanently",
 		zap.String("id", job.ID),
 		zap.String("type", job.Type),
@@ -339,6 +800,8 @@ func (r *Runner) process(job *Job) {
 	if r.onError != nil {
 		r.onError(job, err)
 	}
+
+	r.stepDone(job, err)
 }
 
 // calculateRetryDelay applies exponential backoff.
@@ -352,6 +815,14 @@ func (r *Runner) calculateRetryDelay(job *Job) time.Duration {
 var (
 	ErrNoHandler = &jobError{code: "no_handler", message: "no handler registered for job type"}
 	ErrQueueFull = &jobError{code: "queue_full", message: "job queue is full"}
+	ErrNoStore   = &jobError{code: "no_store", message: "runner has no job store"}
+	ErrNoInput   = &jobError{code: "no_input", message: "job has no workflow input"}
+
+	// errMerged reports a job merged into a duplicate; it never leaves
+	// the package.
+	errMerged = &jobError{code: "merged", message: "job merged into duplicate"}
+
+	ErrVisibilityExpired = &jobError{code: "visibility_expired", message: "job visibility timeout expired too many times"}
 )
 
 type jobError struct {
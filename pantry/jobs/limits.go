@@ -0,0 +1,227 @@
+// jobs/limits.go
+package jobs
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/dalemusser/waffle/pantry/ratelimit"
+	"go.uber.org/zap"
+)
+
+// TypeOption configures a job type registered with Runner.Register.
+type TypeOption func(*typeLimits)
+
+// MaxConcurrency limits how many jobs of the type run at once. With a
+// Store that implements LimitStore, the limit is shared by every runner
+// using the store; otherwise it applies to this runner.
+func MaxConcurrency(n int) TypeOption {
+	return func(l *typeLimits) {
+		l.concurrency = n
+	}
+}
+
+// RateLimit limits how often jobs of the type start, using a token bucket
+// refilled at rate jobs per second and holding up to burst tokens (at
+// least 1). It is shared across runners like MaxConcurrency.
+func RateLimit(rate float64, burst int) TypeOption {
+	return func(l *typeLimits) {
+		if burst < 1 {
+			burst = 1
+		}
+		l.rate = rate
+		l.burst = burst
+	}
+}
+
+// LimitStore coordinates per-type limits across runners. A JobStore that
+// also implements it (all the stores in this package do) shares
+// MaxConcurrency and RateLimit between every runner using the store.
+type LimitStore interface {
+	// AcquireSlot takes one of limit concurrency slots for holder until
+	// ttl passes. It returns false if all slots are taken.
+	AcquireSlot(ctx context.Context, jobType, holder string, limit int, ttl time.Duration) (bool, error)
+
+	// ExtendSlot pushes back the expiry of a slot holder still holds.
+	ExtendSlot(ctx context.Context, jobType, holder string, ttl time.Duration) error
+
+	// ReleaseSlot frees holder's slot.
+	ReleaseSlot(ctx context.Context, jobType, holder string) error
+
+	// TakeToken takes a token from the type's bucket. If the bucket is
+	// empty it takes nothing and returns how long until a token is
+	// available.
+	TakeToken(ctx context.Context, jobType string, rate float64, burst int) (time.Duration, error)
+}
+
+// typeLimits holds a job type's limits and, without a LimitStore, their
+// local state.
+type typeLimits struct {
+	concurrency int
+	rate        float64
+	burst       int
+
+	mu           sync.Mutex
+	running      int
+	limiter      *ratelimit.Limiter
+	blockedUntil time.Time
+	full         bool // blocked because every slot was taken
+}
+
+func newTypeLimits(opts []TypeOption) *typeLimits {
+	l := &typeLimits{}
+	for _, opt := range opts {
+		opt(l)
+	}
+	if l.rate > 0 {
+		l.limiter = ratelimit.New(l.rate, l.burst)
+	}
+	return l
+}
+
+// blocked reports whether workers should leave the type alone for now.
+func (l *typeLimits) blocked(now time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return now.Before(l.blockedUntil)
+}
+
+// block holds the type back for d.
+func (l *typeLimits) block(d time.Duration, full bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if until := time.Now().Add(d); until.After(l.blockedUntil) {
+		l.blockedUntil = until
+	}
+	l.full = l.full || full
+}
+
+// unblock lifts a block caused by full slots once a slot frees up.
+func (l *typeLimits) unblock() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.full {
+		l.full = false
+		l.blockedUntil = time.Time{}
+	}
+}
+
+// limitsFor returns the limits of a job type, or nil.
+func (r *Runner) limitsFor(jobType string) *typeLimits {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.limits[jobType]
+}
+
+// limitStore returns the Store's LimitStore, if it has one.
+func (r *Runner) limitStore() (LimitStore, bool) {
+	ls, ok := r.store.(LimitStore)
+	return ls, ok
+}
+
+// admit checks a job against its type's limits. If the job may run, it
+// returns a function to call when the job finishes. Otherwise it returns
+// nil and how long to wait before trying again; this runner's workers skip
+// the type until then. Errors from a LimitStore hold the job back, since
+// the limits usually protect a third-party API.
+func (r *Runner) admit(job *Job) (func(), time.Duration) {
+	l := r.limitsFor(job.Type)
+	if l == nil {
+		return func() {}, 0
+	}
+	ctx := context.Background()
+	ls, shared := r.limitStore()
+
+	done := func() {}
+	if l.concurrency > 0 {
+		ok := false
+		if shared {
+			var err error
+			ok, err = ls.AcquireSlot(ctx, job.Type, job.lease, l.concurrency, r.visibility)
+			if err != nil {
+				r.logger.Warn("failed to acquire job slot", zap.String("type", job.Type), zap.Error(err))
+			}
+			if ok {
+				done = func() {
+					if err := ls.ReleaseSlot(ctx, job.Type, job.lease); err != nil {
+						r.logger.Warn("failed to release job slot", zap.String("type", job.Type), zap.Error(err))
+					}
+				}
+			}
+		} else {
+			l.mu.Lock()
+			if l.running < l.concurrency {
+				l.running++
+				ok = true
+			}
+			l.mu.Unlock()
+			if ok {
+				done = func() {
+					l.mu.Lock()
+					l.running--
+					l.mu.Unlock()
+				}
+			}
+		}
+		if !ok {
+			l.block(r.poll, true)
+			return nil, r.poll
+		}
+		slot := done
+		done = func() {
+			slot()
+			l.unblock()
+			r.notify()
+		}
+	}
+
+	if l.rate > 0 {
+		var wait time.Duration
+		if shared {
+			var err error
+			wait, err = ls.TakeToken(ctx, job.Type, l.rate, l.burst)
+			if err != nil {
+				r.logger.Warn("failed to take job rate token", zap.String("type", job.Type), zap.Error(err))
+				wait = r.poll
+			}
+		} else if !l.limiter.Allow() {
+			wait = time.Duration((1 - l.limiter.Tokens()) / l.rate * float64(time.Second))
+		}
+		if wait > 0 {
+			done()
+			l.block(wait, false)
+			return nil, wait
+		}
+	}
+
+	return done, 0
+}
+
+// extendSlot keeps a running job's shared concurrency slot alive.
+func (r *Runner) extendSlot(job *Job) {
+	l := r.limitsFor(job.Type)
+	ls, shared := r.limitStore()
+	if l == nil || l.concurrency <= 0 || !shared {
+		return
+	}
+	if err := ls.ExtendSlot(context.Background(), job.Type, job.lease, r.visibility); err != nil {
+		r.logger.Warn("failed to extend job slot", zap.String("type", job.Type), zap.Error(err))
+	}
+}
+
+// requeueLater puts a job held back by its limits back on the in-process
+// queue after wait.
+func (r *Runner) requeueLater(job *Job, wait time.Duration) {
+	r.logger.Debug("job held back by type limits",
+		zap.String("id", job.ID),
+		zap.String("type", job.Type),
+		zap.Duration("wait", wait),
+	)
+	time.AfterFunc(wait, func() {
+		select {
+		case r.queue <- job:
+		case <-r.stopCh:
+		}
+	})
+}
@@ -8,6 +8,8 @@ import (
 	"fmt"
 	"sync"
 	"time"
+
+	"github.com/redis/go-redis/v9"
 )
 
 // Common lock errors.
@@ -183,6 +185,21 @@ func (l *RedisLocker) IsHeld(ctx context.Context, key string) (bool, error) {
 	return value == l.ownerID, nil
 }
 
+// Owner returns the owner ID of the instance holding a lock, or "" if the
+// lock is free. The client's Get must report a missing key as redis.Nil
+// (or an empty value); other errors are returned, so an unreachable Redis
+// isn't mistaken for a free lock.
+func (l *RedisLocker) Owner(ctx context.Context, key string) (string, error) {
+	value, err := l.client.Get(ctx, l.prefix+key)
+	if errors.Is(err, redis.Nil) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("jobs: failed to check lock: %w", err)
+	}
+	return value, nil
+}
+
 // OwnerID returns this locker's owner ID.
 func (l *RedisLocker) OwnerID() string {
 	return l.ownerID
@@ -287,6 +304,24 @@ func (l *MemoryLocker) IsHeld(ctx context.Context, key string) (bool, error) {
 	return existing.owner == l.ownerID, nil
 }
 
+// Owner returns the owner ID of the instance holding a lock, or "" if the
+// lock is free.
+func (l *MemoryLocker) Owner(ctx context.Context, key string) (string, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	existing, ok := l.locks[key]
+	if !ok || existing.expires.Before(time.Now()) {
+		return "", nil
+	}
+	return existing.owner, nil
+}
+
+// OwnerID returns this locker's owner ID.
+func (l *MemoryLocker) OwnerID() string {
+	return l.ownerID
+}
+
 // Cleanup removes expired locks.
 func (l *MemoryLocker) Cleanup() {
 	l.mu.Lock()
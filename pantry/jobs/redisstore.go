@@ -0,0 +1,528 @@
+// jobs/redisstore.go
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisJobStore is a JobStore backed by Redis. Keys for one queue share a
+// hash tag so the store also works on Redis Cluster.
+//
+// Layout (with the default prefix "{jobs}:"):
+//
+//	{jobs}:job:<id>      hash: data, type, prio, seq, attempts, lease, unique
+//	{jobs}:ready:<type>  zset: score -priority, member "<seq>|<id>" (FIFO within a priority)
+//	{jobs}:scheduled     zset: score run-at (unix ms), member id
+//	{jobs}:inflight      zset: score visibility deadline (unix ms), member id
+//	{jobs}:dead          zset: score failed-at (unix ms), member id
+//	{jobs}:types         set of job types seen
+//	{jobs}:unique:<key>  string: id of the job holding a unique key (expires after UniqueFor)
+//	{jobs}:slots:<type>  zset: score slot expiry (unix ms), member holder (MaxConcurrency)
+//	{jobs}:bucket:<type> hash: tokens, ts (RateLimit)
+type RedisJobStore struct {
+	client redis.UniversalClient
+	prefix string
+}
+
+// RedisJobStoreConfig configures the Redis job store.
+type RedisJobStoreConfig struct {
+	// Client is the Redis client.
+	Client redis.UniversalClient
+
+	// Prefix is the key prefix. Keep a {hash tag} in it for Redis Cluster.
+	// Default: "{jobs}:"
+	Prefix string
+}
+
+// NewRedisJobStore creates a Redis-backed job store.
+func NewRedisJobStore(cfg RedisJobStoreConfig) *RedisJobStore {
+	if cfg.Prefix == "" {
+		cfg.Prefix = "{jobs}:"
+	}
+	return &RedisJobStore{
+		client: cfg.Client,
+		prefix: cfg.Prefix,
+	}
+}
+
+// redisReleaseUniqueLua frees a job's unique key if the job still holds it.
+const redisReleaseUniqueLua = `
+local function releaseUnique(p, jk, id)
+  local key = redis.call('HGET', jk, 'unique')
+  if key and key ~= '' and redis.call('GET', p .. 'unique:' .. key) == id then
+    redis.call('DEL', p .. 'unique:' .. key)
+  end
+end
+`
+
+// All scripts take the key prefix as ARGV[1] and a routing key as KEYS[1]
+// so cluster clients send them to the right slot.
+var (
+	// Returns 0 if the ID exists, 1 if enqueued, or the ID of the job
+	// holding the unique key ARGV[8].
+	redisEnqueueScript = redis.NewScript(`
+local p, id = ARGV[1], ARGV[2]
+local jk = p .. 'job:' .. id
+if redis.call('EXISTS', jk) == 1 then return 0 end
+if ARGV[8] ~= '' then
+  local uk = p .. 'unique:' .. ARGV[8]
+  local holder = redis.call('GET', uk)
+  if holder and redis.call('EXISTS', p .. 'job:' .. holder) == 1 then return holder end
+  redis.call('SET', uk, id, 'PX', ARGV[9])
+end
+local seq = string.format('%020d', redis.call('INCR', p .. 'seq'))
+redis.call('HMSET', jk, 'data', ARGV[3], 'type', ARGV[4], 'prio', ARGV[5], 'seq', seq, 'attempts', 0, 'unique', ARGV[8])
+redis.call('SADD', p .. 'types', ARGV[4])
+if tonumber(ARGV[6]) > tonumber(ARGV[7]) then
+  redis.call('ZADD', p .. 'scheduled', ARGV[6], id)
+else
+  redis.call('ZADD', p .. 'ready:' .. ARGV[4], -tonumber(ARGV[5]), seq .. '|' .. id)
+end
+return 1
+`)
+
+	redisDequeueScript = redis.NewScript(`
+local p, now, deadline, lease = ARGV[1], ARGV[2], ARGV[3], ARGV[4]
+local function ready(id)
+  local f = redis.call('HMGET', p .. 'job:' .. id, 'type', 'prio', 'seq')
+  if f[1] then
+    redis.call('HDEL', p .. 'job:' .. id, 'lease')
+    redis.call('ZADD', p .. 'ready:' .. f[1], -tonumber(f[2]), f[3] .. '|' .. id)
+  end
+end
+for _, id in ipairs(redis.call('ZRANGEBYSCORE', p .. 'scheduled', '-inf', now, 'LIMIT', 0, 100)) do
+  redis.call('ZREM', p .. 'scheduled', id)
+  ready(id)
+end
+for _, id in ipairs(redis.call('ZRANGEBYSCORE', p .. 'inflight', '-inf', now, 'LIMIT', 0, 100)) do
+  redis.call('ZREM', p .. 'inflight', id)
+  ready(id)
+end
+local bestKey, bestMember, bestScore
+for i = 5, #ARGV do
+  local key = p .. 'ready:' .. ARGV[i]
+  local r = redis.call('ZRANGE', key, 0, 0, 'WITHSCORES')
+  if r[1] then
+    local s = tonumber(r[2])
+    if not bestKey or s < bestScore or (s == bestScore and r[1] < bestMember) then
+      bestKey, bestMember, bestScore = key, r[1], s
+    end
+  end
+end
+if not bestKey then return false end
+redis.call('ZREM', bestKey, bestMember)
+local id = string.sub(bestMember, string.find(bestMember, '|', 1, true) + 1)
+local jk = p .. 'job:' .. id
+local data = redis.call('HGET', jk, 'data')
+if not data then return false end
+local attempts = redis.call('HINCRBY', jk, 'attempts', 1)
+redis.call('HSET', jk, 'lease', lease)
+redis.call('ZADD', p .. 'inflight', deadline, id)
+return {data, attempts}
+`)
+
+	redisExtendScript = redis.NewScript(`
+local p, id = ARGV[1], ARGV[2]
+if redis.call('HGET', p .. 'job:' .. id, 'lease') ~= ARGV[3] then return 0 end
+redis.call('ZADD', p .. 'inflight', 'XX', ARGV[4], id)
+return 1
+`)
+
+	// Replaces the data of a job that is waiting (not claimed or dead) and
+	// moves it according to its new run time ARGV[4].
+	redisReplaceScript = redis.NewScript(`
+local p, id, now = ARGV[1], ARGV[2], ARGV[5]
+local jk = p .. 'job:' .. id
+if redis.call('EXISTS', jk) == 0 or redis.call('HEXISTS', jk, 'lease') == 1 then return 0 end
+if redis.call('ZSCORE', p .. 'dead', id) then return 0 end
+local f = redis.call('HMGET', jk, 'type', 'prio', 'seq')
+redis.call('HSET', jk, 'data', ARGV[3])
+redis.call('ZREM', p .. 'scheduled', id)
+redis.call('ZREM', p .. 'ready:' .. f[1], f[3] .. '|' .. id)
+if tonumber(ARGV[4]) > tonumber(now) then
+  redis.call('ZADD', p .. 'scheduled', ARGV[4], id)
+else
+  redis.call('ZADD', p .. 'ready:' .. f[1], -tonumber(f[2]), f[3] .. '|' .. id)
+end
+return 1
+`)
+
+	redisAckScript = redis.NewScript(redisReleaseUniqueLua + `
+local p, id = ARGV[1], ARGV[2]
+local jk = p .. 'job:' .. id
+if redis.call('HGET', jk, 'lease') ~= ARGV[3] then return 0 end
+releaseUnique(p, jk, id)
+redis.call('DEL', jk)
+redis.call('ZREM', p .. 'inflight', id)
+return 1
+`)
+
+	// ARGV[5] is the target set ("scheduled" or "dead"), ARGV[6] its score.
+	redisReleaseScript = redis.NewScript(redisReleaseUniqueLua + `
+local p, id = ARGV[1], ARGV[2]
+local jk = p .. 'job:' .. id
+if redis.call('HGET', jk, 'lease') ~= ARGV[3] then return 0 end
+if ARGV[5] == 'dead' then releaseUnique(p, jk, id) end
+redis.call('HSET', jk, 'data', ARGV[4])
+redis.call('HDEL', jk, 'lease')
+redis.call('ZREM', p .. 'inflight', id)
+redis.call('ZADD', p .. ARGV[5], ARGV[6], id)
+return 1
+`)
+
+	// Returns a claimed job to the scheduled set without counting the
+	// attempt. ARGV[4] is the run time.
+	redisUnclaimScript = redis.NewScript(`
+local p, id = ARGV[1], ARGV[2]
+local jk = p .. 'job:' .. id
+if redis.call('HGET', jk, 'lease') ~= ARGV[3] then return 0 end
+redis.call('HDEL', jk, 'lease')
+redis.call('HINCRBY', jk, 'attempts', -1)
+redis.call('ZREM', p .. 'inflight', id)
+redis.call('ZADD', p .. 'scheduled', ARGV[4], id)
+return 1
+`)
+
+	redisDiscardScript = redis.NewScript(`
+local p, id = ARGV[1], ARGV[2]
+if redis.call('ZREM', p .. 'dead', id) == 0 then return 0 end
+redis.call('DEL', p .. 'job:' .. id)
+return 1
+`)
+
+	redisRequeueScript = redis.NewScript(`
+local p, id = ARGV[1], ARGV[2]
+if redis.call('ZREM', p .. 'dead', id) == 0 then return 0 end
+local jk = p .. 'job:' .. id
+redis.call('HSET', jk, 'attempts', 0)
+local f = redis.call('HMGET', jk, 'type', 'prio', 'seq')
+redis.call('ZADD', p .. 'ready:' .. f[1], -tonumber(f[2]), f[3] .. '|' .. id)
+return 1
+`)
+)
+
+func (s *RedisJobStore) keys() []string {
+	return []string{s.prefix + "seq"}
+}
+
+// Enqueue adds a job.
+func (s *RedisJobStore) Enqueue(ctx context.Context, job *Job) error {
+	data, err := encodeJob(job)
+	if err != nil {
+		return fmt.Errorf("jobs: failed to encode job: %w", err)
+	}
+	res, err := redisEnqueueScript.Run(ctx, s.client, s.keys(),
+		s.prefix, job.ID, data, job.Type, job.Priority,
+		job.RunAt.UnixMilli(), time.Now().UnixMilli(),
+		job.UniqueKey, uniqueTTL(job).Milliseconds()).Result()
+	if err != nil {
+		return fmt.Errorf("jobs: failed to enqueue: %w", err)
+	}
+	switch v := res.(type) {
+	case int64:
+		if v == 0 {
+			return ErrJobExists
+		}
+		return nil
+	case string:
+		job.ID = v
+		if job.OnDuplicate == DuplicateReplace {
+			return s.replace(ctx, job)
+		}
+		return ErrDuplicateJob
+	default:
+		return fmt.Errorf("jobs: failed to enqueue: unexpected reply %T", res)
+	}
+}
+
+// replace merges job into the waiting job with its ID, replacing the
+// payload and run time.
+func (s *RedisJobStore) replace(ctx context.Context, job *Job) error {
+	held, err := s.load(ctx, job.ID)
+	if errors.Is(err, ErrJobNotFound) {
+		return ErrDuplicateJob
+	}
+	if err != nil {
+		return err
+	}
+	held.Payload = job.Payload
+	held.RunAt = job.RunAt
+	data, err := encodeJob(held)
+	if err != nil {
+		return fmt.Errorf("jobs: failed to encode job: %w", err)
+	}
+	n, err := redisReplaceScript.Run(ctx, s.client, s.keys(),
+		s.prefix, job.ID, data, job.RunAt.UnixMilli(), time.Now().UnixMilli()).Int()
+	if err != nil {
+		return fmt.Errorf("jobs: failed to replace job: %w", err)
+	}
+	if n == 0 {
+		return ErrDuplicateJob
+	}
+	return nil
+}
+
+// Dequeue claims the next ready job.
+func (s *RedisJobStore) Dequeue(ctx context.Context, types []string, visibility time.Duration) (*Job, error) {
+	if len(types) == 0 {
+		return nil, nil
+	}
+
+	now := time.Now()
+	lease := generateOwnerID()
+	args := []any{s.prefix, now.UnixMilli(), now.Add(visibility).UnixMilli(), lease}
+	for _, t := range types {
+		args = append(args, t)
+	}
+
+	res, err := redisDequeueScript.Run(ctx, s.client, s.keys(), args...).Slice()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("jobs: failed to dequeue: %w", err)
+	}
+
+	data, _ := res[0].(string)
+	attempts, _ := res[1].(int64)
+	job, err := decodeJob([]byte(data), int(attempts))
+	if err != nil {
+		return nil, fmt.Errorf("jobs: failed to decode job: %w", err)
+	}
+	job.lease = lease
+	return job, nil
+}
+
+func (s *RedisJobStore) leased(ctx context.Context, script *redis.Script, job *Job, args ...any) error {
+	args = append([]any{s.prefix, job.ID, job.lease}, args...)
+	n, err := script.Run(ctx, s.client, s.keys(), args...).Int()
+	if err != nil {
+		return fmt.Errorf("jobs: redis job store: %w", err)
+	}
+	if n == 0 {
+		return ErrLeaseLost
+	}
+	return nil
+}
+
+// Extend pushes back the visibility deadline.
+func (s *RedisJobStore) Extend(ctx context.Context, job *Job, visibility time.Duration) error {
+	return s.leased(ctx, redisExtendScript, job, time.Now().Add(visibility).UnixMilli())
+}
+
+// Ack removes a completed job.
+func (s *RedisJobStore) Ack(ctx context.Context, job *Job) error {
+	return s.leased(ctx, redisAckScript, job)
+}
+
+// Retry releases a job to run again at runAt.
+func (s *RedisJobStore) Retry(ctx context.Context, job *Job, runAt time.Time) error {
+	data, err := encodeJob(job)
+	if err != nil {
+		return fmt.Errorf("jobs: failed to encode job: %w", err)
+	}
+	return s.leased(ctx, redisReleaseScript, job, data, "scheduled", runAt.UnixMilli())
+}
+
+// Fail moves a job to the dead-letter set.
+func (s *RedisJobStore) Fail(ctx context.Context, job *Job) error {
+	data, err := encodeJob(job)
+	if err != nil {
+		return fmt.Errorf("jobs: failed to encode job: %w", err)
+	}
+	return s.leased(ctx, redisReleaseScript, job, data, "dead", time.Now().UnixMilli())
+}
+
+// Release returns a job to run at runAt without counting the attempt.
+func (s *RedisJobStore) Release(ctx context.Context, job *Job, runAt time.Time) error {
+	return s.leased(ctx, redisUnclaimScript, job, runAt.UnixMilli())
+}
+
+// DeadLetters returns dead jobs, most recently failed first.
+func (s *RedisJobStore) DeadLetters(ctx context.Context, limit int) ([]*Job, error) {
+	if limit <= 0 {
+		limit = 100
+	}
+	ids, err := s.client.ZRevRange(ctx, s.prefix+"dead", 0, int64(limit-1)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("jobs: failed to list dead letters: %w", err)
+	}
+
+	out := make([]*Job, 0, len(ids))
+	for _, id := range ids {
+		job, err := s.load(ctx, id)
+		if errors.Is(err, ErrJobNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, job)
+	}
+	return out, nil
+}
+
+// DeadLetter returns one dead job.
+func (s *RedisJobStore) DeadLetter(ctx context.Context, id string) (*Job, error) {
+	err := s.client.ZScore(ctx, s.prefix+"dead", id).Err()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrJobNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("jobs: failed to load dead letter: %w", err)
+	}
+	return s.load(ctx, id)
+}
+
+// load reads a job hash.
+func (s *RedisJobStore) load(ctx context.Context, id string) (*Job, error) {
+	vals, err := s.client.HMGet(ctx, s.prefix+"job:"+id, "data", "attempts").Result()
+	if err != nil {
+		return nil, fmt.Errorf("jobs: failed to load job: %w", err)
+	}
+	data, ok := vals[0].(string)
+	if !ok {
+		return nil, ErrJobNotFound
+	}
+	attempts := 0
+	if a, ok := vals[1].(string); ok {
+		attempts, _ = strconv.Atoi(a)
+	}
+	job, err := decodeJob([]byte(data), attempts)
+	if err != nil {
+		return nil, fmt.Errorf("jobs: failed to decode job: %w", err)
+	}
+	return job, nil
+}
+
+// Discard deletes a dead job.
+func (s *RedisJobStore) Discard(ctx context.Context, id string) error {
+	n, err := redisDiscardScript.Run(ctx, s.client, s.keys(), s.prefix, id).Int()
+	if err != nil {
+		return fmt.Errorf("jobs: failed to discard job: %w", err)
+	}
+	if n == 0 {
+		return ErrJobNotFound
+	}
+	return nil
+}
+
+// Requeue moves a dead job back to the queue.
+func (s *RedisJobStore) Requeue(ctx context.Context, id string) error {
+	n, err := redisRequeueScript.Run(ctx, s.client, s.keys(), s.prefix, id).Int()
+	if err != nil {
+		return fmt.Errorf("jobs: failed to requeue: %w", err)
+	}
+	if n == 0 {
+		return ErrJobNotFound
+	}
+	return nil
+}
+
+// Stats returns queue counts. Jobs that are due but not yet moved out of
+// the scheduled set count as scheduled.
+func (s *RedisJobStore) Stats(ctx context.Context) (StoreStats, error) {
+	types, err := s.client.SMembers(ctx, s.prefix+"types").Result()
+	if err != nil {
+		return StoreStats{}, fmt.Errorf("jobs: failed to read stats: %w", err)
+	}
+
+	pipe := s.client.Pipeline()
+	scheduled := pipe.ZCard(ctx, s.prefix+"scheduled")
+	inflight := pipe.ZCard(ctx, s.prefix+"inflight")
+	dead := pipe.ZCard(ctx, s.prefix+"dead")
+	ready := make([]*redis.IntCmd, len(types))
+	for i, t := range types {
+		ready[i] = pipe.ZCard(ctx, s.prefix+"ready:"+t)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return StoreStats{}, fmt.Errorf("jobs: failed to read stats: %w", err)
+	}
+
+	st := StoreStats{
+		Scheduled: scheduled.Val(),
+		InFlight:  inflight.Val(),
+		Dead:      dead.Val(),
+	}
+	for _, c := range ready {
+		st.Ready += c.Val()
+	}
+	return st, nil
+}
+
+var (
+	redisAcquireSlotScript = redis.NewScript(`
+local k = ARGV[1] .. 'slots:' .. ARGV[2]
+redis.call('ZREMRANGEBYSCORE', k, '-inf', ARGV[4])
+if redis.call('ZSCORE', k, ARGV[3]) or redis.call('ZCARD', k) < tonumber(ARGV[5]) then
+  redis.call('ZADD', k, ARGV[6], ARGV[3])
+  redis.call('PEXPIRE', k, ARGV[7])
+  return 1
+end
+return 0
+`)
+
+	// Returns 0 if a token was taken, otherwise the wait in milliseconds.
+	redisTakeTokenScript = redis.NewScript(`
+local k = ARGV[1] .. 'bucket:' .. ARGV[2]
+local rate, burst, now = tonumber(ARGV[3]), tonumber(ARGV[4]), tonumber(ARGV[5])
+local f = redis.call('HMGET', k, 'tokens', 'ts')
+local tokens, ts = tonumber(f[1]), tonumber(f[2])
+if not tokens then tokens, ts = burst, now end
+tokens = math.min(burst, tokens + math.max(0, now - ts) / 1000 * rate)
+local wait = 0
+if tokens >= 1 then
+  tokens = tokens - 1
+else
+  wait = math.ceil((1 - tokens) / rate * 1000)
+end
+redis.call('HMSET', k, 'tokens', tostring(tokens), 'ts', now)
+redis.call('PEXPIRE', k, math.ceil(burst / rate * 1000) + 1000)
+return wait
+`)
+)
+
+// AcquireSlot takes a concurrency slot for holder.
+func (s *RedisJobStore) AcquireSlot(ctx context.Context, jobType, holder string, limit int, ttl time.Duration) (bool, error) {
+	now := time.Now()
+	n, err := redisAcquireSlotScript.Run(ctx, s.client, s.keys(),
+		s.prefix, jobType, holder, now.UnixMilli(), limit, now.Add(ttl).UnixMilli(), ttl.Milliseconds()).Int()
+	if err != nil {
+		return false, fmt.Errorf("jobs: failed to acquire slot: %w", err)
+	}
+	return n == 1, nil
+}
+
+// ExtendSlot pushes back the expiry of holder's slot.
+func (s *RedisJobStore) ExtendSlot(ctx context.Context, jobType, holder string, ttl time.Duration) error {
+	k := s.prefix + "slots:" + jobType
+	err := s.client.ZAddXX(ctx, k, redis.Z{Score: float64(time.Now().Add(ttl).UnixMilli()), Member: holder}).Err()
+	if err != nil {
+		return fmt.Errorf("jobs: failed to extend slot: %w", err)
+	}
+	return nil
+}
+
+// ReleaseSlot frees holder's slot.
+func (s *RedisJobStore) ReleaseSlot(ctx context.Context, jobType, holder string) error {
+	if err := s.client.ZRem(ctx, s.prefix+"slots:"+jobType, holder).Err(); err != nil {
+		return fmt.Errorf("jobs: failed to release slot: %w", err)
+	}
+	return nil
+}
+
+// TakeToken takes a token from the type's bucket.
+func (s *RedisJobStore) TakeToken(ctx context.Context, jobType string, rate float64, burst int) (time.Duration, error) {
+	ms, err := redisTakeTokenScript.Run(ctx, s.client, s.keys(),
+		s.prefix, jobType, rate, burst, time.Now().UnixMilli()).Int64()
+	if err != nil {
+		return 0, fmt.Errorf("jobs: failed to take token: %w", err)
+	}
+	return time.Duration(ms) * time.Millisecond, nil
+}
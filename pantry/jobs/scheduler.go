@@ -50,12 +50,14 @@ type scheduledEntry struct {
 	job    *ScheduledJob
 	ticker *time.Ticker
 	stopCh chan struct{}
+	paused bool
 }
 
 type cronScheduledEntry struct {
 	job     *CronJob
 	nextRun time.Time
 	stopCh  chan struct{}
+	paused  bool
 }
 
 // SchedulerOption configures the scheduler.
@@ -173,6 +175,14 @@ func (s *Scheduler) AddCron(job *CronJob) error {
 		job.Timeout = 5 * time.Minute
 	}
 
+	if job.Jitter < 0 {
+		return fmt.Errorf("jobs: cron job %q: jitter must not be negative", job.Name)
+	}
+
+	// A CRON_TZ= prefix takes precedence over Location
+	if loc := parsed.Location(); loc != nil {
+		job.Location = loc
+	}
 	if job.Location == nil {
 		job.Location = time.Local
 	}
@@ -252,7 +262,7 @@ func (s *Scheduler) startJob(entry *scheduledEntry) {
 
 		// Run immediately if configured
 		if entry.job.RunImmediately {
-			s.executeJob(entry.job.Name, entry.job.Handler, entry.job.Timeout)
+			s.executeJob(entry.job.Name, entry.job.Handler, entry.job.Timeout, time.Time{})
 		}
 
 		for {
@@ -262,7 +272,10 @@ func (s *Scheduler) startJob(entry *scheduledEntry) {
 			case <-s.stopCh:
 				return
 			case <-entry.ticker.C:
-				s.executeJob(entry.job.Name, entry.job.Handler, entry.job.Timeout)
+				if s.isPaused(entry.job.Name) {
+					continue
+				}
+				s.executeJob(entry.job.Name, entry.job.Handler, entry.job.Timeout, time.Time{})
 			}
 		}
 	}()
@@ -271,33 +284,52 @@ func (s *Scheduler) startJob(entry *scheduledEntry) {
 // startCronJob begins execution of a cron-scheduled job.
 func (s *Scheduler) startCronJob(entry *cronScheduledEntry) {
 	entry.stopCh = make(chan struct{})
+	job := entry.job
 
 	// Calculate first run time
-	now := time.Now()
-	if entry.job.Location != nil {
-		now = now.In(entry.job.Location)
-	}
-	entry.nextRun = entry.job.parsed.Next(now)
+	entry.nextRun = job.NextRun(time.Now())
 
 	s.wg.Add(1)
 	go func() {
 		defer s.wg.Done()
 
+		// The last scheduled run, to find runs missed while no scheduler
+		// was running or while a previous run overran.
+		last := s.lastRun(job.Name)
+
 		for {
-			// Calculate time until next run
-			now := time.Now()
-			if entry.job.Location != nil {
-				now = now.In(entry.job.Location)
+			for _, t := range job.missedRuns(last, time.Now()) {
+				select {
+				case <-entry.stopCh:
+					return
+				case <-s.stopCh:
+					return
+				default:
+				}
+				if s.isPaused(job.Name) {
+					last = t
+					continue
+				}
+				s.logger.Info("running missed cron job",
+					zap.String("name", job.Name),
+					zap.Time("scheduled", t),
+					zap.Stringer("misfire", job.Misfire),
+				)
+				s.executeJob(job.Name, job.Handler, job.Timeout, t)
+				last = t
 			}
 
-			waitDuration := entry.nextRun.Sub(now)
-			if waitDuration < 0 {
-				// Missed execution, calculate next
-				entry.nextRun = entry.job.parsed.Next(now)
-				continue
+			// Calculate next run
+			next := job.NextRun(time.Now())
+			s.mu.Lock()
+			entry.nextRun = next
+			s.mu.Unlock()
+			if next.IsZero() {
+				s.logger.Warn("cron job has no future runs", zap.String("name", job.Name))
+				return
 			}
 
-			timer := time.NewTimer(waitDuration)
+			timer := time.NewTimer(job.wait(next, time.Now()))
 
 			select {
 			case <-entry.stopCh:
@@ -307,21 +339,38 @@ func (s *Scheduler) startCronJob(entry *cronScheduledEntry) {
 				timer.Stop()
 				return
 			case <-timer.C:
-				s.executeJob(entry.job.Name, entry.job.Handler, entry.job.Timeout)
-
-				// Calculate next run
-				now = time.Now()
-				if entry.job.Location != nil {
-					now = now.In(entry.job.Location)
+				if s.isPaused(job.Name) {
+					s.logger.Debug("skipping paused cron job", zap.String("name", job.Name))
+				} else {
+					s.executeJob(job.Name, job.Handler, job.Timeout, next)
 				}
-				entry.nextRun = entry.job.parsed.Next(now)
+				last = next
 			}
 		}
 	}()
 }
 
+// lastRun returns the recorded last run of a cron job, or zero.
+func (s *Scheduler) lastRun(name string) time.Time {
+	store, ok := s.history.(LastRunStore)
+	if !ok {
+		return time.Time{}
+	}
+	t, err := store.GetLastRun(context.Background(), name)
+	if err != nil {
+		s.logger.Warn("failed to read last run",
+			zap.String("name", name),
+			zap.Error(err),
+		)
+		return time.Time{}
+	}
+	return t
+}
+
 // executeJob runs a job with optional locking and history recording.
-func (s *Scheduler) executeJob(name string, handler func(ctx context.Context) error, timeout time.Duration) {
+// For cron runs, scheduled is the time the run was due; it is recorded in
+// a LastRunStore, and a scheduled time that already ran is skipped.
+func (s *Scheduler) executeJob(name string, handler func(ctx context.Context) error, timeout time.Duration, scheduled time.Time) {
 	ctx, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
 
@@ -367,10 +416,32 @@ func (s *Scheduler) executeJob(name string, handler func(ctx context.Context) er
 		defer s.locker.Release(ctx, lockKey)
 	}
 
+	// Another instance may have run this scheduled time already
+	lastRuns, _ := s.history.(LastRunStore)
+	if lastRuns != nil && !scheduled.IsZero() {
+		if last, err := lastRuns.GetLastRun(ctx, name); err == nil && !last.Before(scheduled) {
+			s.logger.Debug("skipping job, scheduled run already completed",
+				zap.String("name", name),
+				zap.Time("scheduled", scheduled),
+			)
+			s.recordComplete(ctx, execID, name, start, JobStatusSkipped, nil)
+			return
+		}
+	}
+
 	// Execute the job
 	err := handler(ctx)
 	duration := time.Since(start)
 
+	if lastRuns != nil && !scheduled.IsZero() {
+		if err := lastRuns.SetLastRun(ctx, name, scheduled); err != nil {
+			s.logger.Warn("failed to record last run",
+				zap.String("name", name),
+				zap.Error(err),
+			)
+		}
+	}
+
 	if err != nil {
 		s.logger.Error("scheduled job failed",
 			zap.String("name", name),
@@ -486,6 +557,7 @@ func (s *Scheduler) Get(name string) (*JobInfo, bool) {
 			Interval:       entry.job.Interval,
 			Timeout:        entry.job.Timeout,
 			RunImmediately: entry.job.RunImmediately,
+			Paused:         entry.paused,
 		}, true
 	}
 
@@ -498,6 +570,9 @@ func (s *Scheduler) Get(name string) (*JobInfo, bool) {
 			Timeout:  entry.job.Timeout,
 			NextRun:  entry.nextRun,
 			Location: entry.job.Location,
+			Jitter:   entry.job.Jitter,
+			Misfire:  entry.job.Misfire,
+			Paused:   entry.paused,
 		}, true
 	}
 
@@ -533,6 +608,7 @@ func (s *Scheduler) ListJobs() []*JobInfo {
 			Interval:       entry.job.Interval,
 			Timeout:        entry.job.Timeout,
 			RunImmediately: entry.job.RunImmediately,
+			Paused:         entry.paused,
 		})
 	}
 
@@ -544,6 +620,9 @@ func (s *Scheduler) ListJobs() []*JobInfo {
 			Timeout:  entry.job.Timeout,
 			NextRun:  entry.nextRun,
 			Location: entry.job.Location,
+			Jitter:   entry.job.Jitter,
+			Misfire:  entry.job.Misfire,
+			Paused:   entry.paused,
 		})
 	}
 
@@ -557,14 +636,14 @@ func (s *Scheduler) RunNow(ctx context.Context, name string) error {
 	// Check interval jobs
 	if entry, exists := s.jobs[name]; exists {
 		s.mu.RUnlock()
-		s.executeJob(entry.job.Name, entry.job.Handler, entry.job.Timeout)
+		s.executeJob(entry.job.Name, entry.job.Handler, entry.job.Timeout, time.Time{})
 		return nil
 	}
 
 	// Check cron jobs
 	if entry, exists := s.cronJobs[name]; exists {
 		s.mu.RUnlock()
-		s.executeJob(entry.job.Name, entry.job.Handler, entry.job.Timeout)
+		s.executeJob(entry.job.Name, entry.job.Handler, entry.job.Timeout, time.Time{})
 		return nil
 	}
 
@@ -572,6 +651,52 @@ func (s *Scheduler) RunNow(ctx context.Context, name string) error {
 	return fmt.Errorf("jobs: job %q not found", name)
 }
 
+// Pause stops a job from running on schedule until Resume is called. Runs
+// that fall due while paused are skipped, not caught up. RunNow still runs
+// a paused job. Pausing affects only this scheduler; other instances
+// sharing a Locker keep running the job.
+func (s *Scheduler) Pause(name string) error {
+	return s.setPaused(name, true)
+}
+
+// Resume restarts a paused job.
+func (s *Scheduler) Resume(name string) error {
+	return s.setPaused(name, false)
+}
+
+func (s *Scheduler) setPaused(name string, paused bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if entry, exists := s.jobs[name]; exists {
+		entry.paused = paused
+	} else if entry, exists := s.cronJobs[name]; exists {
+		entry.paused = paused
+	} else {
+		return fmt.Errorf("jobs: job %q not found", name)
+	}
+
+	if paused {
+		s.logger.Info("scheduled job paused", zap.String("name", name))
+	} else {
+		s.logger.Info("scheduled job resumed", zap.String("name", name))
+	}
+	return nil
+}
+
+// isPaused reports whether a job is paused.
+func (s *Scheduler) isPaused(name string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if entry, exists := s.jobs[name]; exists {
+		return entry.paused
+	}
+	if entry, exists := s.cronJobs[name]; exists {
+		return entry.paused
+	}
+	return false
+}
+
 // IsRunning returns whether the scheduler is running.
 func (s *Scheduler) IsRunning() bool {
 	s.mu.RLock()
@@ -594,6 +719,9 @@ type JobInfo struct {
 	RunImmediately bool
 	NextRun        time.Time
 	Location       *time.Location
+	Jitter         time.Duration
+	Misfire        MisfirePolicy
+	Paused         bool
 }
 
 // History returns the history store if configured.
@@ -0,0 +1,430 @@
+// jobs/sqlhistory.go
+package jobs
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// SQLHistoryStore stores job history in PostgreSQL, MySQL or SQLite. It
+// also implements LastRunStore.
+//
+// Stats are computed from the executions table with indexed aggregate
+// queries, so they cover the executions Cleanup has kept. Times are stored
+// as unix milliseconds and durations as nanoseconds so the same schema
+// works on every dialect.
+type SQLHistoryStore struct {
+	db        *sql.DB
+	dialect   SQLDialect
+	table     string
+	lastRuns  string
+	maxPerJob int
+}
+
+// SQLHistoryStoreConfig configures the SQL history store.
+type SQLHistoryStoreConfig struct {
+	// DB is the database handle.
+	DB *sql.DB
+
+	// Dialect selects PostgreSQL, MySQL or SQLite syntax.
+	// Default: DialectPostgres
+	Dialect SQLDialect
+
+	// Table is the executions table name. Last-run times go in a second
+	// table with a "_last_runs" suffix.
+	// Default: "jobs_executions"
+	Table string
+
+	// MaxPerJob caps the executions Cleanup keeps per job, in addition to
+	// its age limit. Zero keeps every execution younger than the age limit.
+	MaxPerJob int
+}
+
+// NewSQLHistoryStore creates a SQL-backed history store. Call CreateTables
+// once (or apply Schema as a migration) before use.
+func NewSQLHistoryStore(cfg SQLHistoryStoreConfig) *SQLHistoryStore {
+	if cfg.Table == "" {
+		cfg.Table = "jobs_executions"
+	}
+	return &SQLHistoryStore{
+		db:        cfg.DB,
+		dialect:   cfg.Dialect,
+		table:     cfg.Table,
+		lastRuns:  cfg.Table + "_last_runs",
+		maxPerJob: cfg.MaxPerJob,
+	}
+}
+
+// Schema returns the statements that create the history tables and their
+// indexes, for use in migrations.
+func (s *SQLHistoryStore) Schema() []string {
+	columns := `
+			id           VARCHAR(64) PRIMARY KEY,
+			job_name     VARCHAR(255) NOT NULL,
+			status       VARCHAR(16) NOT NULL,
+			started_at   BIGINT NOT NULL,
+			completed_at BIGINT,
+			duration_ns  BIGINT,
+			error        TEXT,
+			worker_id    VARCHAR(64),
+			metadata     TEXT`
+	lastRuns := `CREATE TABLE IF NOT EXISTS ` + s.lastRuns + ` (
+			job_name VARCHAR(255) PRIMARY KEY,
+			last_run BIGINT NOT NULL
+		)`
+
+	// MySQL has no CREATE INDEX IF NOT EXISTS, so its indexes are declared
+	// with the table.
+	if s.dialect == DialectMySQL {
+		return []string{
+			`CREATE TABLE IF NOT EXISTS ` + s.table + ` (` + columns + `,
+			INDEX ` + s.table + `_job_idx (job_name, started_at),
+			INDEX ` + s.table + `_started_idx (started_at)
+		)`,
+			lastRuns,
+		}
+	}
+	return []string{
+		`CREATE TABLE IF NOT EXISTS ` + s.table + ` (` + columns + `
+		)`,
+		`CREATE INDEX IF NOT EXISTS ` + s.table + `_job_idx ON ` + s.table + ` (job_name, started_at)`,
+		`CREATE INDEX IF NOT EXISTS ` + s.table + `_started_idx ON ` + s.table + ` (started_at)`,
+		lastRuns,
+	}
+}
+
+// CreateTables creates the history tables if they don't exist.
+func (s *SQLHistoryStore) CreateTables(ctx context.Context) error {
+	for _, q := range s.Schema() {
+		if _, err := s.db.ExecContext(ctx, q); err != nil {
+			return fmt.Errorf("jobs: failed to create history tables: %w", err)
+		}
+	}
+	return nil
+}
+
+func (s *SQLHistoryStore) rebind(q string) string {
+	return rebind(s.dialect, q)
+}
+
+// RecordStart records the start of a job execution.
+func (s *SQLHistoryStore) RecordStart(ctx context.Context, exec *JobExecution) error {
+	metadata, err := marshalMetadata(exec.Metadata)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.ExecContext(ctx, s.rebind(`INSERT INTO `+s.table+`
+		(id, job_name, status, started_at, worker_id, metadata) VALUES (?, ?, ?, ?, ?, ?)`),
+		exec.ID, exec.JobName, string(exec.Status), exec.StartedAt.UnixMilli(), exec.WorkerID, metadata)
+	if err != nil {
+		return fmt.Errorf("jobs: failed to record execution: %w", err)
+	}
+	return nil
+}
+
+// RecordComplete records the completion of a job execution. An execution
+// without a recorded start is inserted.
+func (s *SQLHistoryStore) RecordComplete(ctx context.Context, exec *JobExecution) error {
+	var completed sql.NullInt64
+	if exec.CompletedAt != nil {
+		completed = sql.NullInt64{Int64: exec.CompletedAt.UnixMilli(), Valid: true}
+	}
+
+	res, err := s.db.ExecContext(ctx, s.rebind(`UPDATE `+s.table+`
+		SET status = ?, completed_at = ?, duration_ns = ?, error = ?
+		WHERE id = ?`),
+		string(exec.Status), completed, int64(exec.Duration), nullString(exec.Error), exec.ID)
+	if err != nil {
+		return fmt.Errorf("jobs: failed to record execution: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n > 0 {
+		return nil
+	}
+
+	metadata, err := marshalMetadata(exec.Metadata)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.ExecContext(ctx, s.rebind(`INSERT INTO `+s.table+`
+		(id, job_name, status, started_at, completed_at, duration_ns, error, worker_id, metadata)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`),
+		exec.ID, exec.JobName, string(exec.Status), exec.StartedAt.UnixMilli(), completed,
+		int64(exec.Duration), nullString(exec.Error), exec.WorkerID, metadata)
+	if err != nil {
+		return fmt.Errorf("jobs: failed to record execution: %w", err)
+	}
+	return nil
+}
+
+const sqlExecutionColumns = `id, job_name, status, started_at, completed_at, duration_ns, error, worker_id, metadata`
+
+func scanExecution(sc interface{ Scan(...any) error }) (*JobExecution, error) {
+	var (
+		exec      JobExecution
+		status    string
+		started   int64
+		completed sql.NullInt64
+		duration  sql.NullInt64
+		errMsg    sql.NullString
+		workerID  sql.NullString
+		metadata  sql.NullString
+	)
+	if err := sc.Scan(&exec.ID, &exec.JobName, &status, &started, &completed,
+		&duration, &errMsg, &workerID, &metadata); err != nil {
+		return nil, err
+	}
+
+	exec.Status = JobStatus(status)
+	exec.StartedAt = time.UnixMilli(started)
+	if completed.Valid {
+		t := time.UnixMilli(completed.Int64)
+		exec.CompletedAt = &t
+	}
+	exec.Duration = time.Duration(duration.Int64)
+	exec.Error = errMsg.String
+	exec.WorkerID = workerID.String
+	if metadata.String != "" {
+		if err := json.Unmarshal([]byte(metadata.String), &exec.Metadata); err != nil {
+			return nil, err
+		}
+	}
+	return &exec, nil
+}
+
+// GetExecutions returns recent executions for a job, most recent first.
+func (s *SQLHistoryStore) GetExecutions(ctx context.Context, jobName string, limit int) ([]*JobExecution, error) {
+	if limit <= 0 {
+		limit = 100
+	}
+	rows, err := s.db.QueryContext(ctx, s.rebind(`SELECT `+sqlExecutionColumns+` FROM `+s.table+`
+		WHERE job_name = ? ORDER BY started_at DESC LIMIT ?`), jobName, limit)
+	if err != nil {
+		return nil, fmt.Errorf("jobs: failed to load executions: %w", err)
+	}
+	defer rows.Close()
+
+	var result []*JobExecution
+	for rows.Next() {
+		exec, err := scanExecution(rows)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, exec)
+	}
+	return result, rows.Err()
+}
+
+// GetExecution returns a specific execution by ID, or nil if not found.
+func (s *SQLHistoryStore) GetExecution(ctx context.Context, id string) (*JobExecution, error) {
+	exec, err := scanExecution(s.db.QueryRowContext(ctx,
+		s.rebind(`SELECT `+sqlExecutionColumns+` FROM `+s.table+` WHERE id = ?`), id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("jobs: failed to load execution: %w", err)
+	}
+	return exec, nil
+}
+
+// sqlStatsColumns aggregates executions per job. Durations count finished
+// runs only, as in the other stores.
+const sqlStatsColumns = `job_name,
+	COUNT(*),
+	SUM(CASE WHEN status = 'completed' THEN 1 ELSE 0 END),
+	SUM(CASE WHEN status = 'failed' THEN 1 ELSE 0 END),
+	SUM(CASE WHEN status = 'skipped' THEN 1 ELSE 0 END),
+	MAX(started_at),
+	MAX(CASE WHEN status = 'completed' THEN completed_at END),
+	MAX(CASE WHEN status = 'failed' THEN completed_at END),
+	AVG(CASE WHEN status IN ('completed', 'failed') THEN duration_ns END),
+	MIN(CASE WHEN status IN ('completed', 'failed') THEN duration_ns END),
+	MAX(CASE WHEN status IN ('completed', 'failed') THEN duration_ns END)`
+
+func scanStats(sc interface{ Scan(...any) error }) (*JobStats, error) {
+	var (
+		stats                      JobStats
+		succeeded, failed, skipped sql.NullInt64
+		lastExec, lastOK, lastErr  sql.NullInt64
+		avg                        sql.NullFloat64
+		minDur, maxDur             sql.NullInt64
+	)
+	if err := sc.Scan(&stats.JobName, &stats.TotalExecutions, &succeeded, &failed, &skipped,
+		&lastExec, &lastOK, &lastErr, &avg, &minDur, &maxDur); err != nil {
+		return nil, err
+	}
+
+	stats.SuccessfulExecutions = succeeded.Int64
+	stats.FailedExecutions = failed.Int64
+	stats.SkippedExecutions = skipped.Int64
+	stats.LastExecution = nullTime(lastExec)
+	stats.LastSuccess = nullTime(lastOK)
+	stats.LastFailure = nullTime(lastErr)
+	stats.AverageDuration = time.Duration(avg.Float64)
+	stats.MinDuration = time.Duration(minDur.Int64)
+	stats.MaxDuration = time.Duration(maxDur.Int64)
+	return &stats, nil
+}
+
+// GetStats returns statistics for a job.
+func (s *SQLHistoryStore) GetStats(ctx context.Context, jobName string) (*JobStats, error) {
+	stats, err := scanStats(s.db.QueryRowContext(ctx, s.rebind(`SELECT `+sqlStatsColumns+`
+		FROM `+s.table+` WHERE job_name = ? GROUP BY job_name`), jobName))
+	if errors.Is(err, sql.ErrNoRows) {
+		return &JobStats{JobName: jobName}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("jobs: failed to load job stats: %w", err)
+	}
+	if stats.FailedExecutions > 0 {
+		if stats.LastError, err = s.lastError(ctx, jobName); err != nil {
+			return nil, err
+		}
+	}
+	return stats, nil
+}
+
+// GetAllStats returns statistics for all jobs.
+func (s *SQLHistoryStore) GetAllStats(ctx context.Context) (map[string]*JobStats, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT `+sqlStatsColumns+`
+		FROM `+s.table+` GROUP BY job_name`)
+	if err != nil {
+		return nil, fmt.Errorf("jobs: failed to load job stats: %w", err)
+	}
+
+	result := make(map[string]*JobStats)
+	for rows.Next() {
+		stats, err := scanStats(rows)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		result[stats.JobName] = stats
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for name, stats := range result {
+		if stats.FailedExecutions > 0 {
+			if stats.LastError, err = s.lastError(ctx, name); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return result, nil
+}
+
+// lastError returns the error of a job's most recent failure.
+func (s *SQLHistoryStore) lastError(ctx context.Context, jobName string) (string, error) {
+	var msg sql.NullString
+	err := s.db.QueryRowContext(ctx, s.rebind(`SELECT error FROM `+s.table+`
+		WHERE job_name = ? AND status = 'failed' ORDER BY started_at DESC LIMIT 1`), jobName).Scan(&msg)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return "", fmt.Errorf("jobs: failed to load last error: %w", err)
+	}
+	return msg.String, nil
+}
+
+// Cleanup removes executions older than maxAge and, if MaxPerJob is set,
+// all but the newest MaxPerJob executions of each job. Run it periodically,
+// for example as a scheduled job.
+func (s *SQLHistoryStore) Cleanup(ctx context.Context, maxAge time.Duration) error {
+	cutoff := time.Now().Add(-maxAge).UnixMilli()
+	if _, err := s.db.ExecContext(ctx, s.rebind(`DELETE FROM `+s.table+` WHERE started_at < ?`), cutoff); err != nil {
+		return fmt.Errorf("jobs: failed to clean up history: %w", err)
+	}
+	if s.maxPerJob <= 0 {
+		return nil
+	}
+
+	rows, err := s.db.QueryContext(ctx, s.rebind(`SELECT job_name FROM `+s.table+`
+		GROUP BY job_name HAVING COUNT(*) > ?`), s.maxPerJob)
+	if err != nil {
+		return fmt.Errorf("jobs: failed to clean up history: %w", err)
+	}
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return err
+		}
+		names = append(names, name)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		// Find the start time of the oldest execution to keep
+		var keepFrom int64
+		err := s.db.QueryRowContext(ctx, s.rebind(`SELECT started_at FROM `+s.table+`
+			WHERE job_name = ? ORDER BY started_at DESC LIMIT 1 OFFSET ?`), name, s.maxPerJob-1).Scan(&keepFrom)
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("jobs: failed to clean up history: %w", err)
+		}
+		if _, err := s.db.ExecContext(ctx, s.rebind(`DELETE FROM `+s.table+`
+			WHERE job_name = ? AND started_at < ?`), name, keepFrom); err != nil {
+			return fmt.Errorf("jobs: failed to clean up history: %w", err)
+		}
+	}
+	return nil
+}
+
+// GetLastRun returns the last scheduled run time of a job.
+func (s *SQLHistoryStore) GetLastRun(ctx context.Context, jobName string) (time.Time, error) {
+	var ns int64
+	err := s.db.QueryRowContext(ctx, s.rebind(`SELECT last_run FROM `+s.lastRuns+` WHERE job_name = ?`),
+		jobName).Scan(&ns)
+	if errors.Is(err, sql.ErrNoRows) {
+		return time.Time{}, nil // Never ran
+	}
+	if err != nil {
+		return time.Time{}, fmt.Errorf("jobs: failed to load last run: %w", err)
+	}
+	return time.Unix(0, ns), nil
+}
+
+// SetLastRun records the scheduled run time of a job, with nanosecond
+// precision so it compares equal to the scheduled time.
+func (s *SQLHistoryStore) SetLastRun(ctx context.Context, jobName string, t time.Time) error {
+	q := `INSERT INTO ` + s.lastRuns + ` (job_name, last_run) VALUES (?, ?)
+		ON CONFLICT (job_name) DO UPDATE SET last_run = excluded.last_run`
+	if s.dialect == DialectMySQL {
+		q = `INSERT INTO ` + s.lastRuns + ` (job_name, last_run) VALUES (?, ?)
+		ON DUPLICATE KEY UPDATE last_run = VALUES(last_run)`
+	}
+	if _, err := s.db.ExecContext(ctx, s.rebind(q), jobName, t.UnixNano()); err != nil {
+		return fmt.Errorf("jobs: failed to record last run: %w", err)
+	}
+	return nil
+}
+
+func marshalMetadata(m map[string]string) (sql.NullString, error) {
+	if len(m) == 0 {
+		return sql.NullString{}, nil
+	}
+	data, err := json.Marshal(m)
+	if err != nil {
+		return sql.NullString{}, err
+	}
+	return sql.NullString{String: string(data), Valid: true}, nil
+}
+
+func nullTime(ms sql.NullInt64) *time.Time {
+	if !ms.Valid {
+		return nil
+	}
+	t := time.UnixMilli(ms.Int64)
+	return &t
+}
@@ -0,0 +1,235 @@
+// jobs/sqllock.go
+package jobs
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// SQLLocker implements distributed locking with a lease table in
+// PostgreSQL, MySQL or SQLite, for deployments without Redis.
+//
+// Each lock is a row holding the owner, an expiry and a fencing token. The
+// token increases every time the lock changes hands, and Extend and Release
+// only succeed while the row still carries the token this locker acquired.
+// So a locker that paused past its lease (a GC pause, a lost network) can't
+// extend or release a lock another instance has since taken. Pass Token to
+// downstream systems that should reject writes from a stale holder.
+//
+// Rows are kept after release so tokens never go backwards; there is one
+// row per lock name. Expiry uses each instance's clock, so keep clocks
+// synchronized (NTP).
+type SQLLocker struct {
+	db      *sql.DB
+	dialect SQLDialect
+	table   string
+	ownerID string
+	mu      sync.Mutex
+	tokens  map[string]int64
+}
+
+// SQLLockerConfig configures the SQL locker.
+type SQLLockerConfig struct {
+	// DB is the database handle.
+	DB *sql.DB
+
+	// Dialect selects PostgreSQL, MySQL or SQLite syntax.
+	// Default: DialectPostgres
+	Dialect SQLDialect
+
+	// Table is the lock table name.
+	// Default: "jobs_locks"
+	Table string
+
+	// OwnerID is the unique identifier for this instance.
+	// Default: random ID
+	OwnerID string
+}
+
+// NewSQLLocker creates a SQL-backed distributed locker. Call CreateTable
+// once (or apply Schema as a migration) before use.
+func NewSQLLocker(cfg SQLLockerConfig) *SQLLocker {
+	if cfg.Table == "" {
+		cfg.Table = "jobs_locks"
+	}
+	if cfg.OwnerID == "" {
+		cfg.OwnerID = generateOwnerID()
+	}
+	return &SQLLocker{
+		db:      cfg.DB,
+		dialect: cfg.Dialect,
+		table:   cfg.Table,
+		ownerID: cfg.OwnerID,
+		tokens:  make(map[string]int64),
+	}
+}
+
+// Schema returns the statements that create the lock table, for use in
+// migrations.
+func (l *SQLLocker) Schema() []string {
+	return []string{
+		`CREATE TABLE IF NOT EXISTS ` + l.table + ` (
+			name       VARCHAR(255) PRIMARY KEY,
+			owner      VARCHAR(64) NOT NULL,
+			token      BIGINT NOT NULL,
+			expires_at BIGINT NOT NULL
+		)`,
+	}
+}
+
+// CreateTable creates the lock table if it doesn't exist.
+func (l *SQLLocker) CreateTable(ctx context.Context) error {
+	for _, q := range l.Schema() {
+		if _, err := l.db.ExecContext(ctx, q); err != nil {
+			return fmt.Errorf("jobs: failed to create lock table: %w", err)
+		}
+	}
+	return nil
+}
+
+// Acquire attempts to acquire a lock. Acquiring a lock this locker still
+// holds extends it and keeps its token; otherwise the token increases.
+func (l *SQLLocker) Acquire(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	now := time.Now().UnixMilli()
+	expires := now + ttl.Milliseconds()
+
+	// Take over an expired or released lock, or refresh our own. The token
+	// is set first because MySQL applies assignments in order.
+	res, err := l.db.ExecContext(ctx, rebind(l.dialect, `UPDATE `+l.table+`
+		SET token = CASE WHEN owner = ? AND expires_at > ? THEN token ELSE token + 1 END, owner = ?, expires_at = ?
+		WHERE name = ? AND (owner = ? OR expires_at <= ?)`),
+		l.ownerID, now, l.ownerID, expires, key, l.ownerID, now)
+	if err != nil {
+		return false, fmt.Errorf("jobs: failed to acquire lock: %w", err)
+	}
+	n, _ := res.RowsAffected()
+
+	if n == 0 {
+		// No row, or held by someone else
+		insert := `INSERT INTO ` + l.table + ` (name, owner, token, expires_at) VALUES (?, ?, 1, ?) ON CONFLICT (name) DO NOTHING`
+		if l.dialect == DialectMySQL {
+			insert = `INSERT IGNORE INTO ` + l.table + ` (name, owner, token, expires_at) VALUES (?, ?, 1, ?)`
+		}
+		res, err = l.db.ExecContext(ctx, rebind(l.dialect, insert), key, l.ownerID, expires)
+		if err != nil {
+			return false, fmt.Errorf("jobs: failed to acquire lock: %w", err)
+		}
+		if n, _ = res.RowsAffected(); n == 0 {
+			return false, nil
+		}
+	}
+
+	var token int64
+	err = l.db.QueryRowContext(ctx, rebind(l.dialect,
+		`SELECT token FROM `+l.table+` WHERE name = ? AND owner = ?`), key, l.ownerID).Scan(&token)
+	if errors.Is(err, sql.ErrNoRows) {
+		// Lost it already (ttl shorter than the round trip)
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("jobs: failed to acquire lock: %w", err)
+	}
+
+	l.mu.Lock()
+	l.tokens[key] = token
+	l.mu.Unlock()
+
+	return true, nil
+}
+
+// Release releases a lock if it still carries our token. The row is
+// expired rather than deleted, keeping its token.
+func (l *SQLLocker) Release(ctx context.Context, key string) (bool, error) {
+	token, ok := l.Token(key)
+	if !ok {
+		return false, nil
+	}
+
+	res, err := l.db.ExecContext(ctx, rebind(l.dialect,
+		`UPDATE `+l.table+` SET expires_at = 0 WHERE name = ? AND owner = ? AND token = ? AND expires_at > 0`),
+		key, l.ownerID, token)
+	if err != nil {
+		return false, fmt.Errorf("jobs: failed to release lock: %w", err)
+	}
+
+	l.mu.Lock()
+	delete(l.tokens, key)
+	l.mu.Unlock()
+
+	n, _ := res.RowsAffected()
+	return n == 1, nil
+}
+
+// Extend extends the TTL of a held lock. It fails with ErrLockNotHeld if
+// the lease expired or the lock was taken over since it was acquired.
+func (l *SQLLocker) Extend(ctx context.Context, key string, ttl time.Duration) error {
+	token, ok := l.Token(key)
+	if !ok {
+		return ErrLockNotHeld
+	}
+
+	now := time.Now().UnixMilli()
+	res, err := l.db.ExecContext(ctx, rebind(l.dialect,
+		`UPDATE `+l.table+` SET expires_at = ? WHERE name = ? AND owner = ? AND token = ? AND expires_at > ?`),
+		now+ttl.Milliseconds(), key, l.ownerID, token, now)
+	if err != nil {
+		return fmt.Errorf("jobs: failed to extend lock: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrLockNotHeld
+	}
+	return nil
+}
+
+// IsHeld returns true if we currently hold the lock.
+func (l *SQLLocker) IsHeld(ctx context.Context, key string) (bool, error) {
+	var (
+		owner   string
+		token   int64
+		expires int64
+	)
+	err := l.db.QueryRowContext(ctx, rebind(l.dialect,
+		`SELECT owner, token, expires_at FROM `+l.table+` WHERE name = ?`), key).Scan(&owner, &token, &expires)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("jobs: failed to check lock: %w", err)
+	}
+
+	held, _ := l.Token(key)
+	return owner == l.ownerID && token == held && expires > time.Now().UnixMilli(), nil
+}
+
+// Owner returns the owner ID of the instance holding a lock, or "" if the
+// lock is free.
+func (l *SQLLocker) Owner(ctx context.Context, key string) (string, error) {
+	var owner string
+	err := l.db.QueryRowContext(ctx, rebind(l.dialect,
+		`SELECT owner FROM `+l.table+` WHERE name = ? AND expires_at > ?`), key, time.Now().UnixMilli()).Scan(&owner)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("jobs: failed to check lock: %w", err)
+	}
+	return owner, nil
+}
+
+// Token returns the fencing token of a lock this locker acquired. Tokens
+// increase each time a lock changes hands.
+func (l *SQLLocker) Token(key string) (int64, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	token, ok := l.tokens[key]
+	return token, ok
+}
+
+// OwnerID returns this locker's owner ID.
+func (l *SQLLocker) OwnerID() string {
+	return l.ownerID
+}
@@ -0,0 +1,548 @@
+// jobs/sqlstore.go
+package jobs
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SQLDialect selects SQL syntax for the SQL stores.
+type SQLDialect int
+
+const (
+	// DialectPostgres uses $n placeholders and SKIP LOCKED so many workers
+	// can dequeue concurrently.
+	DialectPostgres SQLDialect = iota
+
+	// DialectSQLite uses ? placeholders. SQLite serializes writers, so
+	// dequeues are atomic without row locks.
+	DialectSQLite
+
+	// DialectMySQL uses ? placeholders and MySQL upserts. It is supported
+	// by SQLHistoryStore and SQLLocker; SQLJobStore and SQLWorkflowStore
+	// need PostgreSQL or SQLite.
+	DialectMySQL
+)
+
+// errMySQLUnsupported is returned by stores that don't support MySQL.
+var errMySQLUnsupported = errors.New("jobs: MySQL is not supported by this store; use PostgreSQL or SQLite")
+
+// SQLJobStore is a JobStore backed by PostgreSQL or SQLite. Times are
+// stored as unix milliseconds so the same schema works on both.
+//
+// It also implements LimitStore with two small tables next to the queue:
+// <table>_slots holds concurrency slots and <table>_buckets holds rate
+// limit buckets.
+type SQLJobStore struct {
+	db      *sql.DB
+	dialect SQLDialect
+	table   string
+}
+
+// SQLJobStoreConfig configures the SQL job store.
+type SQLJobStoreConfig struct {
+	// DB is the database handle.
+	DB *sql.DB
+
+	// Dialect selects PostgreSQL or SQLite syntax.
+	// Default: DialectPostgres
+	Dialect SQLDialect
+
+	// Table is the queue table name.
+	// Default: "jobs_queue"
+	Table string
+}
+
+// NewSQLJobStore creates a SQL-backed job store. Call CreateTable once
+// (or apply the equivalent migration) before use.
+func NewSQLJobStore(cfg SQLJobStoreConfig) *SQLJobStore {
+	if cfg.Table == "" {
+		cfg.Table = "jobs_queue"
+	}
+	return &SQLJobStore{
+		db:      cfg.DB,
+		dialect: cfg.Dialect,
+		table:   cfg.Table,
+	}
+}
+
+// NewPostgresJobStore creates a job store using PostgreSQL.
+func NewPostgresJobStore(db *sql.DB) *SQLJobStore {
+	return NewSQLJobStore(SQLJobStoreConfig{DB: db, Dialect: DialectPostgres})
+}
+
+// NewSQLiteJobStore creates a job store using SQLite.
+func NewSQLiteJobStore(db *sql.DB) *SQLJobStore {
+	return NewSQLJobStore(SQLJobStoreConfig{DB: db, Dialect: DialectSQLite})
+}
+
+// Schema returns the statements that create the queue table, its indexes
+// and the limit tables, for use in migrations. MySQL isn't supported, so
+// it returns nil for DialectMySQL.
+func (s *SQLJobStore) Schema() []string {
+	if s.dialect == DialectMySQL {
+		return nil
+	}
+	return []string{
+		`CREATE TABLE IF NOT EXISTS ` + s.table + ` (
+			id             TEXT PRIMARY KEY,
+			type           TEXT NOT NULL,
+			payload        TEXT,
+			priority       INTEGER NOT NULL DEFAULT 0,
+			max_retries    INTEGER NOT NULL,
+			retry_delay_ms BIGINT NOT NULL,
+			timeout_ms     BIGINT NOT NULL,
+			attempts       INTEGER NOT NULL DEFAULT 0,
+			state          TEXT NOT NULL,
+			lease          TEXT,
+			available_at   BIGINT NOT NULL,
+			created_at     BIGINT NOT NULL,
+			enqueued_at    BIGINT NOT NULL,
+			last_error     TEXT,
+			failed_at      BIGINT,
+			workflow_id    TEXT,
+			step_id        TEXT,
+			input          TEXT,
+			unique_key     TEXT,
+			unique_until   BIGINT
+		)`,
+		`CREATE INDEX IF NOT EXISTS ` + s.table + `_dequeue_idx ON ` + s.table + ` (state, available_at)`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS ` + s.table + `_unique_idx ON ` + s.table + ` (unique_key)`,
+		`CREATE TABLE IF NOT EXISTS ` + s.table + `_slots (
+			type       TEXT NOT NULL,
+			slot       INTEGER NOT NULL,
+			holder     TEXT NOT NULL,
+			expires_at BIGINT NOT NULL,
+			PRIMARY KEY (type, slot)
+		)`,
+		`CREATE TABLE IF NOT EXISTS ` + s.table + `_buckets (
+			type       TEXT PRIMARY KEY,
+			tokens     DOUBLE PRECISION NOT NULL,
+			updated_at BIGINT NOT NULL,
+			version    BIGINT NOT NULL
+		)`,
+	}
+}
+
+// CreateTable creates the queue table, its indexes and the limit tables if
+// they don't exist.
+func (s *SQLJobStore) CreateTable(ctx context.Context) error {
+	if s.dialect == DialectMySQL {
+		return errMySQLUnsupported
+	}
+	for _, q := range s.Schema() {
+		if _, err := s.db.ExecContext(ctx, q); err != nil {
+			return fmt.Errorf("jobs: failed to create job table: %w", err)
+		}
+	}
+	return nil
+}
+
+func (s *SQLJobStore) rebind(q string) string {
+	return rebind(s.dialect, q)
+}
+
+// rebind converts ? placeholders to $n for PostgreSQL.
+func rebind(dialect SQLDialect, q string) string {
+	if dialect != DialectPostgres {
+		return q
+	}
+	var b strings.Builder
+	n := 0
+	for _, r := range q {
+		if r == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+const sqlJobColumns = `id, type, payload, priority, max_retries, retry_delay_ms, timeout_ms, attempts, created_at, last_error, workflow_id, step_id, input, unique_key`
+
+func scanJob(sc interface{ Scan(...any) error }) (*Job, error) {
+	var (
+		job                 Job
+		payload, lastError  sql.NullString
+		workflowID, stepID  sql.NullString
+		input, uniqueKey    sql.NullString
+		retryDelay, timeout int64
+		createdAt           int64
+	)
+	if err := sc.Scan(&job.ID, &job.Type, &payload, &job.Priority, &job.MaxRetries,
+		&retryDelay, &timeout, &job.Attempts, &createdAt, &lastError,
+		&workflowID, &stepID, &input, &uniqueKey); err != nil {
+		return nil, err
+	}
+	job.WorkflowID = workflowID.String
+	job.UniqueKey = uniqueKey.String
+	job.StepID = stepID.String
+	if input.Valid && input.String != "" {
+		job.Input = json.RawMessage(input.String)
+	}
+	if payload.Valid && payload.String != "" {
+		job.Payload = json.RawMessage(payload.String)
+	}
+	job.RetryDelay = time.Duration(retryDelay) * time.Millisecond
+	job.Timeout = time.Duration(timeout) * time.Millisecond
+	job.CreatedAt = time.UnixMilli(createdAt)
+	if lastError.Valid && lastError.String != "" {
+		job.Error = errors.New(lastError.String)
+	}
+	return &job, nil
+}
+
+// Enqueue adds a job.
+func (s *SQLJobStore) Enqueue(ctx context.Context, job *Job) error {
+	payload, err := marshalPayload(job.Payload)
+	if err != nil {
+		return fmt.Errorf("jobs: failed to encode payload: %w", err)
+	}
+	now := time.Now().UnixMilli()
+	available := now
+	if !job.RunAt.IsZero() {
+		available = job.RunAt.UnixMilli()
+	}
+
+	var uniqueUntil sql.NullInt64
+	if job.UniqueKey != "" {
+		// Free the key if its holder outlived UniqueFor
+		if _, err := s.db.ExecContext(ctx, s.rebind(`UPDATE `+s.table+` SET unique_key = NULL
+			WHERE unique_key = ? AND unique_until <= ?`), job.UniqueKey, now); err != nil {
+			return fmt.Errorf("jobs: failed to enqueue: %w", err)
+		}
+		uniqueUntil = sql.NullInt64{Int64: now + uniqueTTL(job).Milliseconds(), Valid: true}
+	}
+
+	// Conflicts on either the ID or the unique key leave the row out
+	res, err := s.db.ExecContext(ctx, s.rebind(`INSERT INTO `+s.table+`
+		(id, type, payload, priority, max_retries, retry_delay_ms, timeout_ms, attempts, state, available_at, created_at, enqueued_at, workflow_id, step_id, input, unique_key, unique_until)
+		VALUES (?, ?, ?, ?, ?, ?, ?, 0, 'ready', ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT DO NOTHING`),
+		job.ID, job.Type, nullString(string(payload)), job.Priority, job.MaxRetries,
+		job.RetryDelay.Milliseconds(), job.Timeout.Milliseconds(),
+		available, job.CreatedAt.UnixMilli(), now,
+		nullString(job.WorkflowID), nullString(job.StepID), nullString(string(job.Input)),
+		nullString(job.UniqueKey), uniqueUntil)
+	if err != nil {
+		return fmt.Errorf("jobs: failed to enqueue: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 1 {
+		return nil
+	}
+	if job.UniqueKey == "" {
+		return ErrJobExists
+	}
+
+	var holder string
+	err = s.db.QueryRowContext(ctx, s.rebind(`SELECT id FROM `+s.table+` WHERE unique_key = ?`),
+		job.UniqueKey).Scan(&holder)
+	if errors.Is(err, sql.ErrNoRows) || holder == job.ID {
+		return ErrJobExists
+	}
+	if err != nil {
+		return fmt.Errorf("jobs: failed to enqueue: %w", err)
+	}
+	job.ID = holder
+	if job.OnDuplicate != DuplicateReplace {
+		return ErrDuplicateJob
+	}
+
+	res, err = s.db.ExecContext(ctx, s.rebind(`UPDATE `+s.table+` SET payload = ?, available_at = ?
+		WHERE id = ? AND state = 'ready'`), nullString(string(payload)), available, holder)
+	if err != nil {
+		return fmt.Errorf("jobs: failed to replace job: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrDuplicateJob
+	}
+	return nil
+}
+
+// Dequeue claims the next ready job. Running jobs whose visibility
+// deadline has passed are eligible again.
+func (s *SQLJobStore) Dequeue(ctx context.Context, types []string, visibility time.Duration) (*Job, error) {
+	if len(types) == 0 {
+		return nil, nil
+	}
+
+	now := time.Now()
+	lease := generateOwnerID()
+
+	lock := ""
+	if s.dialect == DialectPostgres {
+		lock = " FOR UPDATE SKIP LOCKED"
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(types)), ", ")
+
+	q := s.rebind(`UPDATE ` + s.table + `
+		SET state = 'running', lease = ?, attempts = attempts + 1, available_at = ?
+		WHERE id = (
+			SELECT id FROM ` + s.table + `
+			WHERE state IN ('ready', 'running') AND available_at <= ? AND type IN (` + placeholders + `)
+			ORDER BY priority DESC, enqueued_at, id
+			LIMIT 1` + lock + `
+		)
+		RETURNING ` + sqlJobColumns)
+
+	args := []any{lease, now.Add(visibility).UnixMilli(), now.UnixMilli()}
+	for _, t := range types {
+		args = append(args, t)
+	}
+
+	job, err := scanJob(s.db.QueryRowContext(ctx, q, args...))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("jobs: failed to dequeue: %w", err)
+	}
+	job.lease = lease
+	return job, nil
+}
+
+func (s *SQLJobStore) execLeased(ctx context.Context, q string, args ...any) error {
+	res, err := s.db.ExecContext(ctx, s.rebind(q), args...)
+	if err != nil {
+		return fmt.Errorf("jobs: sql job store: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrLeaseLost
+	}
+	return nil
+}
+
+// Extend pushes back the visibility deadline.
+func (s *SQLJobStore) Extend(ctx context.Context, job *Job, visibility time.Duration) error {
+	return s.execLeased(ctx, `UPDATE `+s.table+` SET available_at = ?
+		WHERE id = ? AND lease = ? AND state = 'running'`,
+		time.Now().Add(visibility).UnixMilli(), job.ID, job.lease)
+}
+
+// Ack removes a completed job.
+func (s *SQLJobStore) Ack(ctx context.Context, job *Job) error {
+	return s.execLeased(ctx, `DELETE FROM `+s.table+`
+		WHERE id = ? AND lease = ? AND state = 'running'`,
+		job.ID, job.lease)
+}
+
+// Retry releases a job to run again at runAt.
+func (s *SQLJobStore) Retry(ctx context.Context, job *Job, runAt time.Time) error {
+	return s.execLeased(ctx, `UPDATE `+s.table+`
+		SET state = 'ready', lease = NULL, available_at = ?, last_error = ?
+		WHERE id = ? AND lease = ? AND state = 'running'`,
+		runAt.UnixMilli(), nullString(errorString(job.Error)), job.ID, job.lease)
+}
+
+// Fail moves a job to the dead-letter set.
+func (s *SQLJobStore) Fail(ctx context.Context, job *Job) error {
+	return s.execLeased(ctx, `UPDATE `+s.table+`
+		SET state = 'dead', lease = NULL, unique_key = NULL, last_error = ?, failed_at = ?
+		WHERE id = ? AND lease = ? AND state = 'running'`,
+		nullString(errorString(job.Error)), time.Now().UnixMilli(), job.ID, job.lease)
+}
+
+// Release returns a job to run at runAt without counting the attempt.
+func (s *SQLJobStore) Release(ctx context.Context, job *Job, runAt time.Time) error {
+	return s.execLeased(ctx, `UPDATE `+s.table+`
+		SET state = 'ready', lease = NULL, available_at = ?, attempts = attempts - 1
+		WHERE id = ? AND lease = ? AND state = 'running'`,
+		runAt.UnixMilli(), job.ID, job.lease)
+}
+
+// DeadLetters returns dead jobs, most recently failed first.
+func (s *SQLJobStore) DeadLetters(ctx context.Context, limit int) ([]*Job, error) {
+	if limit <= 0 {
+		limit = 100
+	}
+	rows, err := s.db.QueryContext(ctx, s.rebind(`SELECT `+sqlJobColumns+` FROM `+s.table+`
+		WHERE state = 'dead' ORDER BY failed_at DESC LIMIT ?`), limit)
+	if err != nil {
+		return nil, fmt.Errorf("jobs: failed to list dead letters: %w", err)
+	}
+	defer rows.Close()
+
+	var out []*Job
+	for rows.Next() {
+		job, err := scanJob(rows)
+		if err != nil {
+			return nil, fmt.Errorf("jobs: failed to scan dead letter: %w", err)
+		}
+		out = append(out, job)
+	}
+	return out, rows.Err()
+}
+
+// DeadLetter returns one dead job.
+func (s *SQLJobStore) DeadLetter(ctx context.Context, id string) (*Job, error) {
+	row := s.db.QueryRowContext(ctx, s.rebind(`SELECT `+sqlJobColumns+` FROM `+s.table+`
+		WHERE id = ? AND state = 'dead'`), id)
+	job, err := scanJob(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrJobNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("jobs: failed to load dead letter: %w", err)
+	}
+	return job, nil
+}
+
+// Discard deletes a dead job.
+func (s *SQLJobStore) Discard(ctx context.Context, id string) error {
+	res, err := s.db.ExecContext(ctx, s.rebind(`DELETE FROM `+s.table+` WHERE id = ? AND state = 'dead'`), id)
+	if err != nil {
+		return fmt.Errorf("jobs: failed to discard job: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrJobNotFound
+	}
+	return nil
+}
+
+// Requeue moves a dead job back to the queue.
+func (s *SQLJobStore) Requeue(ctx context.Context, id string) error {
+	res, err := s.db.ExecContext(ctx, s.rebind(`UPDATE `+s.table+`
+		SET state = 'ready', attempts = 0, available_at = ?, failed_at = NULL
+		WHERE id = ? AND state = 'dead'`),
+		time.Now().UnixMilli(), id)
+	if err != nil {
+		return fmt.Errorf("jobs: failed to requeue: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrJobNotFound
+	}
+	return nil
+}
+
+// Stats returns queue counts.
+func (s *SQLJobStore) Stats(ctx context.Context) (StoreStats, error) {
+	var st StoreStats
+	err := s.db.QueryRowContext(ctx, s.rebind(`SELECT
+		COALESCE(SUM(CASE WHEN state = 'ready' AND available_at <= ? THEN 1 ELSE 0 END), 0),
+		COALESCE(SUM(CASE WHEN state = 'ready' AND available_at > ? THEN 1 ELSE 0 END), 0),
+		COALESCE(SUM(CASE WHEN state = 'running' THEN 1 ELSE 0 END), 0),
+		COALESCE(SUM(CASE WHEN state = 'dead' THEN 1 ELSE 0 END), 0)
+		FROM `+s.table), time.Now().UnixMilli(), time.Now().UnixMilli()).
+		Scan(&st.Ready, &st.Scheduled, &st.InFlight, &st.Dead)
+	if err != nil {
+		return StoreStats{}, fmt.Errorf("jobs: failed to read stats: %w", err)
+	}
+	return st, nil
+}
+
+// AcquireSlot takes a concurrency slot for holder. Slots are numbered rows
+// per type; a free (expired or released) slot below limit is reused before
+// a new one is added.
+func (s *SQLJobStore) AcquireSlot(ctx context.Context, jobType, holder string, limit int, ttl time.Duration) (bool, error) {
+	now := time.Now().UnixMilli()
+	expires := now + ttl.Milliseconds()
+	slots := s.table + `_slots`
+
+	res, err := s.db.ExecContext(ctx, s.rebind(`UPDATE `+slots+` SET holder = ?, expires_at = ?
+		WHERE type = ? AND expires_at <= ? AND slot = (
+			SELECT MIN(slot) FROM `+slots+` WHERE type = ? AND slot < ? AND expires_at <= ?
+		)`), holder, expires, jobType, now, jobType, limit, now)
+	if err != nil {
+		return false, fmt.Errorf("jobs: failed to acquire slot: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 1 {
+		return true, nil
+	}
+
+	res, err = s.db.ExecContext(ctx, s.rebind(`INSERT INTO `+slots+` (type, slot, holder, expires_at)
+		SELECT CAST(? AS TEXT), COALESCE(MAX(slot) + 1, 0), CAST(? AS TEXT), CAST(? AS BIGINT)
+		FROM `+slots+` WHERE type = ?
+		HAVING COALESCE(MAX(slot) + 1, 0) < ?
+		ON CONFLICT DO NOTHING`), jobType, holder, expires, jobType, limit)
+	if err != nil {
+		return false, fmt.Errorf("jobs: failed to acquire slot: %w", err)
+	}
+	n, _ := res.RowsAffected()
+	return n == 1, nil
+}
+
+// ExtendSlot pushes back the expiry of holder's slot.
+func (s *SQLJobStore) ExtendSlot(ctx context.Context, jobType, holder string, ttl time.Duration) error {
+	now := time.Now().UnixMilli()
+	_, err := s.db.ExecContext(ctx, s.rebind(`UPDATE `+s.table+`_slots SET expires_at = ?
+		WHERE type = ? AND holder = ? AND expires_at > ?`), now+ttl.Milliseconds(), jobType, holder, now)
+	if err != nil {
+		return fmt.Errorf("jobs: failed to extend slot: %w", err)
+	}
+	return nil
+}
+
+// ReleaseSlot frees holder's slot.
+func (s *SQLJobStore) ReleaseSlot(ctx context.Context, jobType, holder string) error {
+	_, err := s.db.ExecContext(ctx, s.rebind(`UPDATE `+s.table+`_slots SET expires_at = 0
+		WHERE type = ? AND holder = ?`), jobType, holder)
+	if err != nil {
+		return fmt.Errorf("jobs: failed to release slot: %w", err)
+	}
+	return nil
+}
+
+// maxBucketUpdateAttempts bounds optimistic retries in TakeToken.
+const maxBucketUpdateAttempts = 10
+
+// TakeToken takes a token from the type's bucket. The bucket row is
+// updated with a version check, retrying when another runner got there
+// first.
+func (s *SQLJobStore) TakeToken(ctx context.Context, jobType string, rate float64, burst int) (time.Duration, error) {
+	buckets := s.table + `_buckets`
+	for i := 0; i < maxBucketUpdateAttempts; i++ {
+		now := time.Now().UnixMilli()
+
+		var (
+			tokens           float64
+			updated, version int64
+		)
+		err := s.db.QueryRowContext(ctx, s.rebind(`SELECT tokens, updated_at, version FROM `+buckets+`
+			WHERE type = ?`), jobType).Scan(&tokens, &updated, &version)
+		if errors.Is(err, sql.ErrNoRows) {
+			res, err := s.db.ExecContext(ctx, s.rebind(`INSERT INTO `+buckets+` (type, tokens, updated_at, version)
+				VALUES (?, ?, ?, 1) ON CONFLICT (type) DO NOTHING`), jobType, float64(burst-1), now)
+			if err != nil {
+				return 0, fmt.Errorf("jobs: failed to take token: %w", err)
+			}
+			if n, _ := res.RowsAffected(); n == 1 {
+				return 0, nil
+			}
+			continue
+		}
+		if err != nil {
+			return 0, fmt.Errorf("jobs: failed to take token: %w", err)
+		}
+
+		elapsed := float64(max(0, now-updated)) / 1000
+		if tokens += elapsed * rate; tokens > float64(burst) {
+			tokens = float64(burst)
+		}
+		if tokens < 1 {
+			return time.Duration((1 - tokens) / rate * float64(time.Second)), nil
+		}
+
+		res, err := s.db.ExecContext(ctx, s.rebind(`UPDATE `+buckets+`
+			SET tokens = ?, updated_at = ?, version = version + 1
+			WHERE type = ? AND version = ?`), tokens-1, now, jobType, version)
+		if err != nil {
+			return 0, fmt.Errorf("jobs: failed to take token: %w", err)
+		}
+		if n, _ := res.RowsAffected(); n == 1 {
+			return 0, nil
+		}
+	}
+	return 0, errors.New("jobs: failed to take token: bucket too contended")
+}
+
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
@@ -0,0 +1,525 @@
+// jobs/store.go
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/dalemusser/waffle/pantry/ratelimit"
+)
+
+// Store errors.
+var (
+	ErrJobExists   = errors.New("jobs: job with this ID already exists")
+	ErrJobNotFound = errors.New("jobs: job not found")
+	ErrLeaseLost   = errors.New("jobs: job lease lost (visibility timeout expired)")
+
+	// ErrDuplicateJob is returned by Enqueue when another job holds the
+	// job's UniqueKey. The job's ID is set to the holder's ID.
+	ErrDuplicateJob = errors.New("jobs: duplicate job (unique key held by another job)")
+)
+
+// JobStore persists jobs for a Runner so they survive restarts and can be
+// shared by several app instances.
+//
+// Delivery is at-least-once. Dequeue claims a job by giving it a lease that
+// hides it from other consumers until the visibility deadline. If the lease
+// isn't acked, retried, failed or extended before the deadline (for example,
+// because the process crashed), the job becomes available again. Handlers
+// should therefore be idempotent.
+type JobStore interface {
+	// Enqueue persists a job. Jobs with RunAt in the future are not
+	// delivered before then. Returns ErrJobExists if the ID is taken, or
+	// ErrDuplicateJob if another job holds the job's UniqueKey (unless the
+	// job was merged into it, see DuplicateReplace). Ack and Fail release
+	// the unique key.
+	Enqueue(ctx context.Context, job *Job) error
+
+	// Dequeue claims the ready job with the highest priority (oldest first
+	// among equals) whose type is in types, increments its Attempts, and
+	// hides it until now+visibility. Returns nil, nil if no job is ready.
+	Dequeue(ctx context.Context, types []string, visibility time.Duration) (*Job, error)
+
+	// Extend pushes back the visibility deadline of a claimed job.
+	Extend(ctx context.Context, job *Job, visibility time.Duration) error
+
+	// Ack removes a successfully processed job.
+	Ack(ctx context.Context, job *Job) error
+
+	// Retry releases a claimed job to run again at runAt, recording
+	// job.Error.
+	Retry(ctx context.Context, job *Job, runAt time.Time) error
+
+	// Fail moves a claimed job to the dead-letter set, recording job.Error.
+	Fail(ctx context.Context, job *Job) error
+
+	// Release returns a claimed job that wasn't run, to run at runAt. The
+	// attempt isn't counted. Runners use it for jobs held back by their
+	// type's limits.
+	Release(ctx context.Context, job *Job, runAt time.Time) error
+
+	// DeadLetters returns dead jobs, most recently failed first.
+	DeadLetters(ctx context.Context, limit int) ([]*Job, error)
+
+	// DeadLetter returns one dead job. Returns ErrJobNotFound if no dead
+	// job has the ID.
+	DeadLetter(ctx context.Context, id string) (*Job, error)
+
+	// Requeue moves a dead job back to the queue with its attempts reset.
+	// Returns ErrJobNotFound if no dead job has the ID.
+	Requeue(ctx context.Context, id string) error
+
+	// Discard deletes a dead job. Returns ErrJobNotFound if no dead job
+	// has the ID.
+	Discard(ctx context.Context, id string) error
+
+	// Stats returns queue counts.
+	Stats(ctx context.Context) (StoreStats, error)
+}
+
+// StoreStats contains JobStore counts.
+type StoreStats struct {
+	Ready     int64 `json:"ready"`     // Ready to run now
+	Scheduled int64 `json:"scheduled"` // Waiting for RunAt (including retries)
+	InFlight  int64 `json:"in_flight"` // Claimed by a worker
+	Dead      int64 `json:"dead"`      // Exhausted MaxRetries
+}
+
+// storedJob is the serialized form of a Job. Attempts and lease are kept
+// outside it so stores can update them atomically.
+type storedJob struct {
+	ID         string          `json:"id"`
+	Type       string          `json:"type"`
+	Payload    json.RawMessage `json:"payload,omitempty"`
+	Priority   int             `json:"priority"`
+	MaxRetries int             `json:"max_retries"`
+	RetryDelay time.Duration   `json:"retry_delay"`
+	Timeout    time.Duration   `json:"timeout"`
+	CreatedAt  time.Time       `json:"created_at"`
+	RunAt      time.Time       `json:"run_at,omitzero"`
+	LastError  string          `json:"last_error,omitempty"`
+	WorkflowID string          `json:"workflow_id,omitempty"`
+	StepID     string          `json:"step_id,omitempty"`
+	Input      json.RawMessage `json:"input,omitempty"`
+	UniqueKey  string          `json:"unique_key,omitempty"`
+	UniqueFor  time.Duration   `json:"unique_for,omitempty"`
+	OnDup      DuplicatePolicy `json:"on_duplicate,omitempty"`
+}
+
+func encodeJob(job *Job) ([]byte, error) {
+	payload, err := marshalPayload(job.Payload)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(storedJob{
+		ID:         job.ID,
+		Type:       job.Type,
+		Payload:    payload,
+		Priority:   job.Priority,
+		MaxRetries: job.MaxRetries,
+		RetryDelay: job.RetryDelay,
+		Timeout:    job.Timeout,
+		CreatedAt:  job.CreatedAt,
+		RunAt:      job.RunAt,
+		LastError:  errorString(job.Error),
+		WorkflowID: job.WorkflowID,
+		StepID:     job.StepID,
+		Input:      job.Input,
+		UniqueKey:  job.UniqueKey,
+		UniqueFor:  job.UniqueFor,
+		OnDup:      job.OnDuplicate,
+	})
+}
+
+func decodeJob(data []byte, attempts int) (*Job, error) {
+	var s storedJob
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, err
+	}
+	job := &Job{
+		ID:          s.ID,
+		Type:        s.Type,
+		Priority:    s.Priority,
+		MaxRetries:  s.MaxRetries,
+		RetryDelay:  s.RetryDelay,
+		Timeout:     s.Timeout,
+		Attempts:    attempts,
+		CreatedAt:   s.CreatedAt,
+		RunAt:       s.RunAt,
+		WorkflowID:  s.WorkflowID,
+		StepID:      s.StepID,
+		Input:       s.Input,
+		UniqueKey:   s.UniqueKey,
+		UniqueFor:   s.UniqueFor,
+		OnDuplicate: s.OnDup,
+	}
+	if len(s.Payload) > 0 {
+		job.Payload = s.Payload
+	}
+	if s.LastError != "" {
+		job.Error = errors.New(s.LastError)
+	}
+	return job, nil
+}
+
+// marshalPayload encodes a payload, passing through values that are
+// already JSON (a job loaded from a store and enqueued again).
+func marshalPayload(p any) (json.RawMessage, error) {
+	switch v := p.(type) {
+	case nil:
+		return nil, nil
+	case json.RawMessage:
+		return v, nil
+	default:
+		return json.Marshal(v)
+	}
+}
+
+func errorString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
+
+// MemoryJobStore is an in-process JobStore. Jobs don't survive restarts,
+// but delayed jobs, priorities, visibility timeouts and dead letters work
+// the same as with the persistent stores, which makes it useful for tests
+// and single-instance development.
+type MemoryJobStore struct {
+	mu      sync.Mutex
+	jobs    map[string]*memoryJob
+	unique  map[string]memoryUnique
+	slots   map[string]map[string]time.Time
+	buckets map[string]*ratelimit.Limiter
+	seq     int64
+}
+
+// memoryUnique is a unique key held by a job.
+type memoryUnique struct {
+	id    string
+	until time.Time
+}
+
+type memoryJob struct {
+	job       *Job
+	seq       int64
+	state     string // "ready", "running", "dead"
+	available time.Time
+	lease     string
+	failedAt  time.Time
+}
+
+// NewMemoryJobStore creates an in-memory job store.
+func NewMemoryJobStore() *MemoryJobStore {
+	return &MemoryJobStore{
+		jobs:    make(map[string]*memoryJob),
+		unique:  make(map[string]memoryUnique),
+		slots:   make(map[string]map[string]time.Time),
+		buckets: make(map[string]*ratelimit.Limiter),
+	}
+}
+
+// Enqueue adds a job.
+func (s *MemoryJobStore) Enqueue(ctx context.Context, job *Job) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.jobs[job.ID]; ok {
+		return ErrJobExists
+	}
+	if job.UniqueKey != "" {
+		now := time.Now()
+		if u, ok := s.unique[job.UniqueKey]; ok && now.Before(u.until) {
+			if m, ok := s.jobs[u.id]; ok && m.state != "dead" {
+				job.ID = u.id
+				if job.OnDuplicate == DuplicateReplace && m.state == "ready" {
+					m.job.Payload = job.Payload
+					m.job.RunAt = job.RunAt
+					m.available = job.RunAt
+					return nil
+				}
+				return ErrDuplicateJob
+			}
+		}
+		s.unique[job.UniqueKey] = memoryUnique{id: job.ID, until: now.Add(uniqueTTL(job))}
+	}
+	s.seq++
+	cp := *job
+	s.jobs[job.ID] = &memoryJob{
+		job:       &cp,
+		seq:       s.seq,
+		state:     "ready",
+		available: job.RunAt,
+	}
+	return nil
+}
+
+// Dequeue claims the next ready job.
+func (s *MemoryJobStore) Dequeue(ctx context.Context, types []string, visibility time.Duration) (*Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	want := make(map[string]bool, len(types))
+	for _, t := range types {
+		want[t] = true
+	}
+
+	now := time.Now()
+	var best *memoryJob
+	for _, m := range s.jobs {
+		if m.state == "dead" || !want[m.job.Type] || m.available.After(now) {
+			continue
+		}
+		if best == nil || m.job.Priority > best.job.Priority ||
+			(m.job.Priority == best.job.Priority && m.seq < best.seq) {
+			best = m
+		}
+	}
+	if best == nil {
+		return nil, nil
+	}
+
+	best.state = "running"
+	best.available = now.Add(visibility)
+	best.lease = generateOwnerID()
+	best.job.Attempts++
+
+	cp := *best.job
+	cp.lease = best.lease
+	return &cp, nil
+}
+
+// claimed returns the stored job if job still holds its lease.
+func (s *MemoryJobStore) claimed(job *Job) (*memoryJob, error) {
+	m, ok := s.jobs[job.ID]
+	if !ok || m.state != "running" || m.lease != job.lease {
+		return nil, ErrLeaseLost
+	}
+	return m, nil
+}
+
+// Extend pushes back the visibility deadline.
+func (s *MemoryJobStore) Extend(ctx context.Context, job *Job, visibility time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	m, err := s.claimed(job)
+	if err != nil {
+		return err
+	}
+	m.available = time.Now().Add(visibility)
+	return nil
+}
+
+// Ack removes a completed job.
+func (s *MemoryJobStore) Ack(ctx context.Context, job *Job) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	m, err := s.claimed(job)
+	if err != nil {
+		return err
+	}
+	s.releaseUnique(m)
+	delete(s.jobs, job.ID)
+	return nil
+}
+
+// releaseUnique frees the unique key held by m.
+func (s *MemoryJobStore) releaseUnique(m *memoryJob) {
+	if u, ok := s.unique[m.job.UniqueKey]; ok && u.id == m.job.ID {
+		delete(s.unique, m.job.UniqueKey)
+	}
+}
+
+// Retry releases a job to run again at runAt.
+func (s *MemoryJobStore) Retry(ctx context.Context, job *Job, runAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	m, err := s.claimed(job)
+	if err != nil {
+		return err
+	}
+	m.state = "ready"
+	m.lease = ""
+	m.available = runAt
+	m.job.Error = job.Error
+	return nil
+}
+
+// Fail moves a job to the dead-letter set.
+func (s *MemoryJobStore) Fail(ctx context.Context, job *Job) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	m, err := s.claimed(job)
+	if err != nil {
+		return err
+	}
+	m.state = "dead"
+	m.lease = ""
+	m.failedAt = time.Now()
+	m.job.Error = job.Error
+	s.releaseUnique(m)
+	return nil
+}
+
+// Release returns a job to run at runAt without counting the attempt.
+func (s *MemoryJobStore) Release(ctx context.Context, job *Job, runAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	m, err := s.claimed(job)
+	if err != nil {
+		return err
+	}
+	m.state = "ready"
+	m.lease = ""
+	m.available = runAt
+	m.job.Attempts--
+	return nil
+}
+
+// DeadLetters returns dead jobs, most recently failed first.
+func (s *MemoryJobStore) DeadLetters(ctx context.Context, limit int) ([]*Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var dead []*memoryJob
+	for _, m := range s.jobs {
+		if m.state == "dead" {
+			dead = append(dead, m)
+		}
+	}
+	sort.Slice(dead, func(i, j int) bool {
+		return dead[i].failedAt.After(dead[j].failedAt)
+	})
+	if limit > 0 && len(dead) > limit {
+		dead = dead[:limit]
+	}
+
+	out := make([]*Job, len(dead))
+	for i, m := range dead {
+		cp := *m.job
+		out[i] = &cp
+	}
+	return out, nil
+}
+
+// DeadLetter returns one dead job.
+func (s *MemoryJobStore) DeadLetter(ctx context.Context, id string) (*Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	m, ok := s.jobs[id]
+	if !ok || m.state != "dead" {
+		return nil, ErrJobNotFound
+	}
+	cp := *m.job
+	return &cp, nil
+}
+
+// Discard deletes a dead job.
+func (s *MemoryJobStore) Discard(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	m, ok := s.jobs[id]
+	if !ok || m.state != "dead" {
+		return ErrJobNotFound
+	}
+	delete(s.jobs, id)
+	return nil
+}
+
+// Requeue moves a dead job back to the queue.
+func (s *MemoryJobStore) Requeue(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	m, ok := s.jobs[id]
+	if !ok || m.state != "dead" {
+		return ErrJobNotFound
+	}
+	m.state = "ready"
+	m.available = time.Time{}
+	m.failedAt = time.Time{}
+	m.job.Attempts = 0
+	return nil
+}
+
+// Stats returns queue counts.
+func (s *MemoryJobStore) Stats(ctx context.Context) (StoreStats, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var st StoreStats
+	now := time.Now()
+	for _, m := range s.jobs {
+		switch {
+		case m.state == "dead":
+			st.Dead++
+		case m.state == "running" && m.available.After(now):
+			st.InFlight++
+		case m.available.After(now):
+			st.Scheduled++
+		default:
+			st.Ready++
+		}
+	}
+	return st, nil
+}
+
+// AcquireSlot takes a concurrency slot for holder.
+func (s *MemoryJobStore) AcquireSlot(ctx context.Context, jobType, holder string, limit int, ttl time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	slots := s.slots[jobType]
+	if slots == nil {
+		slots = make(map[string]time.Time)
+		s.slots[jobType] = slots
+	}
+	for h, exp := range slots {
+		if !exp.After(now) {
+			delete(slots, h)
+		}
+	}
+	if _, ok := slots[holder]; !ok && len(slots) >= limit {
+		return false, nil
+	}
+	slots[holder] = now.Add(ttl)
+	return true, nil
+}
+
+// ExtendSlot pushes back the expiry of holder's slot.
+func (s *MemoryJobStore) ExtendSlot(ctx context.Context, jobType, holder string, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.slots[jobType][holder]; ok {
+		s.slots[jobType][holder] = time.Now().Add(ttl)
+	}
+	return nil
+}
+
+// ReleaseSlot frees holder's slot.
+func (s *MemoryJobStore) ReleaseSlot(ctx context.Context, jobType, holder string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.slots[jobType], holder)
+	return nil
+}
+
+// TakeToken takes a token from the type's bucket.
+func (s *MemoryJobStore) TakeToken(ctx context.Context, jobType string, rate float64, burst int) (time.Duration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b := s.buckets[jobType]
+	if b == nil {
+		b = ratelimit.New(rate, burst)
+		s.buckets[jobType] = b
+	}
+	if b.Allow() {
+		return 0, nil
+	}
+	return time.Duration((1 - b.Tokens()) / rate * float64(time.Second)), nil
+}
@@ -0,0 +1,103 @@
+// jobs/unique.go
+package jobs
+
+import (
+	"time"
+)
+
+// DuplicatePolicy chooses what happens when a job is enqueued while another
+// job holds its UniqueKey.
+type DuplicatePolicy int
+
+const (
+	// DuplicateDrop drops the new job. Enqueue returns ErrDuplicateJob.
+	DuplicateDrop DuplicatePolicy = iota
+
+	// DuplicateReplace merges the new job into the waiting one: its
+	// payload and RunAt replace those of the job holding the key, and
+	// Enqueue returns nil. If the holder has already started, the new job
+	// is dropped as with DuplicateDrop. Without a Store, only the payload
+	// is replaced.
+	DuplicateReplace
+)
+
+// String returns the policy name.
+func (p DuplicatePolicy) String() string {
+	switch p {
+	case DuplicateDrop:
+		return "drop"
+	case DuplicateReplace:
+		return "replace"
+	default:
+		return "unknown"
+	}
+}
+
+// defaultUniqueFor is how long a unique key is held by default.
+const defaultUniqueFor = time.Hour
+
+// uniqueTTL returns how long job's unique key is held.
+func uniqueTTL(job *Job) time.Duration {
+	if job.UniqueFor > 0 {
+		return job.UniqueFor
+	}
+	return defaultUniqueFor
+}
+
+// localUnique is a unique key held by a job in the in-process queue.
+type localUnique struct {
+	job     *Job
+	until   time.Time
+	started bool
+}
+
+// claimUnique takes job's unique key for the in-process queue. It returns
+// ErrDuplicateJob if another job holds the key, or errMerged if the job was
+// merged into the holder. In both cases job.ID is set to the holder's ID.
+func (r *Runner) claimUnique(job *Job) error {
+	if job.UniqueKey == "" {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	if u, ok := r.unique[job.UniqueKey]; ok && u.job != job && now.Before(u.until) {
+		job.ID = u.job.ID
+		if job.OnDuplicate == DuplicateReplace && !u.started {
+			u.job.Payload = job.Payload
+			return errMerged
+		}
+		return ErrDuplicateJob
+	}
+	if u, ok := r.unique[job.UniqueKey]; !ok || u.job != job {
+		r.unique[job.UniqueKey] = &localUnique{job: job, until: now.Add(uniqueTTL(job))}
+	}
+	return nil
+}
+
+// startUnique marks a job's unique key as started, so DuplicateReplace no
+// longer changes its payload.
+func (r *Runner) startUnique(job *Job) {
+	if job.UniqueKey == "" {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if u, ok := r.unique[job.UniqueKey]; ok && u.job == job {
+		u.started = true
+	}
+}
+
+// releaseUnique frees a job's unique key once it has succeeded or failed
+// permanently.
+func (r *Runner) releaseUnique(job *Job) {
+	if job.UniqueKey == "" {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if u, ok := r.unique[job.UniqueKey]; ok && u.job == job {
+		delete(r.unique, job.UniqueKey)
+	}
+}
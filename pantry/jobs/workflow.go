@@ -0,0 +1,668 @@
+// jobs/workflow.go
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Workflow errors.
+var (
+	ErrWorkflowNotFound = errors.New("jobs: workflow not found")
+	ErrWorkflowConflict = errors.New("jobs: workflow was modified concurrently")
+)
+
+// WorkflowStatus is the overall state of a workflow.
+type WorkflowStatus string
+
+const (
+	WorkflowRunning   WorkflowStatus = "running"
+	WorkflowCompleted WorkflowStatus = "completed"
+	WorkflowFailed    WorkflowStatus = "failed" // A chain step failed permanently
+)
+
+// StepStatus is the state of one workflow step.
+type StepStatus string
+
+const (
+	StepWaiting   StepStatus = "waiting" // Earlier steps haven't finished
+	StepQueued    StepStatus = "queued"  // Enqueued or running
+	StepCompleted StepStatus = "completed"
+	StepFailed    StepStatus = "failed" // Exhausted MaxRetries
+)
+
+// Workflow is a set of jobs run in stages. Every step of a stage must finish
+// before the next stage starts. A chain has one step per stage; a batch is a
+// stage with many steps.
+type Workflow struct {
+	ID          string         `json:"id"`
+	Name        string         `json:"name,omitempty"`
+	Status      WorkflowStatus `json:"status"`
+	Stages      []*Stage       `json:"stages"`
+	CreatedAt   time.Time      `json:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+	CompletedAt time.Time      `json:"completed_at,omitzero"`
+
+	// Version is incremented on every update and used by stores for
+	// optimistic concurrency.
+	Version int64 `json:"version"`
+
+	// NextStep numbers new steps.
+	NextStep int `json:"next_step"`
+}
+
+// Stage is a group of steps that run concurrently.
+type Stage struct {
+	// Batch stages run all their steps even if some fail, and pass a
+	// BatchResult to the next stage. In a chain stage, a failure stops the
+	// workflow.
+	Batch bool `json:"batch,omitempty"`
+
+	// SpawnedBy is the ID of the step that added this stage with FanOut.
+	SpawnedBy string `json:"spawned_by,omitempty"`
+
+	Steps []*Step `json:"steps"`
+}
+
+// Step is one job in a workflow.
+type Step struct {
+	ID         string          `json:"id"`
+	Type       string          `json:"type"`
+	Payload    json.RawMessage `json:"payload,omitempty"`
+	Priority   int             `json:"priority,omitempty"`
+	MaxRetries int             `json:"max_retries,omitempty"`
+	RetryDelay time.Duration   `json:"retry_delay,omitempty"`
+	Timeout    time.Duration   `json:"timeout,omitempty"`
+
+	Status   StepStatus      `json:"status"`
+	JobID    string          `json:"job_id,omitempty"`
+	Runs     int             `json:"runs,omitempty"` // Times enqueued (RetryWorkflow increments it)
+	Attempts int             `json:"attempts,omitempty"`
+	Output   json.RawMessage `json:"output,omitempty"`
+	Error    string          `json:"error,omitempty"`
+
+	StartedAt  time.Time `json:"started_at,omitzero"`
+	FinishedAt time.Time `json:"finished_at,omitzero"`
+}
+
+// BatchResult is the Input of the step that follows a batch.
+type BatchResult struct {
+	Total     int `json:"total"`
+	Succeeded int `json:"succeeded"`
+	Failed    int `json:"failed"`
+
+	// Outputs holds each batch job's output in batch order (null for jobs
+	// that failed or set no output).
+	Outputs []json.RawMessage `json:"outputs"`
+}
+
+// WorkflowProgress counts steps by status.
+type WorkflowProgress struct {
+	Total     int `json:"total"`
+	Waiting   int `json:"waiting"`
+	Queued    int `json:"queued"`
+	Completed int `json:"completed"`
+	Failed    int `json:"failed"`
+}
+
+// Progress counts the workflow's steps by status. Steps added at run time
+// with FanOut are only counted once their parent step has completed.
+func (wf *Workflow) Progress() WorkflowProgress {
+	var p WorkflowProgress
+	for _, st := range wf.Stages {
+		for _, s := range st.Steps {
+			p.Total++
+			switch s.Status {
+			case StepWaiting:
+				p.Waiting++
+			case StepQueued:
+				p.Queued++
+			case StepCompleted:
+				p.Completed++
+			case StepFailed:
+				p.Failed++
+			}
+		}
+	}
+	return p
+}
+
+// Step returns the step with the given ID, or nil.
+func (wf *Workflow) Step(id string) *Step {
+	_, s := wf.find(id)
+	return s
+}
+
+func (wf *Workflow) find(id string) (int, *Step) {
+	for i, st := range wf.Stages {
+		for _, s := range st.Steps {
+			if s.ID == id {
+				return i, s
+			}
+		}
+	}
+	return -1, nil
+}
+
+func (wf *Workflow) newStep(job *Job) (*Step, error) {
+	payload, err := marshalPayload(job.Payload)
+	if err != nil {
+		return nil, fmt.Errorf("jobs: failed to encode payload for %q: %w", job.Type, err)
+	}
+	wf.NextStep++
+	return &Step{
+		ID:         strconv.Itoa(wf.NextStep),
+		Type:       job.Type,
+		Payload:    payload,
+		Priority:   job.Priority,
+		MaxRetries: job.MaxRetries,
+		RetryDelay: job.RetryDelay,
+		Timeout:    job.Timeout,
+		Status:     StepWaiting,
+	}, nil
+}
+
+func (wf *Workflow) addStage(batch bool, spawnedBy string, jobs []*Job) (*Stage, error) {
+	st := &Stage{Batch: batch, SpawnedBy: spawnedBy, Steps: make([]*Step, 0, len(jobs))}
+	for _, job := range jobs {
+		s, err := wf.newStep(job)
+		if err != nil {
+			return nil, err
+		}
+		st.Steps = append(st.Steps, s)
+	}
+	return st, nil
+}
+
+func (st *Stage) finished() bool {
+	for _, s := range st.Steps {
+		if s.Status == StepWaiting || s.Status == StepQueued {
+			return false
+		}
+	}
+	return true
+}
+
+// output is the Input for the stage that follows st.
+func (st *Stage) output() json.RawMessage {
+	if !st.Batch {
+		if len(st.Steps) == 0 {
+			return nil
+		}
+		return st.Steps[0].Output
+	}
+	res := BatchResult{Total: len(st.Steps), Outputs: make([]json.RawMessage, len(st.Steps))}
+	for i, s := range st.Steps {
+		if s.Status == StepCompleted {
+			res.Succeeded++
+			res.Outputs[i] = s.Output
+		} else {
+			res.Failed++
+		}
+	}
+	data, _ := json.Marshal(res)
+	return data
+}
+
+// pendingStep is a step that advance moved to StepQueued and the runner
+// must enqueue.
+type pendingStep struct {
+	step  *Step
+	input json.RawMessage
+}
+
+// advance queues the waiting steps of the first unfinished stage and
+// updates the workflow status. It returns the steps to enqueue.
+func (wf *Workflow) advance(now time.Time) []pendingStep {
+	var input json.RawMessage
+	for i, st := range wf.Stages {
+		if i > 0 {
+			input = wf.Stages[i-1].output()
+		}
+		if st.finished() {
+			if !st.Batch {
+				for _, s := range st.Steps {
+					if s.Status == StepFailed {
+						wf.Status = WorkflowFailed
+						wf.CompletedAt = now
+						return nil
+					}
+				}
+			}
+			continue
+		}
+
+		var out []pendingStep
+		for _, s := range st.Steps {
+			if s.Status != StepWaiting {
+				continue
+			}
+			s.Status = StepQueued
+			s.Runs++
+			s.JobID = wf.ID + ":" + s.ID + ":" + strconv.Itoa(s.Runs)
+			s.StartedAt = now
+			out = append(out, pendingStep{step: s, input: input})
+		}
+		wf.Status = WorkflowRunning
+		return out
+	}
+	wf.Status = WorkflowCompleted
+	wf.CompletedAt = now
+	return nil
+}
+
+// reset prepares a finished workflow to retry its failed steps. The first
+// stage with failures is rerun from its failed steps; every step after it
+// is reset because its input will change, and stages added by FanOut from
+// those steps are dropped (they are added again when the steps rerun).
+// Stages added by FanOut from steps that aren't rerun keep their completed
+// steps, since their jobs don't depend on retried output; only their
+// failed steps are reset. It reports whether there was anything to retry.
+func (wf *Workflow) reset() bool {
+	first := -1
+	for i, st := range wf.Stages {
+		for _, s := range st.Steps {
+			if s.Status == StepFailed {
+				first = i
+				break
+			}
+		}
+		if first >= 0 {
+			break
+		}
+	}
+	if first < 0 {
+		return false
+	}
+
+	for _, s := range wf.Stages[first].Steps {
+		if s.Status == StepFailed {
+			s.reset()
+		}
+	}
+
+	resetIDs := make(map[string]bool)
+	kept := wf.Stages[:first+1]
+	for _, st := range wf.Stages[first+1:] {
+		if st.SpawnedBy != "" {
+			if resetIDs[st.SpawnedBy] {
+				continue
+			}
+			for _, s := range st.Steps {
+				if s.Status == StepFailed {
+					s.reset()
+					resetIDs[s.ID] = true
+				}
+			}
+			kept = append(kept, st)
+			continue
+		}
+		for _, s := range st.Steps {
+			s.reset()
+			resetIDs[s.ID] = true
+		}
+		kept = append(kept, st)
+	}
+	wf.Stages = kept
+	wf.CompletedAt = time.Time{}
+	return true
+}
+
+func (s *Step) reset() {
+	s.Status = StepWaiting
+	s.Attempts = 0
+	s.Output = nil
+	s.Error = ""
+	s.StartedAt = time.Time{}
+	s.FinishedAt = time.Time{}
+}
+
+func (s *Step) job(wfID string, input json.RawMessage) *Job {
+	job := &Job{
+		ID:         s.JobID,
+		Type:       s.Type,
+		Priority:   s.Priority,
+		MaxRetries: s.MaxRetries,
+		RetryDelay: s.RetryDelay,
+		Timeout:    s.Timeout,
+		WorkflowID: wfID,
+		StepID:     s.ID,
+		Input:      input,
+	}
+	if len(s.Payload) > 0 {
+		job.Payload = s.Payload
+	}
+	return job
+}
+
+// WorkflowStore persists workflow state. Updates from concurrent batch jobs
+// (possibly on different instances) go through Update, which must apply fn
+// atomically.
+type WorkflowStore interface {
+	// Create stores a new workflow.
+	Create(ctx context.Context, wf *Workflow) error
+
+	// Get returns a workflow, or ErrWorkflowNotFound.
+	Get(ctx context.Context, id string) (*Workflow, error)
+
+	// Update loads a workflow, applies fn and saves the result, retrying
+	// fn if the workflow changed in between. If fn returns an error,
+	// nothing is saved and the error is returned.
+	Update(ctx context.Context, id string, fn func(*Workflow) error) (*Workflow, error)
+
+	// List returns workflows, newest first.
+	List(ctx context.Context, limit int) ([]*Workflow, error)
+}
+
+// MemoryWorkflowStore is an in-process WorkflowStore.
+type MemoryWorkflowStore struct {
+	mu        sync.Mutex
+	workflows map[string][]byte
+	order     []string
+}
+
+// NewMemoryWorkflowStore creates an in-memory workflow store.
+func NewMemoryWorkflowStore() *MemoryWorkflowStore {
+	return &MemoryWorkflowStore{workflows: make(map[string][]byte)}
+}
+
+// Create stores a new workflow.
+func (s *MemoryWorkflowStore) Create(ctx context.Context, wf *Workflow) error {
+	data, err := json.Marshal(wf)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.workflows[wf.ID] = data
+	s.order = append(s.order, wf.ID)
+	return nil
+}
+
+// Get returns a copy of a workflow.
+func (s *MemoryWorkflowStore) Get(ctx context.Context, id string) (*Workflow, error) {
+	s.mu.Lock()
+	data, ok := s.workflows[id]
+	s.mu.Unlock()
+	if !ok {
+		return nil, ErrWorkflowNotFound
+	}
+	return decodeWorkflow(data)
+}
+
+// Update applies fn under the store's lock.
+func (s *MemoryWorkflowStore) Update(ctx context.Context, id string, fn func(*Workflow) error) (*Workflow, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, ok := s.workflows[id]
+	if !ok {
+		return nil, ErrWorkflowNotFound
+	}
+	wf, err := decodeWorkflow(data)
+	if err != nil {
+		return nil, err
+	}
+	if err := fn(wf); err != nil {
+		return nil, err
+	}
+	wf.Version++
+	if data, err = json.Marshal(wf); err != nil {
+		return nil, err
+	}
+	s.workflows[id] = data
+	return wf, nil
+}
+
+// List returns workflows, newest first.
+func (s *MemoryWorkflowStore) List(ctx context.Context, limit int) ([]*Workflow, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var out []*Workflow
+	for i := len(s.order) - 1; i >= 0; i-- {
+		if limit > 0 && len(out) >= limit {
+			break
+		}
+		wf, err := decodeWorkflow(s.workflows[s.order[i]])
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, wf)
+	}
+	return out, nil
+}
+
+func decodeWorkflow(data []byte) (*Workflow, error) {
+	var wf Workflow
+	if err := json.Unmarshal(data, &wf); err != nil {
+		return nil, fmt.Errorf("jobs: failed to decode workflow: %w", err)
+	}
+	return &wf, nil
+}
+
+// Chain runs jobs one after another. Each job starts after the previous one
+// succeeds and receives its Output as Input. If a job fails permanently,
+// the workflow stops with status failed; RetryWorkflow resumes it from the
+// failed job.
+//
+//	wf, err := runner.Chain(ctx, "import-roster",
+//	    &jobs.Job{Type: "fetch_districts"},
+//	    &jobs.Job{Type: "aggregate"},
+//	    &jobs.Job{Type: "email_report"},
+//	)
+func (r *Runner) Chain(ctx context.Context, name string, steps ...*Job) (*Workflow, error) {
+	if len(steps) == 0 {
+		return nil, errors.New("jobs: chain needs at least one job")
+	}
+	return r.startWorkflow(ctx, name, func(wf *Workflow) error {
+		for _, job := range steps {
+			st, err := wf.addStage(false, "", []*Job{job})
+			if err != nil {
+				return err
+			}
+			wf.Stages = append(wf.Stages, st)
+		}
+		return nil
+	})
+}
+
+// Batch runs jobs concurrently, then runs the then jobs as a chain. The
+// first then job starts once every batch job has succeeded or failed
+// permanently, and receives a BatchResult as Input:
+//
+//	wf, err := runner.Batch(ctx, "sync-schools", schoolJobs,
+//	    &jobs.Job{Type: "sync_complete"},
+//	)
+//
+//	runner.Register("sync_complete", func(ctx context.Context, job *jobs.Job) error {
+//	    var res jobs.BatchResult
+//	    if err := job.DecodeInput(&res); err != nil {
+//	        return err
+//	    }
+//	    log.Printf("%d of %d schools synced", res.Succeeded, res.Total)
+//	    return nil
+//	})
+func (r *Runner) Batch(ctx context.Context, name string, batch []*Job, then ...*Job) (*Workflow, error) {
+	return r.startWorkflow(ctx, name, func(wf *Workflow) error {
+		st, err := wf.addStage(true, "", batch)
+		if err != nil {
+			return err
+		}
+		wf.Stages = append(wf.Stages, st)
+		for _, job := range then {
+			st, err := wf.addStage(false, "", []*Job{job})
+			if err != nil {
+				return err
+			}
+			wf.Stages = append(wf.Stages, st)
+		}
+		return nil
+	})
+}
+
+func (r *Runner) startWorkflow(ctx context.Context, name string, build func(*Workflow) error) (*Workflow, error) {
+	now := time.Now()
+	wf := &Workflow{
+		ID:        generateOwnerID(),
+		Name:      name,
+		Status:    WorkflowRunning,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := build(wf); err != nil {
+		return nil, err
+	}
+
+	pending := wf.advance(now)
+	if err := r.workflows.Create(ctx, wf); err != nil {
+		return nil, fmt.Errorf("jobs: failed to create workflow: %w", err)
+	}
+	r.logger.Debug("workflow started",
+		zap.String("workflow", wf.ID),
+		zap.String("name", wf.Name),
+	)
+	if err := r.enqueueSteps(ctx, wf, pending); err != nil {
+		return nil, fmt.Errorf("jobs: failed to start workflow %s: %w", wf.ID, err)
+	}
+	return wf, nil
+}
+
+// Workflow returns a workflow's current state.
+func (r *Runner) Workflow(ctx context.Context, id string) (*Workflow, error) {
+	return r.workflows.Get(ctx, id)
+}
+
+// Workflows returns recent workflows, newest first.
+func (r *Runner) Workflows(ctx context.Context, limit int) ([]*Workflow, error) {
+	return r.workflows.List(ctx, limit)
+}
+
+// WorkflowStore returns the runner's WorkflowStore.
+func (r *Runner) WorkflowStore() WorkflowStore {
+	return r.workflows
+}
+
+// RetryWorkflow reruns the failed steps of a workflow. Steps that succeeded
+// are not run again, except those after the failed steps, whose input
+// changes (for example, a batch callback runs again with the new counts).
+func (r *Runner) RetryWorkflow(ctx context.Context, id string) (*Workflow, error) {
+	var pending []pendingStep
+	wf, err := r.workflows.Update(ctx, id, func(wf *Workflow) error {
+		if !wf.reset() {
+			return errWorkflowUnchanged
+		}
+		now := time.Now()
+		wf.UpdatedAt = now
+		pending = wf.advance(now)
+		return nil
+	})
+	if errors.Is(err, errWorkflowUnchanged) {
+		return r.workflows.Get(ctx, id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := r.enqueueSteps(ctx, wf, pending); err != nil {
+		return nil, fmt.Errorf("jobs: failed to retry workflow %s: %w", wf.ID, err)
+	}
+	return wf, nil
+}
+
+// errWorkflowUnchanged aborts an Update that has nothing to change.
+var errWorkflowUnchanged = errors.New("jobs: workflow unchanged")
+
+// stepDone records the outcome of a workflow job and starts the next stage
+// when the job's stage has finished. err is nil on success and the final
+// error after retries are exhausted.
+func (r *Runner) stepDone(job *Job, err error) {
+	if job.WorkflowID == "" {
+		return
+	}
+	ctx := context.Background()
+
+	var pending []pendingStep
+	wf, uerr := r.workflows.Update(ctx, job.WorkflowID, func(wf *Workflow) error {
+		pending = nil
+		i, s := wf.find(job.StepID)
+		if s == nil || s.JobID != job.ID || s.Status != StepQueued {
+			// A stale delivery of a step that was already recorded.
+			return errWorkflowUnchanged
+		}
+
+		now := time.Now()
+		s.Attempts = job.Attempts
+		s.FinishedAt = now
+		if err == nil {
+			s.Status = StepCompleted
+			s.Output = job.Output
+			if len(job.fanOut) > 0 {
+				st, err := wf.addStage(true, s.ID, job.fanOut)
+				if err != nil {
+					return err
+				}
+				wf.Stages = append(wf.Stages[:i+1], append([]*Stage{st}, wf.Stages[i+1:]...)...)
+			}
+		} else {
+			s.Status = StepFailed
+			s.Error = err.Error()
+		}
+
+		wf.UpdatedAt = now
+		pending = wf.advance(now)
+		return nil
+	})
+	if errors.Is(uerr, errWorkflowUnchanged) {
+		return
+	}
+	if uerr != nil {
+		r.logger.Error("failed to update workflow",
+			zap.String("workflow", job.WorkflowID),
+			zap.String("step", job.StepID),
+			zap.Error(uerr),
+		)
+		return
+	}
+
+	if wf.Status != WorkflowRunning {
+		r.logger.Info("workflow finished",
+			zap.String("workflow", wf.ID),
+			zap.String("name", wf.Name),
+			zap.String("status", string(wf.Status)),
+		)
+	}
+	r.enqueueSteps(ctx, wf, pending)
+}
+
+// enqueueSteps enqueues steps that advance queued. Step job IDs are
+// deterministic, so a step enqueued twice is rejected by the store. A step
+// that can't be enqueued is recorded as failed, so the workflow doesn't
+// wait on it forever and RetryWorkflow can rerun it; the first such error
+// is returned.
+func (r *Runner) enqueueSteps(ctx context.Context, wf *Workflow, pending []pendingStep) error {
+	var first error
+	for _, p := range pending {
+		job := p.step.job(wf.ID, p.input)
+		err := r.EnqueueContext(ctx, job)
+		if err == nil || errors.Is(err, ErrJobExists) {
+			continue
+		}
+		r.logger.Error("failed to enqueue workflow step",
+			zap.String("workflow", wf.ID),
+			zap.String("step", p.step.ID),
+			zap.Error(err),
+		)
+		if first == nil {
+			first = err
+		}
+		r.stepDone(job, fmt.Errorf("jobs: failed to enqueue step: %w", err))
+	}
+	return first
+}
@@ -0,0 +1,328 @@
+// jobs/workflowstore.go
+package jobs
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// maxWorkflowUpdateAttempts bounds compare-and-set retries in Update.
+const maxWorkflowUpdateAttempts = 50
+
+// RedisWorkflowStore is a WorkflowStore backed by Redis. Updates are
+// compare-and-set on the workflow version, so concurrent batch jobs on
+// different instances don't overwrite each other.
+//
+// Layout (with the default prefix "{jobs}:"):
+//
+//	{jobs}:wf:<id>     hash: data (workflow JSON), version
+//	{jobs}:workflows   zset: score created-at (unix ms), member id
+type RedisWorkflowStore struct {
+	client redis.UniversalClient
+	prefix string
+	ttl    time.Duration
+}
+
+// RedisWorkflowStoreConfig configures the Redis workflow store.
+type RedisWorkflowStoreConfig struct {
+	// Client is the Redis client.
+	Client redis.UniversalClient
+
+	// Prefix is the key prefix. Keep a {hash tag} in it for Redis Cluster.
+	// Default: "{jobs}:"
+	Prefix string
+
+	// TTL is how long a finished workflow is kept.
+	// Default: 7 days
+	TTL time.Duration
+}
+
+// NewRedisWorkflowStore creates a Redis-backed workflow store.
+func NewRedisWorkflowStore(cfg RedisWorkflowStoreConfig) *RedisWorkflowStore {
+	if cfg.Prefix == "" {
+		cfg.Prefix = "{jobs}:"
+	}
+	if cfg.TTL <= 0 {
+		cfg.TTL = 7 * 24 * time.Hour
+	}
+	return &RedisWorkflowStore{
+		client: cfg.Client,
+		prefix: cfg.Prefix,
+		ttl:    cfg.TTL,
+	}
+}
+
+// ARGV: expected version, new version, data, ttl ms (0 = keep forever).
+var redisWorkflowCASScript = redis.NewScript(`
+if redis.call('HGET', KEYS[1], 'version') ~= ARGV[1] then return 0 end
+redis.call('HMSET', KEYS[1], 'data', ARGV[3], 'version', ARGV[2])
+if tonumber(ARGV[4]) > 0 then
+  redis.call('PEXPIRE', KEYS[1], ARGV[4])
+else
+  redis.call('PERSIST', KEYS[1])
+end
+return 1
+`)
+
+func (s *RedisWorkflowStore) key(id string) string {
+	return s.prefix + "wf:" + id
+}
+
+// expiry is how long to keep a workflow: forever while it runs.
+func (s *RedisWorkflowStore) expiry(wf *Workflow) time.Duration {
+	if wf.Status == WorkflowRunning {
+		return 0
+	}
+	return s.ttl
+}
+
+// Create stores a new workflow.
+func (s *RedisWorkflowStore) Create(ctx context.Context, wf *Workflow) error {
+	data, err := json.Marshal(wf)
+	if err != nil {
+		return err
+	}
+	_, err = s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HMSet(ctx, s.key(wf.ID), "data", data, "version", wf.Version)
+		if ttl := s.expiry(wf); ttl > 0 {
+			pipe.PExpire(ctx, s.key(wf.ID), ttl)
+		}
+		pipe.ZAdd(ctx, s.prefix+"workflows", redis.Z{Score: float64(wf.CreatedAt.UnixMilli()), Member: wf.ID})
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("jobs: redis workflow store: %w", err)
+	}
+	return nil
+}
+
+// Get returns a workflow.
+func (s *RedisWorkflowStore) Get(ctx context.Context, id string) (*Workflow, error) {
+	data, err := s.client.HGet(ctx, s.key(id), "data").Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrWorkflowNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("jobs: redis workflow store: %w", err)
+	}
+	return decodeWorkflow(data)
+}
+
+// Update applies fn and saves the result if the version is unchanged,
+// retrying otherwise.
+func (s *RedisWorkflowStore) Update(ctx context.Context, id string, fn func(*Workflow) error) (*Workflow, error) {
+	for range maxWorkflowUpdateAttempts {
+		wf, err := s.Get(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		prev := wf.Version
+		if err := fn(wf); err != nil {
+			return nil, err
+		}
+		wf.Version = prev + 1
+		data, err := json.Marshal(wf)
+		if err != nil {
+			return nil, err
+		}
+
+		n, err := redisWorkflowCASScript.Run(ctx, s.client, []string{s.key(id)},
+			prev, wf.Version, data, s.expiry(wf).Milliseconds()).Int()
+		if err != nil {
+			return nil, fmt.Errorf("jobs: redis workflow store: %w", err)
+		}
+		if n == 1 {
+			return wf, nil
+		}
+	}
+	return nil, ErrWorkflowConflict
+}
+
+// List returns workflows, newest first. Expired workflows are removed from
+// the index as they are found.
+func (s *RedisWorkflowStore) List(ctx context.Context, limit int) ([]*Workflow, error) {
+	if limit <= 0 {
+		limit = 100
+	}
+	ids, err := s.client.ZRevRange(ctx, s.prefix+"workflows", 0, int64(limit-1)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("jobs: redis workflow store: %w", err)
+	}
+
+	out := make([]*Workflow, 0, len(ids))
+	for _, id := range ids {
+		wf, err := s.Get(ctx, id)
+		if errors.Is(err, ErrWorkflowNotFound) {
+			s.client.ZRem(ctx, s.prefix+"workflows", id)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, wf)
+	}
+	return out, nil
+}
+
+// SQLWorkflowStore is a WorkflowStore backed by PostgreSQL or SQLite.
+// Updates use the version column for optimistic concurrency.
+type SQLWorkflowStore struct {
+	db      *sql.DB
+	dialect SQLDialect
+	table   string
+}
+
+// SQLWorkflowStoreConfig configures the SQL workflow store.
+type SQLWorkflowStoreConfig struct {
+	// DB is the database handle.
+	DB *sql.DB
+
+	// Dialect selects PostgreSQL or SQLite syntax.
+	// Default: DialectPostgres
+	Dialect SQLDialect
+
+	// Table is the workflow table name.
+	// Default: "jobs_workflows"
+	Table string
+}
+
+// NewSQLWorkflowStore creates a SQL-backed workflow store. Call CreateTable
+// once (or apply the equivalent migration) before use.
+func NewSQLWorkflowStore(cfg SQLWorkflowStoreConfig) *SQLWorkflowStore {
+	if cfg.Table == "" {
+		cfg.Table = "jobs_workflows"
+	}
+	return &SQLWorkflowStore{
+		db:      cfg.DB,
+		dialect: cfg.Dialect,
+		table:   cfg.Table,
+	}
+}
+
+// CreateTable creates the workflow table if it doesn't exist.
+func (s *SQLWorkflowStore) CreateTable(ctx context.Context) error {
+	if s.dialect == DialectMySQL {
+		return errMySQLUnsupported
+	}
+	q := `CREATE TABLE IF NOT EXISTS ` + s.table + ` (
+		id         TEXT PRIMARY KEY,
+		name       TEXT,
+		status     TEXT NOT NULL,
+		data       TEXT NOT NULL,
+		version    BIGINT NOT NULL,
+		created_at BIGINT NOT NULL,
+		updated_at BIGINT NOT NULL
+	)`
+	if _, err := s.db.ExecContext(ctx, q); err != nil {
+		return fmt.Errorf("jobs: failed to create workflow table: %w", err)
+	}
+	return nil
+}
+
+// Create stores a new workflow.
+func (s *SQLWorkflowStore) Create(ctx context.Context, wf *Workflow) error {
+	data, err := json.Marshal(wf)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.ExecContext(ctx, rebind(s.dialect, `INSERT INTO `+s.table+`
+		(id, name, status, data, version, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?)`),
+		wf.ID, wf.Name, string(wf.Status), string(data), wf.Version,
+		wf.CreatedAt.UnixMilli(), wf.UpdatedAt.UnixMilli())
+	if err != nil {
+		return fmt.Errorf("jobs: failed to create workflow: %w", err)
+	}
+	return nil
+}
+
+// Get returns a workflow.
+func (s *SQLWorkflowStore) Get(ctx context.Context, id string) (*Workflow, error) {
+	var data string
+	err := s.db.QueryRowContext(ctx,
+		rebind(s.dialect, `SELECT data FROM `+s.table+` WHERE id = ?`), id).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrWorkflowNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("jobs: failed to load workflow: %w", err)
+	}
+	return decodeWorkflow([]byte(data))
+}
+
+// Update applies fn and saves the result if the version is unchanged,
+// retrying otherwise.
+func (s *SQLWorkflowStore) Update(ctx context.Context, id string, fn func(*Workflow) error) (*Workflow, error) {
+	q := rebind(s.dialect, `UPDATE `+s.table+`
+		SET status = ?, data = ?, version = ?, updated_at = ?
+		WHERE id = ? AND version = ?`)
+
+	for range maxWorkflowUpdateAttempts {
+		wf, err := s.Get(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		prev := wf.Version
+		if err := fn(wf); err != nil {
+			return nil, err
+		}
+		wf.Version = prev + 1
+		data, err := json.Marshal(wf)
+		if err != nil {
+			return nil, err
+		}
+
+		res, err := s.db.ExecContext(ctx, q, string(wf.Status), string(data), wf.Version,
+			time.Now().UnixMilli(), id, prev)
+		if err != nil {
+			return nil, fmt.Errorf("jobs: failed to update workflow: %w", err)
+		}
+		if n, _ := res.RowsAffected(); n == 1 {
+			return wf, nil
+		}
+	}
+	return nil, ErrWorkflowConflict
+}
+
+// List returns workflows, newest first.
+func (s *SQLWorkflowStore) List(ctx context.Context, limit int) ([]*Workflow, error) {
+	if limit <= 0 {
+		limit = 100
+	}
+	rows, err := s.db.QueryContext(ctx, rebind(s.dialect,
+		`SELECT data FROM `+s.table+` ORDER BY created_at DESC LIMIT ?`), limit)
+	if err != nil {
+		return nil, fmt.Errorf("jobs: failed to list workflows: %w", err)
+	}
+	defer rows.Close()
+
+	var out []*Workflow
+	for rows.Next() {
+		var data string
+		if err := rows.Scan(&data); err != nil {
+			return nil, err
+		}
+		wf, err := decodeWorkflow([]byte(data))
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, wf)
+	}
+	return out, rows.Err()
+}
+
+// Prune deletes finished workflows last updated before the given time.
+func (s *SQLWorkflowStore) Prune(ctx context.Context, before time.Time) (int64, error) {
+	res, err := s.db.ExecContext(ctx, rebind(s.dialect,
+		`DELETE FROM `+s.table+` WHERE status <> ? AND updated_at < ?`),
+		string(WorkflowRunning), before.UnixMilli())
+	if err != nil {
+		return 0, fmt.Errorf("jobs: failed to prune workflows: %w", err)
+	}
+	return res.RowsAffected()
+}
@@ -0,0 +1,257 @@
+// loadshed/algorithm.go
+package loadshed
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// Sample is a single observation fed to an Algorithm when a request completes.
+type Sample struct {
+	// RTT is how long the request took to complete.
+	RTT time.Duration
+
+	// InFlight is the number of requests in flight when this one started.
+	InFlight int
+
+	// Dropped is true when the request failed in a way that indicates
+	// overload (timeout, 503/504 from the handler).
+	Dropped bool
+}
+
+// Algorithm adapts a concurrency limit from observed samples.
+// Implementations must be safe for concurrent use.
+type Algorithm interface {
+	// Name identifies the algorithm in stats and logs.
+	Name() string
+
+	// Limit returns the current concurrency limit.
+	Limit() int
+
+	// Update records a sample and returns the new limit.
+	Update(s Sample) int
+}
+
+// AIMD is an additive-increase/multiplicative-decrease algorithm.
+// The limit grows by one while the limiter is being used and no overload is
+// seen, and shrinks by BackoffRatio when a request is dropped or exceeds
+// Timeout.
+type AIMD struct {
+	// MinLimit is the lowest the limit can go. Defaults to 1.
+	MinLimit int
+
+	// MaxLimit is the highest the limit can go. Defaults to 1000.
+	MaxLimit int
+
+	// InitialLimit is the starting limit. Defaults to 20.
+	InitialLimit int
+
+	// BackoffRatio is the multiplier applied on overload. Defaults to 0.9.
+	BackoffRatio float64
+
+	// Timeout marks a request as overloaded if it takes longer than this.
+	// Defaults to 5 seconds.
+	Timeout time.Duration
+
+	mu    sync.Mutex
+	limit float64
+}
+
+// NewAIMD creates an AIMD algorithm with default settings.
+func NewAIMD() *AIMD {
+	return &AIMD{}
+}
+
+// Name returns "aimd".
+func (a *AIMD) Name() string {
+	return "aimd"
+}
+
+// Limit returns the current limit.
+func (a *AIMD) Limit() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.init()
+	return int(a.limit)
+}
+
+// Update records a sample and returns the new limit.
+func (a *AIMD) Update(s Sample) int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.init()
+
+	if s.Dropped || s.RTT > a.Timeout {
+		a.limit = a.limit * a.BackoffRatio
+	} else if s.InFlight*2 >= int(a.limit) {
+		// Only grow when the limit is actually being exercised; otherwise
+		// an idle service would ratchet the limit up to MaxLimit.
+		a.limit++
+	}
+
+	a.limit = clamp(a.limit, a.MinLimit, a.MaxLimit)
+	return int(a.limit)
+}
+
+// init applies defaults on first use. Caller must hold a.mu.
+func (a *AIMD) init() {
+	if a.limit != 0 {
+		return
+	}
+	if a.MinLimit <= 0 {
+		a.MinLimit = 1
+	}
+	if a.MaxLimit <= 0 {
+		a.MaxLimit = 1000
+	}
+	if a.InitialLimit <= 0 {
+		a.InitialLimit = 20
+	}
+	if a.BackoffRatio <= 0 || a.BackoffRatio >= 1 {
+		a.BackoffRatio = 0.9
+	}
+	if a.Timeout <= 0 {
+		a.Timeout = 5 * time.Second
+	}
+	a.limit = clamp(float64(a.InitialLimit), a.MinLimit, a.MaxLimit)
+}
+
+// Gradient adjusts the limit from the ratio between a long-term average
+// latency and the latest sample. When latency rises above the baseline the
+// limit shrinks proportionally; when latency is at or below baseline the
+// limit grows by a queue allowance of sqrt(limit).
+type Gradient struct {
+	// MinLimit is the lowest the limit can go. Defaults to 1.
+	MinLimit int
+
+	// MaxLimit is the highest the limit can go. Defaults to 1000.
+	MaxLimit int
+
+	// InitialLimit is the starting limit. Defaults to 20.
+	InitialLimit int
+
+	// Tolerance is how much latency may exceed the baseline before the
+	// limit shrinks (2.0 means twice the baseline). Defaults to 1.5.
+	Tolerance float64
+
+	// Smoothing is how quickly the limit moves toward the new estimate,
+	// from 0 (never) to 1 (immediately). Defaults to 0.2.
+	Smoothing float64
+
+	// LongWindow is the number of samples in the baseline latency average.
+	// Defaults to 600.
+	LongWindow int
+
+	mu      sync.Mutex
+	limit   float64
+	longRTT float64 // exponential moving average in seconds
+}
+
+// NewGradient creates a Gradient algorithm with default settings.
+func NewGradient() *Gradient {
+	return &Gradient{}
+}
+
+// Name returns "gradient".
+func (g *Gradient) Name() string {
+	return "gradient"
+}
+
+// Limit returns the current limit.
+func (g *Gradient) Limit() int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.init()
+	return int(g.limit)
+}
+
+// Update records a sample and returns the new limit.
+func (g *Gradient) Update(s Sample) int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.init()
+
+	rtt := s.RTT.Seconds()
+	if rtt <= 0 {
+		return int(g.limit)
+	}
+
+	if g.longRTT == 0 {
+		g.longRTT = rtt
+	} else {
+		alpha := 2.0 / float64(g.LongWindow+1)
+		g.longRTT = g.longRTT*(1-alpha) + rtt*alpha
+	}
+
+	// Don't grow the limit while the service is under-used.
+	if !s.Dropped && float64(s.InFlight) < g.limit/2 {
+		return int(g.limit)
+	}
+
+	gradient := math.Max(0.5, math.Min(1.0, g.Tolerance*g.longRTT/rtt))
+	if s.Dropped {
+		gradient = 0.5
+	}
+	queue := math.Sqrt(g.limit)
+	estimate := g.limit*gradient + queue
+
+	g.limit = g.limit*(1-g.Smoothing) + estimate*g.Smoothing
+	g.limit = clamp(g.limit, g.MinLimit, g.MaxLimit)
+	return int(g.limit)
+}
+
+// init applies defaults on first use. Caller must hold g.mu.
+func (g *Gradient) init() {
+	if g.limit != 0 {
+		return
+	}
+	if g.MinLimit <= 0 {
+		g.MinLimit = 1
+	}
+	if g.MaxLimit <= 0 {
+		g.MaxLimit = 1000
+	}
+	if g.InitialLimit <= 0 {
+		g.InitialLimit = 20
+	}
+	if g.Tolerance < 1 {
+		g.Tolerance = 1.5
+	}
+	if g.Smoothing <= 0 || g.Smoothing > 1 {
+		g.Smoothing = 0.2
+	}
+	if g.LongWindow <= 0 {
+		g.LongWindow = 600
+	}
+	g.limit = clamp(float64(g.InitialLimit), g.MinLimit, g.MaxLimit)
+}
+
+// Fixed is a constant limit. Useful for tests or to pin a limit while
+// investigating a problem.
+type Fixed int
+
+// Name returns "fixed".
+func (f Fixed) Name() string {
+	return "fixed"
+}
+
+// Limit returns the fixed limit.
+func (f Fixed) Limit() int {
+	return int(f)
+}
+
+// Update ignores the sample and returns the fixed limit.
+func (f Fixed) Update(Sample) int {
+	return int(f)
+}
+
+func clamp(v float64, lo, hi int) float64 {
+	if v < float64(lo) {
+		return float64(lo)
+	}
+	if v > float64(hi) {
+		return float64(hi)
+	}
+	return v
+}
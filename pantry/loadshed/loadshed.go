@@ -0,0 +1,259 @@
+// loadshed/loadshed.go
+package loadshed
+
+import (
+	"errors"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/dalemusser/waffle/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+)
+
+// ErrLimitExceeded is returned by Acquire when a request is shed.
+var ErrLimitExceeded = errors.New("loadshed: concurrency limit exceeded")
+
+// Priority classifies requests for shedding. Lower priorities are shed
+// first as the limiter fills up.
+type Priority int
+
+const (
+	// Low is for bulk or batch work such as exports and reports.
+	Low Priority = iota
+
+	// Normal is the default for ordinary traffic.
+	Normal
+
+	// High is for important user-facing operations.
+	High
+
+	// Critical is never shed. Use it for health checks and logins.
+	Critical
+)
+
+// String returns the priority name used in stats and metric labels.
+func (p Priority) String() string {
+	switch p {
+	case Low:
+		return "low"
+	case Normal:
+		return "normal"
+	case High:
+		return "high"
+	case Critical:
+		return "critical"
+	default:
+		return "unknown"
+	}
+}
+
+// DefaultShares is the fraction of the limit each priority may fill.
+// A Low request is shed once half the limit is in use, a Normal request
+// at 90%, and a High request only when the limit is reached.
+// Critical requests are always admitted.
+var DefaultShares = map[Priority]float64{
+	Low:    0.5,
+	Normal: 0.9,
+	High:   1.0,
+}
+
+var (
+	limitGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "loadshed_limit",
+		Help: "Current adaptive concurrency limit.",
+	}, []string{"limiter"})
+	inFlightGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "loadshed_in_flight",
+		Help: "Requests currently in flight.",
+	}, []string{"limiter"})
+	requestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "loadshed_requests_total",
+		Help: "Requests seen by the limiter, by priority and outcome (accepted, shed).",
+	}, []string{"limiter", "priority", "outcome"})
+)
+
+var registerOnce sync.Once
+
+func registerMetrics(logger *zap.Logger) {
+	registerOnce.Do(func() {
+		metrics.MustRegister(logger, "loadshed limit gauge", limitGauge)
+		metrics.MustRegister(logger, "loadshed in-flight gauge", inFlightGauge)
+		metrics.MustRegister(logger, "loadshed requests counter", requestsTotal)
+	})
+}
+
+// Limiter admits or sheds work based on an adaptive concurrency limit.
+type Limiter struct {
+	name   string
+	algo   Algorithm
+	shares map[Priority]float64
+
+	mu       sync.Mutex
+	inFlight int
+	limit    int
+	accepted map[Priority]uint64
+	shed     map[Priority]uint64
+}
+
+// NewLimiter creates a limiter with the given name and algorithm, and
+// registers it for AdminHandler and metrics. If algo is nil, AIMD is used.
+// shares may be nil to use DefaultShares.
+func NewLimiter(name string, algo Algorithm, shares map[Priority]float64) *Limiter {
+	if algo == nil {
+		algo = NewAIMD()
+	}
+	if shares == nil {
+		shares = DefaultShares
+	}
+	l := &Limiter{
+		name:     name,
+		algo:     algo,
+		shares:   shares,
+		limit:    algo.Limit(),
+		accepted: make(map[Priority]uint64),
+		shed:     make(map[Priority]uint64),
+	}
+	registerMetrics(nil)
+	limitGauge.WithLabelValues(name).Set(float64(l.limit))
+	register(l)
+	return l
+}
+
+// Name returns the limiter name.
+func (l *Limiter) Name() string {
+	return l.name
+}
+
+// Token represents an admitted request. Call Done exactly once when the
+// request completes.
+type Token struct {
+	l        *Limiter
+	start    time.Time
+	inFlight int
+}
+
+// Acquire admits a request at priority p, or returns ErrLimitExceeded if it
+// should be shed.
+func (l *Limiter) Acquire(p Priority) (*Token, error) {
+	l.mu.Lock()
+	if p != Critical && float64(l.inFlight) >= l.threshold(p) {
+		l.shed[p]++
+		l.mu.Unlock()
+		requestsTotal.WithLabelValues(l.name, p.String(), "shed").Inc()
+		return nil, ErrLimitExceeded
+	}
+	l.inFlight++
+	inFlight := l.inFlight
+	l.accepted[p]++
+	l.mu.Unlock()
+
+	inFlightGauge.WithLabelValues(l.name).Set(float64(inFlight))
+	requestsTotal.WithLabelValues(l.name, p.String(), "accepted").Inc()
+	return &Token{l: l, start: time.Now(), inFlight: inFlight}, nil
+}
+
+// threshold returns the in-flight count at which priority p is shed.
+// Caller must hold l.mu.
+func (l *Limiter) threshold(p Priority) float64 {
+	share, ok := l.shares[p]
+	if !ok {
+		share = 1.0
+	}
+	t := math.Floor(float64(l.limit) * share)
+	if t < 1 {
+		t = 1
+	}
+	return t
+}
+
+// Done releases the token and feeds the observed latency to the algorithm.
+// dropped should be true if the request failed due to overload.
+func (t *Token) Done(dropped bool) {
+	sample := Sample{
+		RTT:      time.Since(t.start),
+		InFlight: t.inFlight,
+		Dropped:  dropped,
+	}
+	limit := t.l.algo.Update(sample)
+
+	t.l.mu.Lock()
+	t.l.inFlight--
+	t.l.limit = limit
+	inFlight := t.l.inFlight
+	t.l.mu.Unlock()
+
+	inFlightGauge.WithLabelValues(t.l.name).Set(float64(inFlight))
+	limitGauge.WithLabelValues(t.l.name).Set(float64(limit))
+}
+
+// Stats is a point-in-time snapshot of a limiter.
+type Stats struct {
+	Name      string            `json:"name"`
+	Algorithm string            `json:"algorithm"`
+	Limit     int               `json:"limit"`
+	InFlight  int               `json:"in_flight"`
+	Accepted  map[string]uint64 `json:"accepted"`
+	Shed      map[string]uint64 `json:"shed"`
+}
+
+// Stats returns a snapshot of the limiter state.
+func (l *Limiter) Stats() Stats {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	s := Stats{
+		Name:      l.name,
+		Algorithm: l.algo.Name(),
+		Limit:     l.limit,
+		InFlight:  l.inFlight,
+		Accepted:  make(map[string]uint64, len(l.accepted)),
+		Shed:      make(map[string]uint64, len(l.shed)),
+	}
+	for p, n := range l.accepted {
+		s.Accepted[p.String()] = n
+	}
+	for p, n := range l.shed {
+		s.Shed[p.String()] = n
+	}
+	return s
+}
+
+// registry tracks limiters by name for AdminHandler.
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]*Limiter)
+)
+
+func register(l *Limiter) {
+	registryMu.Lock()
+	registry[l.name] = l
+	registryMu.Unlock()
+}
+
+// Lookup returns the registered limiter with the given name.
+func Lookup(name string) (*Limiter, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	l, ok := registry[name]
+	return l, ok
+}
+
+// All returns snapshots of all registered limiters, sorted by name.
+func All() []Stats {
+	registryMu.RLock()
+	limiters := make([]*Limiter, 0, len(registry))
+	for _, l := range registry {
+		limiters = append(limiters, l)
+	}
+	registryMu.RUnlock()
+
+	out := make([]Stats, 0, len(limiters))
+	for _, l := range limiters {
+		out = append(out, l.Stats())
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
@@ -0,0 +1,193 @@
+// loadshed/middleware.go
+package loadshed
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// ClassifyFunc assigns a priority to a request.
+type ClassifyFunc func(r *http.Request) Priority
+
+// ClassifyByPrefix returns a ClassifyFunc that matches the longest path
+// prefix in classes, falling back to def.
+//
+//	loadshed.ClassifyByPrefix(map[string]loadshed.Priority{
+//	    "/health":       loadshed.Critical,
+//	    "/login":        loadshed.Critical,
+//	    "/api/export":   loadshed.Low,
+//	}, loadshed.Normal)
+func ClassifyByPrefix(classes map[string]Priority, def Priority) ClassifyFunc {
+	return func(r *http.Request) Priority {
+		best := -1
+		p := def
+		for prefix, class := range classes {
+			if strings.HasPrefix(r.URL.Path, prefix) && len(prefix) > best {
+				best = len(prefix)
+				p = class
+			}
+		}
+		return p
+	}
+}
+
+// Config configures the load shedding middleware.
+type Config struct {
+	// Name identifies the limiter in metrics and AdminHandler.
+	// Defaults to "default".
+	Name string
+
+	// Algorithm adapts the limit. Defaults to AIMD.
+	Algorithm Algorithm
+
+	// Shares overrides DefaultShares.
+	Shares map[Priority]float64
+
+	// Classify assigns a priority to each request. Defaults to Normal for
+	// everything.
+	Classify ClassifyFunc
+
+	// RetryAfter is sent in the Retry-After header on shed requests.
+	// Defaults to 1 second.
+	RetryAfter time.Duration
+
+	// IsDropped reports whether a completed request indicates overload.
+	// Defaults to DefaultIsDropped.
+	IsDropped func(r *http.Request, status int) bool
+
+	// OnShed is called instead of writing the default 503 response.
+	OnShed func(w http.ResponseWriter, r *http.Request, p Priority)
+}
+
+// DefaultIsDropped treats 503 and 504 responses, and requests whose
+// context deadline expired, as overload signals.
+func DefaultIsDropped(r *http.Request, status int) bool {
+	if status == http.StatusServiceUnavailable || status == http.StatusGatewayTimeout {
+		return true
+	}
+	return errors.Is(r.Context().Err(), context.DeadlineExceeded)
+}
+
+// DefaultConfig returns a default configuration.
+func DefaultConfig() Config {
+	return Config{
+		Name:       "default",
+		RetryAfter: time.Second,
+		IsDropped:  DefaultIsDropped,
+	}
+}
+
+// Middleware returns HTTP middleware that sheds requests when the adaptive
+// concurrency limit is reached. Shed requests receive 503 with Retry-After.
+func Middleware(cfg Config) func(http.Handler) http.Handler {
+	if cfg.Name == "" {
+		cfg.Name = "default"
+	}
+	l := NewLimiter(cfg.Name, cfg.Algorithm, cfg.Shares)
+	return MiddlewareWithLimiter(l, cfg)
+}
+
+// MiddlewareWithLimiter returns middleware using a provided Limiter.
+// Useful when several route groups share one limiter with different
+// priorities, or when you need the limiter for stats.
+func MiddlewareWithLimiter(l *Limiter, cfg Config) func(http.Handler) http.Handler {
+	if cfg.Classify == nil {
+		cfg.Classify = func(*http.Request) Priority { return Normal }
+	}
+	if cfg.RetryAfter <= 0 {
+		cfg.RetryAfter = time.Second
+	}
+	if cfg.IsDropped == nil {
+		cfg.IsDropped = DefaultIsDropped
+	}
+	retryAfter := strconv.Itoa(int((cfg.RetryAfter + time.Second - 1) / time.Second))
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			p := cfg.Classify(r)
+
+			token, err := l.Acquire(p)
+			if err != nil {
+				if cfg.OnShed != nil {
+					cfg.OnShed(w, r, p)
+					return
+				}
+				w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+				w.Header().Set("Retry-After", retryAfter)
+				w.WriteHeader(http.StatusServiceUnavailable)
+				w.Write([]byte("server overloaded"))
+				return
+			}
+
+			protoMajor := r.ProtoMajor
+			if protoMajor < 1 {
+				protoMajor = 1
+			}
+			ww := middleware.NewWrapResponseWriter(w, protoMajor)
+
+			defer func() {
+				status := ww.Status()
+				if status == 0 {
+					status = http.StatusOK
+				}
+				token.Done(cfg.IsDropped(r, status))
+			}()
+
+			next.ServeHTTP(ww, r)
+		})
+	}
+}
+
+// Handler returns middleware that admits requests at a fixed priority.
+// Use it to share one limiter across chi route groups:
+//
+//	lim := loadshed.NewLimiter("api", loadshed.NewGradient(), nil)
+//	r.With(lim.Handler(loadshed.Critical)).Post("/login", login)
+//	r.With(lim.Handler(loadshed.Low)).Get("/export", export)
+func (l *Limiter) Handler(p Priority) func(http.Handler) http.Handler {
+	return MiddlewareWithLimiter(l, Config{
+		Classify: func(*http.Request) Priority { return p },
+	})
+}
+
+// AdminHandler exposes limiter state as JSON.
+//
+//	GET /          → all limiters
+//	GET /{name}    → a single limiter
+type AdminHandler struct{}
+
+// NewAdminHandler creates a new admin handler.
+func NewAdminHandler() *AdminHandler {
+	return &AdminHandler{}
+}
+
+// ServeHTTP handles admin requests.
+func (h *AdminHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	name := strings.Trim(r.URL.Path, "/")
+
+	w.Header().Set("Content-Type", "application/json")
+
+	if name == "" {
+		json.NewEncoder(w).Encode(All())
+		return
+	}
+
+	l, ok := Lookup(name)
+	if !ok {
+		http.Error(w, "Limiter not found", http.StatusNotFound)
+		return
+	}
+	json.NewEncoder(w).Encode(l.Stats())
+}
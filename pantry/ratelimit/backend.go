@@ -0,0 +1,105 @@
+// ratelimit/backend.go
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+)
+
+// Backend stores per-key rate limit state for Middleware. KeyLimiter keeps
+// it in memory; RedisLimiter shares it across instances.
+type Backend interface {
+	// Take consumes n units of key's allowance under limit and reports
+	// whether they were allowed. Nothing is consumed when denied.
+	Take(ctx context.Context, key string, limit Limit, n int) (Result, error)
+
+	// TakeAll consumes n units from each of key's limits only if every
+	// limit allows them, returning a Result per limit. With n of 0 it
+	// reports the remaining allowance without consuming anything.
+	TakeAll(ctx context.Context, key string, limits []Limit, n int) ([]Result, error)
+}
+
+// Limit is a rate with a burst allowance.
+type Limit struct {
+	// Rate is units per second.
+	Rate float64
+
+	// Burst is the most units allowed at once.
+	Burst int
+}
+
+// PerSecond returns a limit of n per second with a burst of n.
+func PerSecond(n int) Limit {
+	return Every(time.Second, n)
+}
+
+// PerMinute returns a limit of n per minute with a burst of n.
+func PerMinute(n int) Limit {
+	return Every(time.Minute, n)
+}
+
+// PerHour returns a limit of n per hour with a burst of n.
+func PerHour(n int) Limit {
+	return Every(time.Hour, n)
+}
+
+// Every returns a limit of n per period with a burst of n.
+func Every(period time.Duration, n int) Limit {
+	return Limit{Rate: float64(n) / period.Seconds(), Burst: n}
+}
+
+// Period returns the time the limit takes to refill its burst; the
+// sliding window algorithm uses it as the window.
+func (l Limit) Period() time.Duration {
+	if l.Rate <= 0 {
+		return 0
+	}
+	return time.Duration(float64(l.Burst) / l.Rate * float64(time.Second))
+}
+
+// String identifies the limit in backend keys, e.g. "60/1m0s".
+func (l Limit) String() string {
+	return fmt.Sprintf("%d/%s", l.Burst, l.Period())
+}
+
+// Result is the outcome of Backend.Take.
+type Result struct {
+	// Allowed reports whether the units were consumed.
+	Allowed bool
+
+	// Limit is the burst size of the limit applied.
+	Limit int
+
+	// Remaining is how many units could be taken now.
+	Remaining int
+
+	// RetryAfter is how long until the request could be allowed. Zero
+	// when allowed.
+	RetryAfter time.Duration
+
+	// ResetAfter is how long until the full burst is available again.
+	ResetAfter time.Duration
+}
+
+// tokenBucketResult builds a Result from the tokens left in a bucket.
+func tokenBucketResult(limit Limit, n int, allowed bool, tokens float64) Result {
+	res := Result{
+		Allowed:   allowed,
+		Limit:     limit.Burst,
+		Remaining: int(math.Floor(tokens)),
+	}
+	if limit.Rate > 0 {
+		res.ResetAfter = seconds((float64(limit.Burst) - tokens) / limit.Rate)
+		if !allowed {
+			res.RetryAfter = seconds(math.Max(0, float64(n)-tokens) / limit.Rate)
+		}
+	}
+	return res
+}
+
+// seconds converts fractional seconds to a Duration.
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
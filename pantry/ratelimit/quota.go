@@ -0,0 +1,297 @@
+// ratelimit/quota.go
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Policy is a quota plan: windows enforced together on one key, such as
+// 60 per minute and 1000 per day.
+type Policy struct {
+	// Name identifies the policy in RateLimit headers, e.g. "free".
+	Name string
+
+	// Windows are the limits. A request must fit in all of them.
+	Windows []Window
+}
+
+// Window allows Limit units per Period.
+type Window struct {
+	Limit  int
+	Period time.Duration
+}
+
+// limits converts the policy's windows to backend limits.
+func (p Policy) limits() []Limit {
+	limits := make([]Limit, len(p.Windows))
+	for i, w := range p.Windows {
+		limits[i] = Every(w.Period, w.Limit)
+	}
+	return limits
+}
+
+// windowName names a window in RateLimit headers: the policy name, with
+// the period appended when there are several windows ("free-minute").
+func (p Policy) windowName(i int) string {
+	if len(p.Windows) == 1 {
+		return p.Name
+	}
+	var period string
+	switch d := p.Windows[i].Period; d {
+	case time.Second:
+		period = "second"
+	case time.Minute:
+		period = "minute"
+	case time.Hour:
+		period = "hour"
+	case 24 * time.Hour:
+		period = "day"
+	default:
+		period = strconv.FormatInt(int64(d.Seconds()), 10) + "s"
+	}
+	return p.Name + "-" + period
+}
+
+// ErrResetNotSupported is returned by Quota.Reset when the backend can't
+// clear state.
+var ErrResetNotSupported = errors.New("ratelimit: backend does not support reset")
+
+// Resetter is implemented by backends that can clear a key's state under
+// a set of limits. KeyLimiter and RedisLimiter implement it.
+type Resetter interface {
+	ResetAll(ctx context.Context, key string, limits []Limit) error
+}
+
+// Quota enforces policies on keys such as API keys or tenants.
+type Quota struct {
+	backend Backend
+}
+
+// NewQuota creates a Quota that keeps its state in backend. A nil backend
+// keeps it in memory.
+func NewQuota(backend Backend) *Quota {
+	if backend == nil {
+		backend = NewKeyLimiter(0, 0, 24*time.Hour)
+	}
+	return &Quota{backend: backend}
+}
+
+// Allow consumes one unit from key's windows under policy.
+func (q *Quota) Allow(ctx context.Context, key string, policy Policy) (Decision, error) {
+	return q.AllowN(ctx, key, policy, 1)
+}
+
+// AllowN consumes n units from key's windows under policy, only if every
+// window allows them. Use n to weight expensive requests.
+func (q *Quota) AllowN(ctx context.Context, key string, policy Policy, n int) (Decision, error) {
+	d := Decision{Allowed: true, Policy: policy}
+	if len(policy.Windows) == 0 {
+		return d, nil
+	}
+
+	results, err := q.backend.TakeAll(ctx, key, policy.limits(), n)
+	if err != nil {
+		return d, err
+	}
+	d.Results = results
+	d.Allowed = results[0].Allowed
+	return d, nil
+}
+
+// Remaining reports key's remaining quota under policy without consuming
+// any.
+func (q *Quota) Remaining(ctx context.Context, key string, policy Policy) (Decision, error) {
+	return q.AllowN(ctx, key, policy, 0)
+}
+
+// Reset clears key's usage in every window of policy, e.g. after a plan
+// upgrade or a support override.
+func (q *Quota) Reset(ctx context.Context, key string, policy Policy) error {
+	r, ok := q.backend.(Resetter)
+	if !ok {
+		return ErrResetNotSupported
+	}
+	return r.ResetAll(ctx, key, policy.limits())
+}
+
+// Decision is the outcome of a quota check.
+type Decision struct {
+	// Allowed reports whether the units were consumed.
+	Allowed bool
+
+	// Policy is the policy applied.
+	Policy Policy
+
+	// Results holds a Result per policy window.
+	Results []Result
+}
+
+// Remaining returns the units left in the tightest window.
+func (d Decision) Remaining() int {
+	remaining := -1
+	for _, res := range d.Results {
+		if remaining < 0 || res.Remaining < remaining {
+			remaining = res.Remaining
+		}
+	}
+	return max(remaining, 0)
+}
+
+// RetryAfter returns how long until a denied request could be allowed.
+func (d Decision) RetryAfter() time.Duration {
+	var retry time.Duration
+	for _, res := range d.Results {
+		retry = max(retry, res.RetryAfter)
+	}
+	return retry
+}
+
+// SetHeaders sets the IETF RateLimit-Policy and RateLimit headers, one
+// item per window, and Retry-After when denied.
+//
+//	RateLimit-Policy: "free-minute";q=60;w=60, "free-day";q=1000;w=86400
+//	RateLimit: "free-minute";r=59;t=1, "free-day";r=999;t=87
+func (d Decision) SetHeaders(h http.Header) {
+	if len(d.Results) == 0 {
+		return
+	}
+
+	policies := make([]string, len(d.Results))
+	limits := make([]string, len(d.Results))
+	for i, res := range d.Results {
+		name := quoteString(d.Policy.windowName(i))
+		policies[i] = fmt.Sprintf("%s;q=%d;w=%d", name, d.Policy.Windows[i].Limit, int64(d.Policy.Windows[i].Period.Seconds()))
+		limits[i] = fmt.Sprintf("%s;r=%d;t=%d", name, res.Remaining, int64(res.ResetAfter.Seconds()+0.999))
+	}
+	h.Set("RateLimit-Policy", strings.Join(policies, ", "))
+	h.Set("RateLimit", strings.Join(limits, ", "))
+
+	if !d.Allowed {
+		h.Set("Retry-After", retryAfter(d.RetryAfter()))
+	}
+}
+
+// quoteString formats s as a structured field string.
+func quoteString(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	return `"` + strings.ReplaceAll(s, `"`, `\"`) + `"`
+}
+
+// Resolver returns the quota key and policy for a request. Returning
+// false leaves the request unlimited.
+type Resolver func(r *http.Request) (key string, policy Policy, ok bool)
+
+// PlanResolver resolves policies by plan name. fn returns the request's
+// key and plan, e.g. from an API key lookup or a JWT claim. Unknown plans
+// use plans[""] if present; requests with no key or policy are unlimited.
+func PlanResolver(plans map[string]Policy, fn func(r *http.Request) (key, plan string)) Resolver {
+	return func(r *http.Request) (string, Policy, bool) {
+		key, plan := fn(r)
+		if key == "" {
+			return "", Policy{}, false
+		}
+		policy, ok := plans[plan]
+		if !ok {
+			policy, ok = plans[""]
+		}
+		return key, policy, ok
+	}
+}
+
+// HeaderKeyFunc returns a KeyFunc that uses a request header, such as an
+// API key header, as the rate limit key.
+func HeaderKeyFunc(header string) KeyFunc {
+	return func(r *http.Request) string {
+		return r.Header.Get(header)
+	}
+}
+
+// QuotaConfig configures the quota middleware.
+type QuotaConfig struct {
+	// Resolver returns each request's key and policy. Required.
+	Resolver Resolver
+
+	// Backend stores quota state. Use a RedisLimiter to share quotas
+	// across instances.
+	// Defaults to an in-memory KeyLimiter keeping idle keys for 24 hours.
+	Backend Backend
+
+	// Cost returns the units a request consumes.
+	// Defaults to 1 per request.
+	Cost func(r *http.Request) int
+
+	// StatusCode is the HTTP status when over quota.
+	// Defaults to 429 Too Many Requests.
+	StatusCode int
+
+	// Message is the response body when over quota.
+	// Defaults to "rate limit exceeded".
+	Message string
+
+	// OnLimited is called when a request is over quota, after the
+	// RateLimit and Retry-After headers are set.
+	OnLimited func(w http.ResponseWriter, r *http.Request)
+
+	// Skip returns true to skip quota checks for a request.
+	Skip func(r *http.Request) bool
+}
+
+// QuotaMiddleware returns HTTP middleware that enforces per-request
+// policies and sets RateLimit headers on every limited response.
+func QuotaMiddleware(cfg QuotaConfig) func(http.Handler) http.Handler {
+	if cfg.StatusCode == 0 {
+		cfg.StatusCode = http.StatusTooManyRequests
+	}
+	if cfg.Message == "" {
+		cfg.Message = "rate limit exceeded"
+	}
+	quota := NewQuota(cfg.Backend)
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if cfg.Skip != nil && cfg.Skip(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			key, policy, ok := cfg.Resolver(r)
+			if !ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			cost := 1
+			if cfg.Cost != nil {
+				cost = cfg.Cost(r)
+			}
+
+			// Backend errors let the request through
+			d, err := quota.AllowN(r.Context(), key, policy, cost)
+			if err != nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			d.SetHeaders(w.Header())
+			if !d.Allowed {
+				if cfg.OnLimited != nil {
+					cfg.OnLimited(w, r)
+					return
+				}
+
+				w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+				w.WriteHeader(cfg.StatusCode)
+				w.Write([]byte(cfg.Message))
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
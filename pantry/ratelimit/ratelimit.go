@@ -2,7 +2,10 @@
 package ratelimit
 
 import (
+	"context"
+	"math"
 	"net/http"
+	"strconv"
 	"sync"
 	"time"
 )
@@ -75,6 +78,28 @@ func (l *Limiter) AllowN(n int) bool {
 	return false
 }
 
+// take consumes n tokens if available and returns the tokens left.
+func (l *Limiter) take(n int) (bool, float64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	elapsed := now.Sub(l.lastTime).Seconds()
+	l.lastTime = now
+
+	l.tokens += elapsed * l.rate
+	if l.tokens > float64(l.burst) {
+		l.tokens = float64(l.burst)
+	}
+
+	if l.tokens >= float64(n) {
+		l.tokens -= float64(n)
+		return true, l.tokens
+	}
+
+	return false, l.tokens
+}
+
 // Tokens returns the current number of available tokens.
 func (l *Limiter) Tokens() float64 {
 	l.mu.Lock()
@@ -160,6 +185,76 @@ func (kl *KeyLimiter) AllowN(key string, n int) bool {
 	return e.limiter.AllowN(n)
 }
 
+// Take implements Backend. Keys limited with the KeyLimiter's own rate
+// and burst share buckets with Allow; other limits get buckets of their own.
+func (kl *KeyLimiter) Take(ctx context.Context, key string, limit Limit, n int) (Result, error) {
+	kl.mu.Lock()
+	defer kl.mu.Unlock()
+
+	allowed, tokens := kl.limiter(key, limit).take(n)
+	return tokenBucketResult(limit, n, allowed, tokens), nil
+}
+
+// TakeAll implements Backend.
+func (kl *KeyLimiter) TakeAll(ctx context.Context, key string, limits []Limit, n int) ([]Result, error) {
+	kl.mu.Lock()
+	defer kl.mu.Unlock()
+
+	limiters := make([]*Limiter, len(limits))
+	tokens := make([]float64, len(limits))
+	allowed := true
+	for i, limit := range limits {
+		limiters[i] = kl.limiter(key, limit)
+		tokens[i] = limiters[i].Tokens()
+		if tokens[i] < float64(n) {
+			allowed = false
+		}
+	}
+
+	results := make([]Result, len(limits))
+	for i, limit := range limits {
+		// Tokens only grow, so every take succeeds
+		if allowed {
+			_, tokens[i] = limiters[i].take(n)
+		}
+		results[i] = tokenBucketResult(limit, n, allowed, tokens[i])
+	}
+	return results, nil
+}
+
+// ResetAll clears key's state under limits, as kept by Take and TakeAll.
+func (kl *KeyLimiter) ResetAll(ctx context.Context, key string, limits []Limit) error {
+	kl.mu.Lock()
+	defer kl.mu.Unlock()
+
+	for _, limit := range limits {
+		delete(kl.limiters, kl.limiterKey(key, limit))
+	}
+	return nil
+}
+
+// limiterKey returns the map key of key's limiter for limit.
+func (kl *KeyLimiter) limiterKey(key string, limit Limit) string {
+	if limit.Rate != kl.rate || limit.Burst != kl.burst {
+		key += "\x00" + limit.String()
+	}
+	return key
+}
+
+// limiter returns key's limiter for limit, creating it if needed. The
+// caller must hold kl.mu.
+func (kl *KeyLimiter) limiter(key string, limit Limit) *Limiter {
+	key = kl.limiterKey(key, limit)
+
+	e, exists := kl.limiters[key]
+	if !exists {
+		e = &entry{limiter: New(limit.Rate, limit.Burst)}
+		kl.limiters[key] = e
+	}
+	e.lastSeen = time.Now()
+	return e.limiter
+}
+
 // cleanup removes stale entries periodically.
 func (kl *KeyLimiter) cleanup() {
 	ticker := time.NewTicker(kl.ttl)
@@ -243,7 +338,13 @@ type Config struct {
 	// Defaults to IPKeyFunc.
 	KeyFunc KeyFunc
 
-	// TTL is how long to keep inactive keys.
+	// Backend stores the rate limit state. Use a RedisLimiter to share
+	// limits across instances.
+	// Defaults to an in-memory KeyLimiter.
+	Backend Backend
+
+	// TTL is how long to keep inactive keys in the default in-memory
+	// backend.
 	// Defaults to 1 hour.
 	TTL time.Duration
 
@@ -278,52 +379,25 @@ func DefaultConfig() Config {
 
 // Middleware returns HTTP middleware that applies rate limiting.
 func Middleware(cfg Config) func(http.Handler) http.Handler {
-	if cfg.KeyFunc == nil {
-		cfg.KeyFunc = IPKeyFunc
-	}
 	if cfg.TTL == 0 {
 		cfg.TTL = time.Hour
 	}
-	if cfg.StatusCode == 0 {
-		cfg.StatusCode = http.StatusTooManyRequests
-	}
-	if cfg.Message == "" {
-		cfg.Message = "rate limit exceeded"
+	if cfg.Backend == nil {
+		cfg.Backend = NewKeyLimiter(cfg.Rate, cfg.Burst, cfg.TTL)
 	}
 
-	limiter := NewKeyLimiter(cfg.Rate, cfg.Burst, cfg.TTL)
-
-	return func(next http.Handler) http.Handler {
-		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			// Check skip function
-			if cfg.Skip != nil && cfg.Skip(r) {
-				next.ServeHTTP(w, r)
-				return
-			}
-
-			key := cfg.KeyFunc(r)
-
-			if !limiter.Allow(key) {
-				if cfg.OnLimited != nil {
-					cfg.OnLimited(w, r)
-					return
-				}
-
-				w.Header().Set("Content-Type", "text/plain; charset=utf-8")
-				w.Header().Set("Retry-After", "1")
-				w.WriteHeader(cfg.StatusCode)
-				w.Write([]byte(cfg.Message))
-				return
-			}
-
-			next.ServeHTTP(w, r)
-		})
-	}
+	return middleware(cfg, Limit{Rate: cfg.Rate, Burst: cfg.Burst})
 }
 
 // MiddlewareWithLimiter returns middleware using a provided KeyLimiter.
 // Useful when you need access to the limiter for metrics or management.
 func MiddlewareWithLimiter(limiter *KeyLimiter, cfg Config) func(http.Handler) http.Handler {
+	cfg.Backend = limiter
+	return middleware(cfg, Limit{Rate: limiter.rate, Burst: limiter.burst})
+}
+
+// middleware applies limit to each request's key with cfg.Backend.
+func middleware(cfg Config, limit Limit) func(http.Handler) http.Handler {
 	if cfg.KeyFunc == nil {
 		cfg.KeyFunc = IPKeyFunc
 	}
@@ -336,6 +410,7 @@ func MiddlewareWithLimiter(limiter *KeyLimiter, cfg Config) func(http.Handler) h
 
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// Check skip function
 			if cfg.Skip != nil && cfg.Skip(r) {
 				next.ServeHTTP(w, r)
 				return
@@ -343,14 +418,16 @@ func MiddlewareWithLimiter(limiter *KeyLimiter, cfg Config) func(http.Handler) h
 
 			key := cfg.KeyFunc(r)
 
-			if !limiter.Allow(key) {
+			// Backend errors let the request through
+			res, err := cfg.Backend.Take(r.Context(), key, limit, 1)
+			if err == nil && !res.Allowed {
 				if cfg.OnLimited != nil {
 					cfg.OnLimited(w, r)
 					return
 				}
 
 				w.Header().Set("Content-Type", "text/plain; charset=utf-8")
-				w.Header().Set("Retry-After", "1")
+				w.Header().Set("Retry-After", retryAfter(res.RetryAfter))
 				w.WriteHeader(cfg.StatusCode)
 				w.Write([]byte(cfg.Message))
 				return
@@ -361,6 +438,16 @@ func MiddlewareWithLimiter(limiter *KeyLimiter, cfg Config) func(http.Handler) h
 	}
 }
 
+// retryAfter formats a delay for the Retry-After header, in whole seconds
+// rounded up.
+func retryAfter(d time.Duration) string {
+	secs := int64(math.Ceil(d.Seconds()))
+	if secs < 1 {
+		secs = 1
+	}
+	return strconv.FormatInt(secs, 10)
+}
+
 // Simple returns a simple rate limit middleware with the given rate and burst.
 // Uses IP-based rate limiting with sensible defaults.
 func Simple(rate float64, burst int) func(http.Handler) http.Handler {
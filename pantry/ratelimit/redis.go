@@ -0,0 +1,393 @@
+// ratelimit/redis.go
+package ratelimit
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ErrInvalidLimit is returned when a limit's rate or burst isn't positive.
+var ErrInvalidLimit = errors.New("ratelimit: limit rate and burst must be positive")
+
+// Algorithm selects how RedisLimiter enforces a limit.
+type Algorithm int
+
+const (
+	// TokenBucket refills Burst tokens at Rate per second, like Limiter.
+	// Stores a small hash per key.
+	TokenBucket Algorithm = iota
+
+	// GCRA (generic cell rate algorithm) behaves like TokenBucket but
+	// stores a single timestamp per key.
+	GCRA
+
+	// SlidingWindow allows at most Burst units in any window of
+	// Limit.Period(). It is exact but stores one entry per unit taken, so
+	// keep it for small limits such as login attempts.
+	SlidingWindow
+)
+
+// FailureMode selects what RedisLimiter does when Redis is unavailable.
+type FailureMode int
+
+const (
+	// FailLocal enforces limits with a process-local fallback limiter.
+	// Each instance then allows the full limit on its own.
+	FailLocal FailureMode = iota
+
+	// FailOpen allows every request.
+	FailOpen
+
+	// FailClosed denies every request.
+	FailClosed
+)
+
+// RedisLimiter is a Backend that keeps rate limit state in Redis, so all
+// instances of an app share the same limits. Each decision is a single
+// Lua script, so it is atomic and works on Redis Cluster.
+type RedisLimiter struct {
+	client    redis.UniversalClient
+	algorithm Algorithm
+	prefix    string
+	timeout   time.Duration
+	onFailure FailureMode
+	fallback  Backend
+	onError   func(err error)
+}
+
+// RedisConfig configures a RedisLimiter.
+type RedisConfig struct {
+	// Client is the Redis client. Required.
+	Client redis.UniversalClient
+
+	// Algorithm selects the limiting algorithm.
+	// Default: TokenBucket.
+	Algorithm Algorithm
+
+	// KeyPrefix is prepended to every key. Use a different prefix for
+	// each middleware so their limits don't share state.
+	// Default: "ratelimit:".
+	KeyPrefix string
+
+	// Timeout bounds each Redis call so a slow Redis doesn't stall
+	// requests; a timeout counts as a failure.
+	// Default: 100 milliseconds.
+	Timeout time.Duration
+
+	// OnFailure selects what happens when Redis fails.
+	// Default: FailLocal.
+	OnFailure FailureMode
+
+	// Fallback limits requests locally under FailLocal.
+	// Default: an in-memory KeyLimiter.
+	Fallback Backend
+
+	// OnError is called with each Redis failure, for logging.
+	OnError func(err error)
+}
+
+// NewRedisLimiter creates a Redis-backed limiter.
+func NewRedisLimiter(cfg RedisConfig) *RedisLimiter {
+	if cfg.KeyPrefix == "" {
+		cfg.KeyPrefix = "ratelimit:"
+	}
+	if cfg.Timeout == 0 {
+		cfg.Timeout = 100 * time.Millisecond
+	}
+	if cfg.Fallback == nil {
+		cfg.Fallback = NewKeyLimiter(0, 0, time.Hour)
+	}
+
+	return &RedisLimiter{
+		client:    cfg.Client,
+		algorithm: cfg.Algorithm,
+		prefix:    cfg.KeyPrefix,
+		timeout:   cfg.Timeout,
+		onFailure: cfg.OnFailure,
+		fallback:  cfg.Fallback,
+		onError:   cfg.OnError,
+	}
+}
+
+// The scripts check every key in KEYS and consume n units from each only
+// if all allow it. ARGV holds the current time in unix ms (so instance
+// clocks should be synchronized), n, and then a pair of values per key.
+// Expiries are formatted with %d because some Lua implementations write
+// large numbers in exponent form.
+var (
+	// Takes rate and burst per key. Returns {allowed, tokens left...}.
+	redisTokenBucketScript = redis.NewScript(`
+local now, n = tonumber(ARGV[1]), tonumber(ARGV[2])
+local tokens, allowed = {}, 1
+for i, k in ipairs(KEYS) do
+  local rate, burst = tonumber(ARGV[2 * i + 1]), tonumber(ARGV[2 * i + 2])
+  local f = redis.call('HMGET', k, 'tokens', 'ts')
+  local t, ts = tonumber(f[1]), tonumber(f[2])
+  if not t then t, ts = burst, now end
+  tokens[i] = math.min(burst, t + math.max(0, now - ts) / 1000 * rate)
+  if tokens[i] < n then allowed = 0 end
+end
+local out = {allowed}
+for i, k in ipairs(KEYS) do
+  local rate, burst = tonumber(ARGV[2 * i + 1]), tonumber(ARGV[2 * i + 2])
+  if allowed == 1 then tokens[i] = tokens[i] - n end
+  redis.call('HMSET', k, 'tokens', tostring(tokens[i]), 'ts', now)
+  redis.call('PEXPIRE', k, string.format('%d', math.ceil(burst / rate * 1000) + 1000))
+  out[i + 1] = tostring(tokens[i])
+end
+return out
+`)
+
+	// Stores the theoretical arrival time. Takes the emission interval in
+	// ms and burst per key. Returns {allowed, then remaining, retry ms and
+	// reset ms per key}.
+	redisGCRAScript = redis.NewScript(`
+local now, n = tonumber(ARGV[1]), tonumber(ARGV[2])
+local tats, allowed = {}, 1
+for i, k in ipairs(KEYS) do
+  local interval, burst = tonumber(ARGV[2 * i + 1]), tonumber(ARGV[2 * i + 2])
+  tats[i] = math.max(tonumber(redis.call('GET', k)) or now, now)
+  if now < tats[i] + n * interval - interval * burst then allowed = 0 end
+end
+local out = {allowed}
+for i, k in ipairs(KEYS) do
+  local interval, burst = tonumber(ARGV[2 * i + 1]), tonumber(ARGV[2 * i + 2])
+  local tolerance = interval * burst
+  local tat, retry = tats[i], 0
+  if allowed == 1 then
+    if n > 0 then
+      tat = tat + n * interval
+      redis.call('SET', k, tostring(tat), 'PX', string.format('%d', math.ceil(tat - now)))
+    end
+  else
+    retry = math.max(0, math.ceil(tat + n * interval - tolerance - now))
+  end
+  table.insert(out, math.floor((now - (tat - tolerance)) / interval))
+  table.insert(out, retry)
+  table.insert(out, math.ceil(tat - now))
+end
+return out
+`)
+
+	// Logs one sorted set entry per unit, scored by time. Takes the window
+	// in ms and limit per key, after a unique ID in ARGV[3]. Returns
+	// {allowed, then remaining, retry ms and reset ms per key}.
+	redisSlidingWindowScript = redis.NewScript(`
+local now, n, id = tonumber(ARGV[1]), tonumber(ARGV[2]), ARGV[3]
+local counts, allowed = {}, 1
+for i, k in ipairs(KEYS) do
+  local window, limit = tonumber(ARGV[2 * i + 2]), tonumber(ARGV[2 * i + 3])
+  redis.call('ZREMRANGEBYSCORE', k, '-inf', now - window)
+  counts[i] = redis.call('ZCARD', k)
+  if counts[i] + n > limit then allowed = 0 end
+end
+local out = {allowed}
+for i, k in ipairs(KEYS) do
+  local window, limit = tonumber(ARGV[2 * i + 2]), tonumber(ARGV[2 * i + 3])
+  local count, retry = counts[i], 0
+  if allowed == 1 and n > 0 then
+    for j = 1, n do
+      redis.call('ZADD', k, now, id .. ':' .. j)
+    end
+    redis.call('PEXPIRE', k, string.format('%d', math.ceil(window)))
+    count = count + n
+  elseif count + n > limit then
+    retry = window
+    if n <= limit then
+      local j = count + n - limit - 1
+      retry = tonumber(redis.call('ZRANGE', k, j, j, 'WITHSCORES')[2]) + window - now
+    end
+  end
+  local reset = 0
+  local newest = redis.call('ZRANGE', k, -1, -1, 'WITHSCORES')
+  if newest[2] then reset = tonumber(newest[2]) + window - now end
+  table.insert(out, limit - count)
+  table.insert(out, math.ceil(retry))
+  table.insert(out, math.ceil(reset))
+end
+return out
+`)
+)
+
+// Take implements Backend. When Redis fails, the outcome follows the
+// configured FailureMode and no error is returned.
+func (l *RedisLimiter) Take(ctx context.Context, key string, limit Limit, n int) (Result, error) {
+	results, err := l.takeAll(ctx, []string{l.prefix + key}, []Limit{limit}, n, func() ([]Result, error) {
+		res, err := l.fallback.Take(ctx, key, limit, n)
+		return []Result{res}, err
+	})
+	if err != nil {
+		return Result{}, err
+	}
+	return results[0], nil
+}
+
+// TakeAll implements Backend. The limits' keys share a hash tag, so they
+// are checked in one script on Redis Cluster too.
+func (l *RedisLimiter) TakeAll(ctx context.Context, key string, limits []Limit, n int) ([]Result, error) {
+	keys := make([]string, len(limits))
+	for i, limit := range limits {
+		keys[i] = l.takeAllKey(key, limit)
+	}
+	return l.takeAll(ctx, keys, limits, n, func() ([]Result, error) {
+		return l.fallback.TakeAll(ctx, key, limits, n)
+	})
+}
+
+// takeAllKey returns the Redis key of key's state under limit for
+// TakeAll. The hash tag keeps all of a key's limits in one cluster slot.
+func (l *RedisLimiter) takeAllKey(key string, limit Limit) string {
+	return l.prefix + "{" + key + "}:" + limit.String()
+}
+
+// takeAll runs the script on keys, applying the FailureMode if Redis
+// fails. fallback takes from the local limiter.
+func (l *RedisLimiter) takeAll(ctx context.Context, keys []string, limits []Limit, n int, fallback func() ([]Result, error)) ([]Result, error) {
+	for _, limit := range limits {
+		if limit.Rate <= 0 || limit.Burst <= 0 {
+			return nil, ErrInvalidLimit
+		}
+	}
+
+	rctx, cancel := context.WithTimeout(ctx, l.timeout)
+	defer cancel()
+
+	results, err := l.run(rctx, keys, limits, n)
+	if err == nil {
+		return results, nil
+	}
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	if l.onError != nil {
+		l.onError(fmt.Errorf("ratelimit: redis failed: %w", err))
+	}
+
+	switch l.onFailure {
+	case FailOpen:
+		results = make([]Result, len(limits))
+		for i, limit := range limits {
+			results[i] = Result{Allowed: true, Limit: limit.Burst, Remaining: limit.Burst}
+		}
+		return results, nil
+	case FailClosed:
+		results = make([]Result, len(limits))
+		for i, limit := range limits {
+			results[i] = Result{Limit: limit.Burst, RetryAfter: time.Second}
+		}
+		return results, nil
+	default:
+		return fallback()
+	}
+}
+
+// run runs the configured algorithm's script.
+func (l *RedisLimiter) run(ctx context.Context, keys []string, limits []Limit, n int) ([]Result, error) {
+	args := []any{time.Now().UnixMilli(), n}
+
+	switch l.algorithm {
+	case GCRA:
+		for _, limit := range limits {
+			args = append(args, 1000/limit.Rate, limit.Burst)
+		}
+		vals, err := redisGCRAScript.Run(ctx, l.client, keys, args...).Int64Slice()
+		if err != nil {
+			return nil, err
+		}
+		return scriptResults(limits, vals)
+
+	case SlidingWindow:
+		id, err := randomID()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, id)
+		for _, limit := range limits {
+			args = append(args, limit.Period().Milliseconds(), limit.Burst)
+		}
+		vals, err := redisSlidingWindowScript.Run(ctx, l.client, keys, args...).Int64Slice()
+		if err != nil {
+			return nil, err
+		}
+		return scriptResults(limits, vals)
+
+	default:
+		for _, limit := range limits {
+			args = append(args, limit.Rate, limit.Burst)
+		}
+		vals, err := redisTokenBucketScript.Run(ctx, l.client, keys, args...).Slice()
+		if err != nil {
+			return nil, err
+		}
+		if len(vals) != len(limits)+1 {
+			return nil, fmt.Errorf("unexpected script result %v", vals)
+		}
+		allowed, _ := vals[0].(int64)
+		results := make([]Result, len(limits))
+		for i, limit := range limits {
+			s, _ := vals[i+1].(string)
+			tokens, err := strconv.ParseFloat(s, 64)
+			if err != nil {
+				return nil, err
+			}
+			results[i] = tokenBucketResult(limit, n, allowed == 1, tokens)
+		}
+		return results, nil
+	}
+}
+
+// scriptResults converts {allowed, then remaining, retry ms and reset ms
+// per limit}.
+func scriptResults(limits []Limit, vals []int64) ([]Result, error) {
+	if len(vals) != 3*len(limits)+1 {
+		return nil, fmt.Errorf("unexpected script result %v", vals)
+	}
+	results := make([]Result, len(limits))
+	for i, limit := range limits {
+		v := vals[3*i+1:]
+		results[i] = Result{
+			Allowed:    vals[0] == 1,
+			Limit:      limit.Burst,
+			Remaining:  int(max(0, min(v[0], int64(limit.Burst)))),
+			RetryAfter: time.Duration(v[1]) * time.Millisecond,
+			ResetAfter: time.Duration(max(0, v[2])) * time.Millisecond,
+		}
+	}
+	return results, nil
+}
+
+// randomID returns a random sliding window entry ID.
+func randomID() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// Reset clears the state Take keeps for key, e.g. after a successful
+// login. Use ResetAll for state kept by TakeAll.
+func (l *RedisLimiter) Reset(ctx context.Context, key string) error {
+	return l.client.Del(ctx, l.prefix+key).Err()
+}
+
+// ResetAll clears the state TakeAll keeps for key under limits, such as a
+// quota's windows.
+func (l *RedisLimiter) ResetAll(ctx context.Context, key string, limits []Limit) error {
+	if len(limits) == 0 {
+		return nil
+	}
+	keys := make([]string, len(limits))
+	for i, limit := range limits {
+		keys[i] = l.takeAllKey(key, limit)
+	}
+	return l.client.Del(ctx, keys...).Err()
+}
@@ -0,0 +1,152 @@
+package ratelimit_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/dalemusser/waffle/pantry/ratelimit"
+	"github.com/redis/go-redis/v9"
+)
+
+// newTestLimiter connects to the server at REDIS_ADDR, if set. Each
+// limiter uses its own key prefix, and Redis failures fail the test
+// instead of falling back to local limiting.
+func newTestLimiter(t *testing.T, alg ratelimit.Algorithm) (*ratelimit.RedisLimiter, redis.UniversalClient, string) {
+	t.Helper()
+	addr := os.Getenv("REDIS_ADDR")
+	if addr == "" {
+		t.Skip("REDIS_ADDR not set")
+	}
+
+	client := redis.NewClient(&redis.Options{Addr: addr})
+	prefix := fmt.Sprintf("ratelimittest:%d:", time.Now().UnixNano())
+	t.Cleanup(func() {
+		ctx := context.Background()
+		if keys, err := client.Keys(ctx, prefix+"*").Result(); err == nil && len(keys) > 0 {
+			client.Del(ctx, keys...)
+		}
+		client.Close()
+	})
+
+	l := ratelimit.NewRedisLimiter(ratelimit.RedisConfig{
+		Client:    client,
+		Algorithm: alg,
+		KeyPrefix: prefix,
+		Timeout:   time.Second,
+		OnFailure: ratelimit.FailClosed,
+		OnError:   func(err error) { t.Error(err) },
+	})
+	return l, client, prefix
+}
+
+var algorithms = []struct {
+	name string
+	alg  ratelimit.Algorithm
+}{
+	{"token bucket", ratelimit.TokenBucket},
+	{"gcra", ratelimit.GCRA},
+	{"sliding window", ratelimit.SlidingWindow},
+}
+
+func TestRedisLimiterTake(t *testing.T) {
+	ctx := context.Background()
+	limit := ratelimit.Limit{Rate: 10, Burst: 3}
+
+	for _, tt := range algorithms {
+		t.Run(tt.name, func(t *testing.T) {
+			l, client, prefix := newTestLimiter(t, tt.alg)
+
+			for i := 0; i < limit.Burst; i++ {
+				res, err := l.Take(ctx, "k", limit, 1)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !res.Allowed || res.Remaining != limit.Burst-1-i {
+					t.Fatalf("take %d: %+v", i, res)
+				}
+			}
+
+			res, err := l.Take(ctx, "k", limit, 1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.Allowed || res.RetryAfter <= 0 || res.RetryAfter > limit.Period() {
+				t.Fatalf("over the limit: %+v", res)
+			}
+
+			ttl, err := client.PTTL(ctx, prefix+"k").Result()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ttl <= 0 || ttl > limit.Period()+time.Second {
+				t.Errorf("key TTL = %v, want at most a period", ttl)
+			}
+
+			time.Sleep(res.RetryAfter + 20*time.Millisecond)
+			if res, _ := l.Take(ctx, "k", limit, 1); !res.Allowed {
+				t.Fatalf("after RetryAfter: %+v", res)
+			}
+
+			if res, _ := l.Take(ctx, "other", limit, limit.Burst+1); res.Allowed {
+				t.Fatalf("n over burst allowed: %+v", res)
+			}
+
+			if err := l.Reset(ctx, "k"); err != nil {
+				t.Fatal(err)
+			}
+			if res, _ := l.Take(ctx, "k", limit, limit.Burst); !res.Allowed {
+				t.Fatalf("after Reset: %+v", res)
+			}
+		})
+	}
+}
+
+func TestRedisLimiterTakeAll(t *testing.T) {
+	ctx := context.Background()
+	limits := []ratelimit.Limit{
+		ratelimit.PerSecond(5),
+		ratelimit.PerHour(2),
+	}
+
+	for _, tt := range algorithms {
+		t.Run(tt.name, func(t *testing.T) {
+			l, _, _ := newTestLimiter(t, tt.alg)
+
+			for i := 0; i < 2; i++ {
+				results, err := l.TakeAll(ctx, "k", limits, 1)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !results[0].Allowed || results[1].Remaining != 1-i {
+					t.Fatalf("take %d: %+v", i, results)
+				}
+			}
+
+			// The hourly limit denies, so the per-second one isn't consumed.
+			results, err := l.TakeAll(ctx, "k", limits, 1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if results[1].Allowed || results[1].RetryAfter <= 0 {
+				t.Fatalf("over the hourly limit: %+v", results)
+			}
+			if results[0].Remaining != 3 {
+				t.Errorf("per-second remaining = %d, want 3", results[0].Remaining)
+			}
+
+			if err := l.ResetAll(ctx, "k", limits); err != nil {
+				t.Fatal(err)
+			}
+			results, err = l.TakeAll(ctx, "k", limits, 2)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !results[0].Allowed || results[1].Remaining != 0 {
+				t.Fatalf("after ResetAll: %+v", results)
+			}
+		})
+	}
+}
@@ -0,0 +1,105 @@
+// retry/budget.go
+package retry
+
+import (
+	"sync"
+	"time"
+)
+
+// BudgetConfig configures a retry budget.
+type BudgetConfig struct {
+	// Ratio is the retries allowed per recent request. 0.1 allows retries
+	// of up to 10% of requests.
+	// Default: 0.1.
+	Ratio float64
+
+	// MinRetriesPerSecond is allowed on top of Ratio, so hosts with little
+	// traffic can still retry.
+	// Default: 10.
+	MinRetriesPerSecond float64
+
+	// Window is how long requests and retries count toward the budget.
+	// Default: 10 seconds.
+	Window time.Duration
+}
+
+// budgetBuckets is how many slices a budget window is counted in.
+const budgetBuckets = 10
+
+// Budget limits retries to a share of recent requests, so that during an
+// outage retries don't multiply the load on a failing dependency. It is
+// safe for concurrent use.
+type Budget struct {
+	mu         sync.Mutex
+	ratio      float64
+	minRetries float64
+	bucket     time.Duration
+	requests   [budgetBuckets]int
+	retries    [budgetBuckets]int
+	head       int
+	headStart  time.Time
+}
+
+// NewBudget creates a retry budget.
+func NewBudget(cfg BudgetConfig) *Budget {
+	if cfg.Ratio <= 0 {
+		cfg.Ratio = 0.1
+	}
+	if cfg.MinRetriesPerSecond <= 0 {
+		cfg.MinRetriesPerSecond = 10
+	}
+	if cfg.Window <= 0 {
+		cfg.Window = 10 * time.Second
+	}
+
+	return &Budget{
+		ratio:      cfg.Ratio,
+		minRetries: cfg.MinRetriesPerSecond * cfg.Window.Seconds(),
+		bucket:     cfg.Window / budgetBuckets,
+		headStart:  time.Now(),
+	}
+}
+
+// RecordRequest counts a first attempt toward the budget.
+func (b *Budget) RecordRequest() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.advance()
+	b.requests[b.head]++
+}
+
+// AllowRetry reports whether a retry fits in the budget, and counts it if
+// so.
+func (b *Budget) AllowRetry() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.advance()
+	var requests, retries int
+	for i := range budgetBuckets {
+		requests += b.requests[i]
+		retries += b.retries[i]
+	}
+
+	if float64(retries) >= b.ratio*float64(requests)+b.minRetries {
+		return false
+	}
+	b.retries[b.head]++
+	return true
+}
+
+// advance moves the head to the current bucket, clearing the buckets that
+// left the window. The caller must hold b.mu.
+func (b *Budget) advance() {
+	elapsed := int(time.Since(b.headStart) / b.bucket)
+	if elapsed <= 0 {
+		return
+	}
+	for i := 0; i < min(elapsed, budgetBuckets); i++ {
+		b.head = (b.head + 1) % budgetBuckets
+		b.requests[b.head] = 0
+		b.retries[b.head] = 0
+	}
+	b.headStart = b.headStart.Add(time.Duration(elapsed) * b.bucket)
+}
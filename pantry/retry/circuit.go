@@ -60,6 +60,13 @@ type CircuitConfig struct {
 
 	// OnStateChange is called when the circuit state changes.
 	OnStateChange func(from, to CircuitState)
+
+	// HealthCheck, if set, decides when an open circuit recovers instead
+	// of live traffic. Once Timeout has passed, it is called in the
+	// background (bounded by Timeout) until it succeeds SuccessThreshold
+	// times in a row, then the circuit closes. Requests are rejected
+	// until then; the circuit is never half-open.
+	HealthCheck func(ctx context.Context) error
 }
 
 // DefaultCircuitConfig returns sensible circuit breaker defaults.
@@ -76,13 +83,20 @@ func DefaultCircuitConfig() CircuitConfig {
 type Circuit struct {
 	mu sync.Mutex
 
-	cfg CircuitConfig
+	cfg  CircuitConfig
+	name string
 
 	state            CircuitState
 	failures         int
 	successes        int
 	lastFailure      time.Time
 	halfOpenRequests int
+
+	changedAt time.Time // time of the last state change
+	openings  int       // counts openings so stale health probes stop
+
+	// onChange is set by a Registry to report state changes.
+	onChange func(c *Circuit, from, to CircuitState, at time.Time, remote bool)
 }
 
 // NewCircuit creates a new circuit breaker.
@@ -165,15 +179,17 @@ func (c *Circuit) allowRequest() error {
 
 	case CircuitOpen:
 		// Check if timeout has elapsed
-		if time.Since(c.lastFailure) >= c.cfg.Timeout {
+		if c.cfg.HealthCheck == nil && time.Since(c.lastFailure) >= c.cfg.Timeout {
 			c.transitionTo(CircuitHalfOpen)
 			return nil
 		}
+		c.rejected()
 		return ErrCircuitOpen
 
 	case CircuitHalfOpen:
 		// Limit concurrent requests
 		if c.halfOpenRequests >= c.cfg.MaxHalfOpenRequests {
+			c.rejected()
 			return ErrCircuitOpen
 		}
 		return nil
@@ -224,14 +240,34 @@ func (c *Circuit) recordResult(err error, wasHalfOpen bool) {
 	}
 }
 
+// rejected counts a request rejected by a named circuit. The caller must
+// hold c.mu.
+func (c *Circuit) rejected() {
+	if c.name != "" {
+		circuitRejectedTotal.WithLabelValues(c.name).Inc()
+	}
+}
+
 // transitionTo changes the circuit state.
 func (c *Circuit) transitionTo(state CircuitState) {
+	c.transition(state, time.Now(), false)
+}
+
+// transition changes the circuit state at the given time. remote is true
+// for changes received from other instances. The caller must hold c.mu.
+func (c *Circuit) transition(state CircuitState, at time.Time, remote bool) {
 	if c.state == state {
 		return
 	}
 
 	oldState := c.state
 	c.state = state
+	c.changedAt = at
+
+	if state == CircuitOpen && c.cfg.HealthCheck != nil {
+		c.openings++
+		go c.probe(c.openings)
+	}
 
 	// Reset counters on state change
 	switch state {
@@ -250,6 +286,67 @@ func (c *Circuit) transitionTo(state CircuitState) {
 	if c.cfg.OnStateChange != nil {
 		go c.cfg.OnStateChange(oldState, state)
 	}
+	if c.onChange != nil {
+		go c.onChange(c, oldState, state, at, remote)
+	}
+}
+
+// probe runs the health check every Timeout while the circuit stays in
+// the opening it was started for, closing the circuit once the check
+// passes SuccessThreshold times in a row.
+func (c *Circuit) probe(opening int) {
+	timer := time.NewTimer(c.cfg.Timeout)
+	defer timer.Stop()
+
+	for range timer.C {
+		c.mu.Lock()
+		current := c.state == CircuitOpen && c.openings == opening
+		c.mu.Unlock()
+		if !current {
+			return
+		}
+
+		healthy := true
+		for range c.cfg.SuccessThreshold {
+			ctx, cancel := context.WithTimeout(context.Background(), c.cfg.Timeout)
+			err := c.cfg.HealthCheck(ctx)
+			cancel()
+			if err != nil {
+				healthy = false
+				break
+			}
+		}
+
+		c.mu.Lock()
+		if c.state != CircuitOpen || c.openings != opening {
+			c.mu.Unlock()
+			return
+		}
+		if healthy {
+			c.transitionTo(CircuitClosed)
+			c.mu.Unlock()
+			return
+		}
+		c.lastFailure = time.Now()
+		c.mu.Unlock()
+
+		timer.Reset(c.cfg.Timeout)
+	}
+}
+
+// apply sets a state received from another instance, ignoring changes
+// older than the circuit's last one.
+func (c *Circuit) apply(state CircuitState, at time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !at.After(c.changedAt) {
+		return
+	}
+	if state == CircuitOpen {
+		c.lastFailure = at
+	}
+	c.transition(state, at, true)
 }
 
 // State returns the current circuit state.
@@ -258,7 +355,7 @@ func (c *Circuit) State() CircuitState {
 	defer c.mu.Unlock()
 
 	// Check for timeout transition
-	if c.state == CircuitOpen && time.Since(c.lastFailure) >= c.cfg.Timeout {
+	if c.state == CircuitOpen && c.cfg.HealthCheck == nil && time.Since(c.lastFailure) >= c.cfg.Timeout {
 		c.transitionTo(CircuitHalfOpen)
 	}
 
@@ -287,6 +384,34 @@ func (c *Circuit) Failures() int {
 	return c.failures
 }
 
+// Name returns the circuit's name in its Registry, or "" if it has none.
+func (c *Circuit) Name() string {
+	return c.name
+}
+
+// CircuitStats is a snapshot of a circuit's state.
+type CircuitStats struct {
+	Name     string    `json:"name"`
+	State    string    `json:"state"`
+	Failures int       `json:"failures"`
+	Since    time.Time `json:"since,omitzero"` // Time of the last state change
+}
+
+// Stats returns a snapshot of the circuit's state.
+func (c *Circuit) Stats() CircuitStats {
+	state := c.State()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return CircuitStats{
+		Name:     c.name,
+		State:    state.String(),
+		Failures: c.failures,
+		Since:    c.changedAt,
+	}
+}
+
 // CircuitBreaker combines retry logic with circuit breaker.
 type CircuitBreaker struct {
 	circuit *Circuit
@@ -0,0 +1,212 @@
+// retry/hedge.go
+package retry
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+)
+
+// HedgeConfig configures hedged requests.
+type HedgeConfig struct {
+	// Percentile of the host's recent latencies after which a hedge is
+	// sent, between 0 and 1.
+	// Default: 0.95.
+	Percentile float64
+
+	// MinDelay is the shortest wait before hedging. It is also used until
+	// enough latencies have been seen.
+	// Default: 50 milliseconds.
+	MinDelay time.Duration
+
+	// MaxHedges is the most extra attempts sent per request.
+	// Default: 1.
+	MaxHedges int
+
+	// Samples is how many recent latencies are kept per host.
+	// Default: 100.
+	Samples int
+}
+
+// minLatencySamples is how many latencies a host needs before its
+// percentile replaces MinDelay.
+const minLatencySamples = 10
+
+// latencies keeps a host's recent response latencies.
+type latencies struct {
+	mu      sync.Mutex
+	samples []time.Duration
+	next    int
+	size    int
+}
+
+func newLatencies(size int) *latencies {
+	return &latencies{samples: make([]time.Duration, 0, size), size: size}
+}
+
+// record adds a latency, replacing the oldest when full.
+func (l *latencies) record(d time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if len(l.samples) < l.size {
+		l.samples = append(l.samples, d)
+		return
+	}
+	l.samples[l.next] = d
+	l.next = (l.next + 1) % l.size
+}
+
+// percentile returns the p percentile, or false if there are too few
+// samples.
+func (l *latencies) percentile(p float64) (time.Duration, bool) {
+	l.mu.Lock()
+	sorted := slices.Clone(l.samples)
+	l.mu.Unlock()
+
+	if len(sorted) < minLatencySamples {
+		return 0, false
+	}
+	slices.Sort(sorted)
+	i := int(p * float64(len(sorted)-1))
+	return sorted[min(max(i, 0), len(sorted)-1)], true
+}
+
+// hedgeable reports whether req may be sent more than once at a time.
+func hedgeable(req *http.Request) bool {
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		return false
+	}
+	return req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+}
+
+// hedgeResult is the outcome of one hedged attempt.
+type hedgeResult struct {
+	resp    *http.Response
+	err     error
+	index   int
+	latency time.Duration
+}
+
+// hedgedRoundTrip sends req and, each time the host's latency threshold
+// passes without a response, another copy of it, up to MaxHedges. The
+// first response wins and the other attempts are canceled. Hedges are
+// drawn from the host's retry budget, if any.
+func (t *Transport) hedgedRoundTrip(base http.RoundTripper, req *http.Request, h *hostState, cfg HedgeConfig) (*http.Response, error) {
+	delay := cfg.MinDelay
+	if p, ok := h.latencies.percentile(cfg.Percentile); ok && p > delay {
+		delay = p
+	}
+
+	results := make(chan hedgeResult, cfg.MaxHedges+1)
+	var cancels []context.CancelFunc
+
+	launch := func(i int) error {
+		ctx, cancel := context.WithCancel(req.Context())
+		r := req.Clone(ctx)
+		if i > 0 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				cancel()
+				return err
+			}
+			r.Body = body
+		}
+		cancels = append(cancels, cancel)
+
+		start := time.Now()
+		go func() {
+			resp, err := base.RoundTrip(r)
+			results <- hedgeResult{resp: resp, err: err, index: i, latency: time.Since(start)}
+		}()
+		return nil
+	}
+
+	if err := launch(0); err != nil {
+		return nil, err
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	host := req.URL.Host
+	pending := 1
+	var lastErr error
+	for pending > 0 {
+		select {
+		case <-timer.C:
+			if len(cancels) > cfg.MaxHedges {
+				continue
+			}
+			if h.budget != nil && !h.budget.AllowRetry() {
+				continue
+			}
+			if err := launch(len(cancels)); err != nil {
+				continue
+			}
+			pending++
+			timer.Reset(delay)
+
+		case res := <-results:
+			pending--
+			if res.err != nil {
+				cancels[res.index]()
+				lastErr = res.err
+				continue
+			}
+
+			h.latencies.record(res.latency)
+			for i, cancel := range cancels {
+				if i != res.index {
+					cancel()
+				}
+			}
+			if pending > 0 {
+				go discardResults(results, pending)
+			}
+
+			if hedges := len(cancels) - 1; hedges > 0 {
+				won := 0
+				if res.index > 0 {
+					won = 1
+				}
+				hedgesTotal.WithLabelValues(host, "won").Add(float64(won))
+				hedgesTotal.WithLabelValues(host, "lost").Add(float64(hedges - won))
+			}
+
+			// Release the winner's context once its body is closed
+			res.resp.Body = &cancelBody{ReadCloser: res.resp.Body, cancel: cancels[res.index]}
+			return res.resp, nil
+		}
+	}
+
+	if hedges := len(cancels) - 1; hedges > 0 {
+		hedgesTotal.WithLabelValues(host, "lost").Add(float64(hedges))
+	}
+	return nil, lastErr
+}
+
+// discardResults closes the responses of attempts that lost.
+func discardResults(results <-chan hedgeResult, n int) {
+	for range n {
+		res := <-results
+		if res.resp != nil && res.resp.Body != nil {
+			res.resp.Body.Close()
+		}
+	}
+}
+
+// cancelBody cancels a request's context when its body is closed.
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
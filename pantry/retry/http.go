@@ -6,6 +6,8 @@ import (
 	"context"
 	"io"
 	"net/http"
+	"strconv"
+	"sync"
 	"time"
 )
 
@@ -28,6 +30,16 @@ type HTTPConfig struct {
 	// MaxRetryAfter caps the Retry-After delay.
 	// Default: 5 minutes.
 	MaxRetryAfter time.Duration
+
+	// Budget limits each host's retries to a share of its recent
+	// requests, shared by all requests through the Transport.
+	// Default: nil (no budget).
+	Budget *BudgetConfig
+
+	// Hedge sends extra attempts of GET and HEAD requests that are slower
+	// than the host's usual latency; the first response wins.
+	// Default: nil (no hedging).
+	Hedge *HedgeConfig
 }
 
 // DefaultHTTPConfig returns sensible HTTP retry defaults.
@@ -48,6 +60,37 @@ type Transport struct {
 
 	// Config is the retry configuration.
 	Config HTTPConfig
+
+	mu    sync.Mutex
+	hosts map[string]*hostState
+}
+
+// hostState is the retry budget and latency history of one host.
+type hostState struct {
+	budget    *Budget
+	latencies *latencies
+}
+
+// host returns the state for a host, creating it on first use.
+func (t *Transport) host(name string, cfg HTTPConfig) *hostState {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.hosts == nil {
+		t.hosts = make(map[string]*hostState)
+	}
+	h, ok := t.hosts[name]
+	if !ok {
+		h = &hostState{}
+		if cfg.Budget != nil {
+			h.budget = NewBudget(*cfg.Budget)
+		}
+		if cfg.Hedge != nil {
+			h.latencies = newLatencies(cfg.Hedge.Samples)
+		}
+		t.hosts[name] = h
+	}
+	return h
 }
 
 // RoundTrip implements http.RoundTripper with retries.
@@ -62,6 +105,23 @@ func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
 	if cfg.MaxRetryAfter <= 0 {
 		cfg.MaxRetryAfter = 5 * time.Minute
 	}
+	if cfg.Hedge != nil {
+		hedge := *cfg.Hedge
+		if hedge.Percentile <= 0 || hedge.Percentile > 1 {
+			hedge.Percentile = 0.95
+		}
+		if hedge.MinDelay <= 0 {
+			hedge.MinDelay = 50 * time.Millisecond
+		}
+		if hedge.MaxHedges <= 0 {
+			hedge.MaxHedges = 1
+		}
+		if hedge.Samples <= 0 {
+			hedge.Samples = 100
+		}
+		cfg.Hedge = &hedge
+	}
+	registerMetrics()
 
 	base := t.Base
 	if base == nil {
@@ -83,6 +143,15 @@ func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
 		}
 	}
 
+	var h *hostState
+	if cfg.Budget != nil || cfg.Hedge != nil {
+		h = t.host(req.URL.Host, cfg)
+	}
+	if h != nil && h.budget != nil {
+		h.budget.RecordRequest()
+	}
+	hedge := cfg.Hedge != nil && hedgeable(req)
+
 	delay := cfg.InitialDelay
 	var lastResp *http.Response
 	var lastErr error
@@ -106,7 +175,13 @@ func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
 		}
 
 		// Make request
-		resp, err := base.RoundTrip(req)
+		var resp *http.Response
+		var err error
+		if hedge {
+			resp, err = t.hedgedRoundTrip(base, req, h, *cfg.Hedge)
+		} else {
+			resp, err = base.RoundTrip(req)
+		}
 
 		// Success
 		if err == nil && !t.shouldRetryStatus(resp.StatusCode, cfg.RetryStatusCodes) {
@@ -150,6 +225,23 @@ func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
 			}
 		}
 
+		// Don't wait past the request's deadline, or retry beyond the
+		// host's budget. The deadline is checked first so a retry that
+		// won't happen doesn't spend budget.
+		deadline, hasDeadline := req.Context().Deadline()
+		stop := hasDeadline && time.Until(deadline) < actualDelay
+		if !stop && h != nil && h.budget != nil && !h.budget.AllowRetry() {
+			retriesTotal.WithLabelValues(req.URL.Host, "budget_exhausted").Inc()
+			stop = true
+		}
+		if stop {
+			if resp != nil {
+				return resp, nil
+			}
+			return nil, lastErr
+		}
+		retriesTotal.WithLabelValues(req.URL.Host, "retried").Inc()
+
 		// Close response body before retry
 		if resp != nil && resp.Body != nil {
 			io.Copy(io.Discard, resp.Body)
@@ -194,28 +286,21 @@ func (t *Transport) shouldRetryStatus(status int, codes []int) bool {
 	return false
 }
 
-// parseRetryAfter parses the Retry-After header value.
+// parseRetryAfter parses the Retry-After header value, in seconds or as
+// an HTTP date.
 func parseRetryAfter(value string) time.Duration {
 	if value == "" {
 		return 0
 	}
 
-	// Try parsing as seconds
-	var seconds int
-	if _, err := time.Parse(time.RFC1123, value); err != nil {
-		// Not a date, try as seconds
-		for _, c := range value {
-			if c >= '0' && c <= '9' {
-				seconds = seconds*10 + int(c-'0')
-			} else {
-				return 0
-			}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0
 		}
 		return time.Duration(seconds) * time.Second
 	}
 
-	// Parse as HTTP date
-	t, err := time.Parse(time.RFC1123, value)
+	t, err := http.ParseTime(value)
 	if err != nil {
 		return 0
 	}
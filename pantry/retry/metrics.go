@@ -0,0 +1,45 @@
+// retry/metrics.go
+package retry
+
+import (
+	"sync"
+
+	"github.com/dalemusser/waffle/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	retriesTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "retry_http_retries_total",
+		Help: "HTTP retries by host and outcome (retried, budget_exhausted).",
+	}, []string{"host", "outcome"})
+	hedgesTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "retry_http_hedges_total",
+		Help: "Hedged HTTP attempts by host and outcome (won, lost).",
+	}, []string{"host", "outcome"})
+
+	circuitStateGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "retry_circuit_state",
+		Help: "Registered circuit state (0 closed, 1 open, 2 half-open).",
+	}, []string{"circuit"})
+	circuitTransitionsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "retry_circuit_transitions_total",
+		Help: "Registered circuit state changes by new state.",
+	}, []string{"circuit", "state"})
+	circuitRejectedTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "retry_circuit_rejected_total",
+		Help: "Requests rejected by registered circuits.",
+	}, []string{"circuit"})
+)
+
+var registerOnce sync.Once
+
+func registerMetrics() {
+	registerOnce.Do(func() {
+		metrics.MustRegister(nil, "retry retries counter", retriesTotal)
+		metrics.MustRegister(nil, "retry hedges counter", hedgesTotal)
+		metrics.MustRegister(nil, "retry circuit state gauge", circuitStateGauge)
+		metrics.MustRegister(nil, "retry circuit transitions counter", circuitTransitionsTotal)
+		metrics.MustRegister(nil, "retry circuit rejected counter", circuitRejectedTotal)
+	})
+}
@@ -0,0 +1,404 @@
+// retry/registry.go
+package retry
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+// ErrCircuitNotFound is returned when a registry has no circuit by a name.
+var ErrCircuitNotFound = errors.New("retry: circuit not found")
+
+// RegistryConfig configures a circuit registry.
+type RegistryConfig struct {
+	// Defaults configures circuits created by Get. Zero fields take
+	// NewCircuit's defaults.
+	Defaults CircuitConfig
+
+	// OnStateChange is called when any circuit changes state, including
+	// changes received from other instances.
+	OnStateChange func(name string, from, to CircuitState)
+}
+
+// Registry holds named circuits, typically one per dependency, so they
+// can be listed, tripped and reset by name, reported in metrics, and
+// optionally shared between instances through Redis.
+type Registry struct {
+	mu            sync.RWMutex
+	circuits      map[string]*Circuit
+	defaults      CircuitConfig
+	onStateChange func(name string, from, to CircuitState)
+
+	sync   *redisSync
+	remote map[string]circuitMessage // synced states of unregistered circuits
+}
+
+// NewRegistry creates a circuit registry.
+func NewRegistry(cfg RegistryConfig) *Registry {
+	registerMetrics()
+
+	return &Registry{
+		circuits:      make(map[string]*Circuit),
+		defaults:      cfg.Defaults,
+		onStateChange: cfg.OnStateChange,
+		remote:        make(map[string]circuitMessage),
+	}
+}
+
+// Get returns the named circuit, creating it with the registry's default
+// config on first use.
+func (r *Registry) Get(name string) *Circuit {
+	r.mu.RLock()
+	c, ok := r.circuits[name]
+	r.mu.RUnlock()
+	if ok {
+		return c
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if c, ok := r.circuits[name]; ok {
+		return c
+	}
+	return r.add(name, r.defaults)
+}
+
+// Register creates the named circuit with its own config, replacing any
+// circuit already registered by that name. Call it at startup, before
+// the circuit is used.
+func (r *Registry) Register(name string, cfg CircuitConfig) *Circuit {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if old, ok := r.circuits[name]; ok {
+		old.mu.Lock()
+		old.onChange = nil
+		old.mu.Unlock()
+	}
+	return r.add(name, cfg)
+}
+
+// add creates and registers a circuit. The caller must hold r.mu.
+func (r *Registry) add(name string, cfg CircuitConfig) *Circuit {
+	c := NewCircuit(cfg)
+	c.name = name
+	c.onChange = r.changed
+	r.circuits[name] = c
+	circuitStateGauge.WithLabelValues(name).Set(float64(CircuitClosed))
+
+	if msg, ok := r.remote[name]; ok {
+		delete(r.remote, name)
+		go c.apply(msg.circuitState(), time.Unix(0, msg.At))
+	}
+	return c
+}
+
+// Lookup returns the named circuit if it is registered.
+func (r *Registry) Lookup(name string) (*Circuit, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	c, ok := r.circuits[name]
+	return c, ok
+}
+
+// All returns the stats of every circuit, sorted by name.
+func (r *Registry) All() []CircuitStats {
+	r.mu.RLock()
+	circuits := make([]*Circuit, 0, len(r.circuits))
+	for _, c := range r.circuits {
+		circuits = append(circuits, c)
+	}
+	r.mu.RUnlock()
+
+	out := make([]CircuitStats, 0, len(circuits))
+	for _, c := range circuits {
+		out = append(out, c.Stats())
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// Trip opens the named circuit.
+func (r *Registry) Trip(name string) error {
+	c, ok := r.Lookup(name)
+	if !ok {
+		return ErrCircuitNotFound
+	}
+	c.Trip()
+	return nil
+}
+
+// Reset closes the named circuit.
+func (r *Registry) Reset(name string) error {
+	c, ok := r.Lookup(name)
+	if !ok {
+		return ErrCircuitNotFound
+	}
+	c.Reset()
+	return nil
+}
+
+// changed reports a circuit's state change to metrics, the callback and,
+// for local changes, other instances.
+func (r *Registry) changed(c *Circuit, from, to CircuitState, at time.Time, remote bool) {
+	circuitStateGauge.WithLabelValues(c.name).Set(float64(c.State()))
+	circuitTransitionsTotal.WithLabelValues(c.name, to.String()).Inc()
+
+	if r.onStateChange != nil {
+		r.onStateChange(c.name, from, to)
+	}
+
+	// Half-open is local: each instance probes on its own
+	if remote || to == CircuitHalfOpen {
+		return
+	}
+	r.mu.RLock()
+	s := r.sync
+	r.mu.RUnlock()
+	if s != nil {
+		s.publish(c.name, to, at)
+	}
+}
+
+// RedisSyncConfig configures sharing circuit state through Redis.
+type RedisSyncConfig struct {
+	// Client is the Redis client. Required.
+	Client redis.UniversalClient
+
+	// Channel is the pub/sub channel for state changes.
+	// Default: "retry:circuits".
+	Channel string
+
+	// Key is the hash of open circuits, read when syncing starts so new
+	// instances open with the rest.
+	// Default: "retry:circuits:open".
+	Key string
+
+	// Logger logs sync failures.
+	// Default: no logging.
+	Logger *zap.Logger
+}
+
+// circuitMessage is a state change shared through Redis.
+type circuitMessage struct {
+	Name     string `json:"name"`
+	State    string `json:"state"`
+	At       int64  `json:"at"` // unix nanoseconds
+	Instance string `json:"instance"`
+}
+
+func (m circuitMessage) circuitState() CircuitState {
+	if m.State == CircuitOpen.String() {
+		return CircuitOpen
+	}
+	return CircuitClosed
+}
+
+// redisSync publishes and receives circuit state changes.
+type redisSync struct {
+	client   redis.UniversalClient
+	channel  string
+	key      string
+	instance string
+	logger   *zap.Logger
+	pubsub   *redis.PubSub
+}
+
+// SyncRedis shares circuit state with other instances through Redis:
+// when a circuit opens or closes here, it opens or closes everywhere.
+// Circuits open elsewhere are opened as syncing starts. Half-open probing
+// stays local to each instance. Instance clocks should be synchronized,
+// since changes older than a circuit's last change are ignored.
+func (r *Registry) SyncRedis(ctx context.Context, cfg RedisSyncConfig) error {
+	if cfg.Channel == "" {
+		cfg.Channel = "retry:circuits"
+	}
+	if cfg.Key == "" {
+		cfg.Key = "retry:circuits:open"
+	}
+	if cfg.Logger == nil {
+		cfg.Logger = zap.NewNop()
+	}
+
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return err
+	}
+	s := &redisSync{
+		client:   cfg.Client,
+		channel:  cfg.Channel,
+		key:      cfg.Key,
+		instance: hex.EncodeToString(id),
+		logger:   cfg.Logger,
+	}
+
+	s.pubsub = cfg.Client.Subscribe(ctx, cfg.Channel)
+	if _, err := s.pubsub.Receive(ctx); err != nil {
+		s.pubsub.Close()
+		return fmt.Errorf("retry: failed to subscribe to circuit channel: %w", err)
+	}
+
+	open, err := cfg.Client.HGetAll(ctx, cfg.Key).Result()
+	if err != nil {
+		s.pubsub.Close()
+		return fmt.Errorf("retry: failed to load open circuits: %w", err)
+	}
+
+	r.mu.Lock()
+	r.sync = s
+	r.mu.Unlock()
+
+	for _, raw := range open {
+		r.receive(s, raw)
+	}
+	go r.listen(s)
+	return nil
+}
+
+// listen applies state changes published by other instances.
+func (r *Registry) listen(s *redisSync) {
+	for msg := range s.pubsub.Channel() {
+		r.receive(s, msg.Payload)
+	}
+}
+
+// receive applies a state change from another instance, or keeps it
+// until the circuit is registered.
+func (r *Registry) receive(s *redisSync, raw string) {
+	var msg circuitMessage
+	if err := json.Unmarshal([]byte(raw), &msg); err != nil {
+		s.logger.Warn("invalid circuit message", zap.Error(err))
+		return
+	}
+	if msg.Instance == s.instance {
+		return
+	}
+
+	r.mu.Lock()
+	c, ok := r.circuits[msg.Name]
+	if !ok {
+		if prev, seen := r.remote[msg.Name]; !seen || msg.At > prev.At {
+			r.remote[msg.Name] = msg
+		}
+	}
+	r.mu.Unlock()
+
+	if ok {
+		c.apply(msg.circuitState(), time.Unix(0, msg.At))
+	}
+}
+
+// publish shares a local state change and records open circuits.
+func (s *redisSync) publish(name string, state CircuitState, at time.Time) {
+	data, err := json.Marshal(circuitMessage{
+		Name:     name,
+		State:    state.String(),
+		At:       at.UnixNano(),
+		Instance: s.instance,
+	})
+	if err != nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	pipe := s.client.Pipeline()
+	if state == CircuitOpen {
+		pipe.HSet(ctx, s.key, name, data)
+	} else {
+		pipe.HDel(ctx, s.key, name)
+	}
+	pipe.Publish(ctx, s.channel, data)
+	if _, err := pipe.Exec(ctx); err != nil {
+		s.logger.Warn("failed to publish circuit state",
+			zap.String("circuit", name),
+			zap.String("state", state.String()),
+			zap.Error(err),
+		)
+	}
+}
+
+// Close stops syncing through Redis. Circuits keep working locally.
+func (r *Registry) Close() error {
+	r.mu.Lock()
+	s := r.sync
+	r.sync = nil
+	r.mu.Unlock()
+
+	if s == nil {
+		return nil
+	}
+	return s.pubsub.Close()
+}
+
+// AdminHandler exposes a registry's circuits as JSON and lets operators
+// trip and reset them.
+//
+//	GET  /              → all circuits
+//	GET  /{name}        → a single circuit
+//	POST /{name}/trip   → open the circuit
+//	POST /{name}/reset  → close the circuit
+type AdminHandler struct {
+	registry *Registry
+}
+
+// NewAdminHandler creates a new admin handler.
+func NewAdminHandler(registry *Registry) *AdminHandler {
+	return &AdminHandler{registry: registry}
+}
+
+// ServeHTTP handles admin requests.
+func (h *AdminHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := strings.Trim(r.URL.Path, "/")
+
+	switch r.Method {
+	case http.MethodGet:
+		w.Header().Set("Content-Type", "application/json")
+		if path == "" {
+			json.NewEncoder(w).Encode(h.registry.All())
+			return
+		}
+		c, ok := h.registry.Lookup(path)
+		if !ok {
+			http.Error(w, "Circuit not found", http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(c.Stats())
+
+	case http.MethodPost:
+		var name string
+		var action func(string) error
+		if n, ok := strings.CutSuffix(path, "/trip"); ok {
+			name, action = n, h.registry.Trip
+		} else if n, ok := strings.CutSuffix(path, "/reset"); ok {
+			name, action = n, h.registry.Reset
+		} else {
+			http.Error(w, "Unknown action", http.StatusNotFound)
+			return
+		}
+
+		if err := action(name); err != nil {
+			http.Error(w, "Circuit not found", http.StatusNotFound)
+			return
+		}
+		c, _ := h.registry.Lookup(name)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(c.Stats())
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,245 @@
+// session/cookie.go
+package session
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dalemusser/waffle/pantry/crypto"
+)
+
+// ErrTooLarge is returned when session data doesn't fit in the cookies
+// a CookieStore may use.
+var ErrTooLarge = errors.New("session: data too large for cookies")
+
+// ClientStore is implemented by stores that keep session data in the
+// client's cookies instead of on the server. The Manager writes the
+// encoded data to the cookie in place of the session ID, splitting it
+// across several cookies when it is long.
+type ClientStore interface {
+	Store
+
+	// Encode serializes and seals session data for a cookie.
+	Encode(data *SessionData) (string, error)
+
+	// Decode opens a value produced by Encode.
+	// Returns ErrInvalidSession if it can't be decrypted.
+	Decode(value string) (*SessionData, error)
+
+	// MaxChunks is the most cookies the encoded data may span, and
+	// ChunkSize the longest value per cookie.
+	MaxChunks() int
+	ChunkSize() int
+}
+
+// CookieStore keeps session data in cookies, encrypted with AES-GCM, so no
+// server-side storage is needed.
+//
+// Keys form a key ring: the first key encrypts, and every key is tried when
+// decrypting. To rotate, put the new key first and keep the old one until
+// sessions sealed with it have expired.
+//
+// Sessions can't be revoked on the server: Delete and Regenerate replace
+// the cookie, but a copy of the old cookie stays valid until it expires.
+// Keep MaxAge short if that matters.
+type CookieStore struct {
+	keys      []*crypto.Encryptor
+	maxChunks int
+	chunkSize int
+}
+
+// CookieStoreConfig configures the cookie store.
+type CookieStoreConfig struct {
+	// Keys is the key ring. The first key encrypts; all are tried to
+	// decrypt. At least one key is required.
+	Keys []*crypto.Encryptor
+
+	// MaxChunks is the most cookies session data may span. Save fails
+	// with ErrTooLarge beyond it.
+	// Default: 5.
+	MaxChunks int
+
+	// ChunkSize is the longest cookie value. Browsers limit a cookie,
+	// including its name and attributes, to about 4096 bytes.
+	// Default: 3800.
+	ChunkSize int
+}
+
+// NewCookieStore creates a cookie store with the given key ring.
+func NewCookieStore(keys ...*crypto.Encryptor) (*CookieStore, error) {
+	return NewCookieStoreWithConfig(CookieStoreConfig{Keys: keys})
+}
+
+// NewCookieStoreWithConfig creates a cookie store with custom configuration.
+func NewCookieStoreWithConfig(cfg CookieStoreConfig) (*CookieStore, error) {
+	if len(cfg.Keys) == 0 {
+		return nil, errors.New("session: cookie store requires at least one key")
+	}
+	if cfg.MaxChunks <= 0 {
+		cfg.MaxChunks = 5
+	}
+	if cfg.ChunkSize <= 0 {
+		cfg.ChunkSize = 3800
+	}
+
+	return &CookieStore{
+		keys:      cfg.Keys,
+		maxChunks: cfg.MaxChunks,
+		chunkSize: cfg.ChunkSize,
+	}, nil
+}
+
+// Encode serializes and encrypts session data with the first key.
+func (s *CookieStore) Encode(data *SessionData) (string, error) {
+	plaintext, err := json.Marshal(data)
+	if err != nil {
+		return "", err
+	}
+	ciphertext, err := s.keys[0].Encrypt(plaintext)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(ciphertext), nil
+}
+
+// Decode decrypts session data with the first key that opens it.
+// Returns ErrExpired if the session has expired.
+func (s *CookieStore) Decode(value string) (*SessionData, error) {
+	ciphertext, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, ErrInvalidSession
+	}
+
+	for _, key := range s.keys {
+		plaintext, err := key.Decrypt(ciphertext)
+		if err != nil {
+			continue
+		}
+
+		var data SessionData
+		if err := json.Unmarshal(plaintext, &data); err != nil {
+			return nil, ErrInvalidSession
+		}
+		if time.Now().After(data.ExpiresAt) {
+			return nil, ErrExpired
+		}
+		return &data, nil
+	}
+
+	return nil, ErrInvalidSession
+}
+
+// MaxChunks returns the most cookies session data may span.
+func (s *CookieStore) MaxChunks() int {
+	return s.maxChunks
+}
+
+// ChunkSize returns the longest cookie value.
+func (s *CookieStore) ChunkSize() int {
+	return s.chunkSize
+}
+
+// Load always returns ErrNotFound: the Manager reads sessions from the
+// cookie with Decode.
+func (s *CookieStore) Load(ctx context.Context, id string) (*SessionData, error) {
+	return nil, ErrNotFound
+}
+
+// Save does nothing: the Manager writes the cookie with Encode.
+func (s *CookieStore) Save(ctx context.Context, data *SessionData) error {
+	return nil
+}
+
+// Delete does nothing: the Manager clears the cookie.
+func (s *CookieStore) Delete(ctx context.Context, id string) error {
+	return nil
+}
+
+// Close does nothing.
+func (s *CookieStore) Close() error {
+	return nil
+}
+
+// Chunked cookies: a value longer than ChunkSize is split across the
+// session cookie and cookies named <name>_1, <name>_2, ... The session
+// cookie's value is then prefixed with the number of chunks and a dot,
+// which can't occur in unchunked (base64url) values.
+
+// chunkName returns the name of the i-th cookie of a chunked value.
+func chunkName(name string, i int) string {
+	if i == 0 {
+		return name
+	}
+	return name + "_" + strconv.Itoa(i)
+}
+
+// readClientCookie reassembles a possibly chunked cookie value. n is the
+// number of cookies it spans (0 if absent).
+func readClientCookie(r *http.Request, name string, maxChunks int) (value string, n int) {
+	c, err := r.Cookie(name)
+	if err != nil || c.Value == "" {
+		return "", 0
+	}
+
+	count, first, chunked := strings.Cut(c.Value, ".")
+	if !chunked {
+		return c.Value, 1
+	}
+	n, err = strconv.Atoi(count)
+	if err != nil || n < 2 || n > maxChunks {
+		return "", 1
+	}
+
+	var b strings.Builder
+	b.WriteString(first)
+	for i := 1; i < n; i++ {
+		c, err := r.Cookie(chunkName(name, i))
+		if err != nil {
+			return "", n
+		}
+		b.WriteString(c.Value)
+	}
+	return b.String(), n
+}
+
+// writeClientCookie writes value across as many cookies as needed and
+// expires chunks left over from a longer previous value.
+func (m *Manager) writeClientCookie(w http.ResponseWriter, r *http.Request, cs ClientStore, value string) error {
+	size := cs.ChunkSize()
+	var chunks []string
+	for len(value) > size {
+		chunks = append(chunks, value[:size])
+		value = value[size:]
+	}
+	chunks = append(chunks, value)
+	if len(chunks) > cs.MaxChunks() {
+		return ErrTooLarge
+	}
+	if len(chunks) > 1 {
+		chunks[0] = strconv.Itoa(len(chunks)) + "." + chunks[0]
+	}
+
+	for i, chunk := range chunks {
+		http.SetCookie(w, m.cookie(chunkName(m.cookieName, i), chunk, int(m.config.MaxAge.Seconds())))
+	}
+
+	_, previous := readClientCookie(r, m.cookieName, cs.MaxChunks())
+	for i := len(chunks); i < previous; i++ {
+		http.SetCookie(w, m.cookie(chunkName(m.cookieName, i), "", -1))
+	}
+	return nil
+}
+
+// clearClientCookie expires every chunk of the request's session cookie.
+func (m *Manager) clearClientCookie(w http.ResponseWriter, r *http.Request, cs ClientStore) {
+	_, n := readClientCookie(r, m.cookieName, cs.MaxChunks())
+	for i := 1; i < n; i++ {
+		http.SetCookie(w, m.cookie(chunkName(m.cookieName, i), "", -1))
+	}
+}
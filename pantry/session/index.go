@@ -0,0 +1,210 @@
+// session/index.go
+package session
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"sort"
+	"time"
+
+	geoip "github.com/dalemusser/waffle/pantry/geo/ip"
+)
+
+// ErrNoUserIndex is returned by the Manager's session listing and
+// revocation methods when the store doesn't implement UserIndex.
+var ErrNoUserIndex = errors.New("session: store does not index sessions by user")
+
+// Device describes the client a session was last used from.
+type Device struct {
+	UserAgent string `json:"user_agent,omitempty"`
+	IP        string `json:"ip,omitempty"`
+
+	// Location is set when the geo/ip middleware runs before the session
+	// is saved.
+	Location *geoip.Location `json:"location,omitempty"`
+}
+
+// deviceFromRequest records the client of a request.
+func deviceFromRequest(r *http.Request) *Device {
+	return &Device{
+		UserAgent: r.UserAgent(),
+		IP:        geoip.GetClientIP(r),
+		Location:  geoip.FromContext(r.Context()),
+	}
+}
+
+// UserIndex is implemented by stores that index sessions by user ID
+// (SessionData.UserID), so a user's sessions can be listed and revoked.
+type UserIndex interface {
+	// ListByUser returns the user's unexpired sessions.
+	ListByUser(ctx context.Context, userID string) ([]*SessionData, error)
+
+	// DeleteByUser removes the user's sessions, except those whose IDs are
+	// in keep. It returns the number of sessions removed.
+	DeleteByUser(ctx context.Context, userID string, keep ...string) (int, error)
+}
+
+// SessionInfo describes one of a user's sessions, for "where you're signed
+// in" pages and admin tools. It omits the session ID, which would let
+// anyone who sees it take over the session; Handle identifies the session
+// instead.
+type SessionInfo struct {
+	Handle    string    `json:"handle"`
+	UserID    string    `json:"user_id"`
+	Device    Device    `json:"device"`
+	CreatedAt time.Time `json:"created_at"`
+	LastSeen  time.Time `json:"last_seen"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// Handle returns a stable, non-secret identifier for the session, matching
+// SessionInfo.Handle. Use it to mark the current session in a list.
+func (s *Session) Handle() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return sessionHandle(s.id)
+}
+
+// sessionHandle derives a session's public identifier from its ID.
+func sessionHandle(id string) string {
+	sum := sha256.Sum256([]byte(id))
+	return hex.EncodeToString(sum[:16])
+}
+
+// Revoker revokes a user's credentials held outside the session store,
+// such as OAuth2 sessions or JWT refresh tokens. Revocation is
+// all-or-nothing: a Revoker only gets the user ID, so it revokes the
+// credentials of every device, including the current one.
+type Revoker interface {
+	RevokeUser(ctx context.Context, userID string) error
+}
+
+// RevokerFunc adapts a function to a Revoker. jwt.TokenService's
+// RevokeAllTokens and oauth2.MemorySessionStore's DeleteByUser have the
+// right signature:
+//
+//	session.RevokerFunc(tokens.RevokeAllTokens)
+type RevokerFunc func(ctx context.Context, userID string) error
+
+// RevokeUser calls f.
+func (f RevokerFunc) RevokeUser(ctx context.Context, userID string) error {
+	return f(ctx, userID)
+}
+
+// userIndex returns the store's user index.
+func (m *Manager) userIndex() (UserIndex, error) {
+	idx, ok := m.store.(UserIndex)
+	if !ok {
+		return nil, ErrNoUserIndex
+	}
+	return idx, nil
+}
+
+// ListSessions returns a user's active sessions, most recently seen first.
+func (m *Manager) ListSessions(ctx context.Context, userID string) ([]SessionInfo, error) {
+	idx, err := m.userIndex()
+	if err != nil {
+		return nil, err
+	}
+
+	sessions, err := idx.ListByUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	infos := make([]SessionInfo, 0, len(sessions))
+	for _, data := range sessions {
+		info := SessionInfo{
+			Handle:    sessionHandle(data.ID),
+			UserID:    data.UserID,
+			CreatedAt: data.CreatedAt,
+			LastSeen:  data.UpdatedAt,
+			ExpiresAt: data.ExpiresAt,
+		}
+		if data.Device != nil {
+			info.Device = *data.Device
+		}
+		infos = append(infos, info)
+	}
+
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].LastSeen.After(infos[j].LastSeen)
+	})
+	return infos, nil
+}
+
+// RevokeSession ends one of a user's sessions, identified by its handle.
+// Returns ErrNotFound if the user has no such session.
+func (m *Manager) RevokeSession(ctx context.Context, userID, handle string) error {
+	idx, err := m.userIndex()
+	if err != nil {
+		return err
+	}
+
+	sessions, err := idx.ListByUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	for _, data := range sessions {
+		if sessionHandle(data.ID) == handle {
+			return m.store.Delete(ctx, data.ID)
+		}
+	}
+	return ErrNotFound
+}
+
+// RevokeOtherSessions ends all of a user's sessions except current, and
+// revokes the user's credentials with the configured Revokers. Call it
+// after a password change to sign the user out everywhere else. A nil
+// current keeps no session. It returns the number of sessions removed.
+//
+// Only the session store keeps current: Revokers revoke all of the user's
+// credentials, so the current device loses its OAuth2 sessions and JWT
+// refresh tokens too and must be issued new ones.
+func (m *Manager) RevokeOtherSessions(ctx context.Context, userID string, current *Session) (int, error) {
+	idx, err := m.userIndex()
+	if err != nil {
+		return 0, err
+	}
+
+	var keep []string
+	if current != nil {
+		keep = append(keep, current.ID())
+	}
+	n, err := idx.DeleteByUser(ctx, userID, keep...)
+	if err != nil {
+		return n, err
+	}
+	return n, m.revoke(ctx, userID)
+}
+
+// RevokeAllSessions ends all of a user's sessions and revokes the user's
+// credentials with the configured Revokers. It returns the number of
+// sessions removed.
+func (m *Manager) RevokeAllSessions(ctx context.Context, userID string) (int, error) {
+	idx, err := m.userIndex()
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := idx.DeleteByUser(ctx, userID)
+	if err != nil {
+		return n, err
+	}
+	return n, m.revoke(ctx, userID)
+}
+
+// revoke calls every Revoker, returning the errors joined.
+func (m *Manager) revoke(ctx context.Context, userID string) error {
+	var errs []error
+	for _, r := range m.config.Revokers {
+		if err := r.RevokeUser(ctx, userID); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,70 @@
+// session/lifetime.go
+package session
+
+import (
+	"net"
+	"net/http"
+	"time"
+
+	geoip "github.com/dalemusser/waffle/pantry/geo/ip"
+)
+
+// expiry returns when a session created at createdAt expires if last
+// active at now: IdleTimeout after now, but no later than MaxAge after
+// creation. Without IdleTimeout, it is MaxAge after now.
+func (m *Manager) expiry(createdAt, now time.Time) time.Time {
+	if m.config.IdleTimeout <= 0 {
+		return now.Add(m.config.MaxAge)
+	}
+	idle := now.Add(m.config.IdleTimeout)
+	absolute := createdAt.Add(m.config.MaxAge)
+	if idle.After(absolute) {
+		return absolute
+	}
+	return idle
+}
+
+// rolling reports whether r renews the session: IdleTimeout is set and r
+// isn't passive.
+func (m *Manager) rolling(r *http.Request) bool {
+	if m.config.IdleTimeout <= 0 {
+		return false
+	}
+	return m.config.Passive == nil || !m.config.Passive(r)
+}
+
+// bound reports whether r may use the session, given the binding options.
+// Sessions saved without device metadata are accepted.
+func (m *Manager) bound(r *http.Request, data *SessionData) bool {
+	if data.Device == nil {
+		return true
+	}
+	if m.config.BindUserAgent && r.UserAgent() != data.Device.UserAgent {
+		return false
+	}
+	if m.config.BindIPv4Prefix > 0 || m.config.BindIPv6Prefix > 0 {
+		return sameSubnet(data.Device.IP, geoip.GetClientIP(r), m.config.BindIPv4Prefix, m.config.BindIPv6Prefix)
+	}
+	return true
+}
+
+// sameSubnet reports whether a and b share a subnet of the given prefix
+// length for their address family. A prefix of 0 accepts any address of
+// that family.
+func sameSubnet(a, b string, v4Prefix, v6Prefix int) bool {
+	ipA, ipB := net.ParseIP(a), net.ParseIP(b)
+	if ipA == nil || ipB == nil {
+		return false
+	}
+
+	if v4A, v4B := ipA.To4(), ipB.To4(); v4A != nil || v4B != nil {
+		if v4A == nil || v4B == nil {
+			return false
+		}
+		mask := net.CIDRMask(v4Prefix, 32)
+		return v4A.Mask(mask).Equal(v4B.Mask(mask))
+	}
+
+	mask := net.CIDRMask(v6Prefix, 128)
+	return ipA.Mask(mask).Equal(ipB.Mask(mask))
+}
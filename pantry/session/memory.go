@@ -3,6 +3,7 @@ package session
 
 import (
 	"context"
+	"slices"
 	"sync"
 	"time"
 )
@@ -11,6 +12,7 @@ import (
 type MemoryStore struct {
 	mu       sync.RWMutex
 	sessions map[string]*SessionData
+	users    map[string]map[string]struct{} // user ID → session IDs
 	stopCh   chan struct{}
 	cleanCh  chan struct{}
 }
@@ -37,6 +39,7 @@ func NewMemoryStoreWithConfig(cfg MemoryStoreConfig) *MemoryStore {
 
 	s := &MemoryStore{
 		sessions: make(map[string]*SessionData),
+		users:    make(map[string]map[string]struct{}),
 		stopCh:   make(chan struct{}),
 		cleanCh:  make(chan struct{}),
 	}
@@ -69,8 +72,13 @@ func (s *MemoryStore) Save(ctx context.Context, data *SessionData) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	if prev, ok := s.sessions[data.ID]; ok && prev.UserID != data.UserID {
+		s.unindex(prev)
+	}
+
 	// Store a copy
 	s.sessions[data.ID] = copySessionData(data)
+	s.index(data)
 	return nil
 }
 
@@ -79,10 +87,47 @@ func (s *MemoryStore) Delete(ctx context.Context, id string) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	delete(s.sessions, id)
+	if data, ok := s.sessions[id]; ok {
+		s.unindex(data)
+		delete(s.sessions, id)
+	}
 	return nil
 }
 
+// ListByUser returns the user's unexpired sessions.
+func (s *MemoryStore) ListByUser(ctx context.Context, userID string) ([]*SessionData, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	now := time.Now()
+	var result []*SessionData
+	for id := range s.users[userID] {
+		data := s.sessions[id]
+		if now.After(data.ExpiresAt) {
+			continue
+		}
+		result = append(result, copySessionData(data))
+	}
+	return result, nil
+}
+
+// DeleteByUser removes the user's sessions, except those in keep.
+func (s *MemoryStore) DeleteByUser(ctx context.Context, userID string, keep ...string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n := 0
+	for id := range s.users[userID] {
+		if slices.Contains(keep, id) {
+			continue
+		}
+		s.unindex(s.sessions[id])
+		delete(s.sessions, id)
+		n++
+	}
+	return n, nil
+}
+
 // Close stops the cleanup goroutine.
 func (s *MemoryStore) Close() error {
 	close(s.stopCh)
@@ -122,11 +167,37 @@ func (s *MemoryStore) removeExpired() {
 	now := time.Now()
 	for id, data := range s.sessions {
 		if now.After(data.ExpiresAt) {
+			s.unindex(data)
 			delete(s.sessions, id)
 		}
 	}
 }
 
+// index adds a session to its user's set. Callers hold s.mu.
+func (s *MemoryStore) index(data *SessionData) {
+	if data.UserID == "" {
+		return
+	}
+	ids, ok := s.users[data.UserID]
+	if !ok {
+		ids = make(map[string]struct{})
+		s.users[data.UserID] = ids
+	}
+	ids[data.ID] = struct{}{}
+}
+
+// unindex removes a session from its user's set. Callers hold s.mu.
+func (s *MemoryStore) unindex(data *SessionData) {
+	ids, ok := s.users[data.UserID]
+	if !ok {
+		return
+	}
+	delete(ids, data.ID)
+	if len(ids) == 0 {
+		delete(s.users, data.UserID)
+	}
+}
+
 // copySessionData creates a deep copy of session data.
 func copySessionData(data *SessionData) *SessionData {
 	dataCopy := make(map[string]any, len(data.Data))
@@ -140,5 +211,7 @@ func copySessionData(data *SessionData) *SessionData {
 		ExpiresAt: data.ExpiresAt,
 		CreatedAt: data.CreatedAt,
 		UpdatedAt: data.UpdatedAt,
+		UserID:    data.UserID,
+		Device:    data.Device,
 	}
 }
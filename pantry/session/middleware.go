@@ -3,7 +3,9 @@ package session
 
 import (
 	"context"
+	"encoding/json"
 	"net/http"
+	"time"
 )
 
 // contextKey is the type for context keys.
@@ -14,6 +16,29 @@ const sessionContextKey contextKey = "session"
 // Middleware returns HTTP middleware that loads sessions automatically.
 // The session is available via FromContext(r.Context()).
 func Middleware(m *Manager) func(http.Handler) http.Handler {
+	return MiddlewareWithConfig(m, MiddlewareConfig{})
+}
+
+// MiddlewareConfig configures session middleware.
+type MiddlewareConfig struct {
+	// WarnBefore is how long before a session expires OnExpiring is
+	// called, so the page can warn the user.
+	// Default: 0 (no warning).
+	WarnBefore time.Duration
+
+	// OnExpiring is called before the handler runs when the session
+	// expires within WarnBefore. remaining is the time left.
+	// Default: HTMXExpiring("session-expiring").
+	OnExpiring func(w http.ResponseWriter, r *http.Request, s *Session, remaining time.Duration)
+}
+
+// MiddlewareWithConfig returns session middleware with custom
+// configuration.
+func MiddlewareWithConfig(m *Manager, cfg MiddlewareConfig) func(http.Handler) http.Handler {
+	if cfg.WarnBefore > 0 && cfg.OnExpiring == nil {
+		cfg.OnExpiring = HTMXExpiring("session-expiring")
+	}
+
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			session, err := m.Get(r)
@@ -22,6 +47,12 @@ func Middleware(m *Manager) func(http.Handler) http.Handler {
 				session, _ = m.New()
 			}
 
+			if cfg.WarnBefore > 0 && !session.IsNew() {
+				if remaining := time.Until(session.ExpiresAt()); remaining <= cfg.WarnBefore {
+					cfg.OnExpiring(w, r, session, remaining)
+				}
+			}
+
 			// Add session to context
 			ctx := context.WithValue(r.Context(), sessionContextKey, session)
 			r = r.WithContext(ctx)
@@ -45,6 +76,32 @@ func Middleware(m *Manager) func(http.Handler) http.Handler {
 	}
 }
 
+// HTMXExpiring returns an OnExpiring hook that triggers a client-side
+// event on HTMX requests, through the HX-Trigger response header. The
+// event detail carries the expiry time and the seconds remaining:
+//
+//	{"session-expiring": {"expiresAt": "2025-01-02T15:04:05Z", "remaining": 120}}
+//
+// Listen for it with hx-on or addEventListener to show a warning. Handlers
+// that set HX-Trigger themselves replace the event.
+func HTMXExpiring(event string) func(w http.ResponseWriter, r *http.Request, s *Session, remaining time.Duration) {
+	return func(w http.ResponseWriter, r *http.Request, s *Session, remaining time.Duration) {
+		if r.Header.Get("HX-Request") != "true" {
+			return
+		}
+		trigger, err := json.Marshal(map[string]any{
+			event: map[string]any{
+				"expiresAt": s.ExpiresAt().UTC().Format(time.RFC3339),
+				"remaining": int(remaining.Seconds()),
+			},
+		})
+		if err != nil {
+			return
+		}
+		w.Header().Set("HX-Trigger", string(trigger))
+	}
+}
+
 // FromContext retrieves the session from the request context.
 // Returns nil if no session is in context (middleware not used).
 func FromContext(ctx context.Context) *Session {
@@ -0,0 +1,174 @@
+// session/mongo.go
+package session
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MongoStore implements MongoDB-backed session storage.
+//
+// Session data is stored as JSON in the document, so values read back
+// have the same types as with RedisStore. Expired sessions are removed by
+// a TTL index on expires_at; call EnsureSchema once at startup to create
+// it. MongoDB's TTL monitor runs about once a minute, so Load also checks
+// expiry itself.
+type MongoStore struct {
+	coll *mongo.Collection
+}
+
+// MongoStoreConfig configures the MongoDB store.
+type MongoStoreConfig struct {
+	// Database is the database holding the sessions collection. Required.
+	Database *mongo.Database
+
+	// Collection is the sessions collection name.
+	// Default: "sessions".
+	Collection string
+}
+
+// mongoSession is the stored document.
+type mongoSession struct {
+	ID        string    `bson:"_id"`
+	UserID    string    `bson:"user_id,omitempty"`
+	Data      string    `bson:"data"`
+	ExpiresAt time.Time `bson:"expires_at"`
+}
+
+// NewMongoStore creates a MongoDB store using the "sessions" collection.
+func NewMongoStore(db *mongo.Database) *MongoStore {
+	return NewMongoStoreWithConfig(MongoStoreConfig{Database: db})
+}
+
+// NewMongoStoreWithConfig creates a MongoDB store with custom configuration.
+func NewMongoStoreWithConfig(cfg MongoStoreConfig) *MongoStore {
+	if cfg.Collection == "" {
+		cfg.Collection = "sessions"
+	}
+	return &MongoStore{
+		coll: cfg.Database.Collection(cfg.Collection),
+	}
+}
+
+// EnsureSchema creates the TTL index that expires sessions and the index
+// on user_id. Call it from the app's EnsureSchema hook; it is safe to call
+// on every startup.
+func (s *MongoStore) EnsureSchema(ctx context.Context) error {
+	_, err := s.coll.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "expires_at", Value: 1}},
+			Options: options.Index().SetName("expires_at_ttl").SetExpireAfterSeconds(0),
+		},
+		{
+			Keys:    bson.D{{Key: "user_id", Value: 1}},
+			Options: options.Index().SetName("user_id"),
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("session: failed to create session indexes: %w", err)
+	}
+	return nil
+}
+
+// Load retrieves session data by ID.
+func (s *MongoStore) Load(ctx context.Context, id string) (*SessionData, error) {
+	var doc mongoSession
+	err := s.coll.FindOne(ctx, bson.M{"_id": id}).Decode(&doc)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var data SessionData
+	if err := json.Unmarshal([]byte(doc.Data), &data); err != nil {
+		return nil, err
+	}
+
+	if time.Now().After(data.ExpiresAt) {
+		return nil, ErrExpired
+	}
+
+	return &data, nil
+}
+
+// Save stores session data.
+func (s *MongoStore) Save(ctx context.Context, data *SessionData) error {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	doc := mongoSession{
+		ID:        data.ID,
+		UserID:    data.UserID,
+		Data:      string(raw),
+		ExpiresAt: data.ExpiresAt,
+	}
+	_, err = s.coll.ReplaceOne(ctx, bson.M{"_id": data.ID}, doc, options.Replace().SetUpsert(true))
+	return err
+}
+
+// Delete removes a session by ID.
+func (s *MongoStore) Delete(ctx context.Context, id string) error {
+	_, err := s.coll.DeleteOne(ctx, bson.M{"_id": id})
+	return err
+}
+
+// ListByUser returns the user's unexpired sessions.
+func (s *MongoStore) ListByUser(ctx context.Context, userID string) ([]*SessionData, error) {
+	cursor, err := s.coll.Find(ctx, bson.M{
+		"user_id":    userID,
+		"expires_at": bson.M{"$gt": time.Now()},
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var result []*SessionData
+	for cursor.Next(ctx) {
+		var doc mongoSession
+		if err := cursor.Decode(&doc); err != nil {
+			return nil, err
+		}
+		var data SessionData
+		if err := json.Unmarshal([]byte(doc.Data), &data); err != nil {
+			return nil, err
+		}
+		result = append(result, &data)
+	}
+	return result, cursor.Err()
+}
+
+// DeleteByUser removes the user's sessions, except those in keep.
+func (s *MongoStore) DeleteByUser(ctx context.Context, userID string, keep ...string) (int, error) {
+	filter := bson.M{"user_id": userID}
+	if len(keep) > 0 {
+		filter["_id"] = bson.M{"$nin": keep}
+	}
+
+	res, err := s.coll.DeleteMany(ctx, filter)
+	if err != nil {
+		return 0, err
+	}
+	return int(res.DeletedCount), nil
+}
+
+// Close does nothing; the caller owns the MongoDB client.
+func (s *MongoStore) Close() error {
+	return nil
+}
+
+// Collection returns the underlying sessions collection.
+func (s *MongoStore) Collection() *mongo.Collection {
+	return s.coll
+}
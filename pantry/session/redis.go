@@ -5,6 +5,7 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"slices"
 	"time"
 
 	"github.com/redis/go-redis/v9"
@@ -141,14 +142,112 @@ func (s *RedisStore) Save(ctx context.Context, data *SessionData) error {
 		return nil
 	}
 
-	return s.client.Set(ctx, s.key(data.ID), bytes, ttl).Err()
+	if data.UserID == "" {
+		return s.client.Set(ctx, s.key(data.ID), bytes, ttl).Err()
+	}
+
+	// Index the session under its user. The index lives as long as the
+	// user's longest-lived session.
+	userKey := s.userKey(data.UserID)
+	pipe := s.client.Pipeline()
+	pipe.Set(ctx, s.key(data.ID), bytes, ttl)
+	pipe.SAdd(ctx, userKey, data.ID)
+	pttl := pipe.PTTL(ctx, userKey)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return err
+	}
+	if pttl.Val() < ttl {
+		return s.client.PExpire(ctx, userKey, ttl).Err()
+	}
+	return nil
 }
 
-// Delete removes a session by ID.
+// Delete removes a session by ID. Its entry in the user index is removed
+// the next time the user's sessions are listed.
 func (s *RedisStore) Delete(ctx context.Context, id string) error {
 	return s.client.Del(ctx, s.key(id)).Err()
 }
 
+// userKey returns the Redis key of a user's session index.
+func (s *RedisStore) userKey(userID string) string {
+	return s.keyPrefix + "user:" + userID
+}
+
+// ListByUser returns the user's unexpired sessions. Index entries for
+// sessions that were deleted, expired, or reassigned are removed.
+func (s *RedisStore) ListByUser(ctx context.Context, userID string) ([]*SessionData, error) {
+	userKey := s.userKey(userID)
+	ids, err := s.client.SMembers(ctx, userKey).Result()
+	if err != nil {
+		return nil, err
+	}
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	// Pipelined GETs rather than MGET, which fails across cluster slots
+	pipe := s.client.Pipeline()
+	cmds := make([]*redis.StringCmd, len(ids))
+	for i, id := range ids {
+		cmds[i] = pipe.Get(ctx, s.key(id))
+	}
+	if _, err := pipe.Exec(ctx); err != nil && !errors.Is(err, redis.Nil) {
+		return nil, err
+	}
+
+	now := time.Now()
+	var result []*SessionData
+	var stale []any
+	for i, cmd := range cmds {
+		raw, err := cmd.Bytes()
+		if err != nil {
+			if errors.Is(err, redis.Nil) {
+				stale = append(stale, ids[i])
+				continue
+			}
+			return nil, err
+		}
+
+		var data SessionData
+		if err := json.Unmarshal(raw, &data); err != nil || data.UserID != userID || now.After(data.ExpiresAt) {
+			stale = append(stale, ids[i])
+			continue
+		}
+		result = append(result, &data)
+	}
+
+	if len(stale) > 0 {
+		s.client.SRem(ctx, userKey, stale...)
+	}
+	return result, nil
+}
+
+// DeleteByUser removes the user's sessions, except those in keep.
+func (s *RedisStore) DeleteByUser(ctx context.Context, userID string, keep ...string) (int, error) {
+	sessions, err := s.ListByUser(ctx, userID)
+	if err != nil {
+		return 0, err
+	}
+
+	var ids []any
+	pipe := s.client.Pipeline()
+	for _, data := range sessions {
+		if slices.Contains(keep, data.ID) {
+			continue
+		}
+		pipe.Del(ctx, s.key(data.ID))
+		ids = append(ids, data.ID)
+	}
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	pipe.SRem(ctx, s.userKey(userID), ids...)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, err
+	}
+	return len(ids), nil
+}
+
 // Close closes the Redis connection.
 func (s *RedisStore) Close() error {
 	return s.client.Close()
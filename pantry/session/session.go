@@ -8,6 +8,7 @@ import (
 	"encoding/json"
 	"errors"
 	"net/http"
+	"reflect"
 	"sync"
 	"time"
 )
@@ -20,6 +21,14 @@ type Session struct {
 	isNew     bool
 	modified  bool
 	expiresAt time.Time
+	createdAt time.Time
+	userID    string
+	device    *Device
+
+	// privileged holds Config.PrivilegeKeys; changing one of them, or the
+	// user ID, sets regenerate so Save issues a new ID.
+	privileged map[string]bool
+	regenerate bool
 }
 
 // ID returns the session ID.
@@ -27,6 +36,41 @@ func (s *Session) ID() string {
 	return s.id
 }
 
+// UserID returns the ID of the user the session belongs to, or "" if the
+// session is anonymous.
+func (s *Session) UserID() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.userID
+}
+
+// SetUserID associates the session with a user, so it appears in the
+// user's session list and is revoked with the user's other sessions.
+// Call it after login and pass "" on logout. Changing the user ID
+// regenerates the session ID when the session is saved.
+func (s *Session) SetUserID(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if id != s.userID {
+		s.regenerate = true
+	}
+	s.userID = id
+	s.modified = true
+}
+
+// Device returns the device metadata recorded when the session was last
+// saved, or nil for a new session.
+func (s *Session) Device() *Device {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.device
+}
+
+// CreatedAt returns when the session was created.
+func (s *Session) CreatedAt() time.Time {
+	return s.createdAt
+}
+
 // IsNew returns true if the session was just created.
 func (s *Session) IsNew() bool {
 	return s.isNew
@@ -99,6 +143,9 @@ func (s *Session) GetTime(key string) time.Time {
 func (s *Session) Set(key string, value any) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
+	if s.privileged[key] && !reflect.DeepEqual(s.data[key], value) {
+		s.regenerate = true
+	}
 	s.data[key] = value
 	s.modified = true
 }
@@ -107,6 +154,9 @@ func (s *Session) Set(key string, value any) {
 func (s *Session) Delete(key string) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
+	if _, ok := s.data[key]; ok && s.privileged[key] {
+		s.regenerate = true
+	}
 	delete(s.data, key)
 	s.modified = true
 }
@@ -115,6 +165,11 @@ func (s *Session) Delete(key string) {
 func (s *Session) Clear() {
 	s.mu.Lock()
 	defer s.mu.Unlock()
+	for key := range s.privileged {
+		if _, ok := s.data[key]; ok {
+			s.regenerate = true
+		}
+	}
 	s.data = make(map[string]any)
 	s.modified = true
 }
@@ -176,6 +231,8 @@ type SessionData struct {
 	ExpiresAt time.Time      `json:"expires_at"`
 	CreatedAt time.Time      `json:"created_at"`
 	UpdatedAt time.Time      `json:"updated_at"`
+	UserID    string         `json:"user_id,omitempty"`
+	Device    *Device        `json:"device,omitempty"`
 }
 
 // MarshalBinary implements encoding.BinaryMarshaler.
@@ -209,6 +266,7 @@ type Manager struct {
 	store      Store
 	cookieName string
 	config     Config
+	privileged map[string]bool
 }
 
 // Config configures the session manager.
@@ -217,10 +275,45 @@ type Config struct {
 	// Default: "session_id".
 	CookieName string
 
-	// MaxAge is the session lifetime.
+	// MaxAge is the session lifetime. With IdleTimeout set, it is the
+	// absolute lifetime, measured from creation, that activity can't extend.
 	// Default: 24 hours.
 	MaxAge time.Duration
 
+	// IdleTimeout ends sessions that see no activity for this long. Each
+	// request renews the session (a rolling expiry), up to MaxAge.
+	// Default: 0 (sessions last MaxAge regardless of activity).
+	IdleTimeout time.Duration
+
+	// TouchInterval throttles renewal: an unmodified session is written
+	// back at most once per interval, rather than on every request. The
+	// idle deadline is accurate to within TouchInterval.
+	// Default: IdleTimeout / 10.
+	TouchInterval time.Duration
+
+	// Passive reports requests that don't count as activity, such as
+	// polling for session status, so they don't keep idle sessions alive.
+	// Default: nil (every request is activity).
+	Passive func(r *http.Request) bool
+
+	// PrivilegeKeys are session keys, such as "role", whose change
+	// regenerates the session ID when the session is saved, as changing
+	// the user ID with SetUserID does.
+	// Default: none.
+	PrivilegeKeys []string
+
+	// BindUserAgent rejects a session used with a different User-Agent
+	// than it was last saved with.
+	// Default: false.
+	BindUserAgent bool
+
+	// BindIPv4Prefix and BindIPv6Prefix reject a session used from outside
+	// the subnet of the IP it was last saved from, such as 24 and 64. A
+	// session never moves between IPv4 and IPv6 when either is set.
+	// Default: 0 (not bound).
+	BindIPv4Prefix int
+	BindIPv6Prefix int
+
 	// Path is the cookie path.
 	// Default: "/".
 	Path string
@@ -244,6 +337,12 @@ type Config struct {
 	// IDGenerator generates session IDs.
 	// Default: cryptographically secure random ID.
 	IDGenerator func() (string, error)
+
+	// Revokers are called by RevokeOtherSessions and RevokeAllSessions to
+	// revoke the user's credentials outside the session store, such as
+	// OAuth2 sessions and JWT refresh tokens. They revoke every device's
+	// credentials, including the current one's.
+	Revokers []Revoker
 }
 
 // DefaultConfig returns sensible defaults.
@@ -276,16 +375,34 @@ func NewManager(store Store, cfg Config) *Manager {
 	if cfg.IDGenerator == nil {
 		cfg.IDGenerator = generateID
 	}
+	if cfg.IdleTimeout > 0 && cfg.TouchInterval == 0 {
+		cfg.TouchInterval = cfg.IdleTimeout / 10
+	}
+
+	privileged := make(map[string]bool, len(cfg.PrivilegeKeys))
+	for _, key := range cfg.PrivilegeKeys {
+		privileged[key] = true
+	}
 
 	return &Manager{
 		store:      store,
 		cookieName: cfg.CookieName,
 		config:     cfg,
+		privileged: privileged,
 	}
 }
 
 // Get retrieves the session from the request, creating a new one if needed.
 func (m *Manager) Get(r *http.Request) (*Session, error) {
+	if cs, ok := m.store.(ClientStore); ok {
+		if value, _ := readClientCookie(r, m.cookieName, cs.MaxChunks()); value != "" {
+			if data, err := cs.Decode(value); err == nil && m.bound(r, data) {
+				return m.load(r, data), nil
+			}
+		}
+		return m.New()
+	}
+
 	cookie, err := r.Cookie(m.cookieName)
 	if err == nil && cookie.Value != "" {
 		// Try to load existing session
@@ -295,13 +412,8 @@ func (m *Manager) Get(r *http.Request) (*Session, error) {
 			if time.Now().After(data.ExpiresAt) {
 				// Session expired, delete it
 				m.store.Delete(r.Context(), cookie.Value)
-			} else {
-				return &Session{
-					id:        data.ID,
-					data:      data.Data,
-					isNew:     false,
-					expiresAt: data.ExpiresAt,
-				}, nil
+			} else if m.bound(r, data) {
+				return m.load(r, data), nil
 			}
 		}
 	}
@@ -317,44 +429,96 @@ func (m *Manager) New() (*Session, error) {
 		return nil, err
 	}
 
+	now := time.Now()
 	return &Session{
-		id:        id,
-		data:      make(map[string]any),
-		isNew:     true,
-		modified:  true,
-		expiresAt: time.Now().Add(m.config.MaxAge),
+		id:         id,
+		data:       make(map[string]any),
+		isNew:      true,
+		modified:   true,
+		expiresAt:  m.expiry(now, now),
+		createdAt:  now,
+		privileged: m.privileged,
 	}, nil
 }
 
+// load builds a session from stored data. With IdleTimeout set, it renews
+// the session if it hasn't been written for TouchInterval.
+func (m *Manager) load(r *http.Request, data *SessionData) *Session {
+	createdAt := data.CreatedAt
+	if createdAt.IsZero() {
+		createdAt = data.UpdatedAt
+	}
+	session := &Session{
+		id:         data.ID,
+		data:       data.Data,
+		isNew:      false,
+		expiresAt:  data.ExpiresAt,
+		createdAt:  createdAt,
+		userID:     data.UserID,
+		device:     data.Device,
+		privileged: m.privileged,
+	}
+
+	if m.rolling(r) && time.Since(data.UpdatedAt) >= m.config.TouchInterval {
+		session.expiresAt = m.expiry(createdAt, time.Now())
+		session.modified = true
+	}
+	return session
+}
+
 // Save persists the session and sets the cookie.
 func (m *Manager) Save(w http.ResponseWriter, r *http.Request, session *Session) error {
-	session.mu.RLock()
+	device := deviceFromRequest(r)
+	now := time.Now()
+
+	session.mu.Lock()
+	var oldID string
+	if session.regenerate {
+		// A privilege change: move the data to a new ID
+		session.regenerate = false
+		if !session.isNew {
+			newID, err := m.config.IDGenerator()
+			if err != nil {
+				session.mu.Unlock()
+				return err
+			}
+			oldID = session.id
+			session.id = newID
+		}
+	}
+	if m.rolling(r) {
+		session.expiresAt = m.expiry(session.createdAt, now)
+	}
+	session.device = device
 	data := &SessionData{
 		ID:        session.id,
 		Data:      session.data,
 		ExpiresAt: session.expiresAt,
-		UpdatedAt: time.Now(),
+		CreatedAt: session.createdAt,
+		UpdatedAt: now,
+		UserID:    session.userID,
+		Device:    device,
 	}
-	if session.isNew {
-		data.CreatedAt = time.Now()
+	if cs, ok := m.store.(ClientStore); ok {
+		// The data itself goes in the cookie
+		value, err := cs.Encode(data)
+		session.mu.Unlock()
+		if err != nil {
+			return err
+		}
+		return m.writeClientCookie(w, r, cs, value)
 	}
-	session.mu.RUnlock()
+	session.mu.Unlock()
 
 	if err := m.store.Save(r.Context(), data); err != nil {
 		return err
 	}
+	if oldID != "" {
+		m.store.Delete(r.Context(), oldID)
+	}
 
 	// Set cookie
-	http.SetCookie(w, &http.Cookie{
-		Name:     m.cookieName,
-		Value:    session.id,
-		Path:     m.config.Path,
-		Domain:   m.config.Domain,
-		MaxAge:   int(m.config.MaxAge.Seconds()),
-		Secure:   m.config.Secure,
-		HttpOnly: m.config.HttpOnly,
-		SameSite: m.config.SameSite,
-	})
+	http.SetCookie(w, m.cookie(m.cookieName, session.id, int(m.config.MaxAge.Seconds())))
 
 	return nil
 }
@@ -366,18 +530,26 @@ func (m *Manager) Destroy(w http.ResponseWriter, r *http.Request, session *Sessi
 	}
 
 	// Clear cookie
-	http.SetCookie(w, &http.Cookie{
-		Name:     m.cookieName,
-		Value:    "",
+	if cs, ok := m.store.(ClientStore); ok {
+		m.clearClientCookie(w, r, cs)
+	}
+	http.SetCookie(w, m.cookie(m.cookieName, "", -1))
+
+	return nil
+}
+
+// cookie builds a session cookie with the configured attributes.
+func (m *Manager) cookie(name, value string, maxAge int) *http.Cookie {
+	return &http.Cookie{
+		Name:     name,
+		Value:    value,
 		Path:     m.config.Path,
 		Domain:   m.config.Domain,
-		MaxAge:   -1,
+		MaxAge:   maxAge,
 		Secure:   m.config.Secure,
 		HttpOnly: m.config.HttpOnly,
 		SameSite: m.config.SameSite,
-	})
-
-	return nil
+	}
 }
 
 // Regenerate creates a new session ID while preserving data.
@@ -395,6 +567,7 @@ func (m *Manager) Regenerate(w http.ResponseWriter, r *http.Request, session *Se
 	session.mu.Lock()
 	session.id = newID
 	session.modified = true
+	session.regenerate = false
 	session.mu.Unlock()
 
 	// Delete old session from store
@@ -404,10 +577,15 @@ func (m *Manager) Regenerate(w http.ResponseWriter, r *http.Request, session *Se
 	return m.Save(w, r, session)
 }
 
-// Refresh extends the session expiration.
+// Refresh extends the session expiration. With IdleTimeout set, it renews
+// the idle deadline, up to the absolute lifetime.
 func (m *Manager) Refresh(w http.ResponseWriter, r *http.Request, session *Session) error {
 	session.mu.Lock()
-	session.expiresAt = time.Now().Add(m.config.MaxAge)
+	if m.config.IdleTimeout > 0 {
+		session.expiresAt = m.expiry(session.createdAt, time.Now())
+	} else {
+		session.expiresAt = time.Now().Add(m.config.MaxAge)
+	}
 	session.modified = true
 	session.mu.Unlock()
 
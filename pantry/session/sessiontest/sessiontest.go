@@ -0,0 +1,325 @@
+// session/sessiontest/sessiontest.go
+package sessiontest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dalemusser/waffle/pantry/session"
+)
+
+// TestStore runs the session.Store conformance suite. newStore is called
+// for each subtest and must return an empty store; TestStore closes it.
+// Stores that implement session.UserIndex are also checked against it.
+//
+//	func TestMyStore(t *testing.T) {
+//		sessiontest.TestStore(t, func(t *testing.T) session.Store {
+//			return NewMyStore(...)
+//		})
+//	}
+func TestStore(t *testing.T, newStore func(t *testing.T) session.Store) {
+	t.Helper()
+
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, s session.Store)
+	}{
+		{"LoadMissing", testLoadMissing},
+		{"SaveLoad", testSaveLoad},
+		{"Overwrite", testOverwrite},
+		{"Delete", testDelete},
+		{"Expired", testExpired},
+		{"Isolation", testIsolation},
+		{"Concurrent", testConcurrent},
+		{"UserIndex", testUserIndex},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newStore(t)
+			defer s.Close()
+			tt.fn(t, s)
+		})
+	}
+}
+
+// newData returns session data with a unique ID that expires in an hour.
+func newData(name string) *session.SessionData {
+	now := time.Now()
+	return &session.SessionData{
+		ID:        fmt.Sprintf("%s-%d", name, now.UnixNano()),
+		Data:      map[string]any{},
+		ExpiresAt: now.Add(time.Hour),
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+}
+
+func mustSave(t *testing.T, s session.Store, data *session.SessionData) {
+	t.Helper()
+	if err := s.Save(context.Background(), data); err != nil {
+		t.Fatalf("Save(%q) error: %v", data.ID, err)
+	}
+}
+
+func mustLoad(t *testing.T, s session.Store, id string) *session.SessionData {
+	t.Helper()
+	data, err := s.Load(context.Background(), id)
+	if err != nil {
+		t.Fatalf("Load(%q) error: %v", id, err)
+	}
+	return data
+}
+
+func assertGone(t *testing.T, s session.Store, id string) {
+	t.Helper()
+	_, err := s.Load(context.Background(), id)
+	if !errors.Is(err, session.ErrNotFound) && !errors.Is(err, session.ErrExpired) {
+		t.Fatalf("Load(%q) error = %v, want ErrNotFound or ErrExpired", id, err)
+	}
+}
+
+// sameTime compares times to the millisecond, the coarsest precision a
+// store may keep.
+func sameTime(a, b time.Time) bool {
+	d := a.Sub(b)
+	return d > -time.Millisecond && d < time.Millisecond
+}
+
+// number converts a stored number to float64, accepting the types stores
+// return after serialization.
+func number(v any) (float64, bool) {
+	switch n := v.(type) {
+	case int:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case float64:
+		return n, true
+	default:
+		return 0, false
+	}
+}
+
+func testLoadMissing(t *testing.T, s session.Store) {
+	_, err := s.Load(context.Background(), "missing")
+	if !errors.Is(err, session.ErrNotFound) {
+		t.Fatalf("Load(missing) error = %v, want ErrNotFound", err)
+	}
+}
+
+func testSaveLoad(t *testing.T, s session.Store) {
+	in := newData("saveload")
+	in.Data = map[string]any{"name": "alice", "admin": true, "count": 3}
+	in.UserID = "user-1"
+	in.Device = &session.Device{UserAgent: "test-agent", IP: "192.0.2.1"}
+	mustSave(t, s, in)
+
+	out := mustLoad(t, s, in.ID)
+	if out.ID != in.ID {
+		t.Errorf("ID = %q, want %q", out.ID, in.ID)
+	}
+	if out.UserID != in.UserID {
+		t.Errorf("UserID = %q, want %q", out.UserID, in.UserID)
+	}
+	if out.Data["name"] != "alice" {
+		t.Errorf("Data[name] = %v, want alice", out.Data["name"])
+	}
+	if out.Data["admin"] != true {
+		t.Errorf("Data[admin] = %v, want true", out.Data["admin"])
+	}
+	if n, ok := number(out.Data["count"]); !ok || n != 3 {
+		t.Errorf("Data[count] = %v, want 3", out.Data["count"])
+	}
+	if !sameTime(out.ExpiresAt, in.ExpiresAt) {
+		t.Errorf("ExpiresAt = %v, want %v", out.ExpiresAt, in.ExpiresAt)
+	}
+	if !sameTime(out.CreatedAt, in.CreatedAt) {
+		t.Errorf("CreatedAt = %v, want %v", out.CreatedAt, in.CreatedAt)
+	}
+	if !sameTime(out.UpdatedAt, in.UpdatedAt) {
+		t.Errorf("UpdatedAt = %v, want %v", out.UpdatedAt, in.UpdatedAt)
+	}
+	if out.Device == nil || *out.Device != *in.Device {
+		t.Errorf("Device = %+v, want %+v", out.Device, in.Device)
+	}
+}
+
+func testOverwrite(t *testing.T, s session.Store) {
+	data := newData("overwrite")
+	data.Data["step"] = "one"
+	mustSave(t, s, data)
+
+	data.Data = map[string]any{"step": "two"}
+	data.ExpiresAt = data.ExpiresAt.Add(time.Hour)
+	mustSave(t, s, data)
+
+	out := mustLoad(t, s, data.ID)
+	if out.Data["step"] != "two" {
+		t.Errorf("Data[step] = %v, want two", out.Data["step"])
+	}
+	if !sameTime(out.ExpiresAt, data.ExpiresAt) {
+		t.Errorf("ExpiresAt = %v, want %v", out.ExpiresAt, data.ExpiresAt)
+	}
+}
+
+func testDelete(t *testing.T, s session.Store) {
+	data := newData("delete")
+	mustSave(t, s, data)
+
+	if err := s.Delete(context.Background(), data.ID); err != nil {
+		t.Fatalf("Delete error: %v", err)
+	}
+	assertGone(t, s, data.ID)
+
+	if err := s.Delete(context.Background(), "missing"); err != nil {
+		t.Fatalf("Delete(missing) error = %v, want nil", err)
+	}
+}
+
+func testExpired(t *testing.T, s session.Store) {
+	past := newData("past")
+	past.ExpiresAt = time.Now().Add(-time.Minute)
+	mustSave(t, s, past)
+	assertGone(t, s, past.ID)
+
+	soon := newData("soon")
+	soon.ExpiresAt = time.Now().Add(1100 * time.Millisecond)
+	mustSave(t, s, soon)
+	mustLoad(t, s, soon.ID)
+
+	time.Sleep(1500 * time.Millisecond)
+	assertGone(t, s, soon.ID)
+}
+
+func testIsolation(t *testing.T, s session.Store) {
+	data := newData("isolation")
+	data.Data["key"] = "saved"
+	mustSave(t, s, data)
+
+	// Changes to the saved value and to loaded copies aren't stored
+	data.Data["key"] = "changed"
+	out := mustLoad(t, s, data.ID)
+	out.Data["key"] = "changed"
+
+	out = mustLoad(t, s, data.ID)
+	if out.Data["key"] != "saved" {
+		t.Errorf("Data[key] = %v, want saved", out.Data["key"])
+	}
+}
+
+func testConcurrent(t *testing.T, s session.Store) {
+	var wg sync.WaitGroup
+	errs := make(chan error, 20)
+
+	for i := range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			data := newData(fmt.Sprintf("concurrent-%d", i))
+			data.Data["i"] = i
+			if err := s.Save(context.Background(), data); err != nil {
+				errs <- err
+				return
+			}
+			out, err := s.Load(context.Background(), data.ID)
+			if err != nil {
+				errs <- err
+				return
+			}
+			if n, _ := number(out.Data["i"]); n != float64(i) {
+				errs <- fmt.Errorf("session %d loaded i = %v", i, out.Data["i"])
+			}
+		}()
+	}
+
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+}
+
+// userSessions lists a user's session IDs, sorted.
+func userSessions(t *testing.T, idx session.UserIndex, userID string) []string {
+	t.Helper()
+	sessions, err := idx.ListByUser(context.Background(), userID)
+	if err != nil {
+		t.Fatalf("ListByUser(%q) error: %v", userID, err)
+	}
+	ids := make([]string, 0, len(sessions))
+	for _, data := range sessions {
+		if data.UserID != userID {
+			t.Errorf("ListByUser(%q) returned session of user %q", userID, data.UserID)
+		}
+		ids = append(ids, data.ID)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func assertIDs(t *testing.T, got []string, want ...string) {
+	t.Helper()
+	sort.Strings(want)
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Fatalf("sessions = %v, want %v", got, want)
+	}
+}
+
+func testUserIndex(t *testing.T, s session.Store) {
+	idx, ok := s.(session.UserIndex)
+	if !ok {
+		t.Skip("store does not implement session.UserIndex")
+	}
+	ctx := context.Background()
+
+	a1, a2, b1, anon, expired := newData("a1"), newData("a2"), newData("b1"), newData("anon"), newData("expired")
+	a1.UserID, a2.UserID, b1.UserID, expired.UserID = "alice", "alice", "bob", "alice"
+	expired.ExpiresAt = time.Now().Add(-time.Minute)
+	for _, data := range []*session.SessionData{a1, a2, b1, anon, expired} {
+		mustSave(t, s, data)
+	}
+
+	assertIDs(t, userSessions(t, idx, "alice"), a1.ID, a2.ID)
+	assertIDs(t, userSessions(t, idx, "bob"), b1.ID)
+	assertIDs(t, userSessions(t, idx, "nobody"))
+
+	// Moving a session to another user moves it in the index
+	a2.UserID = "bob"
+	mustSave(t, s, a2)
+	assertIDs(t, userSessions(t, idx, "alice"), a1.ID)
+	assertIDs(t, userSessions(t, idx, "bob"), a2.ID, b1.ID)
+
+	// Deleted sessions leave the index
+	if err := s.Delete(ctx, a1.ID); err != nil {
+		t.Fatalf("Delete error: %v", err)
+	}
+	assertIDs(t, userSessions(t, idx, "alice"))
+
+	// DeleteByUser keeps the listed sessions
+	n, err := idx.DeleteByUser(ctx, "bob", b1.ID)
+	if err != nil {
+		t.Fatalf("DeleteByUser error: %v", err)
+	}
+	if n != 1 {
+		t.Errorf("DeleteByUser removed %d sessions, want 1", n)
+	}
+	assertGone(t, s, a2.ID)
+	mustLoad(t, s, b1.ID)
+
+	if _, err := idx.DeleteByUser(ctx, "bob"); err != nil {
+		t.Fatalf("DeleteByUser error: %v", err)
+	}
+	assertGone(t, s, b1.ID)
+	assertIDs(t, userSessions(t, idx, "bob"))
+
+	// Anonymous sessions are untouched
+	mustLoad(t, s, anon.ID)
+}
@@ -0,0 +1,307 @@
+// session/sql.go
+package session
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SQLDialect selects SQL syntax for the SQL store.
+type SQLDialect int
+
+const (
+	// DialectPostgres uses $n placeholders.
+	DialectPostgres SQLDialect = iota
+
+	// DialectSQLite uses ? placeholders.
+	DialectSQLite
+
+	// DialectMySQL uses ? placeholders and MySQL upserts.
+	DialectMySQL
+)
+
+// SQLStore implements session storage in a database/sql table, for apps
+// that already have PostgreSQL, MySQL or SQLite connected.
+//
+// Session data is stored as JSON, with the user ID and expiry in their own
+// columns for the user index and cleanup. Expired rows are ignored on read
+// and deleted by a background cleanup.
+type SQLStore struct {
+	db      *sql.DB
+	dialect SQLDialect
+	table   string
+
+	mu      sync.Mutex
+	closed  bool
+	stopCh  chan struct{}
+	cleanCh chan struct{}
+}
+
+// SQLStoreConfig configures the SQL store.
+type SQLStoreConfig struct {
+	// DB is the database handle. The store doesn't close it.
+	DB *sql.DB
+
+	// Dialect selects PostgreSQL, MySQL or SQLite syntax.
+	// Default: DialectPostgres.
+	Dialect SQLDialect
+
+	// Table is the sessions table name.
+	// Default: "sessions".
+	Table string
+
+	// CleanupInterval is how often to delete expired sessions.
+	// Default: 10 minutes. Set to a negative value to disable background
+	// cleanup (call DeleteExpired yourself, e.g. from a scheduled job).
+	CleanupInterval time.Duration
+}
+
+// NewSQLStore creates a SQL session store. Call CreateTable once (or apply
+// Schema as a migration) before use.
+func NewSQLStore(cfg SQLStoreConfig) *SQLStore {
+	if cfg.Table == "" {
+		cfg.Table = "sessions"
+	}
+	if cfg.CleanupInterval == 0 {
+		cfg.CleanupInterval = 10 * time.Minute
+	}
+
+	s := &SQLStore{
+		db:      cfg.DB,
+		dialect: cfg.Dialect,
+		table:   cfg.Table,
+		stopCh:  make(chan struct{}),
+		cleanCh: make(chan struct{}),
+	}
+
+	if cfg.CleanupInterval > 0 {
+		go s.cleanup(cfg.CleanupInterval)
+	} else {
+		close(s.cleanCh)
+	}
+
+	return s
+}
+
+// NewSQLiteStore creates a SQL session store using SQLite.
+func NewSQLiteStore(db *sql.DB) *SQLStore {
+	return NewSQLStore(SQLStoreConfig{DB: db, Dialect: DialectSQLite})
+}
+
+// NewPostgresStore creates a SQL session store using PostgreSQL.
+func NewPostgresStore(db *sql.DB) *SQLStore {
+	return NewSQLStore(SQLStoreConfig{DB: db, Dialect: DialectPostgres})
+}
+
+// NewMySQLStore creates a SQL session store using MySQL.
+func NewMySQLStore(db *sql.DB) *SQLStore {
+	return NewSQLStore(SQLStoreConfig{DB: db, Dialect: DialectMySQL})
+}
+
+// Schema returns the statements that create the sessions table and its
+// indexes, for use in migrations.
+func (s *SQLStore) Schema() []string {
+	dataType := "TEXT"
+	if s.dialect == DialectMySQL {
+		dataType = "MEDIUMTEXT"
+	}
+	columns := `
+			id         VARCHAR(255) PRIMARY KEY,
+			user_id    VARCHAR(255),
+			data       ` + dataType + ` NOT NULL,
+			expires_at BIGINT NOT NULL`
+
+	// MySQL has no CREATE INDEX IF NOT EXISTS, so its indexes are declared
+	// with the table.
+	if s.dialect == DialectMySQL {
+		return []string{
+			`CREATE TABLE IF NOT EXISTS ` + s.table + ` (` + columns + `,
+			INDEX ` + s.table + `_user_idx (user_id),
+			INDEX ` + s.table + `_expires_idx (expires_at)
+		)`,
+		}
+	}
+	return []string{
+		`CREATE TABLE IF NOT EXISTS ` + s.table + ` (` + columns + `
+		)`,
+		`CREATE INDEX IF NOT EXISTS ` + s.table + `_user_idx ON ` + s.table + ` (user_id)`,
+		`CREATE INDEX IF NOT EXISTS ` + s.table + `_expires_idx ON ` + s.table + ` (expires_at)`,
+	}
+}
+
+// CreateTable creates the sessions table if it doesn't exist.
+func (s *SQLStore) CreateTable(ctx context.Context) error {
+	for _, q := range s.Schema() {
+		if _, err := s.db.ExecContext(ctx, q); err != nil {
+			return fmt.Errorf("session: failed to create sessions table: %w", err)
+		}
+	}
+	return nil
+}
+
+// rebind converts ? placeholders to $n for PostgreSQL.
+func (s *SQLStore) rebind(q string) string {
+	if s.dialect != DialectPostgres {
+		return q
+	}
+	var b strings.Builder
+	n := 0
+	for _, r := range q {
+		if r == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// nullString stores an empty user ID as NULL.
+func nullString(v string) sql.NullString {
+	return sql.NullString{String: v, Valid: v != ""}
+}
+
+// Load retrieves session data by ID.
+func (s *SQLStore) Load(ctx context.Context, id string) (*SessionData, error) {
+	var raw string
+	err := s.db.QueryRowContext(ctx,
+		s.rebind(`SELECT data FROM `+s.table+` WHERE id = ?`), id,
+	).Scan(&raw)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var data SessionData
+	if err := json.Unmarshal([]byte(raw), &data); err != nil {
+		return nil, err
+	}
+
+	if time.Now().After(data.ExpiresAt) {
+		return nil, ErrExpired
+	}
+
+	return &data, nil
+}
+
+// Save stores session data.
+func (s *SQLStore) Save(ctx context.Context, data *SessionData) error {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	q := `INSERT INTO ` + s.table + ` (id, user_id, data, expires_at) VALUES (?, ?, ?, ?)`
+	if s.dialect == DialectMySQL {
+		q += ` ON DUPLICATE KEY UPDATE user_id = VALUES(user_id), data = VALUES(data), expires_at = VALUES(expires_at)`
+	} else {
+		q += ` ON CONFLICT (id) DO UPDATE SET user_id = excluded.user_id, data = excluded.data, expires_at = excluded.expires_at`
+	}
+
+	_, err = s.db.ExecContext(ctx, s.rebind(q),
+		data.ID, nullString(data.UserID), string(raw), data.ExpiresAt.UnixMilli())
+	return err
+}
+
+// Delete removes a session by ID.
+func (s *SQLStore) Delete(ctx context.Context, id string) error {
+	_, err := s.db.ExecContext(ctx, s.rebind(`DELETE FROM `+s.table+` WHERE id = ?`), id)
+	return err
+}
+
+// ListByUser returns the user's unexpired sessions.
+func (s *SQLStore) ListByUser(ctx context.Context, userID string) ([]*SessionData, error) {
+	rows, err := s.db.QueryContext(ctx,
+		s.rebind(`SELECT data FROM `+s.table+` WHERE user_id = ? AND expires_at > ?`),
+		userID, time.Now().UnixMilli())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []*SessionData
+	for rows.Next() {
+		var raw string
+		if err := rows.Scan(&raw); err != nil {
+			return nil, err
+		}
+		var data SessionData
+		if err := json.Unmarshal([]byte(raw), &data); err != nil {
+			return nil, err
+		}
+		result = append(result, &data)
+	}
+	return result, rows.Err()
+}
+
+// DeleteByUser removes the user's sessions, except those in keep.
+func (s *SQLStore) DeleteByUser(ctx context.Context, userID string, keep ...string) (int, error) {
+	q := `DELETE FROM ` + s.table + ` WHERE user_id = ?`
+	args := []any{userID}
+	if len(keep) > 0 {
+		q += ` AND id NOT IN (` + strings.TrimSuffix(strings.Repeat("?, ", len(keep)), ", ") + `)`
+		for _, id := range keep {
+			args = append(args, id)
+		}
+	}
+
+	res, err := s.db.ExecContext(ctx, s.rebind(q), args...)
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	return int(n), err
+}
+
+// DeleteExpired removes expired sessions and returns how many were
+// deleted.
+func (s *SQLStore) DeleteExpired(ctx context.Context) (int64, error) {
+	res, err := s.db.ExecContext(ctx,
+		s.rebind(`DELETE FROM `+s.table+` WHERE expires_at <= ?`), time.Now().UnixMilli())
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// Close stops the cleanup goroutine. It doesn't close the database.
+func (s *SQLStore) Close() error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil
+	}
+	s.closed = true
+	s.mu.Unlock()
+
+	close(s.stopCh)
+	<-s.cleanCh
+	return nil
+}
+
+// cleanup periodically removes expired sessions.
+func (s *SQLStore) cleanup(interval time.Duration) {
+	defer close(s.cleanCh)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stopCh:
+			return
+		case <-ticker.C:
+			s.DeleteExpired(context.Background())
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package session_test
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/dalemusser/waffle/pantry/session"
+	"github.com/dalemusser/waffle/pantry/session/sessiontest"
+	_ "github.com/mattn/go-sqlite3"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func TestMemoryStore(t *testing.T) {
+	sessiontest.TestStore(t, func(t *testing.T) session.Store {
+		return session.NewMemoryStore()
+	})
+}
+
+func TestSQLStore_SQLite(t *testing.T) {
+	sessiontest.TestStore(t, func(t *testing.T) session.Store {
+		db, err := sql.Open("sqlite3", "file:"+t.TempDir()+"/sessions.db?_busy_timeout=5000")
+		if err != nil {
+			t.Fatal(err)
+		}
+		db.SetMaxOpenConns(1)
+		t.Cleanup(func() { db.Close() })
+
+		store := session.NewSQLiteStore(db)
+		if err := store.CreateTable(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		return store
+	})
+}
+
+// TestRedisStore runs against the server at REDIS_ADDR, if set. Each
+// subtest uses its own key prefix.
+func TestRedisStore(t *testing.T) {
+	addr := os.Getenv("REDIS_ADDR")
+	if addr == "" {
+		t.Skip("REDIS_ADDR not set")
+	}
+
+	sessiontest.TestStore(t, func(t *testing.T) session.Store {
+		store, err := session.NewRedisStoreWithConfig(session.RedisStoreConfig{
+			Address:   addr,
+			KeyPrefix: fmt.Sprintf("sessiontest:%d:", time.Now().UnixNano()),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return store
+	})
+}
+
+// TestMongoStore runs against the server at MONGO_URI, if set. Each
+// subtest uses its own collection.
+func TestMongoStore(t *testing.T) {
+	uri := os.Getenv("MONGO_URI")
+	if uri == "" {
+		t.Skip("MONGO_URI not set")
+	}
+
+	ctx := context.Background()
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Disconnect(ctx)
+	db := client.Database("sessiontest")
+
+	sessiontest.TestStore(t, func(t *testing.T) session.Store {
+		store := session.NewMongoStoreWithConfig(session.MongoStoreConfig{
+			Database:   db,
+			Collection: fmt.Sprintf("sessions_%d", time.Now().UnixNano()),
+		})
+		if err := store.EnsureSchema(ctx); err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { store.Collection().Drop(ctx) })
+		return store
+	})
+}
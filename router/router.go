@@ -6,6 +6,7 @@ import (
 	"github.com/dalemusser/waffle/logging"
 	"github.com/dalemusser/waffle/metrics"
 	"github.com/dalemusser/waffle/middleware"
+	"github.com/dalemusser/waffle/server"
 	"github.com/go-chi/chi/v5"
 	chimw "github.com/go-chi/chi/v5/middleware"
 	"go.uber.org/zap"
@@ -15,6 +16,7 @@ import (
 // - RequestID
 // - RealIP
 // - Recoverer (panic → 500)
+// - connection draining (Connection: close during shutdown)
 // - Compression (if EnableCompression is true)
 // - body size limit (MaxRequestBodyBytes)
 // - metrics HTTP middleware
@@ -29,6 +31,9 @@ func New(coreCfg *config.CoreConfig, logger *zap.Logger) chi.Router {
 	r.Use(chimw.RealIP)
 	r.Use(logging.Recoverer(logger))
 
+	// Ask clients to reconnect elsewhere once shutdown draining begins
+	r.Use(server.DrainMiddleware)
+
 	// Compression (config-driven, early in stack to compress all responses)
 	// Pass nil for logger since config validation already catches invalid levels;
 	// the runtime clamping is defense in depth and unlikely to trigger.
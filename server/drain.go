@@ -0,0 +1,342 @@
+// server/drain.go
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/dalemusser/waffle/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+)
+
+// ErrDraining is returned by the readiness check once shutdown has begun.
+var ErrDraining = errors.New("server is draining")
+
+// DrainPhase describes where the server is in its shutdown sequence.
+type DrainPhase int32
+
+const (
+	// PhaseServing is normal operation: ready and accepting connections.
+	PhaseServing DrainPhase = iota
+
+	// PhaseDraining means a shutdown signal was received. Readiness fails,
+	// keep-alives are disabled, and the server keeps accepting connections
+	// for the pre-stop delay so load balancers can deregister it.
+	PhaseDraining
+
+	// PhaseShuttingDown means the listener is closed and in-flight requests
+	// and hijacked connections are being given time to finish.
+	PhaseShuttingDown
+
+	// PhaseStopped means the server has fully stopped.
+	PhaseStopped
+)
+
+// String returns the phase name used in logs and metric labels.
+func (p DrainPhase) String() string {
+	switch p {
+	case PhaseServing:
+		return "serving"
+	case PhaseDraining:
+		return "draining"
+	case PhaseShuttingDown:
+		return "shutting_down"
+	case PhaseStopped:
+		return "stopped"
+	default:
+		return "unknown"
+	}
+}
+
+var (
+	drainPhaseGauge = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "http_server_drain_phase",
+		Help: "Current shutdown phase (0=serving, 1=draining, 2=shutting_down, 3=stopped).",
+	})
+	drainPhaseDuration = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "http_server_drain_phase_duration_seconds",
+		Help: "Time spent in each shutdown phase during the last shutdown.",
+	}, []string{"phase"})
+	hijackedConnsGauge = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "http_server_hijacked_connections",
+		Help: "Number of open hijacked connections (WebSocket, etc.).",
+	})
+	drainForcedCloses = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "http_server_drain_forced_closes_total",
+		Help: "Hijacked connections force-closed because they outlived the shutdown timeout.",
+	})
+)
+
+// registerDrainMetrics registers the drain collectors with the default registry.
+func registerDrainMetrics(logger *zap.Logger) {
+	metrics.MustRegister(logger, "drain phase gauge", drainPhaseGauge)
+	metrics.MustRegister(logger, "drain phase duration gauge", drainPhaseDuration)
+	metrics.MustRegister(logger, "hijacked connections gauge", hijackedConnsGauge)
+	metrics.MustRegister(logger, "drain forced closes counter", drainForcedCloses)
+}
+
+// Drainer coordinates connection draining during shutdown. It drives the
+// readiness check, marks responses with "Connection: close" while draining,
+// tracks hijacked connections, and runs OnDrain hooks so long-lived
+// connections (WebSocket hubs, SSE brokers) can be closed politely.
+//
+// ListenAndServeWithContext uses DefaultDrainer. Applications typically only
+// wire its readiness check and register hooks:
+//
+//	health.MountAt(r, "/readyz", map[string]health.Check{
+//	    "drain": server.DefaultDrainer.ReadinessCheck(),
+//	}, logger)
+//	server.DefaultDrainer.OnDrain(hub.Close)
+type Drainer struct {
+	phase atomic.Int32
+
+	mu       sync.Mutex
+	done     chan struct{}
+	hooks    []func()
+	conns    map[*drainConn]struct{}
+	hijacked int
+}
+
+// DefaultDrainer is the Drainer used by ListenAndServeWithContext.
+var DefaultDrainer = NewDrainer()
+
+// NewDrainer creates a Drainer in the serving phase.
+func NewDrainer() *Drainer {
+	return &Drainer{
+		done:  make(chan struct{}),
+		conns: make(map[*drainConn]struct{}),
+	}
+}
+
+// Phase returns the current drain phase.
+func (d *Drainer) Phase() DrainPhase {
+	return DrainPhase(d.phase.Load())
+}
+
+// Draining reports whether shutdown has begun (any phase after serving).
+func (d *Drainer) Draining() bool {
+	return d.Phase() != PhaseServing
+}
+
+// Done returns a channel that is closed when draining begins. Handlers that
+// hold streams open can select on it to finish early.
+func (d *Drainer) Done() <-chan struct{} {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.done
+}
+
+// OnDrain registers fn to run when the listener closes, before waiting for
+// in-flight requests. Use it to close WebSocket hubs or SSE brokers so their
+// clients receive a proper close instead of a dropped connection.
+func (d *Drainer) OnDrain(fn func()) {
+	if fn == nil {
+		return
+	}
+	d.mu.Lock()
+	d.hooks = append(d.hooks, fn)
+	d.mu.Unlock()
+}
+
+// ReadinessCheck returns a health check that fails with ErrDraining once
+// shutdown has begun. It is compatible with health.Check.
+func (d *Drainer) ReadinessCheck() func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		if d.Draining() {
+			return ErrDraining
+		}
+		return nil
+	}
+}
+
+// Middleware sets "Connection: close" on responses while draining so
+// clients reopen connections elsewhere. On HTTP/2 the same header makes the
+// server send GOAWAY for the connection.
+func (d *Drainer) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if d.Draining() {
+			w.Header().Set("Connection", "close")
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// DrainMiddleware is Middleware on DefaultDrainer.
+func DrainMiddleware(next http.Handler) http.Handler {
+	return DefaultDrainer.Middleware(next)
+}
+
+// HijackedConns returns the number of open hijacked connections.
+func (d *Drainer) HijackedConns() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.hijacked
+}
+
+// reset returns the drainer to the serving phase for a new server run.
+// Registered hooks are kept.
+func (d *Drainer) reset() {
+	d.mu.Lock()
+	select {
+	case <-d.done:
+		d.done = make(chan struct{})
+	default:
+	}
+	d.mu.Unlock()
+	d.setPhase(PhaseServing)
+}
+
+// setPhase records a phase transition.
+func (d *Drainer) setPhase(p DrainPhase) {
+	d.phase.Store(int32(p))
+	drainPhaseGauge.Set(float64(p))
+	if p == PhaseDraining {
+		d.mu.Lock()
+		select {
+		case <-d.done:
+		default:
+			close(d.done)
+		}
+		d.mu.Unlock()
+	}
+}
+
+// runHooks runs the registered OnDrain hooks, recovering from panics so a
+// faulty hook cannot abort shutdown.
+func (d *Drainer) runHooks(logger *zap.Logger) {
+	d.mu.Lock()
+	hooks := append([]func(){}, d.hooks...)
+	d.mu.Unlock()
+
+	for _, fn := range hooks {
+		func() {
+			defer func() {
+				if rec := recover(); rec != nil {
+					logger.Error("drain hook panicked", zap.Any("panic", rec))
+				}
+			}()
+			fn()
+		}()
+	}
+}
+
+// wrapListener returns a listener whose connections are tracked so that
+// hijacked connections can be waited on and closed during shutdown.
+func (d *Drainer) wrapListener(ln net.Listener) net.Listener {
+	return &drainListener{Listener: ln, d: d}
+}
+
+// connState is installed as http.Server.ConnState to notice hijacks.
+func (d *Drainer) connState(c net.Conn, state http.ConnState) {
+	if state != http.StateHijacked {
+		return
+	}
+	// Under TLS the server sees a *tls.Conn wrapping our tracked conn.
+	if tc, ok := c.(*tls.Conn); ok {
+		c = tc.NetConn()
+	}
+	dc, ok := c.(*drainConn)
+	if !ok {
+		return
+	}
+	d.mu.Lock()
+	if !dc.hijacked && !dc.closed {
+		dc.hijacked = true
+		d.conns[dc] = struct{}{}
+		d.hijacked++
+		hijackedConnsGauge.Set(float64(d.hijacked))
+	}
+	d.mu.Unlock()
+}
+
+// forget removes a closed connection from tracking.
+func (d *Drainer) forget(dc *drainConn) {
+	d.mu.Lock()
+	dc.closed = true
+	if _, ok := d.conns[dc]; ok {
+		delete(d.conns, dc)
+		d.hijacked--
+		hijackedConnsGauge.Set(float64(d.hijacked))
+	}
+	d.mu.Unlock()
+}
+
+// waitHijacked waits until all hijacked connections have closed or ctx is
+// done, then force-closes any that remain. It returns the number of
+// connections that had to be force-closed.
+func (d *Drainer) waitHijacked(ctx context.Context) int {
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		if d.HijackedConns() == 0 {
+			return 0
+		}
+		select {
+		case <-ctx.Done():
+			d.mu.Lock()
+			remaining := make([]*drainConn, 0, len(d.conns))
+			for dc := range d.conns {
+				remaining = append(remaining, dc)
+			}
+			d.mu.Unlock()
+			for _, dc := range remaining {
+				_ = dc.Close()
+			}
+			drainForcedCloses.Add(float64(len(remaining)))
+			return len(remaining)
+		case <-ticker.C:
+		}
+	}
+}
+
+// drainListener wraps accepted connections in drainConn.
+type drainListener struct {
+	net.Listener
+	d *Drainer
+}
+
+func (l *drainListener) Accept() (net.Conn, error) {
+	c, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return &drainConn{Conn: c, d: l.d}, nil
+}
+
+// drainConn is a tracked connection. Its hijacked/closed fields are
+// guarded by the owning Drainer's mutex.
+type drainConn struct {
+	net.Conn
+	d        *Drainer
+	once     sync.Once
+	hijacked bool
+	closed   bool
+}
+
+func (c *drainConn) Close() error {
+	err := c.Conn.Close()
+	c.once.Do(func() { c.d.forget(c) })
+	return err
+}
+
+// NetConn returns the underlying connection, like tls.Conn.NetConn, for
+// code that needs it (e.g. to set SO_LINGER).
+func (c *drainConn) NetConn() net.Conn {
+	return c.Conn
+}
+
+// ReadFrom preserves the sendfile fast path of the underlying connection.
+func (c *drainConn) ReadFrom(r io.Reader) (int64, error) {
+	if rf, ok := c.Conn.(io.ReaderFrom); ok {
+		return rf.ReadFrom(r)
+	}
+	return io.Copy(c.Conn, r)
+}
@@ -61,6 +61,12 @@ func WithShutdownSignals(parent context.Context, logger *zap.Logger) (context.Co
 //
 // It does NOT wire any routes itself; callers must provide a fully
 // configured http.Handler (e.g., chi.Router).
+//
+// On cancellation it drains connections using DefaultDrainer: readiness
+// fails and keep-alives are disabled for cfg.HTTP.PreStopDelay while the
+// listener stays open, then the listener is closed, OnDrain hooks run, and
+// in-flight requests and hijacked connections get cfg.HTTP.ShutdownTimeout
+// to finish.
 func ListenAndServeWithContext(
 	ctx context.Context,
 	cfg *config.CoreConfig,
@@ -93,6 +99,12 @@ func ListenAndServeWithContext(
 		logger.Warn("failed to attach stdlib error logger", zap.Error(err))
 	}
 
+	// Track connections so shutdown can drain them (see drain.go).
+	drainer := DefaultDrainer
+	drainer.reset()
+	registerDrainMetrics(logger)
+	srv.ConnState = drainer.connState
+
 	httpAddr := ":" + strconv.Itoa(cfg.HTTP.HTTPPort)
 	httpsAddr := ":" + strconv.Itoa(cfg.HTTP.HTTPSPort)
 
@@ -121,6 +133,7 @@ func ListenAndServeWithContext(
 		if err != nil {
 			return fmt.Errorf("listen http %s: %w", httpAddr, err)
 		}
+		baseLn = drainer.wrapListener(baseLn)
 		ln = baseLn // No TLS wrapping in HTTP-only mode
 		logger.Info("HTTP server listening", zap.String("addr", ln.Addr().String()))
 		go servePrimary(srv, ln, serveErr)
@@ -235,6 +248,7 @@ func ListenAndServeWithContext(
 			_ = shutdownAux(auxSrv, context.Background())
 			return fmt.Errorf("listen https %s: %w", httpsAddr, listenErr)
 		}
+		baseLn = drainer.wrapListener(baseLn)
 		ln = tls.NewListener(baseLn, tlsCfg)
 		logger.Info("HTTPS server (Let's Encrypt "+challenge+") listening",
 			zap.String("addr", httpsAddr),
@@ -297,6 +311,7 @@ func ListenAndServeWithContext(
 			_ = shutdownAux(auxSrv, context.Background())
 			return fmt.Errorf("listen https %s: %w", httpsAddr, listenErr)
 		}
+		baseLn = drainer.wrapListener(baseLn)
 		ln = tls.NewListener(baseLn, tlsCfg)
 		logger.Info("HTTPS server (manual TLS) listening",
 			zap.String("addr", httpsAddr),
@@ -312,23 +327,9 @@ func ListenAndServeWithContext(
 		select {
 		case <-ctx.Done():
 			// Graceful shutdown path requested by caller.
-			logger.Info("shutting down server…")
-			// Create shutdown context with configured timeout.
-			// Use context.Background() as parent since ctx is already cancelled.
-			// The shutdown timeout is intentionally independent of ctx's deadline
-			// to ensure we have a consistent window for graceful shutdown regardless
-			// of when cancellation occurred. Callers control total operation time
-			// by when they cancel ctx, and ShutdownTimeout controls cleanup time.
-			shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.HTTP.ShutdownTimeout)
-			defer cancel()
-			_ = shutdownAux(auxSrv, shutdownCtx)
-			if err := srv.Shutdown(shutdownCtx); err != nil {
-				cleanupListener()
-				return fmt.Errorf("server shutdown: %w", err)
-			}
+			err := drainAndShutdown(drainer, srv, auxSrv, cfg, logger)
 			cleanupListener()
-			logger.Info("server stopped gracefully")
-			return nil
+			return err
 
 		case err := <-serveErr:
 			// Primary server crashed or closed unexpectedly.
@@ -364,6 +365,63 @@ func ListenAndServeWithContext(
 	}
 }
 
+// drainAndShutdown runs the shutdown sequence:
+//
+//  1. draining: readiness fails and keep-alives are disabled, but the
+//     listener keeps accepting for cfg.HTTP.PreStopDelay so load balancers
+//     can stop routing traffic here before connections are refused.
+//  2. shutting_down: the listeners close, OnDrain hooks run, and in-flight
+//     requests and hijacked connections get cfg.HTTP.ShutdownTimeout to
+//     finish. Hijacked connections still open at the deadline are closed.
+//  3. stopped.
+func drainAndShutdown(d *Drainer, srv, auxSrv *http.Server, cfg *config.CoreConfig, logger *zap.Logger) error {
+	// 1) Draining
+	start := time.Now()
+	d.setPhase(PhaseDraining)
+	srv.SetKeepAlivesEnabled(false)
+	logger.Info("draining server",
+		zap.String("phase", PhaseDraining.String()),
+		zap.Duration("pre_stop_delay", cfg.HTTP.PreStopDelay))
+	if cfg.HTTP.PreStopDelay > 0 {
+		time.Sleep(cfg.HTTP.PreStopDelay)
+	}
+	drainPhaseDuration.WithLabelValues(PhaseDraining.String()).Set(time.Since(start).Seconds())
+
+	// 2) Shutting down
+	// Use context.Background() as parent since ctx is already cancelled.
+	// The shutdown timeout is intentionally independent of ctx's deadline
+	// to ensure we have a consistent window for graceful shutdown regardless
+	// of when cancellation occurred. Callers control total operation time
+	// by when they cancel ctx, and ShutdownTimeout controls cleanup time.
+	start = time.Now()
+	d.setPhase(PhaseShuttingDown)
+	logger.Info("shutting down server…",
+		zap.String("phase", PhaseShuttingDown.String()),
+		zap.Int("hijacked_connections", d.HijackedConns()))
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.HTTP.ShutdownTimeout)
+	defer cancel()
+
+	shutdownErr := make(chan error, 1)
+	go func() {
+		_ = shutdownAux(auxSrv, shutdownCtx)
+		shutdownErr <- srv.Shutdown(shutdownCtx)
+	}()
+	d.runHooks(logger)
+	if forced := d.waitHijacked(shutdownCtx); forced > 0 {
+		logger.Warn("force-closed hijacked connections after shutdown timeout", zap.Int("count", forced))
+	}
+	err := <-shutdownErr
+	drainPhaseDuration.WithLabelValues(PhaseShuttingDown.String()).Set(time.Since(start).Seconds())
+
+	// 3) Stopped
+	d.setPhase(PhaseStopped)
+	if err != nil {
+		return fmt.Errorf("server shutdown: %w", err)
+	}
+	logger.Info("server stopped gracefully", zap.String("phase", PhaseStopped.String()))
+	return nil
+}
+
 // servePrimary runs srv.Serve on the provided listener and reports terminal errors.
 func servePrimary(srv *http.Server, ln net.Listener, ch chan<- error) {
 	if err := srv.Serve(ln); err != nil && err != http.ErrServerClosed {